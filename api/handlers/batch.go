@@ -0,0 +1,97 @@
+package handlers
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/feichai0017/document-processor/pkg/logger"
+    "github.com/feichai0017/document-processor/pkg/queue"
+)
+
+// GetBatch 返回批量任务的整体状态、进度和每个文件的结果
+func (h *DocumentHandler) GetBatch(c *gin.Context) {
+    batchID := c.Param("batchId")
+    if batchID == "" {
+        h.handleError(c, http.StatusBadRequest, "Batch ID is required", nil)
+        return
+    }
+
+    batch, err := h.service.GetBatch(c.Request.Context(), batchID)
+    if err != nil {
+        h.handleBatchError(c, "Failed to get batch", err)
+        return
+    }
+
+    c.JSON(http.StatusOK, batch)
+}
+
+// DownloadBatch 将处理成功的结果打包为 zip 下载，所有文件结束后才能下载
+func (h *DocumentHandler) DownloadBatch(c *gin.Context) {
+    batchID := c.Param("batchId")
+    if batchID == "" {
+        h.handleError(c, http.StatusBadRequest, "Batch ID is required", nil)
+        return
+    }
+
+    batch, err := h.service.GetBatch(c.Request.Context(), batchID)
+    if err != nil {
+        h.handleBatchError(c, "Failed to get batch", err)
+        return
+    }
+    if !batch.Finished() {
+        h.handleError(c, http.StatusConflict, "Batch is still processing",
+            fmt.Errorf("batch %s is %s", batchID, batch.Status))
+        return
+    }
+
+    filename := fmt.Sprintf("batch_%s.zip", batchID)
+    c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+    c.Header("Content-Type", "application/zip")
+    c.Status(http.StatusOK)
+    // 响应已经开始，出错时只能中断连接
+    if err := h.service.WriteBatchResults(c.Request.Context(), batch, c.Writer); err != nil {
+        h.logger.Error("Failed to write batch results",
+            logger.String("batchId", batchID),
+            logger.Error(err),
+        )
+        c.Abort()
+    }
+}
+
+// StreamBatch 推送批量任务中每个任务的状态，所有任务结束后关闭连接
+func (h *DocumentHandler) StreamBatch(c *gin.Context) {
+    batchID := c.Param("batchId")
+    if batchID == "" {
+        h.handleError(c, http.StatusBadRequest, "Batch ID is required", nil)
+        return
+    }
+
+    batch, err := h.service.GetBatch(c.Request.Context(), batchID)
+    if err != nil {
+        h.handleBatchError(c, "Failed to get batch", err)
+        return
+    }
+
+    var taskIDs []string
+    for _, item := range batch.Items {
+        if item.TaskID != "" {
+            taskIDs = append(taskIDs, item.TaskID)
+        }
+    }
+    if len(taskIDs) == 0 {
+        h.handleError(c, http.StatusConflict, "Batch has no tasks", nil)
+        return
+    }
+    h.streamStatus(c, taskIDs)
+}
+
+// handleBatchError 批量任务不存在或已过期返回 404
+func (h *DocumentHandler) handleBatchError(c *gin.Context, message string, err error) {
+    if errors.Is(err, queue.ErrBatchNotFound) {
+        h.handleError(c, http.StatusNotFound, message, err)
+        return
+    }
+    h.handleError(c, http.StatusInternalServerError, message, err)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/feichai0017/document-processor/internal/models"
+)
+
+// DeadTaskResponse 死信队列中的任务
+type DeadTaskResponse struct {
+    TaskID       string `json:"taskId"`
+    Queue        string `json:"queue"`
+    Filename     string `json:"filename,omitempty"`
+    LastError    string `json:"lastError"`
+    ErrorClass   string `json:"errorClass,omitempty"`
+    Retried      int    `json:"retried"`
+    MaxRetry     int    `json:"maxRetry"`
+    LastFailedAt string `json:"lastFailedAt"`
+    Cancelled    bool   `json:"cancelled"`
+}
+
+// RequeueRequest 重新入队请求，taskIds 为空时必须设置 all
+type RequeueRequest struct {
+    TaskIDs []string                  `json:"taskIds"`
+    All     bool                      `json:"all"`
+    Options *models.ProcessingOptions `json:"options"` // 替换原有的处理选项，为空时保持不变
+}
+
+// PurgeRequest 清理请求，taskIds 为空时必须设置 all
+type PurgeRequest struct {
+    TaskIDs []string `json:"taskIds"`
+    All     bool     `json:"all"`
+}
+
+// ListFailedTasks 分页列出重试耗尽的任务，包括最后的错误和重试次数
+func (h *DocumentHandler) ListFailedTasks(c *gin.Context) {
+    page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+    if err != nil || page < 1 {
+        h.handleError(c, http.StatusBadRequest, "Invalid page", err)
+        return
+    }
+    size, err := strconv.Atoi(c.DefaultQuery("size", "20"))
+    if err != nil || size < 1 || size > 100 {
+        h.handleError(c, http.StatusBadRequest, "Invalid size, must be between 1 and 100", err)
+        return
+    }
+
+    tasks, total, err := h.service.ListFailedTasks(c.Request.Context(), page, size)
+    if err != nil {
+        h.handleError(c, http.StatusInternalServerError, "Failed to list failed tasks", err)
+        return
+    }
+
+    items := make([]DeadTaskResponse, 0, len(tasks))
+    for _, task := range tasks {
+        item := DeadTaskResponse{
+            TaskID:       task.TaskID,
+            Queue:        task.Queue,
+            LastError:    task.LastError,
+            ErrorClass:   task.ErrorClass,
+            Retried:      task.Retried,
+            MaxRetry:     task.MaxRetry,
+            LastFailedAt: task.LastFailedAt.Format("2006-01-02T15:04:05Z07:00"),
+            Cancelled:    task.Cancelled,
+        }
+        if task.Task != nil {
+            item.Filename, _ = task.Task.Payload["filename"].(string)
+        }
+        items = append(items, item)
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "tasks": items,
+        "total": total,
+        "page":  page,
+        "size":  size,
+    })
+}
+
+// RequeueFailedTasks 将一个或多个失败任务重新入队，可选地修改处理选项
+func (h *DocumentHandler) RequeueFailedTasks(c *gin.Context) {
+    var req RequeueRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        h.handleError(c, http.StatusBadRequest, "Invalid requeue request", err)
+        return
+    }
+    if err := checkSelection(req.TaskIDs, req.All); err != nil {
+        h.handleError(c, http.StatusBadRequest, "Invalid requeue request", err)
+        return
+    }
+
+    result, err := h.service.RequeueFailedTasks(c.Request.Context(), req.TaskIDs, req.Options)
+    if err != nil {
+        h.handleError(c, http.StatusBadRequest, "Failed to requeue tasks", err)
+        return
+    }
+
+    c.JSON(http.StatusOK, result)
+}
+
+// PurgeFailedTasks 删除失败任务及其上传的文件
+func (h *DocumentHandler) PurgeFailedTasks(c *gin.Context) {
+    var req PurgeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        h.handleError(c, http.StatusBadRequest, "Invalid purge request", err)
+        return
+    }
+    if err := checkSelection(req.TaskIDs, req.All); err != nil {
+        h.handleError(c, http.StatusBadRequest, "Invalid purge request", err)
+        return
+    }
+
+    purged, err := h.service.PurgeFailedTasks(c.Request.Context(), req.TaskIDs)
+    if err != nil {
+        h.handleError(c, http.StatusInternalServerError, "Failed to purge tasks", err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "purged": purged,
+    })
+}
+
+// checkSelection 批量操作必须明确指定任务或设置 all，避免空请求作用于整个队列
+func checkSelection(taskIDs []string, all bool) error {
+    switch {
+    case len(taskIDs) == 0 && !all:
+        return fmt.Errorf("taskIds is required unless all is true")
+    case len(taskIDs) > 0 && all:
+        return fmt.Errorf("taskIds and all are mutually exclusive")
+    }
+    return nil
+}
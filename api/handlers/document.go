@@ -1,12 +1,19 @@
 package handlers
 
 import (
+    "context"
     "encoding/json"
+    "errors"
     "fmt"
+    "math"
     "net/http"
     "path/filepath"
+    "strings"
+    "time"
     
     "github.com/gin-gonic/gin"
+    docagent "github.com/feichai0017/document-processor/internal/agent/document"
+    "github.com/feichai0017/document-processor/internal/models"
     "github.com/feichai0017/document-processor/internal/service/document"
     "github.com/feichai0017/document-processor/pkg/logger"
 )
@@ -18,12 +25,13 @@ type DocumentHandler struct {
 
 // ProcessResponse 定义处理响应结构
 type ProcessResponse struct {
-    TaskID    string `json:"taskId"`
-    Status    string `json:"status"`
-    Filename  string `json:"filename"`
-    FileSize  int64  `json:"fileSize"`
-    FileType  string `json:"fileType"`
-    CreatedAt string `json:"createdAt"`
+    TaskID       string `json:"taskId"`
+    Status       string `json:"status"`
+    Filename     string `json:"filename"`
+    FileSize     int64  `json:"fileSize"`
+    FileType     string `json:"fileType"`
+    CreatedAt    string `json:"createdAt"`
+    Deduplicated bool   `json:"deduplicated,omitempty"` // 相同文件和处理选项已有任务，返回的是已有任务
 }
 
 // ErrorResponse 定义错误响应结构
@@ -48,19 +56,32 @@ func (h *DocumentHandler) ProcessDocument(c *gin.Context) {
     }
     defer file.Close()
 
-    task, err := h.service.ProcessFile(c.Request.Context(), file, header)
+    opts, err := parseProcessingOptions(c)
     if err != nil {
-        h.handleError(c, http.StatusInternalServerError, "Failed to process file", err)
+        h.handleError(c, http.StatusBadRequest, "Invalid processing options", err)
+        return
+    }
+
+    ctx, err := submissionContext(c)
+    if err != nil {
+        h.handleError(c, http.StatusBadRequest, "Invalid submission", err)
+        return
+    }
+
+    task, err := h.service.ProcessFile(ctx, file, header, opts)
+    if err != nil {
+        h.handleSubmitError(c, "Failed to process file", err)
         return
     }
 
     c.JSON(http.StatusOK, ProcessResponse{
-        TaskID:    task.ID,
-        Status:    string(task.Status),
-        Filename:  header.Filename,
-        FileSize:  header.Size,
-        FileType:  filepath.Ext(header.Filename),
-        CreatedAt: task.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+        TaskID:       task.ID,
+        Status:       string(task.Status),
+        Filename:     header.Filename,
+        FileSize:     header.Size,
+        FileType:     filepath.Ext(header.Filename),
+        CreatedAt:    task.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+        Deduplicated: task.Deduplicated,
     })
 }
 
@@ -78,27 +99,42 @@ func (h *DocumentHandler) ProcessBatch(c *gin.Context) {
         return
     }
 
-    tasks, err := h.service.ProcessBatch(c.Request.Context(), files)
+    opts, err := parseProcessingOptions(c)
     if err != nil {
-        h.handleError(c, http.StatusInternalServerError, "Failed to process files", err)
+        h.handleError(c, http.StatusBadRequest, "Invalid processing options", err)
         return
     }
 
-    responses := make([]ProcessResponse, len(tasks))
-    for i, task := range tasks {
-        responses[i] = ProcessResponse{
-            TaskID:    task.ID,
-            Status:    string(task.Status),
-            Filename:  files[i].Filename,
-            FileSize:  files[i].Size,
-            FileType:  filepath.Ext(files[i].Filename),
-            CreatedAt: task.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-        }
+    ctx, err := submissionContext(c)
+    if err != nil {
+        h.handleError(c, http.StatusBadRequest, "Invalid submission", err)
+        return
+    }
+    // callbackUrl 对每个任务发送事件，batchCallbackUrl 在全部文件结束后发送一次批量任务事件
+    if callbackURL := strings.TrimSpace(c.PostForm("batchCallbackUrl")); callbackURL != "" {
+        ctx = document.WithBatchCallbackURL(ctx, callbackURL)
     }
 
-    c.JSON(http.StatusOK, gin.H{
-        "message": fmt.Sprintf("Processing %d documents", len(files)),
-        "tasks":   responses,
+    batch, err := h.service.ProcessBatch(ctx, files, opts, models.BatchPolicy(c.PostForm("policy")))
+    if err != nil {
+        h.handleSubmitError(c, "Failed to process files", err)
+        return
+    }
+
+    // 单个文件失败记录在对应的条目中，没有任何文件提交成功时返回 400
+    submitted := 0
+    for _, item := range batch.Items {
+        if item.TaskID != "" {
+            submitted++
+        }
+    }
+    status := http.StatusOK
+    if submitted == 0 {
+        status = http.StatusBadRequest
+    }
+    c.JSON(status, gin.H{
+        "message": fmt.Sprintf("Processing %d of %d documents", submitted, len(files)),
+        "batch":   batch,
     })
 }
 
@@ -116,15 +152,7 @@ func (h *DocumentHandler) GetStatus(c *gin.Context) {
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{
-        "taskId":    task.ID,
-        "status":    string(task.Status),
-        "progress":  task.Progress,
-        "error":     task.Error,
-        "metadata":  task.Metadata,
-        "createdAt": task.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-        "updatedAt": task.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
-    })
+    c.JSON(http.StatusOK, statusResponse(task))
 }
 
 // DownloadResult 下载处理结果
@@ -153,6 +181,38 @@ func (h *DocumentHandler) DownloadResult(c *gin.Context) {
     c.Data(http.StatusOK, "application/json", resultJSON)
 }
 
+// ReviewCorrectionRequest 修正复核请求
+type ReviewCorrectionRequest struct {
+    Status models.CorrectionStatus `json:"status" binding:"required"` // accepted 或 rejected
+}
+
+// ReviewCorrection 接受或拒绝单条 LLM 修正
+func (h *DocumentHandler) ReviewCorrection(c *gin.Context) {
+    taskID := c.Param("taskId")
+    correctionID := c.Param("correctionId")
+    if taskID == "" || correctionID == "" {
+        h.handleError(c, http.StatusBadRequest, "Task ID and correction ID are required", nil)
+        return
+    }
+
+    var req ReviewCorrectionRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        h.handleError(c, http.StatusBadRequest, "Invalid review request", err)
+        return
+    }
+
+    result, err := h.service.ReviewCorrection(c.Request.Context(), taskID, correctionID, req.Status)
+    if err != nil {
+        h.handleError(c, http.StatusBadRequest, "Failed to review correction", err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "taskId":   taskID,
+        "revision": result.Revision,
+    })
+}
+
 // CancelTask 取消处理任务
 func (h *DocumentHandler) CancelTask(c *gin.Context) {
     taskID := c.Param("taskId")
@@ -172,6 +232,105 @@ func (h *DocumentHandler) CancelTask(c *gin.Context) {
     })
 }
 
+// statusResponse 任务状态的响应格式，状态查询和状态推送共用
+func statusResponse(task *models.ProcessingTask) gin.H {
+    response := gin.H{
+        "taskId":     task.ID,
+        "status":     string(task.Status),
+        "progress":   task.Progress,
+        "stage":      task.Stage,
+        "pagesDone":  task.PagesDone,
+        "pagesTotal": task.PagesTotal,
+        "error":      task.Error,
+        "errorClass": task.ErrorClass,
+        "metadata":   task.Metadata,
+        "type":       task.Type,
+        "attempts":   task.Attempts,
+        "stages":     task.Stages,
+        "errors":     task.Errors,
+        "createdAt":  task.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+        "updatedAt":  task.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+    }
+    if task.StartedAt != nil {
+        response["startedAt"] = task.StartedAt.Format("2006-01-02T15:04:05Z07:00")
+    }
+    if task.FinishedAt != nil {
+        response["finishedAt"] = task.FinishedAt.Format("2006-01-02T15:04:05Z07:00")
+    }
+    if task.ETA != nil {
+        response["eta"] = task.ETA.Format("2006-01-02T15:04:05Z07:00")
+        response["etaSeconds"] = int(math.Max(0, time.Until(*task.ETA).Seconds()))
+    }
+    return response
+}
+
+// parseProcessingOptions 从表单字段解析处理选项，
+// queries 为 JSON 数组，如 [{"text":"What is the invoice number?","alias":"INVOICE_NO"}]，
+// schema 为 JSON Schema 对象，提供时按 Schema 抽取结构化数据，
+// promptVersions 按提示词 ID 固定模板版本，如 {"ocr_correction":1}，
+// summaryLength 为 short、medium 或 long 时生成摘要，translateTo 为目标语言代码时按块翻译
+func parseProcessingOptions(c *gin.Context) (*models.ProcessingOptions, error) {
+    opts := &models.ProcessingOptions{
+        Mode:          models.ProcessingMode(strings.ToLower(c.PostForm("mode"))),
+        DocumentType:  c.PostForm("documentType"),
+        SummaryLength: models.SummaryLength(strings.ToLower(c.PostForm("summaryLength"))),
+        TranslateTo:   strings.TrimSpace(c.PostForm("translateTo")),
+    }
+
+    if raw := c.PostForm("queries"); raw != "" {
+        if err := json.Unmarshal([]byte(raw), &opts.Queries); err != nil {
+            return nil, fmt.Errorf("invalid queries: %w", err)
+        }
+    }
+
+    if raw := c.PostForm("promptVersions"); raw != "" {
+        if err := json.Unmarshal([]byte(raw), &opts.PromptVersions); err != nil {
+            return nil, fmt.Errorf("invalid promptVersions: %w", err)
+        }
+    }
+
+    if raw := strings.TrimSpace(c.PostForm("schema")); raw != "" {
+        opts.Schema = json.RawMessage(raw)
+    }
+
+    if err := opts.Validate(); err != nil {
+        return nil, err
+    }
+
+    return opts, nil
+}
+
+// submissionContext 读取 Idempotency-Key、X-Tenant-ID 请求头和 callbackUrl 表单字段：
+// 客户端重试时使用相同的 key 不会重复创建任务，任务记录所属租户，任务结束时向 callbackUrl 发送签名事件
+func submissionContext(c *gin.Context) (context.Context, error) {
+    ctx := c.Request.Context()
+    if key := strings.TrimSpace(c.GetHeader("Idempotency-Key")); key != "" {
+        if len(key) > 255 {
+            return nil, fmt.Errorf("Idempotency-Key must not exceed 255 characters")
+        }
+        ctx = document.WithIdempotencyKey(ctx, key)
+    }
+    if tenant := strings.TrimSpace(c.GetHeader("X-Tenant-ID")); tenant != "" {
+        ctx = document.WithTenant(ctx, tenant)
+    }
+    if callbackURL := strings.TrimSpace(c.PostForm("callbackUrl")); callbackURL != "" {
+        ctx = document.WithCallbackURL(ctx, callbackURL)
+    }
+    return ctx, nil
+}
+
+// handleSubmitError 幂等键冲突返回 409，请求无效返回 400，其他错误返回 500
+func (h *DocumentHandler) handleSubmitError(c *gin.Context, message string, err error) {
+    switch {
+    case errors.Is(err, document.ErrIdempotencyConflict):
+        h.handleError(c, http.StatusConflict, message, err)
+    case docagent.ClassifyError(err) == docagent.ErrorClassInvalidRequest:
+        h.handleError(c, http.StatusBadRequest, message, err)
+    default:
+        h.handleError(c, http.StatusInternalServerError, message, err)
+    }
+}
+
 // handleError 统一错误处理
 func (h *DocumentHandler) handleError(c *gin.Context, status int, message string, err error) {
     h.logger.Error(message,
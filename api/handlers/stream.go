@@ -0,0 +1,67 @@
+package handlers
+
+import (
+    "io"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// streamHeartbeat 没有状态变化时发送注释行的间隔，防止代理断开空闲连接
+const streamHeartbeat = 15 * time.Second
+
+// StreamStatus 以 Server-Sent Events 推送单个任务的状态，任务结束后关闭连接
+func (h *DocumentHandler) StreamStatus(c *gin.Context) {
+    taskID := c.Param("taskId")
+    if taskID == "" {
+        h.handleError(c, http.StatusBadRequest, "Task ID is required", nil)
+        return
+    }
+    h.streamStatus(c, []string{taskID})
+}
+
+// StreamBatchStatus 推送多个任务的状态，任务 ID 通过 taskIds 参数以逗号分隔，
+// 所有任务结束后关闭连接
+func (h *DocumentHandler) StreamBatchStatus(c *gin.Context) {
+    taskIDs := splitQuery(c.Query("taskIds"))
+    if len(taskIDs) == 0 {
+        h.handleError(c, http.StatusBadRequest, "taskIds is required", nil)
+        return
+    }
+    h.streamStatus(c, taskIDs)
+}
+
+// streamStatus 每个事件的名称为任务状态 (pending、running、completed、failed、cancelled)，
+// 数据与状态查询接口的响应相同
+func (h *DocumentHandler) streamStatus(c *gin.Context, taskIDs []string) {
+    events, err := h.service.StreamTaskStatus(c.Request.Context(), taskIDs)
+    if err != nil {
+        h.handleError(c, http.StatusInternalServerError, "Failed to stream status", err)
+        return
+    }
+
+    c.Header("Content-Type", "text/event-stream")
+    c.Header("Cache-Control", "no-cache")
+    c.Header("Connection", "keep-alive")
+    c.Header("X-Accel-Buffering", "no")
+
+    heartbeat := time.NewTicker(streamHeartbeat)
+    defer heartbeat.Stop()
+
+    c.Stream(func(w io.Writer) bool {
+        select {
+        case task, ok := <-events:
+            if !ok {
+                return false
+            }
+            c.SSEvent(string(task.Status), statusResponse(task))
+            return true
+        case <-heartbeat.C:
+            io.WriteString(w, ": keep-alive\n\n")
+            return true
+        case <-c.Request.Context().Done():
+            return false
+        }
+    })
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/feichai0017/document-processor/internal/service/document"
+    "github.com/feichai0017/document-processor/pkg/taskindex"
+)
+
+// ListTasks 按状态、类型、创建时间、文件名、租户和批量任务过滤任务，游标分页。
+// 请求带 X-Tenant-ID 时只返回该租户的任务
+func (h *DocumentHandler) ListTasks(c *gin.Context) {
+    filter := &taskindex.Filter{
+        Statuses: splitQuery(c.Query("status")),
+        Kinds:    splitQuery(c.Query("type")),
+        Tenant:   c.Query("tenant"),
+        BatchID:  c.Query("batchId"),
+        Filename: strings.TrimSpace(c.Query("filename")),
+        Sort:     taskindex.SortField(c.DefaultQuery("sort", string(taskindex.SortCreatedAt))),
+        Cursor:   c.Query("cursor"),
+    }
+    if tenant := strings.TrimSpace(c.GetHeader("X-Tenant-ID")); tenant != "" {
+        filter.Tenant = tenant
+    }
+
+    switch filter.Sort {
+    case taskindex.SortCreatedAt, taskindex.SortUpdatedAt:
+    default:
+        h.handleError(c, http.StatusBadRequest, "Invalid sort, must be createdAt or updatedAt", nil)
+        return
+    }
+    switch order := c.DefaultQuery("order", "desc"); order {
+    case "asc":
+        filter.Asc = true
+    case "desc":
+    default:
+        h.handleError(c, http.StatusBadRequest, "Invalid order, must be asc or desc", nil)
+        return
+    }
+
+    limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+    if err != nil || limit < 1 || limit > 100 {
+        h.handleError(c, http.StatusBadRequest, "Invalid limit, must be between 1 and 100", err)
+        return
+    }
+    filter.Limit = limit
+
+    if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+        h.handleError(c, http.StatusBadRequest, "Invalid from, must be RFC 3339", err)
+        return
+    }
+    if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+        h.handleError(c, http.StatusBadRequest, "Invalid to, must be RFC 3339", err)
+        return
+    }
+
+    page, err := h.service.ListTasks(c.Request.Context(), filter)
+    if err != nil {
+        switch {
+        case errors.Is(err, taskindex.ErrInvalidCursor):
+            h.handleError(c, http.StatusBadRequest, "Invalid cursor", err)
+        case errors.Is(err, document.ErrTaskIndexDisabled):
+            h.handleError(c, http.StatusServiceUnavailable, "Task listing is not available", err)
+        default:
+            h.handleError(c, http.StatusInternalServerError, "Failed to list tasks", err)
+        }
+        return
+    }
+
+    c.JSON(http.StatusOK, page)
+}
+
+// splitQuery 逗号分隔的查询参数
+func splitQuery(value string) []string {
+    var values []string
+    for _, v := range strings.Split(value, ",") {
+        if v = strings.TrimSpace(v); v != "" {
+            values = append(values, v)
+        }
+    }
+    return values
+}
+
+func parseTimeQuery(c *gin.Context, name string) (time.Time, error) {
+    value := c.Query(name)
+    if value == "" {
+        return time.Time{}, nil
+    }
+    return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/feichai0017/document-processor/internal/service/document"
+    "github.com/feichai0017/document-processor/pkg/webhook"
+)
+
+// ListWebhookDeliveries 返回任务的回调投递记录，包括每次尝试的状态码和错误
+func (h *DocumentHandler) ListWebhookDeliveries(c *gin.Context) {
+    taskID := c.Param("taskId")
+    if taskID == "" {
+        h.handleError(c, http.StatusBadRequest, "Task ID is required", nil)
+        return
+    }
+
+    deliveries, err := h.service.ListWebhookDeliveries(c.Request.Context(), taskID)
+    if err != nil {
+        h.handleWebhookError(c, "Failed to list webhook deliveries", err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "taskId":     taskID,
+        "deliveries": deliveries,
+    })
+}
+
+// ListBatchWebhookDeliveries 返回批量任务的 batch.finished 回调投递记录
+func (h *DocumentHandler) ListBatchWebhookDeliveries(c *gin.Context) {
+    batchID := c.Param("batchId")
+    if batchID == "" {
+        h.handleError(c, http.StatusBadRequest, "Batch ID is required", nil)
+        return
+    }
+
+    deliveries, err := h.service.ListWebhookDeliveries(c.Request.Context(), webhook.BatchSubject(batchID))
+    if err != nil {
+        h.handleWebhookError(c, "Failed to list webhook deliveries", err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "batchId":    batchID,
+        "deliveries": deliveries,
+    })
+}
+
+// ReplayWebhook 重新投递一条记录中的事件，投递在后台进行，结果写入投递记录
+func (h *DocumentHandler) ReplayWebhook(c *gin.Context) {
+    deliveryID := c.Param("deliveryId")
+    if deliveryID == "" {
+        h.handleError(c, http.StatusBadRequest, "Delivery ID is required", nil)
+        return
+    }
+
+    delivery, err := h.service.ReplayWebhook(c.Request.Context(), deliveryID)
+    if err != nil {
+        h.handleWebhookError(c, "Failed to replay webhook", err)
+        return
+    }
+
+    response := gin.H{
+        "message":    "Webhook replay scheduled",
+        "deliveryId": delivery.ID,
+        "taskId":     delivery.Event.TaskID,
+        "url":        delivery.URL,
+    }
+    if delivery.Event.BatchID != "" {
+        response["batchId"] = delivery.Event.BatchID
+    }
+    c.JSON(http.StatusAccepted, response)
+}
+
+func (h *DocumentHandler) handleWebhookError(c *gin.Context, message string, err error) {
+    switch {
+    case errors.Is(err, webhook.ErrDeliveryNotFound):
+        h.handleError(c, http.StatusNotFound, message, err)
+    case errors.Is(err, document.ErrWebhooksDisabled):
+        h.handleError(c, http.StatusNotImplemented, message, err)
+    default:
+        h.handleError(c, http.StatusInternalServerError, message, err)
+    }
+}
@@ -25,11 +25,28 @@ func SetupRoutes(r *gin.Engine, h *handlers.Handlers) {
     // 文档处理路由组
     docs := v1.Group("/documents")
     {
+        docs.GET("", h.Document.ListTasks)
         docs.POST("/process", h.Document.ProcessDocument)
         docs.POST("/batch", h.Document.ProcessBatch)
+        docs.GET("/batch/:batchId", h.Document.GetBatch)
+        docs.GET("/batch/:batchId/download", h.Document.DownloadBatch)
+        docs.GET("/batch/:batchId/stream", h.Document.StreamBatch)
         docs.GET("/status/:taskId", h.Document.GetStatus)
+        docs.GET("/status/:taskId/stream", h.Document.StreamStatus)
+        docs.GET("/status/stream", h.Document.StreamBatchStatus)
         docs.GET("/download/:taskId", h.Document.DownloadResult)
+        docs.PUT("/corrections/:taskId/:correctionId", h.Document.ReviewCorrection)
         docs.DELETE("/task/:taskId", h.Document.CancelTask)
+
+        // 死信队列：重试耗尽的任务
+        docs.GET("/failed", h.Document.ListFailedTasks)
+        docs.POST("/failed/requeue", h.Document.RequeueFailedTasks)
+        docs.DELETE("/failed", h.Document.PurgeFailedTasks)
+
+        // 任务完成回调的投递记录和重放
+        docs.GET("/task/:taskId/webhooks", h.Document.ListWebhookDeliveries)
+        docs.GET("/batch/:batchId/webhooks", h.Document.ListBatchWebhookDeliveries)
+        docs.POST("/webhooks/:deliveryId/replay", h.Document.ReplayWebhook)
     }
 
 }
\ No newline at end of file
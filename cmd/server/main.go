@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"expvar"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/feichai0017/document-processor/api/handlers"
 	"github.com/feichai0017/document-processor/api/routes"
+	"github.com/feichai0017/document-processor/config"
 	"github.com/feichai0017/document-processor/internal/service/document"
 	"github.com/feichai0017/document-processor/pkg/logger"
 	"github.com/gin-gonic/gin"
@@ -34,6 +36,13 @@ func main() {
 		log.Fatal("Failed to get document service:", logger.Error(err))
 	}
 
+	// 任务索引随服务关闭
+	indexCtx, stopIndex := context.WithCancel(context.Background())
+	defer stopIndex()
+	if err := docService.StartTaskIndex(indexCtx); err != nil {
+		log.Fatal("Failed to start task index:", logger.Error(err))
+	}
+
 	// init handlers
 	h := handlers.NewHandlers(docService, log)
 	r := gin.New()
@@ -45,6 +54,20 @@ func main() {
 		Handler: r,
 	}
 
+	// 运行指标（expvar），包括视觉 LLM 连接池各实例的健康和熔断状态，
+	// 只监听内部地址（METRICS_SERVER_ADDR），不挂在公开的 API 路由上
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/debug/vars", expvar.Handler())
+	metricsSrv := &http.Server{
+		Addr:    config.GetMetricsConfig().ServerAddr,
+		Handler: metricsMux,
+	}
+	go func() {
+		if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Metrics server error:", logger.Error(err))
+		}
+	}()
+
 	// start server
 	go func() {
 		log.Info("Server starting on port 8080")
@@ -66,5 +89,9 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Error("Server forced to shutdown:", logger.Error(err))
 	}
+	metricsSrv.Close()
+	if err := docService.Close(); err != nil {
+		log.Error("Failed to close document service:", logger.Error(err))
+	}
 
 }
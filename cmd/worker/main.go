@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/feichai0017/document-processor/config"
 	"github.com/feichai0017/document-processor/internal/service/document"
 	"github.com/feichai0017/document-processor/pkg/logger"
+	"github.com/feichai0017/document-processor/pkg/queue"
 	"github.com/feichai0017/document-processor/pkg/worker"
 )
 
@@ -31,16 +37,21 @@ func main() {
 		os.Exit(1)
 	}
 
-	// create worker config
+	// create worker config, WORKER_TASK_TYPES limits this process to a subset
+	// of task types, e.g. a dedicated OCR pool with WORKER_TASK_TYPES=image;
+	// webhook deliveries run in their own pool
+	pools, err := workerPools(config.GetQueueConfig())
+	if err != nil {
+		log.Error("Invalid worker task types", logger.Error(err))
+		os.Exit(1)
+	}
+	webhookCfg := config.GetWebhookConfig()
 	workerCfg := &worker.Config{
-		RedisAddr:   "localhost:6379",
-		RedisDB:     0,
-		Concurrency: 10,
-		Queues: map[string]int{
-			"critical": 6,
-			"default":  3,
-			"low":      1,
-		},
+		RedisAddr:         "localhost:6379",
+		RedisDB:           0,
+		Pools:             pools,
+		WebhookBackoff:    webhookCfg.Backoff,
+		WebhookMaxBackoff: webhookCfg.MaxBackoff,
 	}
 
 	// init worker
@@ -50,6 +61,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	// expose metrics (expvar) on /debug/vars, including the vision LLM pool state;
+	// bound to an internal address (METRICS_WORKER_ADDR, default 127.0.0.1:9091)
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/debug/vars", expvar.Handler())
+	go func() {
+		if err := http.ListenAndServe(config.GetMetricsConfig().WorkerAddr, metricsMux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Metrics server error", logger.Error(err))
+		}
+	}()
+
 	// create a context that can be cancelled
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -68,5 +89,42 @@ func main() {
 	// 优雅关闭
 	log.Info("Shutting down worker...")
 	documentWorker.Stop()
+	if err := docService.Close(); err != nil {
+		log.Error("Failed to close document service", logger.Error(err))
+	}
 	log.Info("Worker stopped")
 }
+
+// webhookKind selects the webhook delivery pool in WORKER_TASK_TYPES
+const webhookKind = "webhook"
+
+// workerPools builds one pool per configured task type, all types when none is configured
+func workerPools(cfg *config.QueueConfig) ([]worker.PoolConfig, error) {
+	kinds := cfg.WorkerTaskTypes
+	if len(kinds) == 0 {
+		for _, taskType := range queue.TaskTypes {
+			kinds = append(kinds, queue.TaskKind(taskType))
+		}
+		kinds = append(kinds, webhookKind)
+	}
+
+	pools := make([]worker.PoolConfig, 0, len(kinds))
+	for _, kind := range kinds {
+		if kind == webhookKind {
+			pools = append(pools, worker.PoolConfig{
+				TaskType:    queue.TaskTypeWebhookDeliver,
+				Concurrency: cfg.TaskType(kind).Concurrency,
+			})
+			continue
+		}
+		taskType := kind + ":process"
+		if queue.TaskKind(taskType) != kind {
+			return nil, fmt.Errorf("unknown task type: %s", kind)
+		}
+		pools = append(pools, worker.PoolConfig{
+			TaskType:    taskType,
+			Concurrency: cfg.TaskType(kind).Concurrency,
+		})
+	}
+	return pools, nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"github.com/joho/godotenv"
+	"log"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	cacheOnce   sync.Once
+	cacheConfig *CacheConfig
+)
+
+type CacheConfig struct {
+	// Enabled 缓存 LLM 输出（OCR 校正、结构化抽取），调参时重复处理同一文档不再重复调用模型
+	Enabled bool
+	// Backend 为 memory（进程内 LRU）、redis 或 storage（S3）
+	Backend string
+	TTL     time.Duration
+	// Size memory 后端的最大条目数
+	Size int
+	// Prefix redis 键和存储对象的前缀
+	Prefix string
+}
+
+func GetCacheConfig() *CacheConfig {
+	cacheOnce.Do(func() {
+		// 获取当前文件的目录
+		_, filename, _, _ := runtime.Caller(0)
+		configDir := filepath.Dir(filename)
+
+		// 构建到项目根目录的路径
+		rootDir := filepath.Dir(configDir)
+		envPath := filepath.Join(rootDir, ".env")
+
+		// 加载 .env 文件
+		if err := godotenv.Load(envPath); err != nil {
+			log.Printf("Warning: .env file not found at %s, falling back to environment variables", envPath)
+		}
+
+		cacheConfig = &CacheConfig{
+			Enabled: getEnvBool("LLM_CACHE_ENABLED", false),
+			Backend: getEnv("LLM_CACHE_BACKEND", "memory"),
+			TTL:     getEnvDuration("LLM_CACHE_TTL", 24*time.Hour),
+			Size:    getEnvInt("LLM_CACHE_SIZE", 1000),
+			Prefix:  getEnv("LLM_CACHE_PREFIX", "llm-cache/"),
+		}
+	})
+	return cacheConfig
+}
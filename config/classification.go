@@ -0,0 +1,50 @@
+package config
+
+import (
+	"github.com/joho/godotenv"
+	"log"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+var (
+	classificationOnce   sync.Once
+	classificationConfig *ClassificationConfig
+)
+
+type ClassificationConfig struct {
+	Enabled bool
+	// UseLLM 为 false 时只使用关键词规则
+	UseLLM bool
+	// MinConfidence LLM 置信度低于该值时与规则结果比较
+	MinConfidence float64
+	// ApplyConfidence 分类结果达到该置信度才按类型切换处理配置（引擎、费用分析等），否则只记录分类结果
+	ApplyConfidence float64
+}
+
+func GetClassificationConfig() *ClassificationConfig {
+	classificationOnce.Do(func() {
+		// 获取当前文件的目录
+		_, filename, _, _ := runtime.Caller(0)
+		configDir := filepath.Dir(filename)
+
+		// 构建到项目根目录的路径
+		rootDir := filepath.Dir(configDir)
+		envPath := filepath.Join(rootDir, ".env")
+
+		// 加载 .env 文件
+		if err := godotenv.Load(envPath); err != nil {
+			log.Printf("Warning: .env file not found at %s, falling back to environment variables", envPath)
+		}
+
+		classificationConfig = &ClassificationConfig{
+			// 分类会改变已有调用方的处理路由，默认关闭
+			Enabled:         getEnvBool("CLASSIFICATION_ENABLED", false),
+			UseLLM:          getEnvBool("CLASSIFICATION_USE_LLM", true),
+			MinConfidence:   getEnvFloat("CLASSIFICATION_MIN_CONFIDENCE", 0.6),
+			ApplyConfidence: getEnvFloat("CLASSIFICATION_APPLY_CONFIDENCE", 0.7),
+		}
+	})
+	return classificationConfig
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"github.com/joho/godotenv"
+	"log"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	dedupOnce   sync.Once
+	dedupConfig *DedupConfig
+)
+
+type DedupConfig struct {
+	// Enabled 按文件内容和处理选项去重，相同的上传复用已完成的结果或正在处理的任务
+	Enabled bool
+	// TTL 去重记录的有效期，不应超过结果的保留时间
+	TTL time.Duration
+	// IdempotencyTTL Idempotency-Key 的有效期，期间使用相同 key 的请求返回同一个任务
+	IdempotencyTTL time.Duration
+}
+
+func GetDedupConfig() *DedupConfig {
+	dedupOnce.Do(func() {
+		// 获取当前文件的目录
+		_, filename, _, _ := runtime.Caller(0)
+		configDir := filepath.Dir(filename)
+
+		// 构建到项目根目录的路径
+		rootDir := filepath.Dir(configDir)
+		envPath := filepath.Join(rootDir, ".env")
+
+		// 加载 .env 文件
+		if err := godotenv.Load(envPath); err != nil {
+			log.Printf("Warning: .env file not found at %s, falling back to environment variables", envPath)
+		}
+
+		dedupConfig = &DedupConfig{
+			Enabled:        getEnvBool("DEDUP_ENABLED", false),
+			TTL:            getEnvDuration("DEDUP_TTL", 24*time.Hour),
+			IdempotencyTTL: getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		}
+	})
+	return dedupConfig
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"github.com/joho/godotenv"
+	"log"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+var (
+	metricsOnce   sync.Once
+	metricsConfig *MetricsConfig
+)
+
+// MetricsConfig 运行指标（expvar）的监听地址，指标包含内部实例地址和进程信息，
+// 只监听在内部地址上，不经过公开的 API 路由
+type MetricsConfig struct {
+	// ServerAddr API 服务的指标地址
+	ServerAddr string
+	// WorkerAddr worker 的指标地址
+	WorkerAddr string
+}
+
+func GetMetricsConfig() *MetricsConfig {
+	metricsOnce.Do(func() {
+		// 获取当前文件的目录
+		_, filename, _, _ := runtime.Caller(0)
+		configDir := filepath.Dir(filename)
+
+		// 构建到项目根目录的路径
+		rootDir := filepath.Dir(configDir)
+		envPath := filepath.Join(rootDir, ".env")
+
+		// 加载 .env 文件
+		if err := godotenv.Load(envPath); err != nil {
+			log.Printf("Warning: .env file not found at %s, falling back to environment variables", envPath)
+		}
+
+		metricsConfig = &MetricsConfig{
+			ServerAddr: getEnv("METRICS_SERVER_ADDR", "127.0.0.1:9090"),
+			WorkerAddr: getEnv("METRICS_WORKER_ADDR", "127.0.0.1:9091"),
+		}
+	})
+	return metricsConfig
+}
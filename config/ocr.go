@@ -0,0 +1,52 @@
+package config
+
+import (
+	"github.com/joho/godotenv"
+	"log"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+var (
+	ocrOnce   sync.Once
+	ocrConfig *OCRConfig
+)
+
+type OCRConfig struct {
+	// ImageEngine 图像默认使用的处理引擎：textract 或 tesseract（本地 OCR，启用 LLM 时做 OCR 校正）
+	ImageEngine string
+	// Languages Tesseract 识别语言，如 eng、chi_sim
+	Languages []string
+	// MinConfidence 低于该置信度的识别区域不计入结果
+	MinConfidence float64
+}
+
+func GetOCRConfig() *OCRConfig {
+	ocrOnce.Do(func() {
+		// 获取当前文件的目录
+		_, filename, _, _ := runtime.Caller(0)
+		configDir := filepath.Dir(filename)
+
+		// 构建到项目根目录的路径
+		rootDir := filepath.Dir(configDir)
+		envPath := filepath.Join(rootDir, ".env")
+
+		// 加载 .env 文件
+		if err := godotenv.Load(envPath); err != nil {
+			log.Printf("Warning: .env file not found at %s, falling back to environment variables", envPath)
+		}
+
+		languages := getEnvList("OCR_LANGUAGES")
+		if len(languages) == 0 {
+			languages = []string{"eng"}
+		}
+
+		ocrConfig = &OCRConfig{
+			ImageEngine:   getEnv("IMAGE_ENGINE", "textract"),
+			Languages:     languages,
+			MinConfidence: getEnvFloat("OCR_MIN_CONFIDENCE", 60),
+		}
+	})
+	return ocrConfig
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"github.com/joho/godotenv"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ollamaOnce   sync.Once
+	ollamaConfig *OllamaConfig
+)
+
+type OllamaConfig struct {
+	Enabled bool
+	// Backend 为 ollama 或 openai（OpenAI 兼容服务，如 vLLM、LM Studio）
+	Backend  string
+	Endpoint string
+	// Endpoints 多个实例时逗号分隔，请求在健康的实例间轮询
+	Endpoints   []string
+	APIKey      string
+	Model       string
+	MaxTokens   int
+	Temperature float64
+
+	MaxConcurrent       int
+	PoolTimeout         time.Duration
+	RequestTimeout      time.Duration
+	MaxRetries          int
+	RetryBackoff        time.Duration
+	BreakerThreshold    int           // 连续失败多少次后熔断实例
+	BreakerCooldown     time.Duration // 熔断后多久放行试探请求
+	HealthCheckInterval time.Duration
+	// ExtractionMaxAttempts 结构化抽取校验失败时的最大尝试次数
+	ExtractionMaxAttempts int
+	// TranslateSourceLanguages 只翻译这些语言的内容块，如 zh,de；为空时翻译所有块
+	TranslateSourceLanguages []string
+}
+
+func GetOllamaConfig() *OllamaConfig {
+	ollamaOnce.Do(func() {
+		// 获取当前文件的目录
+		_, filename, _, _ := runtime.Caller(0)
+		configDir := filepath.Dir(filename)
+
+		// 构建到项目根目录的路径
+		rootDir := filepath.Dir(configDir)
+		envPath := filepath.Join(rootDir, ".env")
+
+		// 加载 .env 文件
+		if err := godotenv.Load(envPath); err != nil {
+			log.Printf("Warning: .env file not found at %s, falling back to environment variables", envPath)
+		}
+
+		ollamaConfig = &OllamaConfig{
+			Enabled:                  getEnvBool("OLLAMA_ENABLED", true),
+			Backend:                  getEnv("LLM_BACKEND", "ollama"),
+			Endpoint:                 getEnv("OLLAMA_ENDPOINT", "http://localhost:11434"),
+			Endpoints:                getEnvList("OLLAMA_ENDPOINTS"),
+			APIKey:                   getEnv("LLM_API_KEY", ""),
+			Model:                    getEnv("OLLAMA_MODEL", "llama3.2-vision"),
+			MaxTokens:                getEnvInt("OLLAMA_MAX_TOKENS", 2048),
+			Temperature:              getEnvFloat("OLLAMA_TEMPERATURE", 0.1),
+			ExtractionMaxAttempts:    getEnvInt("OLLAMA_EXTRACTION_MAX_ATTEMPTS", 3),
+			MaxConcurrent:            getEnvInt("OLLAMA_MAX_CONCURRENT", 4),
+			PoolTimeout:              getEnvDuration("OLLAMA_POOL_TIMEOUT", 30*time.Second),
+			RequestTimeout:           getEnvDuration("OLLAMA_REQUEST_TIMEOUT", 120*time.Second),
+			MaxRetries:               getEnvInt("OLLAMA_MAX_RETRIES", 2),
+			RetryBackoff:             getEnvDuration("OLLAMA_RETRY_BACKOFF", time.Second),
+			BreakerThreshold:         getEnvInt("OLLAMA_BREAKER_THRESHOLD", 3),
+			BreakerCooldown:          getEnvDuration("OLLAMA_BREAKER_COOLDOWN", 30*time.Second),
+			HealthCheckInterval:      getEnvDuration("OLLAMA_HEALTH_INTERVAL", 15*time.Second),
+			TranslateSourceLanguages: getEnvList("TRANSLATE_SOURCE_LANGUAGES"),
+		}
+	})
+	return ollamaConfig
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvList 读取逗号分隔的列表，忽略空项
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
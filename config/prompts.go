@@ -0,0 +1,45 @@
+package config
+
+import (
+	"github.com/joho/godotenv"
+	"log"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	promptsOnce   sync.Once
+	promptsConfig *PromptsConfig
+)
+
+type PromptsConfig struct {
+	// Dir 提示词模板目录，为空时只使用内置模板
+	Dir string
+	// ReloadInterval 检查模板目录变化的间隔，0 表示不热加载
+	ReloadInterval time.Duration
+}
+
+func GetPromptsConfig() *PromptsConfig {
+	promptsOnce.Do(func() {
+		// 获取当前文件的目录
+		_, filename, _, _ := runtime.Caller(0)
+		configDir := filepath.Dir(filename)
+
+		// 构建到项目根目录的路径
+		rootDir := filepath.Dir(configDir)
+		envPath := filepath.Join(rootDir, ".env")
+
+		// 加载 .env 文件
+		if err := godotenv.Load(envPath); err != nil {
+			log.Printf("Warning: .env file not found at %s, falling back to environment variables", envPath)
+		}
+
+		promptsConfig = &PromptsConfig{
+			Dir:            getEnv("PROMPT_DIR", ""),
+			ReloadInterval: getEnvDuration("PROMPT_RELOAD_INTERVAL", 30*time.Second),
+		}
+	})
+	return promptsConfig
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"github.com/joho/godotenv"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	queueOnce   sync.Once
+	queueConfig *QueueConfig
+)
+
+// TaskTypeConfig 单个任务类型（image、pdf、word、document）的队列配置
+type TaskTypeConfig struct {
+	// Concurrency worker 同时处理该类型任务的数量
+	Concurrency int
+	// Timeout 单个任务的处理超时
+	Timeout time.Duration
+}
+
+type QueueConfig struct {
+	// WorkerTaskTypes 本进程 worker 处理的任务类型，为空时处理全部类型，
+	// 可以部署只处理 image 的 OCR 专用 worker 和处理 pdf、word 的文本 worker
+	WorkerTaskTypes []string
+	// TaskTypes 按任务类型（image、pdf、word、document、webhook）索引
+	TaskTypes map[string]TaskTypeConfig
+}
+
+// TaskType 返回任务类型的配置，kind 为任务类型去掉 ":process" 后的部分
+func (c *QueueConfig) TaskType(kind string) TaskTypeConfig {
+	if cfg, ok := c.TaskTypes[kind]; ok {
+		return cfg
+	}
+	return c.TaskTypes["document"]
+}
+
+func GetQueueConfig() *QueueConfig {
+	queueOnce.Do(func() {
+		// 获取当前文件的目录
+		_, filename, _, _ := runtime.Caller(0)
+		configDir := filepath.Dir(filename)
+
+		// 构建到项目根目录的路径
+		rootDir := filepath.Dir(configDir)
+		envPath := filepath.Join(rootDir, ".env")
+
+		// 加载 .env 文件
+		if err := godotenv.Load(envPath); err != nil {
+			log.Printf("Warning: .env file not found at %s, falling back to environment variables", envPath)
+		}
+
+		var types []string
+		for _, kind := range getEnvList("WORKER_TASK_TYPES") {
+			types = append(types, strings.ToLower(kind))
+		}
+
+		queueConfig = &QueueConfig{
+			WorkerTaskTypes: types,
+			TaskTypes: map[string]TaskTypeConfig{
+				// OCR 和视觉 LLM 处理图像，耗时长、占用资源多
+				"image": {
+					Concurrency: getEnvInt("QUEUE_IMAGE_CONCURRENCY", 2),
+					Timeout:     getEnvDuration("QUEUE_IMAGE_TIMEOUT", 30*time.Minute),
+				},
+				// 文本层提取通常在秒级完成
+				"pdf": {
+					Concurrency: getEnvInt("QUEUE_PDF_CONCURRENCY", 8),
+					Timeout:     getEnvDuration("QUEUE_PDF_TIMEOUT", 10*time.Minute),
+				},
+				"word": {
+					Concurrency: getEnvInt("QUEUE_WORD_CONCURRENCY", 4),
+					Timeout:     getEnvDuration("QUEUE_WORD_TIMEOUT", 10*time.Minute),
+				},
+				"document": {
+					Concurrency: getEnvInt("QUEUE_DOCUMENT_CONCURRENCY", 4),
+					Timeout:     getEnvDuration("QUEUE_DOCUMENT_TIMEOUT", 30*time.Minute),
+				},
+				// 回调投递，单次请求的超时由 WEBHOOK_TIMEOUT 控制
+				"webhook": {
+					Concurrency: getEnvInt("QUEUE_WEBHOOK_CONCURRENCY", 4),
+				},
+			},
+		}
+	})
+	return queueConfig
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"github.com/joho/godotenv"
+	"log"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	taskIndexOnce   sync.Once
+	taskIndexConfig *TaskIndexConfig
+)
+
+type TaskIndexConfig struct {
+	// Enabled API 服务维护持久化的任务索引，用于任务列表和搜索
+	Enabled bool
+	// Path SQLite 数据库文件的位置
+	Path string
+	// ReconcileInterval 与队列核对未结束任务状态的间隔，补上服务停止期间错过的状态变化
+	ReconcileInterval time.Duration
+}
+
+func GetTaskIndexConfig() *TaskIndexConfig {
+	taskIndexOnce.Do(func() {
+		// 获取当前文件的目录
+		_, filename, _, _ := runtime.Caller(0)
+		configDir := filepath.Dir(filename)
+
+		// 构建到项目根目录的路径
+		rootDir := filepath.Dir(configDir)
+		envPath := filepath.Join(rootDir, ".env")
+
+		// 加载 .env 文件
+		if err := godotenv.Load(envPath); err != nil {
+			log.Printf("Warning: .env file not found at %s, falling back to environment variables", envPath)
+		}
+
+		taskIndexConfig = &TaskIndexConfig{
+			Enabled:           getEnvBool("TASK_INDEX_ENABLED", true),
+			Path:              getEnv("TASK_INDEX_PATH", filepath.Join(rootDir, "data", "tasks.db")),
+			ReconcileInterval: getEnvDuration("TASK_INDEX_RECONCILE_INTERVAL", time.Minute),
+		}
+	})
+	return taskIndexConfig
+}
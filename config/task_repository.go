@@ -0,0 +1,48 @@
+package config
+
+import (
+	"github.com/joho/godotenv"
+	"log"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	taskRepositoryOnce   sync.Once
+	taskRepositoryConfig *TaskRepositoryConfig
+)
+
+type TaskRepositoryConfig struct {
+	// Backend 任务仓库：redis 或 sqlite。API 服务和 worker 部署在不同机器时只能使用 redis
+	Backend string
+	// Path sqlite 数据库文件的位置
+	Path string
+	// Retention redis 中任务记录的保留时间，0 表示不过期
+	Retention time.Duration
+}
+
+func GetTaskRepositoryConfig() *TaskRepositoryConfig {
+	taskRepositoryOnce.Do(func() {
+		// 获取当前文件的目录
+		_, filename, _, _ := runtime.Caller(0)
+		configDir := filepath.Dir(filename)
+
+		// 构建到项目根目录的路径
+		rootDir := filepath.Dir(configDir)
+		envPath := filepath.Join(rootDir, ".env")
+
+		// 加载 .env 文件
+		if err := godotenv.Load(envPath); err != nil {
+			log.Printf("Warning: .env file not found at %s, falling back to environment variables", envPath)
+		}
+
+		taskRepositoryConfig = &TaskRepositoryConfig{
+			Backend:   getEnv("TASK_REPOSITORY", "redis"),
+			Path:      getEnv("TASK_REPOSITORY_PATH", filepath.Join(rootDir, "data", "task_records.db")),
+			Retention: getEnvDuration("TASK_RETENTION", 30*24*time.Hour),
+		}
+	})
+	return taskRepositoryConfig
+}
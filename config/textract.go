@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"sync"
 )
 
@@ -15,11 +16,15 @@ var (
 )
 
 type TextractConfig struct {
-	BucketName string
-	Region     string
-	Endpoint   string
-	AccessKey  string
-	SecretKey  string
+	BucketName  string
+	Region      string
+	Endpoint    string
+	AccessKey   string
+	SecretKey   string
+	EnableAsync bool // 通过 S3 对象异步分析多页文档
+
+	// PDFMaxLocalBytes 启用异步分析后，超过该大小的 PDF 不再由本地处理器读取文本层，0 表示不限制
+	PDFMaxLocalBytes int64
 }
 
 func GetTextractConfig() *TextractConfig {
@@ -37,11 +42,20 @@ func GetTextractConfig() *TextractConfig {
 			log.Printf("Warning: .env file not found at %s, falling back to environment variables", envPath)
 		}
 
+		enableAsync, _ := strconv.ParseBool(os.Getenv("TEXTRACT_ASYNC_ENABLED"))
+		pdfMaxLocalBytes := int64(50 * 1024 * 1024)
+		if v, err := strconv.ParseInt(os.Getenv("TEXTRACT_PDF_MAX_LOCAL_BYTES"), 10, 64); err == nil {
+			pdfMaxLocalBytes = v
+		}
+
 		textractConfig = &TextractConfig{
-			Region:     os.Getenv("AWS_REGION"),
-			Endpoint:   os.Getenv("AWS_ENDPOINT"),
-			AccessKey:  os.Getenv("AWS_ACCESS_KEY"),
-			SecretKey:  os.Getenv("AWS_SECRET_KEY"),
+			BucketName:  os.Getenv("AWS_S3_BUCKET_NAME"),
+			Region:      os.Getenv("AWS_REGION"),
+			Endpoint:    os.Getenv("AWS_ENDPOINT"),
+			AccessKey:   os.Getenv("AWS_ACCESS_KEY"),
+			SecretKey:   os.Getenv("AWS_SECRET_KEY"),
+			EnableAsync: enableAsync,
+			PDFMaxLocalBytes: pdfMaxLocalBytes,
 		}
 	})
 	return textractConfig
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"github.com/joho/godotenv"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	webhookOnce   sync.Once
+	webhookConfig *WebhookConfig
+)
+
+type WebhookConfig struct {
+	// Secret HMAC-SHA256 签名密钥，为空时不支持回调
+	Secret      string
+	MaxAttempts int
+	// Backoff 首次重试的等待时间，之后每次翻倍，不超过 MaxBackoff；重试由 worker 调度
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Timeout    time.Duration
+	// AllowPrivateNetworks 允许回调到 localhost 和内网地址，仅用于本地开发
+	AllowPrivateNetworks bool
+	// LogTTL 回调地址和投递记录的保留时间
+	LogTTL time.Duration
+	// PublicBaseURL 外部访问 API 的地址，用于生成事件中的结果下载链接
+	PublicBaseURL string
+}
+
+func GetWebhookConfig() *WebhookConfig {
+	webhookOnce.Do(func() {
+		// 获取当前文件的目录
+		_, filename, _, _ := runtime.Caller(0)
+		configDir := filepath.Dir(filename)
+
+		// 构建到项目根目录的路径
+		rootDir := filepath.Dir(configDir)
+		envPath := filepath.Join(rootDir, ".env")
+
+		// 加载 .env 文件
+		if err := godotenv.Load(envPath); err != nil {
+			log.Printf("Warning: .env file not found at %s, falling back to environment variables", envPath)
+		}
+
+		webhookConfig = &WebhookConfig{
+			Secret:               getEnv("WEBHOOK_SECRET", ""),
+			MaxAttempts:          getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+			Backoff:              getEnvDuration("WEBHOOK_BACKOFF", 2*time.Second),
+			MaxBackoff:           getEnvDuration("WEBHOOK_MAX_BACKOFF", 5*time.Minute),
+			Timeout:              getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+			AllowPrivateNetworks: getEnvBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
+			LogTTL:               getEnvDuration("WEBHOOK_LOG_TTL", 7*24*time.Hour),
+			PublicBaseURL:        strings.TrimRight(getEnv("PUBLIC_BASE_URL", "http://localhost:8080"), "/"),
+		}
+	})
+	return webhookConfig
+}
@@ -0,0 +1,218 @@
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/feichai0017/document-processor/internal/agent/document/image"
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/pkg/jsonschema"
+	"github.com/feichai0017/document-processor/pkg/logger"
+)
+
+const systemPrompt = `You classify business documents.
+Look at the first page and answer with JSON: the document type and your confidence between 0 and 1.
+Use "other" when the document matches none of the types.`
+
+// ChatClient 分类所需的 LLM 对话接口
+type ChatClient interface {
+	Chat(ctx context.Context, messages []image.ChatMessage, format json.RawMessage) (string, error)
+}
+
+// Config 分类配置
+type Config struct {
+	MinConfidence float64 // LLM 置信度低于该值时与规则结果比较
+	MaxInputChars int     // 发送给模型的首页文本上限
+}
+
+// Input 分类输入，通常只包含首页
+type Input struct {
+	Filename string
+	Text     string   // 首页文本（PDF 文本层），可为空
+	Images   []string // 首页图像 base64，可为空
+}
+
+// Classifier 使用 LLM 识别文档类型，LLM 不可用或不确定时使用关键词规则兜底
+type Classifier struct {
+	client    ChatClient // 为 nil 时只使用规则
+	logger    logger.Logger
+	config    *Config
+	schema    json.RawMessage
+	validator *jsonschema.Schema
+}
+
+func NewClassifier(client ChatClient, log logger.Logger, cfg *Config) *Classifier {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if cfg.MinConfidence <= 0 {
+		cfg.MinConfidence = 0.6
+	}
+	if cfg.MaxInputChars <= 0 {
+		cfg.MaxInputChars = 4000
+	}
+
+	schema := responseSchema()
+	validator, _ := jsonschema.Parse(schema)
+
+	return &Classifier{
+		client:    client,
+		logger:    log,
+		config:    cfg,
+		schema:    schema,
+		validator: validator,
+	}
+}
+
+// Classify 返回文档类型及置信度，不会返回错误，最差情况为 other
+func (c *Classifier) Classify(ctx context.Context, in *Input) *models.Classification {
+	rules := ClassifyByRules(in.Filename + "\n" + in.Text)
+
+	if c.client == nil || (in.Text == "" && len(in.Images) == 0) {
+		return rules
+	}
+
+	result, err := c.classifyWithLLM(ctx, in)
+	if err != nil {
+		c.logger.Warn("LLM classification failed, using rules",
+			logger.String("filename", in.Filename),
+			logger.Error(err),
+		)
+		return rules
+	}
+
+	if result.Confidence < c.config.MinConfidence && rules.Confidence > result.Confidence {
+		return rules
+	}
+	return result
+}
+
+func (c *Classifier) classifyWithLLM(ctx context.Context, in *Input) (*models.Classification, error) {
+	text := truncate(in.Text, c.config.MaxInputChars)
+
+	content := fmt.Sprintf("Document types: %s\nFile name: %s",
+		strings.Join(models.KnownDocumentTypes, ", "), in.Filename)
+	if text != "" {
+		content += "\n\nFirst page text:\n" + text
+	}
+
+	output, err := c.client.Chat(ctx, []image.ChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: content, Images: in.Images},
+	}, c.schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.validator.Validate([]byte(output)); err != nil {
+		return nil, err
+	}
+
+	var answer struct {
+		DocumentType string  `json:"documentType"`
+		Confidence   float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(output), &answer); err != nil {
+		return nil, fmt.Errorf("failed to decode classification: %w", err)
+	}
+
+	return &models.Classification{
+		DocumentType: answer.DocumentType,
+		Confidence:   answer.Confidence,
+		Method:       models.ClassifiedByLLM,
+	}, nil
+}
+
+// responseSchema 约束模型输出为已知类型之一
+func responseSchema() json.RawMessage {
+	schema, _ := json.Marshal(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"documentType": map[string]interface{}{
+				"type": "string",
+				"enum": models.KnownDocumentTypes,
+			},
+			"confidence": map[string]interface{}{
+				"type":    "number",
+				"minimum": 0,
+				"maximum": 1,
+			},
+		},
+		"required": []string{"documentType", "confidence"},
+	})
+	return schema
+}
+
+// keywords 规则分类使用的关键词，按命中的不同关键词数量打分
+var keywords = map[string][]string{
+	models.DocTypeInvoice: {
+		"invoice", "发票", "invoice number", "invoice no", "bill to", "amount due", "due date", "tax invoice", "税额",
+	},
+	models.DocTypeReceipt: {
+		"receipt", "收据", "小票", "cashier", "change due", "thank you for", "收银",
+	},
+	models.DocTypeIDCard: {
+		"identity card", "id card", "身份证", "passport", "护照", "date of birth", "出生", "nationality",
+		"driver license", "driver's license", "公民身份号码",
+	},
+	models.DocTypeContract: {
+		"agreement", "contract", "合同", "协议", "hereinafter", "party a", "party b", "甲方", "乙方",
+		"whereas", "governing law", "in witness whereof",
+	},
+	models.DocTypeBankStatement: {
+		"bank statement", "account statement", "对账单", "opening balance", "closing balance",
+		"statement period", "account number", "账号", "余额",
+	},
+}
+
+// ClassifyByRules 按关键词命中数量分类，没有命中时返回 other
+func ClassifyByRules(text string) *models.Classification {
+	text = strings.ToLower(text)
+
+	best := &models.Classification{
+		DocumentType: models.DocTypeOther,
+		Method:       models.ClassifiedByRules,
+	}
+	bestScore := 0
+
+	types := make([]string, 0, len(keywords))
+	for docType := range keywords {
+		types = append(types, docType)
+	}
+	sort.Strings(types)
+
+	for _, docType := range types {
+		score := 0
+		for _, kw := range keywords[docType] {
+			if strings.Contains(text, kw) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best.DocumentType = docType
+			bestScore = score
+		}
+	}
+
+	if bestScore > 0 {
+		// 命中越多越可信，规则结果最高 0.9
+		best.Confidence = math.Min(0.9, math.Round((0.3+0.15*float64(bestScore))*100)/100)
+	}
+	return best
+}
+
+// truncate 按字符数截断文本，不会切断多字节字符
+func truncate(text string, maxChars int) string {
+	if len(text) <= maxChars {
+		return text
+	}
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return string(runes[:maxChars])
+}
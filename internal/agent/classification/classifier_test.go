@@ -0,0 +1,44 @@
+package classification
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/feichai0017/document-processor/internal/agent/document/image"
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/pkg/logger"
+)
+
+type stubChat struct {
+	messages []image.ChatMessage
+	reply    string
+}
+
+func (s *stubChat) Chat(ctx context.Context, messages []image.ChatMessage, format json.RawMessage) (string, error) {
+	s.messages = messages
+	return s.reply, nil
+}
+
+func TestClassifyTruncatesByRunes(t *testing.T) {
+	chat := &stubChat{reply: `{"documentType":"invoice","confidence":0.95}`}
+	classifier := NewClassifier(chat, logger.NewTestLogger(), &Config{MaxInputChars: 5})
+
+	result := classifier.Classify(context.Background(), &Input{
+		Filename: "scan.pdf",
+		Text:     "增值税专用发票 发票号码 12345",
+	})
+	if result.DocumentType != models.DocTypeInvoice || result.Method != models.ClassifiedByLLM {
+		t.Fatalf("Classify() = %+v", result)
+	}
+
+	content := chat.messages[1].Content
+	if !utf8.ValidString(content) {
+		t.Fatalf("prompt is not valid UTF-8: %q", content)
+	}
+	if !strings.HasSuffix(content, "\n增值税专用") {
+		t.Errorf("prompt text was not truncated to 5 characters: %q", content)
+	}
+}
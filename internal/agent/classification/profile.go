@@ -0,0 +1,110 @@
+package classification
+
+import (
+	"encoding/json"
+
+	"github.com/feichai0017/document-processor/internal/models"
+)
+
+// Profile 文档类型对应的下游处理配置
+type Profile struct {
+	DocumentType string
+	Engine       string          // 处理引擎，为空时按文件类型选择
+	Features     []string        // Textract FeatureTypes
+	Schema       json.RawMessage // 结构化抽取 Schema，为空时不抽取
+	Prompt       string          // 结构化抽取的附加说明
+}
+
+// profiles 内置的文档类型处理配置，发票和收据通过 DocumentType 自动进入费用分析模式
+var profiles = map[string]*Profile{
+	models.DocTypeInvoice: {
+		DocumentType: models.DocTypeInvoice,
+		Engine:       "textract",
+	},
+	models.DocTypeReceipt: {
+		DocumentType: models.DocTypeReceipt,
+		Engine:       "textract",
+	},
+	models.DocTypeIDCard: {
+		DocumentType: models.DocTypeIDCard,
+		Engine:       "textract",
+		Features:     []string{"FORMS"},
+		Schema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"fullName": {"type": "string"},
+				"documentNumber": {"type": "string"},
+				"dateOfBirth": {"type": ["string", "null"]},
+				"expiryDate": {"type": ["string", "null"]},
+				"nationality": {"type": ["string", "null"]},
+				"address": {"type": ["string", "null"]}
+			},
+			"required": ["fullName", "documentNumber"]
+		}`),
+		Prompt: "This is an identity document. Dates use the YYYY-MM-DD format.",
+	},
+	models.DocTypeContract: {
+		DocumentType: models.DocTypeContract,
+		Schema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"title": {"type": ["string", "null"]},
+				"parties": {"type": "array", "items": {"type": "string"}},
+				"effectiveDate": {"type": ["string", "null"]},
+				"terminationDate": {"type": ["string", "null"]},
+				"governingLaw": {"type": ["string", "null"]},
+				"totalValue": {"type": ["number", "null"]}
+			},
+			"required": ["parties"]
+		}`),
+		Prompt: "This is a contract. List every contracting party. Dates use the YYYY-MM-DD format.",
+	},
+	models.DocTypeBankStatement: {
+		DocumentType: models.DocTypeBankStatement,
+		Engine:       "textract",
+		Features:     []string{"TABLES", "FORMS"},
+		Schema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"bankName": {"type": ["string", "null"]},
+				"accountHolder": {"type": ["string", "null"]},
+				"accountNumber": {"type": "string"},
+				"currency": {"type": ["string", "null"]},
+				"periodStart": {"type": ["string", "null"]},
+				"periodEnd": {"type": ["string", "null"]},
+				"openingBalance": {"type": ["number", "null"]},
+				"closingBalance": {"type": ["number", "null"]}
+			},
+			"required": ["accountNumber"]
+		}`),
+		Prompt: "This is a bank statement. Amounts are plain numbers without currency symbols.",
+	},
+}
+
+// ProfileFor 返回文档类型的处理配置，未知类型返回 nil
+func ProfileFor(documentType string) *Profile {
+	return profiles[documentType]
+}
+
+// Apply 将配置填入处理选项，请求中已显式设置的选项保持不变
+func (p *Profile) Apply(opts *models.ProcessingOptions) {
+	if p == nil || opts == nil {
+		return
+	}
+
+	if opts.DocumentType == "" {
+		opts.DocumentType = p.DocumentType
+	}
+	if opts.Engine == "" {
+		opts.Engine = p.Engine
+	}
+	if len(opts.Features) == 0 {
+		opts.Features = p.Features
+	}
+	if len(opts.Schema) == 0 {
+		opts.Schema = p.Schema
+	}
+	if opts.Prompt == "" {
+		opts.Prompt = p.Prompt
+	}
+}
@@ -0,0 +1,43 @@
+package document
+
+import (
+    "context"
+
+    "github.com/feichai0017/document-processor/internal/models"
+)
+
+type sourceKey struct{}
+
+type optionsKey struct{}
+
+// Source 文档在对象存储中的位置
+type Source struct {
+    // Bucket 为空时由处理器使用自身配置的存储桶
+    Bucket string
+    Key    string
+}
+
+// WithSource 将文档的存储位置附加到 context 上，
+// 需要直接访问已上传对象的处理器（如 Textract 异步分析）从中读取
+func WithSource(ctx context.Context, src Source) context.Context {
+    return context.WithValue(ctx, sourceKey{}, src)
+}
+
+// SourceFromContext 获取文档的存储位置
+func SourceFromContext(ctx context.Context) (Source, bool) {
+    src, ok := ctx.Value(sourceKey{}).(Source)
+    return src, ok && src.Key != ""
+}
+
+// WithOptions 将单次请求的处理选项附加到 context 上
+func WithOptions(ctx context.Context, opts *models.ProcessingOptions) context.Context {
+    return context.WithValue(ctx, optionsKey{}, opts)
+}
+
+// OptionsFromContext 获取处理选项，未设置时返回空选项
+func OptionsFromContext(ctx context.Context) *models.ProcessingOptions {
+    if opts, ok := ctx.Value(optionsKey{}).(*models.ProcessingOptions); ok && opts != nil {
+        return opts
+    }
+    return &models.ProcessingOptions{}
+}
@@ -0,0 +1,109 @@
+package document
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+)
+
+// ErrorClass 处理错误的类别，决定任务是否值得重试
+type ErrorClass string
+
+const (
+    ErrorClassCorruptInput    ErrorClass = "corrupt_input"    // 文件损坏或无法解析
+    ErrorClassUnsupportedType ErrorClass = "unsupported_type" // 不支持的文件类型或处理模式
+    ErrorClassInvalidRequest  ErrorClass = "invalid_request"  // 任务数据或处理选项无效
+    ErrorClassThrottled       ErrorClass = "throttled"        // 配额不足或被限流
+    ErrorClassTransient       ErrorClass = "transient"        // 网络、超时、服务端 5xx 等临时故障
+    ErrorClassUnknown         ErrorClass = "unknown"
+)
+
+// Permanent 重试也不会成功的错误
+func (c ErrorClass) Permanent() bool {
+    switch c {
+    case ErrorClassCorruptInput, ErrorClassUnsupportedType, ErrorClassInvalidRequest:
+        return true
+    }
+    return false
+}
+
+// Error 带类别的处理错误
+type Error struct {
+    Class ErrorClass
+    Err   error
+}
+
+func (e *Error) Error() string {
+    return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+    return e.Err
+}
+
+// NewError 为错误标记类别，err 为 nil 时返回 nil
+func NewError(class ErrorClass, err error) error {
+    if err == nil {
+        return nil
+    }
+    return &Error{Class: class, Err: err}
+}
+
+// Errorf 创建带类别的错误，格式同 fmt.Errorf
+func Errorf(class ErrorClass, format string, args ...interface{}) error {
+    return &Error{Class: class, Err: fmt.Errorf(format, args...)}
+}
+
+// classifier 其他包的错误类型可以实现该接口声明自身类别，如 LLM 客户端的 StatusError
+type classifier interface {
+    ErrorClass() ErrorClass
+}
+
+// apiError AWS SDK 返回的服务端错误
+type apiError interface {
+    ErrorCode() string
+}
+
+// ClassifyError 判断错误类别：优先使用错误链中显式标记的类别，
+// 其次按 AWS 错误码、超时和网络错误判断，无法判断时返回 unknown
+func ClassifyError(err error) ErrorClass {
+    if err == nil {
+        return ""
+    }
+
+    var classified *Error
+    if errors.As(err, &classified) {
+        return classified.Class
+    }
+    var c classifier
+    if errors.As(err, &c) {
+        return c.ErrorClass()
+    }
+    var api apiError
+    if errors.As(err, &api) {
+        if class, ok := awsErrorClasses[api.ErrorCode()]; ok {
+            return class
+        }
+    }
+    if errors.Is(err, context.DeadlineExceeded) {
+        return ErrorClassTransient
+    }
+    var netErr net.Error
+    if errors.As(err, &netErr) {
+        return ErrorClassTransient
+    }
+    return ErrorClassUnknown
+}
+
+// awsErrorClasses Textract 等 AWS 服务的错误码
+var awsErrorClasses = map[string]ErrorClass{
+    "ThrottlingException":                    ErrorClassThrottled,
+    "ProvisionedThroughputExceededException": ErrorClassThrottled,
+    "LimitExceededException":                 ErrorClassThrottled,
+    "InternalServerError":                    ErrorClassTransient,
+    "ServiceUnavailableException":            ErrorClassTransient,
+    "BadDocumentException":                   ErrorClassCorruptInput,
+    "DocumentTooLargeException":              ErrorClassCorruptInput,
+    "UnsupportedDocumentException":           ErrorClassUnsupportedType,
+}
@@ -0,0 +1,106 @@
+package image
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+
+    "github.com/feichai0017/document-processor/internal/models"
+)
+
+var (
+    correctionPattern  = regexp.MustCompile(`\[CORRECTION:\s*(.+?)\s*->\s*(.*?)\s*\]`)
+    uncertaintyPattern = regexp.MustCompile(`\[UNCERTAIN:\s*(.+?)\s*\]`)
+)
+
+// ParseRevision 解析 LLM 响应中的 [CORRECTION: a -> b] 和 [UNCERTAIN: text] 标记，
+// 并按字符（rune）偏移定位到 OCR 文本中
+func ParseRevision(ocrText, response string) *models.TextRevision {
+    revision := &models.TextRevision{
+        OCRText:       ocrText,
+        Corrections:   []models.Correction{},
+        Uncertainties: []models.Uncertainty{},
+    }
+
+    text := []rune(ocrText)
+    var used [][2]int
+    cursor := 0
+
+    for i, match := range correctionPattern.FindAllStringSubmatch(response, -1) {
+        correction := models.Correction{
+            ID:        fmt.Sprintf("c%d", i+1),
+            Original:  trimMarkerText(match[1]),
+            Corrected: trimMarkerText(match[2]),
+            Start:     -1,
+            End:       -1,
+            Status:    models.CorrectionPending,
+        }
+
+        // 标记通常按阅读顺序出现，先从上一处之后查找，找不到再查找全文
+        start := findRunes(text, []rune(correction.Original), cursor, used)
+        if start < 0 {
+            start = findRunes(text, []rune(correction.Original), 0, used)
+        }
+        if start >= 0 {
+            correction.Start = start
+            correction.End = start + len([]rune(correction.Original))
+            used = append(used, [2]int{correction.Start, correction.End})
+            cursor = correction.End
+        }
+
+        revision.Corrections = append(revision.Corrections, correction)
+    }
+
+    for _, match := range uncertaintyPattern.FindAllStringSubmatch(response, -1) {
+        uncertainty := models.Uncertainty{
+            Text:  trimMarkerText(match[1]),
+            Start: -1,
+            End:   -1,
+        }
+        if start := findRunes(text, []rune(uncertainty.Text), 0, nil); start >= 0 {
+            uncertainty.Start = start
+            uncertainty.End = start + len([]rune(uncertainty.Text))
+        }
+        revision.Uncertainties = append(revision.Uncertainties, uncertainty)
+    }
+
+    revision.MergedText = revision.Merge()
+    return revision
+}
+
+// findRunes 返回 needle 在 text 中从 from 开始的字符偏移，跳过与已定位片段重叠的位置
+func findRunes(text, needle []rune, from int, used [][2]int) int {
+    if len(needle) == 0 {
+        return -1
+    }
+
+    for start := from; start+len(needle) <= len(text); start++ {
+        if string(text[start:start+len(needle)]) != string(needle) {
+            continue
+        }
+        end := start + len(needle)
+        overlaps := false
+        for _, span := range used {
+            if start < span[1] && end > span[0] {
+                overlaps = true
+                break
+            }
+        }
+        if !overlaps {
+            return start
+        }
+    }
+    return -1
+}
+
+// trimMarkerText 去掉标记值两端的空白和模型常加的引号
+func trimMarkerText(s string) string {
+    s = strings.TrimSpace(s)
+    if len(s) >= 2 {
+        first, last := s[0], s[len(s)-1]
+        if (first == '"' || first == '\'') && first == last {
+            s = s[1 : len(s)-1]
+        }
+    }
+    return s
+}
@@ -0,0 +1,92 @@
+package image
+
+import (
+    "testing"
+
+    "github.com/feichai0017/document-processor/internal/models"
+)
+
+func TestParseRevisionRuneOffsets(t *testing.T) {
+    ocr := "发票号码：INV-1OO1，合计金额￥1,375"
+    response := "发票号码：INV-1001，合计金额￥1,375\n[CORRECTION: INV-1OO1 -> INV-1001]"
+
+    revision := ParseRevision(ocr, response)
+    if len(revision.Corrections) != 1 {
+        t.Fatalf("corrections = %+v", revision.Corrections)
+    }
+    c := revision.Corrections[0]
+    // 偏移按字符计算，"发票号码：" 是 5 个字符、15 个字节
+    if c.Start != 5 || c.End != 13 {
+        t.Errorf("offsets = [%d, %d), want [5, 13)", c.Start, c.End)
+    }
+    if got := string([]rune(ocr)[c.Start:c.End]); got != "INV-1OO1" {
+        t.Errorf("text at offsets = %q", got)
+    }
+    if c.ID != "c1" || c.Status != models.CorrectionPending {
+        t.Errorf("correction = %+v", c)
+    }
+    if revision.MergedText != "发票号码：INV-1001，合计金额￥1,375" {
+        t.Errorf("MergedText = %q", revision.MergedText)
+    }
+}
+
+func TestParseRevisionRepeatedOriginal(t *testing.T) {
+    // 同一原文出现两次时按阅读顺序依次定位
+    revision := ParseRevision("yuo and yuo", "[CORRECTION: yuo -> you] [CORRECTION: 'yuo' -> \"you\"]")
+    if len(revision.Corrections) != 2 {
+        t.Fatalf("corrections = %+v", revision.Corrections)
+    }
+    if revision.Corrections[0].Start != 0 || revision.Corrections[1].Start != 8 {
+        t.Errorf("starts = %d, %d; want 0, 8", revision.Corrections[0].Start, revision.Corrections[1].Start)
+    }
+    if revision.MergedText != "you and you" {
+        t.Errorf("MergedText = %q", revision.MergedText)
+    }
+}
+
+func TestParseRevisionOverlappingCorrections(t *testing.T) {
+    // 第二条修正只能与第一条重叠，无法定位，先出现的修正生效
+    revision := ParseRevision("Tota1 due", "[CORRECTION: Tota1 -> Total] [CORRECTION: a1 d -> al d]")
+    first, second := revision.Corrections[0], revision.Corrections[1]
+    if !first.Located() || first.Start != 0 || first.End != 5 {
+        t.Errorf("first correction = %+v", first)
+    }
+    if second.Located() {
+        t.Errorf("overlapping correction was located: %+v", second)
+    }
+    if revision.MergedText != "Total due" {
+        t.Errorf("MergedText = %q", revision.MergedText)
+    }
+}
+
+func TestParseRevisionOriginalNotFound(t *testing.T) {
+    revision := ParseRevision("Invoice INV-1001", "[CORRECTION: Receipt -> Invoice]")
+    c := revision.Corrections[0]
+    if c.Start != -1 || c.End != -1 || c.Located() {
+        t.Errorf("correction = %+v, want unlocated", c)
+    }
+    // 未定位的修正不改变文本
+    if revision.MergedText != "Invoice INV-1001" {
+        t.Errorf("MergedText = %q", revision.MergedText)
+    }
+}
+
+func TestParseRevisionUncertainties(t *testing.T) {
+    revision := ParseRevision("合计 ￥1,375 签名 XyZ", "[UNCERTAIN: XyZ] [UNCERTAIN: \"￥1,375\"] [UNCERTAIN: missing]")
+    want := []models.Uncertainty{
+        {Text: "XyZ", Start: 13, End: 16},
+        {Text: "￥1,375", Start: 3, End: 9},
+        {Text: "missing", Start: -1, End: -1},
+    }
+    if len(revision.Uncertainties) != len(want) {
+        t.Fatalf("uncertainties = %+v", revision.Uncertainties)
+    }
+    for i, u := range revision.Uncertainties {
+        if u != want[i] {
+            t.Errorf("uncertainty %d = %+v, want %+v", i, u, want[i])
+        }
+    }
+    if len(revision.Corrections) != 0 || revision.MergedText != revision.OCRText {
+        t.Errorf("uncertainty markers changed the text: %+v", revision)
+    }
+}
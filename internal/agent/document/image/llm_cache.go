@@ -0,0 +1,103 @@
+package image
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "time"
+
+    "github.com/feichai0017/document-processor/pkg/cache"
+    "github.com/feichai0017/document-processor/pkg/logger"
+)
+
+// LLMCacheKey 决定 LLM 输出的全部输入，任一字段变化都会得到不同的缓存键
+type LLMCacheKey struct {
+    Operation     string      `json:"operation"` // correction、extraction 等
+    Images        []string    `json:"images"`    // 图像字节的 SHA-256
+    Model         string      `json:"model"`
+    PromptID      string      `json:"promptId"`
+    PromptVersion int         `json:"promptVersion"`
+    Options       interface{} `json:"options,omitempty"` // 其他影响输出的选项，如温度、Schema、文档文本摘要
+}
+
+// String 返回键的 SHA-256
+func (k LLMCacheKey) String() string {
+    data, _ := json.Marshal(k)
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
+
+// HashBytes 返回数据的 SHA-256
+func HashBytes(data []byte) string {
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
+
+// HashBase64 返回 base64 编码数据解码后的 SHA-256，解码失败时对原字符串计算
+func HashBase64(b64 string) string {
+    data, err := base64.StdEncoding.DecodeString(b64)
+    if err != nil {
+        data = []byte(b64)
+    }
+    return HashBytes(data)
+}
+
+// LLMCache LLM 输出缓存，缓存后端出错时只记录日志并视为未命中。
+// nil 的 *LLMCache 可以直接使用，表示不缓存
+type LLMCache struct {
+    store  cache.Cache
+    ttl    time.Duration
+    logger logger.Logger
+}
+
+func NewLLMCache(store cache.Cache, ttl time.Duration, log logger.Logger) *LLMCache {
+    return &LLMCache{
+        store:  store,
+        ttl:    ttl,
+        logger: log,
+    }
+}
+
+// Get 读取缓存并解码到 value，返回是否命中
+func (c *LLMCache) Get(ctx context.Context, key LLMCacheKey, value interface{}) bool {
+    if c == nil {
+        return false
+    }
+
+    data, ok, err := c.store.Get(ctx, c.key(key))
+    if err != nil {
+        c.logger.Warn("Failed to read LLM cache", logger.String("operation", key.Operation), logger.Error(err))
+        return false
+    }
+    if !ok {
+        return false
+    }
+
+    if err := json.Unmarshal(data, value); err != nil {
+        c.logger.Warn("Failed to decode LLM cache entry", logger.String("operation", key.Operation), logger.Error(err))
+        return false
+    }
+    return true
+}
+
+// Set 写入缓存
+func (c *LLMCache) Set(ctx context.Context, key LLMCacheKey, value interface{}) {
+    if c == nil {
+        return
+    }
+
+    data, err := json.Marshal(value)
+    if err != nil {
+        c.logger.Warn("Failed to encode LLM cache entry", logger.String("operation", key.Operation), logger.Error(err))
+        return
+    }
+    if err := c.store.Set(ctx, c.key(key), data, c.ttl); err != nil {
+        c.logger.Warn("Failed to write LLM cache", logger.String("operation", key.Operation), logger.Error(err))
+    }
+}
+
+func (c *LLMCache) key(key LLMCacheKey) string {
+    return "llm:" + key.Operation + ":" + key.String()
+}
@@ -0,0 +1,544 @@
+package image
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "expvar"
+    "fmt"
+    "image"
+    "net/http"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// ErrLLMUnavailable 所有实例都不健康或处于熔断状态，调用方应跳过 LLM 步骤
+var ErrLLMUnavailable = errors.New("vision llm unavailable: no healthy endpoint")
+
+var errPoolClosed = errors.New("vision llm pool is closed")
+
+type breakerState int
+
+const (
+    breakerClosed   breakerState = iota
+    breakerOpen                  // 熔断中，拒绝请求
+    breakerHalfOpen              // 冷却结束，只放行一个试探请求
+)
+
+func (s breakerState) String() string {
+    switch s {
+    case breakerOpen:
+        return "open"
+    case breakerHalfOpen:
+        return "half-open"
+    default:
+        return "closed"
+    }
+}
+
+// llmEndpoint 一个 LLM 实例及其熔断、健康状态
+type llmEndpoint struct {
+    url    string
+    client VisionLLM
+
+    mu                  sync.Mutex
+    state               breakerState
+    consecutiveFailures int
+    openedAt            time.Time
+    trialInFlight       bool
+    healthy             bool
+    lastError           string
+    lastCheck           time.Time
+
+    inFlight int64
+    requests int64
+    failures int64
+    retries  int64
+}
+
+// allow 判断是否可以向该实例发送请求，半开状态下会占用唯一的试探名额
+func (e *llmEndpoint) allow(cooldown time.Duration) bool {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+
+    if !e.healthy {
+        return false
+    }
+
+    switch e.state {
+    case breakerOpen:
+        if time.Since(e.openedAt) < cooldown {
+            return false
+        }
+        e.state = breakerHalfOpen
+        e.trialInFlight = true
+        return true
+    case breakerHalfOpen:
+        if e.trialInFlight {
+            return false
+        }
+        e.trialInFlight = true
+        return true
+    default:
+        return true
+    }
+}
+
+// available 与 allow 相同但不修改状态
+func (e *llmEndpoint) available(cooldown time.Duration) bool {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+
+    if !e.healthy {
+        return false
+    }
+    switch e.state {
+    case breakerOpen:
+        return time.Since(e.openedAt) >= cooldown
+    case breakerHalfOpen:
+        return !e.trialInFlight
+    default:
+        return true
+    }
+}
+
+// record 记录请求结果，counted 为 false 的错误（如请求本身无效、调用方取消）不计入熔断
+func (e *llmEndpoint) record(err error, counted bool, threshold int) {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+
+    e.trialInFlight = false
+
+    if err == nil {
+        e.state = breakerClosed
+        e.consecutiveFailures = 0
+        return
+    }
+
+    e.lastError = err.Error()
+    if !counted {
+        return
+    }
+
+    atomic.AddInt64(&e.failures, 1)
+    e.consecutiveFailures++
+    if e.state == breakerHalfOpen || e.consecutiveFailures >= threshold {
+        e.state = breakerOpen
+        e.openedAt = time.Now()
+    }
+}
+
+func (e *llmEndpoint) setHealth(err error) {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+
+    e.healthy = err == nil
+    e.lastCheck = time.Now()
+    if err != nil {
+        e.lastError = err.Error()
+    }
+}
+
+// VisionLLMPool 多个 LLM 实例的客户端，按轮询在健康且未熔断的实例间分配请求，
+// 失败时按指数退避换实例重试，并限制总并发数。本身也实现 VisionLLM
+type VisionLLMPool struct {
+    config    *OllamaConfig
+    endpoints []*llmEndpoint
+    slots     chan struct{}
+    next      uint64
+    waiting   int64
+
+    stop      chan struct{}
+    closeOnce sync.Once
+}
+
+// defaultFailureThreshold 未配置熔断阈值时使用，避免第一次失败就熔断
+const defaultFailureThreshold = 3
+
+func NewVisionLLMPool(config *OllamaConfig) (*VisionLLMPool, error) {
+    if config.FailureThreshold <= 0 {
+        withDefaults := *config
+        withDefaults.FailureThreshold = defaultFailureThreshold
+        config = &withDefaults
+    }
+
+    urls := config.Endpoints
+    if len(urls) == 0 {
+        urls = []string{config.Endpoint}
+    }
+
+    size := config.MaxPoolSize
+    if size <= 0 {
+        size = 1
+    }
+
+    pool := &VisionLLMPool{
+        config: config,
+        slots:  make(chan struct{}, size),
+        stop:   make(chan struct{}),
+    }
+
+    for _, url := range urls {
+        endpointConfig := *config
+        endpointConfig.Endpoint = url
+        client, err := NewVisionLLM(&endpointConfig)
+        if err != nil {
+            return nil, err
+        }
+        pool.endpoints = append(pool.endpoints, &llmEndpoint{
+            url:     url,
+            client:  client,
+            healthy: true, // 首次探测前视为健康
+        })
+    }
+
+    if config.HealthCheckInterval > 0 {
+        go pool.healthLoop(config.HealthCheckInterval)
+    }
+    registerPool(pool)
+
+    return pool, nil
+}
+
+// AnalyzeImage 将图像和提示词发送给可用实例
+func (p *VisionLLMPool) AnalyzeImage(ctx context.Context, img image.Image, prompt string) (string, error) {
+    var result string
+    err := p.do(ctx, func(client VisionLLM) error {
+        var err error
+        result, err = client.AnalyzeImage(ctx, img, prompt)
+        return err
+    })
+    return result, err
+}
+
+// AnalyzeImageStream 使用流式响应分析图像，已经回调过内容后失败不再重试，避免重复输出
+func (p *VisionLLMPool) AnalyzeImageStream(ctx context.Context, img image.Image, prompt string, onToken func(string)) (string, error) {
+    var result string
+    err := p.do(ctx, func(client VisionLLM) error {
+        started := false
+        var err error
+        result, err = client.AnalyzeImageStream(ctx, img, prompt, func(token string) {
+            started = true
+            if onToken != nil {
+                onToken(token)
+            }
+        })
+        if err != nil && started {
+            return &streamError{err: err}
+        }
+        return err
+    })
+    return result, err
+}
+
+// Chat 发送多轮对话
+func (p *VisionLLMPool) Chat(ctx context.Context, messages []ChatMessage, format json.RawMessage) (string, error) {
+    var result string
+    err := p.do(ctx, func(client VisionLLM) error {
+        var err error
+        result, err = client.Chat(ctx, messages, format)
+        return err
+    })
+    return result, err
+}
+
+// Ping 任一实例可用即返回 nil
+func (p *VisionLLMPool) Ping(ctx context.Context) error {
+    var lastErr error
+    for _, e := range p.endpoints {
+        if lastErr = e.client.Ping(ctx); lastErr == nil {
+            return nil
+        }
+    }
+    return lastErr
+}
+
+// Available 是否有实例可以接收请求
+func (p *VisionLLMPool) Available() bool {
+    for _, e := range p.endpoints {
+        if e.available(p.config.BreakerCooldown) {
+            return true
+        }
+    }
+    return false
+}
+
+func (p *VisionLLMPool) Close() error {
+    p.closeOnce.Do(func() {
+        close(p.stop)
+        unregisterPool(p)
+        for _, e := range p.endpoints {
+            e.client.Close()
+        }
+    })
+    return nil
+}
+
+func (p *VisionLLMPool) closed() bool {
+    select {
+    case <-p.stop:
+        return true
+    default:
+        return false
+    }
+}
+
+// do 获取并发额度后执行请求，可重试的错误换实例重试
+func (p *VisionLLMPool) do(ctx context.Context, call func(VisionLLM) error) error {
+    if p.closed() {
+        return errPoolClosed
+    }
+
+    release, err := p.acquire(ctx)
+    if err != nil {
+        return err
+    }
+    defer release()
+
+    tried := make(map[*llmEndpoint]bool, len(p.endpoints))
+    var lastErr error
+    for attempt := 0; attempt <= p.config.MaxRetries; attempt++ {
+        if attempt > 0 {
+            select {
+            case <-time.After(p.config.RetryBackoff << (attempt - 1)):
+            case <-ctx.Done():
+                return ctx.Err()
+            }
+        }
+
+        endpoint := p.pick(tried)
+        if endpoint == nil {
+            if lastErr != nil {
+                return fmt.Errorf("%w (last error: %v)", ErrLLMUnavailable, lastErr)
+            }
+            return ErrLLMUnavailable
+        }
+        tried[endpoint] = true
+
+        if attempt > 0 {
+            atomic.AddInt64(&endpoint.retries, 1)
+        }
+        atomic.AddInt64(&endpoint.requests, 1)
+        atomic.AddInt64(&endpoint.inFlight, 1)
+        err := call(endpoint.client)
+        atomic.AddInt64(&endpoint.inFlight, -1)
+
+        if err == nil {
+            endpoint.record(nil, true, p.config.FailureThreshold)
+            return nil
+        }
+
+        // 调用方取消或超时不是实例的问题
+        if ctx.Err() != nil {
+            endpoint.record(err, false, p.config.FailureThreshold)
+            return err
+        }
+
+        retry, counted := classifyLLMError(err)
+        endpoint.record(err, counted, p.config.FailureThreshold)
+        if !retry {
+            return err
+        }
+        lastErr = err
+    }
+
+    return fmt.Errorf("vision llm request failed after %d attempts: %w", p.config.MaxRetries+1, lastErr)
+}
+
+// acquire 等待并发额度，超过 PoolTimeout 返回错误
+func (p *VisionLLMPool) acquire(ctx context.Context) (func(), error) {
+    release := func() { <-p.slots }
+
+    select {
+    case p.slots <- struct{}{}:
+        return release, nil
+    default:
+    }
+
+    atomic.AddInt64(&p.waiting, 1)
+    defer atomic.AddInt64(&p.waiting, -1)
+
+    var timeout <-chan time.Time
+    if p.config.PoolTimeout > 0 {
+        timer := time.NewTimer(p.config.PoolTimeout)
+        defer timer.Stop()
+        timeout = timer.C
+    }
+
+    select {
+    case p.slots <- struct{}{}:
+        return release, nil
+    case <-timeout:
+        return nil, fmt.Errorf("timeout waiting for available client")
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    case <-p.stop:
+        return nil, errPoolClosed
+    }
+}
+
+// pick 轮询选择可用实例，优先选择本次请求还没试过的实例
+func (p *VisionLLMPool) pick(tried map[*llmEndpoint]bool) *llmEndpoint {
+    n := len(p.endpoints)
+    start := int(atomic.AddUint64(&p.next, 1) - 1)
+
+    for _, allowTried := range []bool{false, true} {
+        for i := 0; i < n; i++ {
+            e := p.endpoints[(start+i)%n]
+            if tried[e] != allowTried {
+                continue
+            }
+            if e.allow(p.config.BreakerCooldown) {
+                return e
+            }
+        }
+    }
+    return nil
+}
+
+func (p *VisionLLMPool) healthLoop(interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        p.checkHealth(interval)
+        select {
+        case <-p.stop:
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+func (p *VisionLLMPool) checkHealth(timeout time.Duration) {
+    if timeout > 10*time.Second {
+        timeout = 10 * time.Second
+    }
+
+    var wg sync.WaitGroup
+    for _, e := range p.endpoints {
+        wg.Add(1)
+        go func(e *llmEndpoint) {
+            defer wg.Done()
+            ctx, cancel := context.WithTimeout(context.Background(), timeout)
+            defer cancel()
+            e.setHealth(e.client.Ping(ctx))
+        }(e)
+    }
+    wg.Wait()
+}
+
+// streamError 流式响应已输出部分内容后的失败，不能重试
+type streamError struct {
+    err error
+}
+
+func (e *streamError) Error() string { return e.err.Error() }
+func (e *streamError) Unwrap() error { return e.err }
+
+// classifyLLMError 判断错误是否可以重试、是否计入实例的熔断计数。
+// 4xx（408、429 除外）说明请求本身有问题，换实例也不会成功
+func classifyLLMError(err error) (retry bool, counted bool) {
+    var streamErr *streamError
+    if errors.As(err, &streamErr) {
+        return false, true
+    }
+
+    var statusErr *StatusError
+    if errors.As(err, &statusErr) {
+        switch {
+        case statusErr.StatusCode == http.StatusRequestTimeout,
+            statusErr.StatusCode == http.StatusTooManyRequests:
+            return true, true
+        case statusErr.StatusCode >= 400 && statusErr.StatusCode < 500:
+            return false, false
+        }
+    }
+
+    return true, true
+}
+
+// LLMPoolStats 连接池状态，通过 expvar 的 vision_llm_pools 暴露
+type LLMPoolStats struct {
+    MaxConcurrent int                `json:"maxConcurrent"`
+    InUse         int                `json:"inUse"`
+    Waiting       int64              `json:"waiting"`
+    Available     bool               `json:"available"`
+    Endpoints     []LLMEndpointStats `json:"endpoints"`
+}
+
+type LLMEndpointStats struct {
+    URL                 string    `json:"url"`
+    Healthy             bool      `json:"healthy"`
+    Breaker             string    `json:"breaker"`
+    ConsecutiveFailures int       `json:"consecutiveFailures"`
+    InFlight            int64     `json:"inFlight"`
+    Requests            int64     `json:"requests"`
+    Failures            int64     `json:"failures"`
+    Retries             int64     `json:"retries"`
+    LastError           string    `json:"lastError,omitempty"`
+    LastCheck           time.Time `json:"lastCheck,omitempty"`
+}
+
+// Stats 返回当前状态快照
+func (p *VisionLLMPool) Stats() LLMPoolStats {
+    stats := LLMPoolStats{
+        MaxConcurrent: cap(p.slots),
+        InUse:         len(p.slots),
+        Waiting:       atomic.LoadInt64(&p.waiting),
+        Available:     p.Available(),
+        Endpoints:     make([]LLMEndpointStats, 0, len(p.endpoints)),
+    }
+
+    for _, e := range p.endpoints {
+        e.mu.Lock()
+        stats.Endpoints = append(stats.Endpoints, LLMEndpointStats{
+            URL:                 e.url,
+            Healthy:             e.healthy,
+            Breaker:             e.state.String(),
+            ConsecutiveFailures: e.consecutiveFailures,
+            InFlight:            atomic.LoadInt64(&e.inFlight),
+            Requests:            atomic.LoadInt64(&e.requests),
+            Failures:            atomic.LoadInt64(&e.failures),
+            Retries:             atomic.LoadInt64(&e.retries),
+            LastError:           e.lastError,
+            LastCheck:           e.lastCheck,
+        })
+        e.mu.Unlock()
+    }
+
+    return stats
+}
+
+var (
+    poolsMu     sync.Mutex
+    pools       = make(map[*VisionLLMPool]struct{})
+    publishOnce sync.Once
+)
+
+// registerPool 将连接池加入 expvar 指标，进程内所有连接池发布在同一个变量下
+func registerPool(p *VisionLLMPool) {
+    publishOnce.Do(func() {
+        expvar.Publish("vision_llm_pools", expvar.Func(func() interface{} {
+            poolsMu.Lock()
+            defer poolsMu.Unlock()
+
+            stats := make([]LLMPoolStats, 0, len(pools))
+            for pool := range pools {
+                stats = append(stats, pool.Stats())
+            }
+            return stats
+        }))
+    })
+
+    poolsMu.Lock()
+    pools[p] = struct{}{}
+    poolsMu.Unlock()
+}
+
+func unregisterPool(p *VisionLLMPool) {
+    poolsMu.Lock()
+    delete(pools, p)
+    poolsMu.Unlock()
+}
@@ -0,0 +1,58 @@
+package image
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/feichai0017/document-processor/internal/testutil/fakeollama"
+)
+
+func newFakePool(t *testing.T, config *OllamaConfig) (*VisionLLMPool, *fakeollama.Server) {
+    t.Helper()
+
+    server := fakeollama.NewServer()
+    t.Cleanup(server.Close)
+
+    config.Endpoint = server.URL()
+    config.Model = "llama3.2-vision"
+    pool, err := NewVisionLLMPool(config)
+    if err != nil {
+        t.Fatalf("NewVisionLLMPool() error = %v", err)
+    }
+    t.Cleanup(func() { pool.Close() })
+    return pool, server
+}
+
+func TestVisionLLMPoolDefaultsFailureThreshold(t *testing.T) {
+    pool, server := newFakePool(t, &OllamaConfig{BreakerCooldown: time.Minute})
+    server.FailNext(1)
+
+    if _, err := pool.Chat(context.Background(), []ChatMessage{{Role: "user", Content: "hi"}}, nil); err == nil {
+        t.Fatal("Chat() error = nil, want 503")
+    }
+    if !pool.Available() {
+        t.Fatal("breaker opened on the first failure")
+    }
+}
+
+func TestVisionLLMPoolUnavailableAfterThreshold(t *testing.T) {
+    pool, server := newFakePool(t, &OllamaConfig{FailureThreshold: 2, BreakerCooldown: time.Minute})
+    server.FailNext(2)
+
+    for i := 0; i < 2; i++ {
+        pool.Chat(context.Background(), []ChatMessage{{Role: "user", Content: "hi"}}, nil)
+    }
+    if pool.Available() {
+        t.Fatal("breaker is still closed after reaching the threshold")
+    }
+
+    _, err := pool.Chat(context.Background(), []ChatMessage{{Role: "user", Content: "hi"}}, nil)
+    if !errors.Is(err, ErrLLMUnavailable) {
+        t.Fatalf("Chat() error = %v, want ErrLLMUnavailable", err)
+    }
+    if got := len(server.Requests()); got != 2 {
+        t.Errorf("open breaker still sent requests: got %d, want 2", got)
+    }
+}
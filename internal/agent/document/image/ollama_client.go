@@ -3,91 +3,97 @@ package image
 import (
     "bytes"
     "context"
-    "encoding/base64"
     "encoding/json"
     "fmt"
     "image"
-    "image/jpeg"
     "io"
     "net/http"
-    "time"
+    "strings"
 )
 
-// OllamaResponse 定义 Ollama API 响应结构
+// OllamaOptions 定义模型参数，Ollama 只读取 options 中的采样参数
+type OllamaOptions struct {
+    NumPredict  int     `json:"num_predict,omitempty"`
+    Temperature float64 `json:"temperature"`
+}
+
+// OllamaChatRequest 定义 /api/chat 请求结构
+type OllamaChatRequest struct {
+    Model    string          `json:"model"`
+    Messages []ChatMessage `json:"messages"`
+    Stream   bool            `json:"stream"`
+    Format   json.RawMessage `json:"format,omitempty"`
+    Options  OllamaOptions   `json:"options"`
+}
+
+// OllamaResponse 定义 /api/chat 响应结构，流式响应的每一行也是该结构
 type OllamaResponse struct {
-    Response    string    `json:"response"`
-    Created     int64     `json:"created"`
-    Model       string    `json:"model"`
-    Done        bool      `json:"done"`
-    Context     []int     `json:"context,omitempty"`
-    TotalDuration int64   `json:"total_duration,omitempty"`
-    LoadDuration  int64   `json:"load_duration,omitempty"`
-    PromptEvalCount int   `json:"prompt_eval_count,omitempty"`
-    EvalCount    int      `json:"eval_count,omitempty"`
-    EvalDuration int64    `json:"eval_duration,omitempty"`
-    Error        string   `json:"error,omitempty"`
+    Model           string        `json:"model"`
+    CreatedAt       string        `json:"created_at"`
+    Message         ChatMessage `json:"message"`
+    Done            bool          `json:"done"`
+    DoneReason      string        `json:"done_reason,omitempty"`
+    TotalDuration   int64         `json:"total_duration,omitempty"`
+    LoadDuration    int64         `json:"load_duration,omitempty"`
+    PromptEvalCount int           `json:"prompt_eval_count,omitempty"`
+    EvalCount       int           `json:"eval_count,omitempty"`
+    EvalDuration    int64         `json:"eval_duration,omitempty"`
+    Error           string        `json:"error,omitempty"`
 }
 
 type OllamaClient struct {
-    endpoint    string
-    model       string
-    maxTokens   int
-    temperature float64
-    httpClient  *http.Client
+    endpoint     string
+    model        string
+    maxTokens    int
+    temperature  float64
+    stream       bool
+    httpClient   *http.Client
+    streamClient *http.Client // 流式请求不设置整体超时
 }
 
 func NewOllamaClient(config *OllamaConfig) *OllamaClient {
-    return &OllamaClient{
-        endpoint:    config.Endpoint,
+    c := &OllamaClient{
+        endpoint:    strings.TrimRight(config.Endpoint, "/"),
         model:       config.Model,
         maxTokens:   config.MaxTokens,
         temperature: config.Temperature,
-        httpClient: &http.Client{
-            Timeout: 120 * time.Second,
-        },
+        stream:      config.Stream,
     }
+    c.httpClient, c.streamClient = newHTTPClients(config)
+    return c
 }
 
+// AnalyzeImage 将图像和提示词发送给视觉模型，按配置决定是否使用流式响应
 func (c *OllamaClient) AnalyzeImage(ctx context.Context, img image.Image, prompt string) (string, error) {
-    // 将图像转换为 base64
-    buf := new(bytes.Buffer)
-    if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 85}); err != nil {
-        return "", fmt.Errorf("failed to encode image: %w", err)
-    }
-    base64Img := base64.StdEncoding.EncodeToString(buf.Bytes())
-
-    // 准备请求体
-    reqBody := map[string]interface{}{
-        "model":       c.model,
-        "prompt":      fmt.Sprintf("[img]%s[/img]\n%s", base64Img, prompt),
-        "stream":      false,
-        "raw":         true,
-        "max_tokens":  c.maxTokens,
-        "temperature": c.temperature,
+    message, err := NewImageMessage(img, prompt)
+    if err != nil {
+        return "", err
     }
+    return c.Chat(ctx, []ChatMessage{message}, nil)
+}
 
-    reqData, err := json.Marshal(reqBody)
+// AnalyzeImageStream 使用流式响应分析图像，onToken 不为空时逐段回调生成的内容
+func (c *OllamaClient) AnalyzeImageStream(ctx context.Context, img image.Image, prompt string, onToken func(string)) (string, error) {
+    message, err := NewImageMessage(img, prompt)
     if err != nil {
-        return "", fmt.Errorf("failed to marshal request: %w", err)
+        return "", err
     }
+    return c.chatStream(ctx, c.newChatRequest([]ChatMessage{message}, nil, true), onToken)
+}
 
-    req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint+"/api/generate", bytes.NewReader(reqData))
-    if err != nil {
-        return "", fmt.Errorf("failed to create request: %w", err)
+// Chat 发送多轮对话，format 不为空时要求模型按该 JSON Schema（或 "json"）输出
+func (c *OllamaClient) Chat(ctx context.Context, messages []ChatMessage, format json.RawMessage) (string, error) {
+    req := c.newChatRequest(messages, format, c.stream)
+    if c.stream {
+        return c.chatStream(ctx, req, nil)
     }
-    req.Header.Set("Content-Type", "application/json")
 
-    resp, err := c.httpClient.Do(req)
+    resp, err := c.doChat(ctx, req)
     if err != nil {
-        return "", fmt.Errorf("failed to send request: %w", err)
+        return "", err
     }
     defer resp.Body.Close()
 
-    if resp.StatusCode != http.StatusOK {
-        body, _ := io.ReadAll(resp.Body)
-        return "", fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
-    }
-
     var result OllamaResponse
     if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
         return "", fmt.Errorf("failed to decode response: %w", err)
@@ -97,57 +103,96 @@ func (c *OllamaClient) AnalyzeImage(ctx context.Context, img image.Image, prompt
         return "", fmt.Errorf("ollama error: %s", result.Error)
     }
 
-    return result.Response, nil
+    return result.Message.Content, nil
 }
 
-func (c *OllamaClient) Close() error {
-    c.httpClient.CloseIdleConnections()
-    return nil
+// chatStream 读取 NDJSON 流式响应，每行一个 OllamaResponse
+func (c *OllamaClient) chatStream(ctx context.Context, req *OllamaChatRequest, onToken func(string)) (string, error) {
+    resp, err := c.doChat(ctx, req)
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+
+    var content strings.Builder
+    decoder := json.NewDecoder(resp.Body)
+    for {
+        var chunk OllamaResponse
+        if err := decoder.Decode(&chunk); err != nil {
+            if err == io.EOF {
+                return "", fmt.Errorf("stream ended before completion")
+            }
+            return "", fmt.Errorf("failed to decode stream: %w", err)
+        }
+
+        if chunk.Error != "" {
+            return "", fmt.Errorf("ollama error: %s", chunk.Error)
+        }
+
+        if chunk.Message.Content != "" {
+            content.WriteString(chunk.Message.Content)
+            if onToken != nil {
+                onToken(chunk.Message.Content)
+            }
+        }
+
+        if chunk.Done {
+            return content.String(), nil
+        }
+    }
 }
 
-type OllamaClientPool struct {
-    clients chan *OllamaClient
-    config  *OllamaConfig
+// newChatRequest 构建 /api/chat 请求
+func (c *OllamaClient) newChatRequest(messages []ChatMessage, format json.RawMessage, stream bool) *OllamaChatRequest {
+    return &OllamaChatRequest{
+        Model:    c.model,
+        Messages: messages,
+        Stream:   stream,
+        Format:   format,
+        Options: OllamaOptions{
+            NumPredict:  c.maxTokens,
+            Temperature: c.temperature,
+        },
+    }
 }
 
-func NewOllamaClientPool(config *OllamaConfig) *OllamaClientPool {
-    pool := &OllamaClientPool{
-        clients: make(chan *OllamaClient, config.MaxPoolSize),
-        config:  config,
+// doChat 发送 /api/chat 请求，调用方负责关闭响应体
+func (c *OllamaClient) doChat(ctx context.Context, chatReq *OllamaChatRequest) (*http.Response, error) {
+    reqData, err := json.Marshal(chatReq)
+    if err != nil {
+        return nil, fmt.Errorf("failed to marshal request: %w", err)
     }
-    
-    // 预创建客户端
-    for i := 0; i < config.MaxPoolSize; i++ {
-        pool.clients <- NewOllamaClient(config)
+
+    req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint+"/api/chat", bytes.NewReader(reqData))
+    if err != nil {
+        return nil, fmt.Errorf("failed to create request: %w", err)
     }
-    
-    return pool
-}
+    req.Header.Set("Content-Type", "application/json")
 
-func (p *OllamaClientPool) Get(ctx context.Context) (*OllamaClient, error) {
-    select {
-    case client := <-p.clients:
-        return client, nil
-    case <-time.After(p.config.PoolTimeout):
-        return nil, fmt.Errorf("timeout waiting for available client")
-    case <-ctx.Done():
-        return nil, ctx.Err()
+    client := c.httpClient
+    if chatReq.Stream {
+        client = c.streamClient
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("failed to send request: %w", err)
     }
-}
 
-func (p *OllamaClientPool) Put(client *OllamaClient) {
-    select {
-    case p.clients <- client:
-    default:
-        // 池已满，丢弃客户端
+    if resp.StatusCode != http.StatusOK {
+        defer resp.Body.Close()
+        body, _ := io.ReadAll(resp.Body)
+        return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
     }
+
+    return resp, nil
 }
 
-func (p *OllamaClientPool) Close() error {
-    close(p.clients)
-    // 关闭所有客户端
-    for client := range p.clients {
-        client.Close()
-    }
+// Ping 通过 /api/tags 检查实例是否可用
+func (c *OllamaClient) Ping(ctx context.Context) error {
+    return ping(ctx, c.httpClient, c.endpoint+"/api/tags", "")
+}
+
+func (c *OllamaClient) Close() error {
+    c.httpClient.CloseIdleConnections()
     return nil
-} 
\ No newline at end of file
+}
@@ -0,0 +1,169 @@
+package image
+
+import (
+    "bytes"
+    "context"
+    "encoding/base64"
+    "errors"
+    "image"
+    "image/color"
+    "image/jpeg"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/feichai0017/document-processor/internal/testutil/fakeollama"
+)
+
+func newTestImage() image.Image {
+    img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+    img.Set(1, 1, color.White)
+    return img
+}
+
+func newFakeOllama(t *testing.T, config *OllamaConfig) (*OllamaClient, *fakeollama.Server) {
+    t.Helper()
+
+    server := fakeollama.NewServer()
+    t.Cleanup(server.Close)
+
+    config.Endpoint = server.URL()
+    if config.Model == "" {
+        config.Model = "llama3.2-vision"
+    }
+    client := NewOllamaClient(config)
+    t.Cleanup(func() { client.Close() })
+    return client, server
+}
+
+func TestOllamaChatSendsImagesAndOptions(t *testing.T) {
+    client, server := newFakeOllama(t, &OllamaConfig{MaxTokens: 512, Temperature: 0.2})
+    server.SetReply(func(req fakeollama.ChatRequest) string { return "Invoice INV-1001" })
+
+    got, err := client.AnalyzeImage(context.Background(), newTestImage(), "Read the text")
+    if err != nil {
+        t.Fatalf("AnalyzeImage() error = %v", err)
+    }
+    if got != "Invoice INV-1001" {
+        t.Errorf("AnalyzeImage() = %q", got)
+    }
+
+    requests := server.Requests()
+    if len(requests) != 1 {
+        t.Fatalf("got %d requests, want 1", len(requests))
+    }
+    req := requests[0]
+    if req.Model != "llama3.2-vision" {
+        t.Errorf("model = %q", req.Model)
+    }
+    if req.Stream == nil || *req.Stream {
+        t.Errorf("stream = %v, want false", req.Stream)
+    }
+    if len(req.Messages) != 1 || req.Messages[0].Role != "user" || req.Messages[0].Content != "Read the text" {
+        t.Fatalf("messages = %+v", req.Messages)
+    }
+
+    // 图像通过 images 字段发送，而不是嵌入提示词
+    if len(req.Messages[0].Images) != 1 {
+        t.Fatalf("got %d images, want 1", len(req.Messages[0].Images))
+    }
+    data, err := base64.StdEncoding.DecodeString(req.Messages[0].Images[0])
+    if err != nil {
+        t.Fatalf("image is not base64: %v", err)
+    }
+    if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+        t.Errorf("image is not a jpeg: %v", err)
+    }
+
+    // 采样参数在 options 中
+    if req.Options["num_predict"] != float64(512) || req.Options["temperature"] != 0.2 {
+        t.Errorf("options = %v", req.Options)
+    }
+}
+
+func TestOllamaChatFormat(t *testing.T) {
+    client, server := newFakeOllama(t, &OllamaConfig{})
+    server.SetReply(func(req fakeollama.ChatRequest) string { return `{"total": 12}` })
+
+    messages := []ChatMessage{
+        {Role: "system", Content: "Extract fields"},
+        {Role: "user", Content: "Total: 12"},
+    }
+    got, err := client.Chat(context.Background(), messages, []byte(`{"type":"object"}`))
+    if err != nil {
+        t.Fatalf("Chat() error = %v", err)
+    }
+    if got != `{"total": 12}` {
+        t.Errorf("Chat() = %q", got)
+    }
+
+    req := server.Requests()[0]
+    if string(req.Format) != `{"type":"object"}` {
+        t.Errorf("format = %s", req.Format)
+    }
+    if len(req.Messages) != 2 || req.Messages[0].Role != "system" {
+        t.Errorf("messages = %+v", req.Messages)
+    }
+}
+
+func TestOllamaStream(t *testing.T) {
+    client, server := newFakeOllama(t, &OllamaConfig{})
+    server.SetReply(func(req fakeollama.ChatRequest) string { return "ACME Corporation total 1375" })
+
+    var tokens []string
+    got, err := client.AnalyzeImageStream(context.Background(), newTestImage(), "Read the text", func(token string) {
+        tokens = append(tokens, token)
+    })
+    if err != nil {
+        t.Fatalf("AnalyzeImageStream() error = %v", err)
+    }
+    if got != "ACME Corporation total 1375" {
+        t.Errorf("AnalyzeImageStream() = %q", got)
+    }
+    if len(tokens) != 4 || strings.Join(tokens, "") != got {
+        t.Errorf("tokens = %q", tokens)
+    }
+    if req := server.Requests()[0]; req.Stream == nil || !*req.Stream {
+        t.Errorf("stream = %v, want true", req.Stream)
+    }
+}
+
+func TestOllamaStreamOutlivesRequestTimeout(t *testing.T) {
+    // 整个生成过程比 RequestTimeout 长，但每段都在超时内到达
+    client, server := newFakeOllama(t, &OllamaConfig{Stream: true, RequestTimeout: 100 * time.Millisecond})
+    server.SetReply(func(req fakeollama.ChatRequest) string { return "one two three four five" })
+    server.SetDelay(40 * time.Millisecond)
+
+    got, err := client.Chat(context.Background(), []ChatMessage{{Role: "user", Content: "count"}}, nil)
+    if err != nil {
+        t.Fatalf("Chat() error = %v", err)
+    }
+    if got != "one two three four five" {
+        t.Errorf("Chat() = %q", got)
+    }
+}
+
+func TestOllamaStreamCancelledByContext(t *testing.T) {
+    client, server := newFakeOllama(t, &OllamaConfig{Stream: true})
+    server.SetReply(func(req fakeollama.ChatRequest) string { return "one two three four five" })
+    server.SetDelay(50 * time.Millisecond)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 80*time.Millisecond)
+    defer cancel()
+    _, err := client.Chat(ctx, []ChatMessage{{Role: "user", Content: "count"}}, nil)
+    if !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("Chat() error = %v, want deadline exceeded", err)
+    }
+}
+
+func TestOllamaStatusError(t *testing.T) {
+    client, server := newFakeOllama(t, &OllamaConfig{})
+    server.FailNext(1)
+
+    _, err := client.Chat(context.Background(), []ChatMessage{{Role: "user", Content: "hi"}}, nil)
+    var statusErr *StatusError
+    if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+        t.Fatalf("Chat() error = %v, want 503 status error", err)
+    }
+}
@@ -0,0 +1,284 @@
+package image
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "image"
+    "io"
+    "net/http"
+    "strings"
+)
+
+// OpenAIContentPart 多模态消息的内容片段
+type OpenAIContentPart struct {
+    Type     string          `json:"type"` // text 或 image_url
+    Text     string          `json:"text,omitempty"`
+    ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+}
+
+type OpenAIImageURL struct {
+    URL string `json:"url"` // data:image/jpeg;base64,...
+}
+
+// OpenAIMessage content 为字符串或 []OpenAIContentPart
+type OpenAIMessage struct {
+    Role    string      `json:"role"`
+    Content interface{} `json:"content"`
+}
+
+// OpenAIResponseFormat 对应 response_format，json_schema 需要服务端支持结构化输出
+type OpenAIResponseFormat struct {
+    Type       string            `json:"type"` // json_object 或 json_schema
+    JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type OpenAIJSONSchema struct {
+    Name   string          `json:"name"`
+    Schema json.RawMessage `json:"schema"`
+}
+
+// OpenAIChatRequest 定义 /chat/completions 请求结构
+type OpenAIChatRequest struct {
+    Model          string                `json:"model"`
+    Messages       []OpenAIMessage       `json:"messages"`
+    MaxTokens      int                   `json:"max_tokens,omitempty"`
+    Temperature    float64               `json:"temperature"`
+    Stream         bool                  `json:"stream"`
+    ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
+}
+
+// OpenAIResponse 定义 /chat/completions 响应结构，流式响应中使用 Delta
+type OpenAIResponse struct {
+    ID      string `json:"id"`
+    Model   string `json:"model"`
+    Choices []struct {
+        Index        int           `json:"index"`
+        Message      OpenAIMessage `json:"message"`
+        Delta        OpenAIMessage `json:"delta"`
+        FinishReason string        `json:"finish_reason"`
+    } `json:"choices"`
+    Error *struct {
+        Message string `json:"message"`
+        Type    string `json:"type"`
+    } `json:"error,omitempty"`
+}
+
+// OpenAIClient OpenAI 兼容接口（vLLM、LM Studio 等）的视觉 LLM 客户端
+type OpenAIClient struct {
+    baseURL      string
+    apiKey       string
+    model        string
+    maxTokens    int
+    temperature  float64
+    stream       bool
+    httpClient   *http.Client
+    streamClient *http.Client // 流式请求不设置整体超时
+}
+
+func NewOpenAIClient(config *OllamaConfig) *OpenAIClient {
+    c := &OpenAIClient{
+        baseURL:     strings.TrimRight(config.Endpoint, "/"),
+        apiKey:      config.APIKey,
+        model:       config.Model,
+        maxTokens:   config.MaxTokens,
+        temperature: config.Temperature,
+        stream:      config.Stream,
+    }
+    c.httpClient, c.streamClient = newHTTPClients(config)
+    return c
+}
+
+// AnalyzeImage 将图像和提示词发送给视觉模型，按配置决定是否使用流式响应
+func (c *OpenAIClient) AnalyzeImage(ctx context.Context, img image.Image, prompt string) (string, error) {
+    message, err := NewImageMessage(img, prompt)
+    if err != nil {
+        return "", err
+    }
+    return c.Chat(ctx, []ChatMessage{message}, nil)
+}
+
+// AnalyzeImageStream 使用流式响应分析图像，onToken 不为空时逐段回调生成的内容
+func (c *OpenAIClient) AnalyzeImageStream(ctx context.Context, img image.Image, prompt string, onToken func(string)) (string, error) {
+    message, err := NewImageMessage(img, prompt)
+    if err != nil {
+        return "", err
+    }
+    return c.chatStream(ctx, c.newChatRequest([]ChatMessage{message}, nil, true), onToken)
+}
+
+// Chat 发送多轮对话，format 为 "json" 时使用 json_object，为 JSON Schema 时使用 json_schema
+func (c *OpenAIClient) Chat(ctx context.Context, messages []ChatMessage, format json.RawMessage) (string, error) {
+    req := c.newChatRequest(messages, format, c.stream)
+    if c.stream {
+        return c.chatStream(ctx, req, nil)
+    }
+
+    resp, err := c.doChat(ctx, req)
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+
+    var result OpenAIResponse
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return "", fmt.Errorf("failed to decode response: %w", err)
+    }
+
+    if result.Error != nil {
+        return "", fmt.Errorf("openai error: %s", result.Error.Message)
+    }
+    if len(result.Choices) == 0 {
+        return "", fmt.Errorf("openai response has no choices")
+    }
+
+    content, _ := result.Choices[0].Message.Content.(string)
+    return content, nil
+}
+
+// chatStream 读取 SSE 流式响应，每个 data 行一个 OpenAIResponse，以 [DONE] 结束
+func (c *OpenAIClient) chatStream(ctx context.Context, req *OpenAIChatRequest, onToken func(string)) (string, error) {
+    resp, err := c.doChat(ctx, req)
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+
+    var content strings.Builder
+    scanner := bufio.NewScanner(resp.Body)
+    scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if !strings.HasPrefix(line, "data:") {
+            continue
+        }
+        data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+        if data == "[DONE]" {
+            return content.String(), nil
+        }
+
+        var chunk OpenAIResponse
+        if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+            return "", fmt.Errorf("failed to decode stream: %w", err)
+        }
+        if chunk.Error != nil {
+            return "", fmt.Errorf("openai error: %s", chunk.Error.Message)
+        }
+
+        for _, choice := range chunk.Choices {
+            if token, _ := choice.Delta.Content.(string); token != "" {
+                content.WriteString(token)
+                if onToken != nil {
+                    onToken(token)
+                }
+            }
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return "", fmt.Errorf("failed to read stream: %w", err)
+    }
+
+    return "", fmt.Errorf("stream ended before completion")
+}
+
+// newChatRequest 构建 /chat/completions 请求，图像转换为 data URL 形式的 image_url
+func (c *OpenAIClient) newChatRequest(messages []ChatMessage, format json.RawMessage, stream bool) *OpenAIChatRequest {
+    req := &OpenAIChatRequest{
+        Model:       c.model,
+        Messages:    make([]OpenAIMessage, 0, len(messages)),
+        MaxTokens:   c.maxTokens,
+        Temperature: c.temperature,
+        Stream:      stream,
+    }
+
+    for _, m := range messages {
+        if len(m.Images) == 0 {
+            req.Messages = append(req.Messages, OpenAIMessage{Role: m.Role, Content: m.Content})
+            continue
+        }
+
+        parts := []OpenAIContentPart{{Type: "text", Text: m.Content}}
+        for _, img := range m.Images {
+            parts = append(parts, OpenAIContentPart{
+                Type:     "image_url",
+                ImageURL: &OpenAIImageURL{URL: imageDataURL(img)},
+            })
+        }
+        req.Messages = append(req.Messages, OpenAIMessage{Role: m.Role, Content: parts})
+    }
+
+    switch {
+    case len(format) == 0:
+    case string(format) == `"json"`:
+        req.ResponseFormat = &OpenAIResponseFormat{Type: "json_object"}
+    default:
+        req.ResponseFormat = &OpenAIResponseFormat{
+            Type:       "json_schema",
+            JSONSchema: &OpenAIJSONSchema{Name: "extraction", Schema: format},
+        }
+    }
+
+    return req
+}
+
+// doChat 发送 /chat/completions 请求，调用方负责关闭响应体
+func (c *OpenAIClient) doChat(ctx context.Context, chatReq *OpenAIChatRequest) (*http.Response, error) {
+    reqData, err := json.Marshal(chatReq)
+    if err != nil {
+        return nil, fmt.Errorf("failed to marshal request: %w", err)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewReader(reqData))
+    if err != nil {
+        return nil, fmt.Errorf("failed to create request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+    if c.apiKey != "" {
+        req.Header.Set("Authorization", "Bearer "+c.apiKey)
+    }
+
+    client := c.httpClient
+    if chatReq.Stream {
+        client = c.streamClient
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("failed to send request: %w", err)
+    }
+
+    if resp.StatusCode != http.StatusOK {
+        defer resp.Body.Close()
+        body, _ := io.ReadAll(resp.Body)
+        return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+    }
+
+    return resp, nil
+}
+
+// Ping 通过 /models 检查服务是否可用
+func (c *OpenAIClient) Ping(ctx context.Context) error {
+    return ping(ctx, c.httpClient, c.baseURL+"/models", c.apiKey)
+}
+
+func (c *OpenAIClient) Close() error {
+    c.httpClient.CloseIdleConnections()
+    return nil
+}
+
+// imageDataURL 将 base64 图像包装为 data URL，MIME 类型由图像头部检测
+func imageDataURL(b64 string) string {
+    head := b64
+    if len(head) > 64 {
+        head = head[:64]
+    }
+    mimeType := "image/jpeg"
+    if data, err := base64.StdEncoding.DecodeString(head); err == nil {
+        if detected := http.DetectContentType(data); strings.HasPrefix(detected, "image/") {
+            mimeType = detected
+        }
+    }
+    return fmt.Sprintf("data:%s;base64,%s", mimeType, b64)
+}
@@ -6,6 +6,7 @@ import (
     "context"
     "crypto/sha256"
     "encoding/hex"
+    "errors"
     "fmt"
     "image"
     "image/color"
@@ -18,6 +19,8 @@ import (
     "github.com/disintegration/imaging"
     "github.com/otiai10/gosseract/v2"
     
+    "github.com/feichai0017/document-processor/internal/agent/document"
+    "github.com/feichai0017/document-processor/internal/agent/prompts"
     "github.com/feichai0017/document-processor/internal/models"
     "github.com/feichai0017/document-processor/pkg/logger"
 )
@@ -27,7 +30,8 @@ type Processor struct {
     logger        logger.Logger
     preprocessors []ImagePreprocessor
     config        *ProcessOptions
-    ollamaPool    *OllamaClientPool
+    llmPool       *VisionLLMPool
+    ownsPool      bool // 连接池由处理器创建，关闭处理器时一并关闭
 }
 
 // 图像预处理接口
@@ -46,6 +50,9 @@ type ProcessOptions struct {
     OCRConfig     *OCRConfig
     OllamaConfig  *OllamaConfig
     TableConfig    *TableConfig
+    Prompts       *prompts.Registry // 提示词模板库，为空时使用内置模板
+    Cache         *LLMCache         // LLM 输出缓存，为空时不缓存
+    LLMPool       *VisionLLMPool    // 与其他 LLM 步骤共享的连接池，为空时按 OllamaConfig 创建
 }
 
 type OCRConfig struct {
@@ -70,15 +77,27 @@ type PreprocessConfig struct {
     GammaCorrection   float64
 }
 
+// OllamaConfig 视觉 LLM 配置，Backend 为 openai 时 Endpoint 为 OpenAI 兼容服务的
+// base URL（如 http://localhost:8000/v1），APIKey 可为空
 type OllamaConfig struct {
     Enabled     bool
+    Backend     LLMBackend    // ollama（默认）或 openai
     Endpoint    string
+    Endpoints   []string      // 多个实例时负载均衡，为空时使用 Endpoint
+    APIKey      string
     Model       string
     MaxTokens   int
     Temperature float64
-    Prompt      string
-    MaxPoolSize int           // 新增：连接池大小
-    PoolTimeout time.Duration // 新增：连接池超时时间
+    Stream      bool          // 使用流式响应
+    MaxPoolSize int           // 最大并发请求数
+    PoolTimeout time.Duration // 等待空闲并发额度的超时时间
+
+    RequestTimeout      time.Duration // 单次请求超时，默认 120s
+    MaxRetries          int           // 失败后换实例重试的次数
+    RetryBackoff        time.Duration // 重试退避基数，按 2 的指数增长
+    FailureThreshold    int           // 连续失败多少次后熔断该实例
+    BreakerCooldown     time.Duration // 熔断持续时间，之后放行一次试探请求
+    HealthCheckInterval time.Duration // 健康探测间隔，0 表示不探测
 }
 
 type TableConfig struct {
@@ -91,86 +110,65 @@ type TableConfig struct {
 }
 
 
-// 创建新的处理器
+// DefaultProcessOptions 返回默认的处理选项，调用方可以在此基础上修改
+func DefaultProcessOptions() *ProcessOptions {
+    return &ProcessOptions{
+        Language:      []string{"eng"},
+        DPI:          300,
+        PageSegMode:  gosseract.PSM_AUTO,
+        MinConfidence: 60.0,
+        PreprocessConfig: &PreprocessConfig{
+            AdaptiveBlockSize:  11,
+            AdaptiveConstant:   2,
+            MedianBlurSize:     3,
+            BorderSize:         10,
+            DeskewAngleLimit:   5,
+            DenoiseStrength:    0.5,
+            SharpenStrength:    0.5,
+            GammaCorrection:    1.0,
+            Denoise:           true,
+            Sharpen:           true,
+            ContrastNormalize: true,
+        },
+        OCRConfig: &OCRConfig{
+            EnableLangModel: true,
+            MinWordLength:   3,
+            MaxWordLength:   45,
+        },
+        OllamaConfig: &OllamaConfig{
+            Enabled:             true,
+            Endpoint:            "http://localhost:11434",
+            Model:               "llama3.2-vision",
+            MaxTokens:           2048,
+            Temperature:         0.7,
+            MaxPoolSize:         4,
+            PoolTimeout:         time.Second * 30,
+            MaxRetries:          2,
+            RetryBackoff:        time.Second,
+            FailureThreshold:    3,
+            BreakerCooldown:     30 * time.Second,
+            HealthCheckInterval: 15 * time.Second,
+        },
+        TableConfig: &TableConfig{
+            Enabled:       true,
+            MinLineLength: 50,
+            MaxLineGap:    10,
+            EdgeThreshold: 30,
+            MinCellWidth:  20,
+            MinCellHeight: 20,
+        },
+    }
+}
+
+// 创建新的处理器，opts 为空时使用默认选项
 func NewProcessor(logger logger.Logger, opts *ProcessOptions) (*Processor, error) {
     if logger == nil {
         return nil, fmt.Errorf("logger is required")
     }
-    promptTemplate :=`Please analyze this image with high attention to detail and help improve the OCR results. Follow these steps:
-
-    1. Text Verification:
-    - Carefully examine the text detected by OCR: %s
-    - Check for common OCR errors (0/O, 1/I/l, rn/m, etc.)
-    - Verify numbers and special characters
-    - Identify any missing or incorrectly merged words
-    
-    2. Layout Analysis:
-    - Identify the document structure (headers, paragraphs, lists)
-    - Note any columns or text blocks
-    - Detect text alignment and formatting
-    - Identify any tables or structured data
-    
-    3. Context-Based Correction:
-    - Consider the document type and context
-    - Check for domain-specific terminology
-    - Verify proper nouns and technical terms
-    - Ensure sentence coherence and grammatical correctness
-    
-    4. Output Format:
-    - Provide the corrected text
-    - Mark significant corrections with [CORRECTION: original -> corrected]
-    - Note any uncertain interpretations with [UNCERTAIN: text]
-    - Maintain original formatting where possible
-    
-    Please provide the most accurate transcription of the text in the image, incorporating all these aspects in your analysis.`
-
-    // 设置默认选项
     if opts == nil {
-        opts = &ProcessOptions{
-            Language:      []string{"eng"},
-            DPI:          300,
-            PageSegMode:  gosseract.PSM_AUTO,
-            MinConfidence: 60.0,
-            PreprocessConfig: &PreprocessConfig{
-                AdaptiveBlockSize:  11,
-                AdaptiveConstant:   2,
-                MedianBlurSize:     3,
-                BorderSize:         10,
-                DeskewAngleLimit:   5,
-                DenoiseStrength:    0.5,
-                SharpenStrength:    0.5,
-                GammaCorrection:    1.0,
-                Denoise:           true,
-                Sharpen:           true,
-                ContrastNormalize: true,
-            },
-            OCRConfig: &OCRConfig{
-                EnableLangModel: true,
-                MinWordLength:   3,
-                MaxWordLength:   45,
-            },
-            OllamaConfig: &OllamaConfig{
-                Enabled:     true,
-                Endpoint:    "http://localhost:11434",
-                Model:       "llama3.2-vision",
-                MaxTokens:   2048,
-                Temperature: 0.7,
-                Prompt:      promptTemplate,
-                MaxPoolSize: 4,
-                PoolTimeout: time.Second * 30,
-            },
-            TableConfig: &TableConfig{
-                Enabled:       true,
-                MinLineLength: 50,
-                MaxLineGap:    10,
-                EdgeThreshold: 30,
-                MinCellWidth:  20,
-                MinCellHeight: 20,
-            },
-        }
+        opts = DefaultProcessOptions()
     }
 
-
     // 构建预处理管道
     preprocessors := []ImagePreprocessor{
         NewGrayscaleProcessor(),
@@ -184,12 +182,37 @@ func NewProcessor(logger logger.Logger, opts *ProcessOptions) (*Processor, error
         NewSharpenProcessor(opts.PreprocessConfig.SharpenStrength),
     }
 
-    return &Processor{
+    if opts.Prompts == nil {
+        registry, err := prompts.NewRegistry("", logger)
+        if err != nil {
+            return nil, fmt.Errorf("failed to load prompts: %w", err)
+        }
+        opts.Prompts = registry
+    }
+
+    processor := &Processor{
         logger:        logger,
         preprocessors: preprocessors,
         config:        opts,
-        ollamaPool:    NewOllamaClientPool(opts.OllamaConfig),
-    }, nil
+    }
+
+    switch {
+    case opts.LLMPool != nil:
+        // 使用共享连接池的模型配置，缓存键和结果中的模型信息与实际调用一致
+        processor.llmPool = opts.LLMPool
+        opts.OllamaConfig = opts.LLMPool.config
+    case opts.OllamaConfig != nil && opts.OllamaConfig.Enabled:
+        llmPool, err := NewVisionLLMPool(opts.OllamaConfig)
+        if err != nil {
+            return nil, fmt.Errorf("failed to create vision llm pool: %w", err)
+        }
+        processor.llmPool = llmPool
+        processor.ownsPool = true
+    default:
+        opts.OllamaConfig = &OllamaConfig{Enabled: false}
+    }
+
+    return processor, nil
 }
 
 func (p *Processor) CanProcess(mimeType string) bool {
@@ -225,35 +248,49 @@ func (p *Processor) Process(ctx context.Context, file io.Reader) ([]models.Docum
     // 解码图像
     img, _, err := image.Decode(bytes.NewReader(imageData))
     if err != nil {
-        return nil, fmt.Errorf("failed to decode image: %w", err)
+        return nil, document.Errorf(document.ErrorClassCorruptInput, "failed to decode image: %w", err)
     }
 
     // 应用预处理管道
-    processedImg, err := p.applyPreprocessing(img)
+    processedImg, err := p.applyPreprocessing(ctx, img)
     if err != nil {
         return nil, fmt.Errorf("failed to preprocess image: %w", err)
     }
 
     // OCR 处理
-    text, confidence, regions, err := p.performOCRWithClient(processedImg, client)
+    document.ReportProgress(ctx, document.StageProcessing, 0, 1)
+    text, confidence, regions, err := p.performOCRWithClient(ctx, processedImg, client)
     if err != nil {
         return nil, err
     }
+    document.ReportProgress(ctx, document.StageProcessing, 1, 1)
 
-    // Ollama 视觉分析
+    // 视觉 LLM 分析（Ollama 或 OpenAI 兼容服务）
     var ollamaText string
+    var promptTmpl *prompts.Template
+    cacheHit := false
     if p.config.OllamaConfig.Enabled {
-        // 从连接池获取客户端
-        ollamaClient, err := p.ollamaPool.Get(ctx)
+        var prompt string
+        promptTmpl, prompt, err = p.correctionPrompt(ctx, text)
         if err != nil {
-            p.logger.Error("Failed to get Ollama client", logger.Error(err))
+            p.logger.Error("Failed to build correction prompt", logger.Error(err))
         } else {
-            defer p.ollamaPool.Put(ollamaClient)
-            
-            prompt := fmt.Sprintf(p.config.OllamaConfig.Prompt, text)
-            ollamaText, err = ollamaClient.AnalyzeImage(ctx, processedImg, prompt)
-            if err != nil {
-                p.logger.Error("Failed to analyze image with Ollama", logger.Error(err))
+            document.ReportProgress(ctx, document.StageCorrecting, 1, 1)
+            cacheKey := p.correctionCacheKey(ctx, imageData, promptTmpl)
+            if p.config.Cache.Get(ctx, cacheKey, &ollamaText) {
+                cacheHit = true
+            } else if !p.llmPool.Available() {
+                // 所有实例都不可用时跳过 LLM 校正，只返回 OCR 结果
+                p.logger.Warn("Vision LLM unavailable, skipping correction")
+            } else {
+                ollamaText, err = p.llmPool.AnalyzeImage(ctx, processedImg, prompt)
+                if errors.Is(err, ErrLLMUnavailable) {
+                    p.logger.Warn("Vision LLM unavailable, skipping correction", logger.Error(err))
+                } else if err != nil {
+                    p.logger.Error("Failed to analyze image with vision LLM", logger.Error(err))
+                } else {
+                    p.config.Cache.Set(ctx, cacheKey, ollamaText)
+                }
             }
         }
     }
@@ -271,20 +308,78 @@ func (p *Processor) Process(ctx context.Context, file io.Reader) ([]models.Docum
     }
 
     if ollamaText != "" {
-        chunks = append(chunks, models.DocumentChunk{
-            Content: ollamaText,
-            Metadata: map[string]interface{}{
-                "source": "ollama",
-                "model":  p.config.OllamaConfig.Model,
-            },
-        })
+        chunks = append(chunks, p.correctionChunk(text, ollamaText, promptTmpl, cacheHit))
     }
 
     return chunks, nil
 }
 
+// correctionChunk 由视觉 LLM 的校正结果生成分块
+func (p *Processor) correctionChunk(ocrText, llmText string, tmpl *prompts.Template, cacheHit bool) models.DocumentChunk {
+    // 解析修正标记，生成与 OCR 文本的结构化差异
+    revision := ParseRevision(ocrText, llmText)
+    // 记录提示词及版本，便于复现结果和对比不同版本
+    return models.DocumentChunk{
+        Content: llmText,
+        Metadata: map[string]interface{}{
+            "source":        "ollama",
+            "model":         p.config.OllamaConfig.Model,
+            "backend":       string(p.config.OllamaConfig.Backend.orDefault()),
+            "revision":      revision,
+            "promptId":      tmpl.Key(),
+            "promptVersion": tmpl.Version,
+            "cacheHit":      cacheHit,
+        },
+    }
+}
+
+// correctionPrompt 按文档类型和语言选择 OCR 校正提示词，请求可以指定版本
+func (p *Processor) correctionPrompt(ctx context.Context, ocrText string) (*prompts.Template, string, error) {
+    opts := document.OptionsFromContext(ctx)
+    language := ""
+    if len(p.config.Language) > 0 {
+        language = p.config.Language[0]
+    }
+
+    tmpl, err := p.config.Prompts.GetVersion(prompts.OCRCorrection, opts.DocumentType, language,
+        opts.PromptVersions[prompts.OCRCorrection])
+    if err != nil {
+        return nil, "", err
+    }
+
+    prompt, err := tmpl.Render(prompts.Data{
+        OCRText:      ocrText,
+        DocumentType: opts.DocumentType,
+        Language:     language,
+    })
+    if err != nil {
+        return nil, "", err
+    }
+    return tmpl, prompt, nil
+}
+
+// correctionCacheKey OCR 校正的缓存键，提示词中的 OCR 文本由图像和 OCR 配置决定
+func (p *Processor) correctionCacheKey(ctx context.Context, imageData []byte, tmpl *prompts.Template) LLMCacheKey {
+    llmConfig := p.config.OllamaConfig
+    return LLMCacheKey{
+        Operation:     "correction",
+        Images:        []string{HashBytes(imageData)},
+        Model:         llmConfig.Model,
+        PromptID:      tmpl.Key(),
+        PromptVersion: tmpl.Version,
+        Options: map[string]interface{}{
+            "backend":      llmConfig.Backend.orDefault(),
+            "temperature":  llmConfig.Temperature,
+            "maxTokens":    llmConfig.MaxTokens,
+            "language":     p.config.Language,
+            "pageSegMode":  p.config.PageSegMode,
+            "documentType": document.OptionsFromContext(ctx).DocumentType,
+        },
+    }
+}
+
 // 图像预处理
-func (p *Processor) applyPreprocessing(img image.Image) (image.Image, error) {
+func (p *Processor) applyPreprocessing(ctx context.Context, img image.Image) (image.Image, error) {
     if img == nil {
         return nil, fmt.Errorf("input image is nil")
     }
@@ -293,6 +388,10 @@ func (p *Processor) applyPreprocessing(img image.Image) (image.Image, error) {
     result := img
     
     for _, processor := range p.preprocessors {
+        // Tesseract 和预处理步骤本身不能中断，在步骤之间检查取消
+        if err := ctx.Err(); err != nil {
+            return nil, err
+        }
         result, err = processor.Process(result)
         if err != nil {
             p.logger.Error("Preprocessing failed", logger.Error(err))
@@ -307,7 +406,7 @@ func (p *Processor) applyPreprocessing(img image.Image) (image.Image, error) {
 }
 
 // 执行OCR (新方法，接受client参数)
-func (p *Processor) performOCRWithClient(img image.Image, client *gosseract.Client) (string, float64, []map[string]interface{}, error) {
+func (p *Processor) performOCRWithClient(ctx context.Context, img image.Image, client *gosseract.Client) (string, float64, []map[string]interface{}, error) {
     // 置高级 OCR 参数
     if err := client.SetVariable("load_system_dawg", "1"); err != nil {
         return "", 0, nil, err
@@ -342,12 +441,20 @@ func (p *Processor) performOCRWithClient(img image.Image, client *gosseract.Clie
         return "", 0, nil, fmt.Errorf("failed to set image: %w", err)
     }
 
+    if err := ctx.Err(); err != nil {
+        return "", 0, nil, err
+    }
+
     // 获取文本
     text, err := client.Text()
     if err != nil {
         return "", 0, nil, fmt.Errorf("failed to get text: %w", err)
     }
 
+    if err := ctx.Err(); err != nil {
+        return "", 0, nil, err
+    }
+
     // 获取文本区域信息
     boxes, err := client.GetBoundingBoxesVerbose()
     if err != nil {
@@ -372,7 +479,7 @@ func (p *Processor) ExtractMetadata(ctx context.Context, file io.Reader) (models
     // 解码图像
     img, format, err := image.Decode(bytes.NewReader(imageData))
     if err != nil {
-        return models.DocumentMetadata{}, fmt.Errorf("failed to decode image: %w", err)
+        return models.DocumentMetadata{}, document.Errorf(document.ErrorClassCorruptInput, "failed to decode image: %w", err)
     }
 
     // 计算文件哈希
@@ -501,8 +608,8 @@ func (p *Processor) postProcessOCR(text string, boxes []gosseract.BoundingBox) (
 
 // Close 实现 document.Processor 接口的 Close 方法
 func (p *Processor) Close() error {
-    if p.ollamaPool != nil {
-        return p.ollamaPool.Close()
+    if p.llmPool != nil && p.ownsPool {
+        return p.llmPool.Close()
     }
     return nil
 }
@@ -548,7 +655,10 @@ func (p *Processor) ProcessTable(ctx context.Context, img image.Image) ([]TableC
         cellImg := imaging.Crop(processedImg, cells[i].Bounds)
         
         // OCR识别
-        text, _, _, err := p.performOCRWithClient(cellImg, client)
+        text, _, _, err := p.performOCRWithClient(ctx, cellImg, client)
+        if ctxErr := ctx.Err(); ctxErr != nil {
+            return nil, ctxErr
+        }
         if err != nil {
             p.logger.Error("Failed to recognize cell text", logger.Error(err))
             continue
@@ -0,0 +1,107 @@
+package image
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/feichai0017/document-processor/internal/agent/document"
+    "github.com/feichai0017/document-processor/internal/agent/prompts"
+    "github.com/feichai0017/document-processor/internal/models"
+    "github.com/feichai0017/document-processor/pkg/logger"
+)
+
+func newPromptProcessor(t *testing.T) *Processor {
+    t.Helper()
+
+    dir := t.TempDir()
+    files := map[string]string{
+        "ocr_correction/invoice.v2.tmpl":     "invoice v2: {{ .OCRText }}",
+        "ocr_correction/invoice.eng.v3.tmpl": "invoice eng v3: {{ .OCRText }}",
+    }
+    for file, content := range files {
+        path := filepath.Join(dir, filepath.FromSlash(file))
+        os.MkdirAll(filepath.Dir(path), 0o755)
+        if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    registry, err := prompts.NewRegistry(dir, logger.NewTestLogger())
+    if err != nil {
+        t.Fatalf("NewRegistry() error = %v", err)
+    }
+
+    pool, _ := newFakePool(t, &OllamaConfig{})
+    opts := DefaultProcessOptions()
+    opts.Prompts = registry
+    opts.LLMPool = pool
+    p, err := NewProcessor(logger.NewTestLogger(), opts)
+    if err != nil {
+        t.Fatalf("NewProcessor() error = %v", err)
+    }
+    return p
+}
+
+func TestCorrectionChunkRecordsPrompt(t *testing.T) {
+    p := newPromptProcessor(t)
+
+    tests := []struct {
+        name        string
+        options     *models.ProcessingOptions
+        wantID      string
+        wantVersion int
+        wantPrompt  string
+    }{
+        {
+            name:        "latest for document type and language",
+            options:     &models.ProcessingOptions{DocumentType: "invoice"},
+            wantID:      "ocr_correction/invoice.eng",
+            wantVersion: 3,
+            wantPrompt:  "invoice eng v3: Tota1",
+        },
+        {
+            name:        "pinned version",
+            options:     &models.ProcessingOptions{DocumentType: "invoice", PromptVersions: map[string]int{prompts.OCRCorrection: 2}},
+            wantID:      "ocr_correction/invoice",
+            wantVersion: 2,
+            wantPrompt:  "invoice v2: Tota1",
+        },
+        {
+            name:        "builtin default",
+            options:     &models.ProcessingOptions{DocumentType: "contract"},
+            wantID:      "ocr_correction/default",
+            wantVersion: 1,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ctx := document.WithOptions(context.Background(), tt.options)
+            tmpl, prompt, err := p.correctionPrompt(ctx, "Tota1")
+            if err != nil {
+                t.Fatalf("correctionPrompt() error = %v", err)
+            }
+            if tt.wantPrompt != "" && prompt != tt.wantPrompt {
+                t.Errorf("prompt = %q, want %q", prompt, tt.wantPrompt)
+            }
+            if !strings.Contains(prompt, "Tota1") {
+                t.Errorf("prompt does not contain the OCR text: %q", prompt)
+            }
+
+            chunk := p.correctionChunk("Tota1", "Total\n[CORRECTION: Tota1 -> Total]", tmpl, true)
+            meta := chunk.Metadata
+            if meta["promptId"] != tt.wantID || meta["promptVersion"] != tt.wantVersion {
+                t.Errorf("prompt metadata = %v v%v, want %s v%d", meta["promptId"], meta["promptVersion"], tt.wantID, tt.wantVersion)
+            }
+            if meta["source"] != "ollama" || meta["model"] != "llama3.2-vision" || meta["cacheHit"] != true {
+                t.Errorf("metadata = %v", meta)
+            }
+            revision, ok := meta["revision"].(*models.TextRevision)
+            if !ok || revision.MergedText != "Total" {
+                t.Errorf("revision = %#v", meta["revision"])
+            }
+        })
+    }
+}
@@ -0,0 +1,204 @@
+package image
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "net/http"
+    "sort"
+    "time"
+
+    "github.com/aws/aws-sdk-go-v2/aws"
+    "github.com/aws/aws-sdk-go-v2/service/textract"
+    "github.com/aws/aws-sdk-go-v2/service/textract/types"
+    "github.com/feichai0017/document-processor/internal/agent/document"
+    "github.com/feichai0017/document-processor/internal/models"
+    "github.com/feichai0017/document-processor/pkg/logger"
+)
+
+const (
+    // documents above this size can't be sent as raw bytes to AnalyzeDocument
+    maxSyncDocumentBytes = 10 * 1024 * 1024
+
+    defaultPollInterval = 5 * time.Second
+    defaultMaxPollTime  = 15 * time.Minute
+)
+
+// asyncSource decides whether the document has to go through async analysis
+// and resolves its s3 location from the context
+func (p *TextractProcessor) asyncSource(ctx context.Context, data []byte) (document.Source, bool) {
+    if !p.config.EnableAsync {
+        return document.Source{}, false
+    }
+
+    if !isPDF(data) && !isTIFF(data) && len(data) <= maxSyncDocumentBytes {
+        return document.Source{}, false
+    }
+
+    src, ok := document.SourceFromContext(ctx)
+    if !ok {
+        p.logger.Warn("Async textract requires the s3 location of the document, falling back to sync analysis")
+        return document.Source{}, false
+    }
+    if src.Bucket == "" {
+        src.Bucket = p.config.S3Bucket
+    }
+    if src.Bucket == "" {
+        p.logger.Warn("No s3 bucket configured for async textract, falling back to sync analysis",
+            logger.String("key", src.Key),
+        )
+        return document.Source{}, false
+    }
+
+    return src, true
+}
+
+// processAsync runs StartDocumentAnalysis on the s3 object, waits for the job
+// and returns page-numbered chunks
+func (p *TextractProcessor) processAsync(ctx context.Context, src document.Source) ([]models.DocumentChunk, error) {
+    queries := p.queriesConfig(ctx)
+    input := &textract.StartDocumentAnalysisInput{
+        DocumentLocation: &types.DocumentLocation{
+            S3Object: &types.S3Object{
+                Bucket: aws.String(src.Bucket),
+                Name:   aws.String(src.Key),
+            },
+        },
+        FeatureTypes:  p.featureTypes(ctx, queries),
+        QueriesConfig: queries,
+    }
+
+    start, err := p.client.StartDocumentAnalysis(ctx, input)
+    if err != nil {
+        return nil, fmt.Errorf("failed to start document analysis: %w", err)
+    }
+    jobID := aws.ToString(start.JobId)
+
+    p.logger.Info("Started async textract analysis",
+        logger.String("jobId", jobID),
+        logger.String("bucket", src.Bucket),
+        logger.String("key", src.Key),
+    )
+
+    // textract does not report page progress while the job runs
+    document.ReportProgress(ctx, document.StageAnalyzing, 0, 0)
+    blocks, pages, err := p.waitForAnalysis(ctx, jobID)
+    if err != nil {
+        return nil, err
+    }
+    document.ReportProgress(ctx, document.StageProcessing, pages, pages)
+
+    chunks, review := p.buildPageChunks(blocks, pages)
+    document.ReportReview(ctx, review...)
+    return chunks, nil
+}
+
+// waitForAnalysis polls GetDocumentAnalysis until the job finishes and
+// collects all result pages through NextToken
+func (p *TextractProcessor) waitForAnalysis(ctx context.Context, jobID string) ([]types.Block, int, error) {
+    interval := p.config.PollInterval
+    if interval <= 0 {
+        interval = defaultPollInterval
+    }
+    maxWait := p.config.MaxPollTime
+    if maxWait <= 0 {
+        maxWait = defaultMaxPollTime
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, maxWait)
+    defer cancel()
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        out, err := p.client.GetDocumentAnalysis(ctx, &textract.GetDocumentAnalysisInput{
+            JobId: aws.String(jobID),
+        })
+        if err != nil {
+            return nil, 0, fmt.Errorf("failed to get document analysis %s: %w", jobID, err)
+        }
+
+        switch out.JobStatus {
+        case types.JobStatusInProgress:
+            select {
+            case <-ticker.C:
+                continue
+            case <-ctx.Done():
+                return nil, 0, fmt.Errorf("document analysis %s did not finish: %w", jobID, ctx.Err())
+            }
+        case types.JobStatusFailed:
+            return nil, 0, fmt.Errorf("document analysis %s failed: %s", jobID, aws.ToString(out.StatusMessage))
+        case types.JobStatusPartialSuccess:
+            p.logger.Warn("Document analysis partially succeeded",
+                logger.String("jobId", jobID),
+                logger.Any("warnings", out.Warnings),
+            )
+        }
+
+        pages := 0
+        if out.DocumentMetadata != nil && out.DocumentMetadata.Pages != nil {
+            pages = int(*out.DocumentMetadata.Pages)
+        }
+
+        blocks := out.Blocks
+        for next := out.NextToken; next != nil; {
+            page, err := p.client.GetDocumentAnalysis(ctx, &textract.GetDocumentAnalysisInput{
+                JobId:     aws.String(jobID),
+                NextToken: next,
+            })
+            if err != nil {
+                return nil, 0, fmt.Errorf("failed to get document analysis %s: %w", jobID, err)
+            }
+            blocks = append(blocks, page.Blocks...)
+            next = page.NextToken
+        }
+
+        return blocks, pages, nil
+    }
+}
+
+// buildPageChunks groups blocks by page and builds chunks for every page,
+// together with the document level review items of all pages
+func (p *TextractProcessor) buildPageChunks(blocks []types.Block, pageCount int) ([]models.DocumentChunk, []models.ReviewItem) {
+    byPage := make(map[int][]types.Block)
+    for _, block := range blocks {
+        page := 1
+        if block.Page != nil {
+            page = int(*block.Page)
+        }
+        byPage[page] = append(byPage[page], block)
+    }
+
+    pages := make([]int, 0, len(byPage))
+    for page := range byPage {
+        pages = append(pages, page)
+    }
+    sort.Ints(pages)
+    if pageCount == 0 && len(pages) > 0 {
+        pageCount = pages[len(pages)-1]
+    }
+
+    var chunks []models.DocumentChunk
+    var review []models.ReviewItem
+    for _, page := range pages {
+        pageChunks, pageReview := p.buildChunks(byPage[page])
+        review = append(review, pageReview...)
+        for _, chunk := range pageChunks {
+            chunk.Metadata["page"] = page
+            chunk.Metadata["section"] = fmt.Sprintf("page_%d", page)
+            chunk.Metadata["pageCount"] = pageCount
+            chunks = append(chunks, chunk)
+        }
+    }
+
+    return chunks, review
+}
+
+func isPDF(data []byte) bool {
+    return http.DetectContentType(data) == "application/pdf"
+}
+
+func isTIFF(data []byte) bool {
+    return bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*"))
+}
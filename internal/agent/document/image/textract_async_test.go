@@ -0,0 +1,184 @@
+package image
+
+import (
+    "context"
+    "encoding/json"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/aws/aws-sdk-go-v2/aws"
+    "github.com/aws/aws-sdk-go-v2/service/textract/types"
+    "github.com/feichai0017/document-processor/internal/agent/document"
+    "github.com/feichai0017/document-processor/internal/testutil/fakeaws"
+    "github.com/feichai0017/document-processor/pkg/logger"
+)
+
+func newFakeTextract(t *testing.T, cfg *TextractConfig) (*TextractProcessor, *fakeaws.Server) {
+    t.Helper()
+
+    server, err := fakeaws.NewServer()
+    if err != nil {
+        t.Fatalf("failed to start fake aws: %v", err)
+    }
+    t.Cleanup(server.Close)
+
+    cfg.Region = fakeaws.Region
+    cfg.AccessKey = "test"
+    cfg.SecretKey = "test"
+    cfg.Endpoint = server.URL()
+
+    processor, err := NewTextractProcessor(context.Background(), cfg, logger.NewTestLogger())
+    if err != nil {
+        t.Fatalf("failed to create textract processor: %v", err)
+    }
+    return processor, server
+}
+
+func TestProcessAsyncPaginatesThroughNextToken(t *testing.T) {
+    processor, server := newFakeTextract(t, &TextractConfig{
+        MinConfidence: 80,
+        EnableAsync:   true,
+        S3Bucket:      "documents",
+        PollInterval:  10 * time.Millisecond,
+        MaxPollTime:   5 * time.Second,
+    })
+
+    ctx := document.WithSource(context.Background(), document.Source{Key: "uploads/contract.pdf"})
+    chunks, err := processor.Process(ctx, strings.NewReader("%PDF-1.7\n% scanned contract"))
+    if err != nil {
+        t.Fatalf("Process() error = %v", err)
+    }
+
+    if len(chunks) != 2 {
+        t.Fatalf("got %d chunks, want one per page", len(chunks))
+    }
+    wantText := []string{"Master Services Agreement", "Signed on 1 March 2024"}
+    for i, chunk := range chunks {
+        if chunk.Content != wantText[i] {
+            t.Errorf("chunk %d content = %q, want %q", i, chunk.Content, wantText[i])
+        }
+        if chunk.Metadata["page"] != i+1 || chunk.Metadata["pageCount"] != 2 {
+            t.Errorf("chunk %d page = %v/%v, want %d/2", i, chunk.Metadata["page"], chunk.Metadata["pageCount"], i+1)
+        }
+    }
+
+    var operations []string
+    var tokens []string
+    for _, req := range server.Requests() {
+        if req.Service != "textract" {
+            continue
+        }
+        operations = append(operations, req.Operation)
+        if req.Operation == "GetDocumentAnalysis" {
+            var body struct {
+                JobId     string
+                NextToken string
+            }
+            if err := json.Unmarshal(req.Body, &body); err != nil {
+                t.Fatalf("invalid GetDocumentAnalysis request: %v", err)
+            }
+            if body.JobId != "fake-analysis-job-1" {
+                t.Errorf("JobId = %q, want fake-analysis-job-1", body.JobId)
+            }
+            tokens = append(tokens, body.NextToken)
+        }
+    }
+
+    // 第一次轮询 IN_PROGRESS，第二次成功并返回 NextToken，第三次读取剩余结果
+    wantOps := "StartDocumentAnalysis,GetDocumentAnalysis,GetDocumentAnalysis,GetDocumentAnalysis"
+    if got := strings.Join(operations, ","); got != wantOps {
+        t.Errorf("operations = %s, want %s", got, wantOps)
+    }
+    if got := strings.Join(tokens, ","); got != ",,page-2" {
+        t.Errorf("next tokens = %q, want \",,page-2\"", got)
+    }
+}
+
+func TestProcessAsyncJobFailed(t *testing.T) {
+    processor, server := newFakeTextract(t, &TextractConfig{
+        EnableAsync:  true,
+        S3Bucket:     "documents",
+        PollInterval: 10 * time.Millisecond,
+    })
+    server.SetFixture("GetDocumentAnalysis", []byte(`{"JobStatus":"FAILED","StatusMessage":"unsupported document"}`))
+
+    ctx := document.WithSource(context.Background(), document.Source{Key: "uploads/broken.pdf"})
+    _, err := processor.Process(ctx, strings.NewReader("%PDF-1.7\n"))
+    if err == nil || !strings.Contains(err.Error(), "unsupported document") {
+        t.Fatalf("Process() error = %v, want job failure", err)
+    }
+}
+
+func TestBuildPageChunks(t *testing.T) {
+    processor := &TextractProcessor{config: &TextractConfig{MinConfidence: 80}}
+
+    line := func(id string, page int32, text string) types.Block {
+        block := types.Block{
+            BlockType:  types.BlockTypeLine,
+            Id:         aws.String(id),
+            Text:       aws.String(text),
+            Confidence: aws.Float32(99),
+        }
+        if page > 0 {
+            block.Page = aws.Int32(page)
+        }
+        return block
+    }
+
+    tests := []struct {
+        name      string
+        blocks    []types.Block
+        pageCount int
+        wantPages []int
+        wantText  []string
+        wantCount int
+    }{
+        {
+            name:      "blocks are grouped and ordered by page",
+            blocks:    []types.Block{line("c", 3, "third"), line("a", 1, "first"), line("b", 1, "second")},
+            pageCount: 3,
+            wantPages: []int{1, 3},
+            wantText:  []string{"first\nsecond", "third"},
+            wantCount: 3,
+        },
+        {
+            name:      "missing page defaults to page 1",
+            blocks:    []types.Block{line("a", 0, "only")},
+            pageCount: 1,
+            wantPages: []int{1},
+            wantText:  []string{"only"},
+            wantCount: 1,
+        },
+        {
+            name:      "page count falls back to the last page",
+            blocks:    []types.Block{line("a", 1, "first"), line("b", 2, "second")},
+            wantPages: []int{1, 2},
+            wantText:  []string{"first", "second"},
+            wantCount: 2,
+        },
+        {
+            name: "no blocks",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            chunks, _ := processor.buildPageChunks(tt.blocks, tt.pageCount)
+            if len(chunks) != len(tt.wantPages) {
+                t.Fatalf("got %d chunks, want %d", len(chunks), len(tt.wantPages))
+            }
+            for i, chunk := range chunks {
+                if chunk.Metadata["page"] != tt.wantPages[i] {
+                    t.Errorf("chunk %d page = %v, want %d", i, chunk.Metadata["page"], tt.wantPages[i])
+                }
+                if chunk.Metadata["pageCount"] != tt.wantCount {
+                    t.Errorf("chunk %d pageCount = %v, want %d", i, chunk.Metadata["pageCount"], tt.wantCount)
+                }
+                if chunk.Content != tt.wantText[i] {
+                    t.Errorf("chunk %d content = %q, want %q", i, chunk.Content, tt.wantText[i])
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,271 @@
+package image
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/aws/aws-sdk-go-v2/aws"
+    "github.com/aws/aws-sdk-go-v2/service/textract"
+    "github.com/aws/aws-sdk-go-v2/service/textract/types"
+    "github.com/feichai0017/document-processor/internal/agent/document"
+    "github.com/feichai0017/document-processor/internal/models"
+    "github.com/ledongthuc/pdf"
+)
+
+// processExpense calls AnalyzeExpense and maps every expense document found
+// in the file into a typed models.Expense chunk
+func (p *TextractProcessor) processExpense(ctx context.Context, data []byte) ([]models.DocumentChunk, error) {
+    if err := checkExpenseInput(data); err != nil {
+        return nil, err
+    }
+
+    document.ReportProgress(ctx, document.StageProcessing, 0, 1)
+    result, err := p.client.AnalyzeExpense(ctx, &textract.AnalyzeExpenseInput{
+        Document: &types.Document{
+            Bytes: data,
+        },
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to analyze expense: %w", err)
+    }
+    document.ReportProgress(ctx, document.StageProcessing, 1, 1)
+
+    chunks := []models.DocumentChunk{}
+    for _, doc := range result.ExpenseDocuments {
+        expense, confidence, review := p.mapExpense(doc)
+
+        metadata := p.chunkMetadata("expense", confidence, review)
+        metadata["expense"] = expense
+        chunks = append(chunks, models.DocumentChunk{
+            Content:  expenseSummary(expense),
+            Metadata: metadata,
+        })
+    }
+
+    return chunks, nil
+}
+
+// checkExpenseInput rejects files synchronous AnalyzeExpense can't handle,
+// it only accepts jpeg, png and single-page pdf documents
+func checkExpenseInput(data []byte) error {
+    switch contentType := http.DetectContentType(data); contentType {
+    case "image/jpeg", "image/png":
+        return nil
+    case "application/pdf":
+        reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+        if err != nil {
+            return document.Errorf(document.ErrorClassCorruptInput, "failed to open pdf: %w", err)
+        }
+        if pages := reader.NumPage(); pages != 1 {
+            return document.Errorf(document.ErrorClassUnsupportedType, "expense analysis supports single-page pdf only, got %d pages", pages)
+        }
+        return nil
+    default:
+        return document.Errorf(document.ErrorClassUnsupportedType, "expense analysis does not support %s", contentType)
+    }
+}
+
+// mapExpense maps SummaryFields and LineItemGroups of one expense document,
+// low confidence summary fields are kept but flagged for review
+func (p *TextractProcessor) mapExpense(doc types.ExpenseDocument) (*models.Expense, float64, []models.ReviewItem) {
+    expense := &models.Expense{
+        Index:         int(aws.ToInt32(doc.ExpenseIndex)),
+        SummaryFields: []models.ExpenseField{},
+        LineItems:     []models.ExpenseLineItem{},
+    }
+
+    var review []models.ReviewItem
+    var totalConfidence float64
+
+    for _, field := range doc.SummaryFields {
+        f := toExpenseField(field)
+        if f.Value == "" {
+            continue
+        }
+        expense.SummaryFields = append(expense.SummaryFields, f)
+        totalConfidence += f.Confidence
+
+        if f.Confidence < float64(p.config.MinConfidence) {
+            review = append(review, models.ReviewItem{
+                Source:     "textract",
+                BlockType:  "EXPENSE_FIELD",
+                Text:       fmt.Sprintf("%s: %s", f.Type, f.Value),
+                Confidence: f.Confidence,
+                Threshold:  float64(p.config.MinConfidence),
+                Page:       f.Page,
+            })
+        }
+
+        if expense.Currency == "" && f.Currency != "" {
+            expense.Currency = f.Currency
+        }
+
+        // the first value of each known type wins
+        switch f.Type {
+        case "VENDOR_NAME", "NAME":
+            setIfEmpty(&expense.Vendor, f.Value)
+        case "INVOICE_RECEIPT_ID":
+            setIfEmpty(&expense.InvoiceNumber, f.Value)
+        case "INVOICE_RECEIPT_DATE":
+            setIfEmpty(&expense.InvoiceDate, f.Value)
+        case "DUE_DATE":
+            setIfEmpty(&expense.DueDate, f.Value)
+        case "SUBTOTAL":
+            setAmountIfEmpty(&expense.Subtotal, f.Value)
+        case "TAX":
+            setAmountIfEmpty(&expense.Tax, f.Value)
+        case "TOTAL":
+            setAmountIfEmpty(&expense.Total, f.Value)
+        case "AMOUNT_DUE":
+            setAmountIfEmpty(&expense.AmountDue, f.Value)
+        }
+    }
+
+    for _, group := range doc.LineItemGroups {
+        for _, item := range group.LineItems {
+            lineItem := models.ExpenseLineItem{
+                Group:  int(aws.ToInt32(group.LineItemGroupIndex)),
+                Fields: []models.ExpenseField{},
+            }
+            for _, field := range item.LineItemExpenseFields {
+                f := toExpenseField(field)
+                if f.Value == "" {
+                    continue
+                }
+                lineItem.Fields = append(lineItem.Fields, f)
+
+                switch f.Type {
+                case "ITEM":
+                    setIfEmpty(&lineItem.Description, f.Value)
+                case "PRODUCT_CODE":
+                    setIfEmpty(&lineItem.ProductCode, f.Value)
+                case "QUANTITY":
+                    setAmountIfEmpty(&lineItem.Quantity, f.Value)
+                case "UNIT_PRICE":
+                    setAmountIfEmpty(&lineItem.UnitPrice, f.Value)
+                case "PRICE":
+                    setAmountIfEmpty(&lineItem.Price, f.Value)
+                }
+            }
+            if len(lineItem.Fields) > 0 {
+                expense.LineItems = append(expense.LineItems, lineItem)
+            }
+        }
+    }
+
+    avgConfidence := 0.0
+    if len(expense.SummaryFields) > 0 {
+        avgConfidence = totalConfidence / float64(len(expense.SummaryFields))
+    }
+
+    return expense, avgConfidence, review
+}
+
+// toExpenseField flattens a textract expense field, the confidence of the
+// value detection is used as field confidence
+func toExpenseField(field types.ExpenseField) models.ExpenseField {
+    f := models.ExpenseField{
+        Page: int(aws.ToInt32(field.PageNumber)),
+    }
+    if field.Type != nil {
+        f.Type = aws.ToString(field.Type.Text)
+    }
+    if field.LabelDetection != nil {
+        f.Label = strings.TrimSpace(aws.ToString(field.LabelDetection.Text))
+    }
+    if field.ValueDetection != nil {
+        f.Value = strings.TrimSpace(aws.ToString(field.ValueDetection.Text))
+        f.Confidence = float64(aws.ToFloat32(field.ValueDetection.Confidence))
+    }
+    if field.Currency != nil {
+        f.Currency = aws.ToString(field.Currency.Code)
+    }
+    return f
+}
+
+// expenseSummary renders the main expense fields as chunk text
+func expenseSummary(expense *models.Expense) string {
+    var lines []string
+    add := func(label, value string) {
+        if value != "" {
+            lines = append(lines, fmt.Sprintf("%s: %s", label, value))
+        }
+    }
+    addAmount := func(label string, value *float64) {
+        if value != nil {
+            add(label, strconv.FormatFloat(*value, 'f', 2, 64))
+        }
+    }
+
+    add("Vendor", expense.Vendor)
+    add("Invoice Number", expense.InvoiceNumber)
+    add("Invoice Date", expense.InvoiceDate)
+    add("Due Date", expense.DueDate)
+    add("Currency", expense.Currency)
+    addAmount("Subtotal", expense.Subtotal)
+    addAmount("Tax", expense.Tax)
+    addAmount("Total", expense.Total)
+    addAmount("Amount Due", expense.AmountDue)
+    for _, item := range expense.LineItems {
+        line := item.Description
+        if item.Price != nil {
+            line = fmt.Sprintf("%s %.2f", line, *item.Price)
+        }
+        add("Item", strings.TrimSpace(line))
+    }
+
+    return strings.Join(lines, "\n")
+}
+
+func setIfEmpty(target *string, value string) {
+    if *target == "" {
+        *target = value
+    }
+}
+
+func setAmountIfEmpty(target **float64, value string) {
+    if *target != nil {
+        return
+    }
+    if amount, ok := parseAmount(value); ok {
+        *target = &amount
+    }
+}
+
+// parseAmount parses amounts like "$1,234.50", "1.234,50 €" or "12"
+func parseAmount(value string) (float64, bool) {
+    var b strings.Builder
+    for _, r := range value {
+        if (r >= '0' && r <= '9') || r == '.' || r == ',' || r == '-' {
+            b.WriteRune(r)
+        }
+    }
+    s := b.String()
+    if s == "" {
+        return 0, false
+    }
+
+    lastDot := strings.LastIndex(s, ".")
+    lastComma := strings.LastIndex(s, ",")
+    switch {
+    case lastComma > lastDot && len(s)-lastComma-1 != 3:
+        // comma is the decimal separator
+        s = strings.ReplaceAll(s, ".", "")
+        s = strings.Replace(s, ",", ".", 1)
+    case lastComma < 0 && strings.Count(s, ".") > 1:
+        // dots are thousands separators, e.g. "1.234.567"
+        s = strings.ReplaceAll(s, ".", "")
+    default:
+        s = strings.ReplaceAll(s, ",", "")
+    }
+
+    amount, err := strconv.ParseFloat(s, 64)
+    if err != nil {
+        return 0, false
+    }
+    return amount, true
+}
@@ -0,0 +1,133 @@
+package image
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "testing"
+
+    "github.com/feichai0017/document-processor/internal/agent/document"
+    "github.com/feichai0017/document-processor/internal/models"
+)
+
+func TestParseAmount(t *testing.T) {
+    tests := []struct {
+        value  string
+        want   float64
+        wantOK bool
+    }{
+        {"12", 12, true},
+        {"$1,234.50", 1234.50, true},
+        {"USD 1,234.56", 1234.56, true},
+        {"1,234,567.89", 1234567.89, true},
+        {"1,234", 1234, true},
+        {"¥1,000", 1000, true},
+        {"1.234,50 €", 1234.50, true},
+        {"€12,50", 12.50, true},
+        {"12,5", 12.5, true},
+        {"1.234.567,89", 1234567.89, true},
+        {"1.234.567", 1234567, true},
+        {"1 234,50", 1234.50, true},      // 法语，空格分隔千位
+        {"1 234,50 €", 1234.50, true}, // 不换行空格
+        {"CHF 1'234.50", 1234.50, true},
+        {"-$12.00", -12, true},
+        {"", 0, false},
+        {"N/A", 0, false},
+        {".", 0, false},
+        {"2024-03-01", 0, false},
+    }
+
+    for _, tt := range tests {
+        got, ok := parseAmount(tt.value)
+        if ok != tt.wantOK || (ok && got != tt.want) {
+            t.Errorf("parseAmount(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+        }
+    }
+}
+
+func TestCheckExpenseInput(t *testing.T) {
+    tests := []struct {
+        name string
+        data []byte
+        want document.ErrorClass
+    }{
+        {"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), ""},
+        {"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), ""},
+        {"single-page pdf", minimalPDF(1), ""},
+        {"multi-page pdf", minimalPDF(3), document.ErrorClassUnsupportedType},
+        {"broken pdf", []byte("%PDF-1.4\nbroken"), document.ErrorClassCorruptInput},
+        {"docx", []byte("PK\x03\x04\x14\x00\x06\x00word/document.xml"), document.ErrorClassUnsupportedType},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            err := checkExpenseInput(tt.data)
+            if tt.want == "" {
+                if err != nil {
+                    t.Fatalf("checkExpenseInput() error = %v", err)
+                }
+                return
+            }
+            if got := document.ClassifyError(err); got != tt.want {
+                t.Fatalf("checkExpenseInput() error = %v (%s), want %s", err, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestProcessExpense(t *testing.T) {
+    processor, _ := newFakeTextract(t, &TextractConfig{MinConfidence: 80})
+
+    ctx := document.WithOptions(context.Background(), &models.ProcessingOptions{DocumentType: "invoice"})
+    chunks, err := processor.Process(ctx, bytes.NewReader([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")))
+    if err != nil {
+        t.Fatalf("Process() error = %v", err)
+    }
+    if len(chunks) != 1 {
+        t.Fatalf("got %d chunks, want 1", len(chunks))
+    }
+
+    expense, ok := chunks[0].Metadata["expense"].(*models.Expense)
+    if !ok {
+        t.Fatalf("chunk has no expense: %v", chunks[0].Metadata)
+    }
+    if expense.Vendor != "ACME Corporation" || expense.InvoiceNumber != "INV-1001" || expense.Currency != "USD" {
+        t.Errorf("unexpected expense header: %+v", expense)
+    }
+    if expense.Total == nil || *expense.Total != 1375 {
+        t.Errorf("Total = %v, want 1375", expense.Total)
+    }
+    // TAX 的置信度低于 MinConfidence，需要复核
+    if chunks[0].Metadata["needsReview"] != true {
+        t.Errorf("low confidence tax was not flagged for review")
+    }
+}
+
+// minimalPDF 生成指定页数的空白 PDF
+func minimalPDF(pages int) []byte {
+    var buf bytes.Buffer
+    var offsets []int
+    object := func(body string) {
+        offsets = append(offsets, buf.Len())
+        fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+    }
+
+    buf.WriteString("%PDF-1.4\n")
+    object("<< /Type /Catalog /Pages 2 0 R >>")
+    kids := ""
+    for i := 0; i < pages; i++ {
+        kids += fmt.Sprintf("%d 0 R ", i+3)
+    }
+    object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, pages))
+    for i := 0; i < pages; i++ {
+        object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>")
+    }
+
+    xref := buf.Len()
+    fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+    for _, offset := range offsets {
+        fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+    }
+    fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+    return buf.Bytes()
+}
@@ -7,11 +7,14 @@ import (
     "image"
     "io"
     "strings"
+    "time"
 
+    "github.com/aws/aws-sdk-go-v2/aws"
     "github.com/aws/aws-sdk-go-v2/config"
     "github.com/aws/aws-sdk-go-v2/service/textract"
     "github.com/aws/aws-sdk-go-v2/service/textract/types"
     "github.com/aws/aws-sdk-go-v2/credentials"
+    "github.com/feichai0017/document-processor/internal/agent/document"
     "github.com/feichai0017/document-processor/internal/models"
     "github.com/feichai0017/document-processor/pkg/logger"
 )
@@ -26,11 +29,18 @@ type TextractConfig struct {
     Region        string
     AccessKey     string
     SecretKey     string
+    Endpoint      string // optional endpoint override, e.g. a local textract stub
     MinConfidence float32
     EnableTable   bool
     EnableForm    bool
     FeatureTypes  []types.FeatureType
 	QueriesConfig []types.Query
+
+    // async analysis of multi-page documents already uploaded to S3
+    EnableAsync  bool
+    S3Bucket     string
+    PollInterval time.Duration
+    MaxPollTime  time.Duration
 }
 
 func NewTextractProcessor(ctx context.Context, cfg *TextractConfig, log logger.Logger) (*TextractProcessor, error) {
@@ -50,7 +60,11 @@ func NewTextractProcessor(ctx context.Context, cfg *TextractConfig, log logger.L
     }
 
     // create textract client
-    client := textract.NewFromConfig(awsCfg)
+    client := textract.NewFromConfig(awsCfg, func(o *textract.Options) {
+        if cfg.Endpoint != "" {
+            o.BaseEndpoint = aws.String(cfg.Endpoint)
+        }
+    })
 
     return &TextractProcessor{
         client: client,
@@ -65,7 +79,8 @@ func (p *TextractProcessor) CanProcess(mimeType string) bool {
         "image/jpg":  true,
         "image/png":  true,
         "image/tiff": true,
-        "application/pdf": true,
+        // multi-page pdf is only supported through async analysis
+        "application/pdf": p.config.EnableAsync,
     }
     return supportedTypes[strings.ToLower(mimeType)]
 }
@@ -77,74 +92,98 @@ func (p *TextractProcessor) Process(ctx context.Context, reader io.Reader) ([]mo
         return nil, fmt.Errorf("failed to read file: %w", err)
     }
 
-    // prepare textract request
+    // invoices and receipts go through expense analysis
+    if document.OptionsFromContext(ctx).ResolveMode() == models.ModeExpense {
+        return p.processExpense(ctx, data)
+    }
+
+    // multi-page and large documents go through async analysis of the s3 object
+    if src, ok := p.asyncSource(ctx, data); ok {
+        return p.processAsync(ctx, src)
+    }
+
+    // prepare textract request, queries come from config and the request
+    queries := p.queriesConfig(ctx)
     input := &textract.AnalyzeDocumentInput{
         Document: &types.Document{
             Bytes: data,
         },
-        FeatureTypes: p.config.FeatureTypes,
-    }
-
-    // if queries config is set, add it to the request
-    if len(p.config.QueriesConfig) > 0 {
-        input.QueriesConfig = &types.QueriesConfig{
-            Queries: p.config.QueriesConfig,
-        }
+        FeatureTypes:  p.featureTypes(ctx, queries),
+        QueriesConfig: queries,
     }
 
-    // call textract api
+    // call textract api, synchronous analysis handles a single page
+    document.ReportProgress(ctx, document.StageProcessing, 0, 1)
     result, err := p.client.AnalyzeDocument(ctx, input)
     if err != nil {
         return nil, fmt.Errorf("failed to analyze document: %w", err)
     }
+    document.ReportProgress(ctx, document.StageProcessing, 1, 1)
+
+    chunks, review := p.buildChunks(result.Blocks)
+    document.ReportReview(ctx, review...)
+    return chunks, nil
+}
 
-    // process result
+// buildChunks turns textract blocks into text, table and form chunks, review
+// items that belong to no chunk (low confidence form pairs) are returned
+// separately so they can be surfaced at document level
+func (p *TextractProcessor) buildChunks(blocks []types.Block) ([]models.DocumentChunk, []models.ReviewItem) {
     chunks := []models.DocumentChunk{}
+    var review []models.ReviewItem
     
     // process text blocks
-    textBlocks := p.processBlocks(result.Blocks)
-    if len(textBlocks) > 0 {
+    textBlocks, textConfidence, textReview := p.processBlocks(blocks)
+    if len(textBlocks) > 0 || len(textReview) > 0 {
         chunks = append(chunks, models.DocumentChunk{
-            Content: strings.Join(textBlocks, "\n"),
-            Metadata: map[string]interface{}{
-                "source": "textract",
-                "type":   "text",
-            },
+            Content:  strings.Join(textBlocks, "\n"),
+            Metadata: p.chunkMetadata("text", textConfidence, textReview),
         })
     }
 
     // process tables (if enabled)
     if p.config.EnableTable {
-        tables := p.processTables(result.Blocks)
+        tables := p.processTables(blocks)
         for _, table := range tables {
+            metadata := p.chunkMetadata("table", table.Confidence, table.Review)
+            metadata["rows"] = table.Rows
+            metadata["cols"] = table.Cols
             chunks = append(chunks, models.DocumentChunk{
-                Content: table.Content,
-                Metadata: map[string]interface{}{
-                    "source": "textract",
-                    "type":   "table",
-                    "rows":   table.Rows,
-                    "cols":   table.Cols,
-                },
+                Content:  table.Content,
+                Metadata: metadata,
             })
         }
     }
 
     // process forms (if enabled)
     if p.config.EnableForm {
-        forms := p.processForms(result.Blocks)
+        forms, formReview := p.processForms(blocks)
         for _, form := range forms {
+            metadata := p.chunkMetadata("form", form.Confidence, nil)
+            metadata["key"] = form.Key
             chunks = append(chunks, models.DocumentChunk{
-                Content: fmt.Sprintf("%s: %s", form.Key, form.Value),
-                Metadata: map[string]interface{}{
-                    "source": "textract",
-                    "type":   "form",
-                    "key":    form.Key,
-                },
+                Content:  fmt.Sprintf("%s: %s", form.Key, form.Value),
+                Metadata: metadata,
             })
         }
+        review = append(review, formReview...)
     }
 
-    return chunks, nil
+    // process query answers
+    if results, queryReview := p.processQueries(blocks); len(results) > 0 {
+        var total float64
+        for _, r := range results {
+            total += r.Confidence
+        }
+        metadata := p.chunkMetadata("query", total/float64(len(results)), queryReview)
+        metadata["queries"] = results
+        chunks = append(chunks, models.DocumentChunk{
+            Content:  queriesContent(results),
+            Metadata: metadata,
+        })
+    }
+
+    return chunks, review
 }
 
 func (p *TextractProcessor) ExtractMetadata(ctx context.Context, reader io.Reader) (models.DocumentMetadata, error) {
@@ -161,7 +200,7 @@ func (p *TextractProcessor) ExtractMetadata(ctx context.Context, reader io.Reade
     // decode image to get basic info
     img, _, err := image.Decode(bytes.NewReader(data))
     if err != nil {
-        return metadata, fmt.Errorf("failed to decode image: %w", err)
+        return metadata, document.Errorf(document.ErrorClassCorruptInput, "failed to decode image: %w", err)
     }
 
     bounds := img.Bounds()
@@ -177,38 +216,143 @@ func (p *TextractProcessor) Close() error {
     return nil
 }
 
-// helper method: process text blocks
-func (p *TextractProcessor) processBlocks(blocks []types.Block) []string {
+// chunkMetadata builds the common metadata for textract chunks, low confidence
+// regions are attached under "review" so they can be surfaced for human review
+func (p *TextractProcessor) chunkMetadata(chunkType string, confidence float64, review []models.ReviewItem) map[string]interface{} {
+    metadata := map[string]interface{}{
+        "source":        "textract",
+        "type":          chunkType,
+        "minConfidence": float64(p.config.MinConfidence),
+    }
+    if confidence > 0 {
+        metadata["confidence"] = confidence
+    }
+    if len(review) > 0 {
+        metadata["review"] = review
+        metadata["needsReview"] = true
+    }
+    return metadata
+}
+
+// helper method: check block confidence against the configured threshold,
+// a block without confidence is treated as unreliable
+func (p *TextractProcessor) isLowConfidence(block types.Block) bool {
+    return block.Confidence == nil || *block.Confidence < p.config.MinConfidence
+}
+
+// helper method: build a review item for a low confidence block
+func (p *TextractProcessor) reviewItem(block types.Block, text string) models.ReviewItem {
+    item := models.ReviewItem{
+        Source:     "textract",
+        BlockType:  string(block.BlockType),
+        Text:       text,
+        Confidence: blockConfidence(block),
+        Threshold:  float64(p.config.MinConfidence),
+    }
+    if block.Page != nil {
+        item.Page = int(*block.Page)
+    }
+    if block.Geometry != nil && block.Geometry.BoundingBox != nil {
+        box := block.Geometry.BoundingBox
+        item.BoundingBox = &models.BoundingBox{
+            Left:   float64(box.Left),
+            Top:    float64(box.Top),
+            Width:  float64(box.Width),
+            Height: float64(box.Height),
+        }
+    }
+    return item
+}
+
+// blockConfidence returns the block confidence as float64, 0 if missing
+func blockConfidence(block types.Block) float64 {
+    if block.Confidence == nil {
+        return 0
+    }
+    return float64(*block.Confidence)
+}
+
+// helper method: process text blocks, lines below MinConfidence are kept out
+// of the text and returned for review together with low confidence words
+func (p *TextractProcessor) processBlocks(blocks []types.Block) ([]string, float64, []models.ReviewItem) {
     var texts []string
+    var review []models.ReviewItem
+    var totalConfidence float64
+
+    // words of a flagged line are already covered by the line itself
+    flaggedWords := make(map[string]bool)
     for _, block := range blocks {
-        if block.BlockType == types.BlockTypeLine && 
-           block.Confidence != nil && 
-           *block.Confidence >= p.config.MinConfidence {
-            texts = append(texts, *block.Text)
+        if block.BlockType != types.BlockTypeLine || block.Text == nil {
+            continue
+        }
+        if p.isLowConfidence(block) {
+            review = append(review, p.reviewItem(block, *block.Text))
+            for _, rel := range block.Relationships {
+                if rel.Type == types.RelationshipTypeChild {
+                    for _, id := range rel.Ids {
+                        flaggedWords[id] = true
+                    }
+                }
+            }
+            continue
         }
+        texts = append(texts, *block.Text)
+        totalConfidence += blockConfidence(block)
+    }
+
+    for _, block := range blocks {
+        if block.BlockType != types.BlockTypeWord || block.Text == nil {
+            continue
+        }
+        if block.Id != nil && flaggedWords[*block.Id] {
+            continue
+        }
+        if p.isLowConfidence(block) {
+            review = append(review, p.reviewItem(block, *block.Text))
+        }
+    }
+
+    avgConfidence := 0.0
+    if len(texts) > 0 {
+        avgConfidence = totalConfidence / float64(len(texts))
     }
-    return texts
+
+    return texts, avgConfidence, review
 }
 
 // table struct
 type Table struct {
-    Content string
-    Rows    int
-    Cols    int
-    Cells   [][]string
+    Content    string
+    Rows       int
+    Cols       int
+    Cells      [][]string
+    Confidence float64
+    Review     []models.ReviewItem
 }
 
 // process tables
 func (p *TextractProcessor) processTables(blocks []types.Block) []Table {
     var tables []Table
     var currentTable *Table
+    var cellConfidence float64
+    var cellCount int
+
+    // finish the current table with the average confidence of its cells
+    flush := func() {
+        if currentTable == nil {
+            return
+        }
+        if cellCount > 0 {
+            currentTable.Confidence = cellConfidence / float64(cellCount)
+        }
+        tables = append(tables, *currentTable)
+    }
     
     for _, block := range blocks {
         // check if it's a table block
         if block.BlockType == types.BlockTypeTable {
-            if currentTable != nil {
-                tables = append(tables, *currentTable)
-            }
+            flush()
+            cellConfidence, cellCount = 0, 0
             
             // get table rows and columns
             var rowCount, colCount int32
@@ -241,29 +385,39 @@ func (p *TextractProcessor) processTables(blocks []types.Block) []Table {
             if block.RowIndex != nil && block.ColumnIndex != nil {
                 row := int(*block.RowIndex - 1)
                 col := int(*block.ColumnIndex - 1)
+                text := p.getTextFromRelationships(block.Relationships, blocks)
                 if block.Text != nil {
-                    currentTable.Cells[row][col] = *block.Text
+                    text = *block.Text
+                }
+                currentTable.Cells[row][col] = text
+
+                // low confidence cells stay in the grid but are flagged
+                cellConfidence += blockConfidence(block)
+                cellCount++
+                if p.isLowConfidence(block) {
+                    currentTable.Review = append(currentTable.Review, p.reviewItem(block, text))
                 }
             }
         }
     }
     
-    if currentTable != nil {
-        tables = append(tables, *currentTable)
-    }
+    flush()
     
     return tables
 }
 
 // form field struct
 type FormField struct {
-    Key   string
-    Value string
+    Key        string
+    Value      string
+    Confidence float64
 }
 
-// process forms
-func (p *TextractProcessor) processForms(blocks []types.Block) []FormField {
+// process forms, pairs where either the key or the value is below
+// MinConfidence are returned for review instead of as fields
+func (p *TextractProcessor) processForms(blocks []types.Block) ([]FormField, []models.ReviewItem) {
     var forms []FormField
+    var review []models.ReviewItem
     
     for _, block := range blocks {
         if block.BlockType == types.BlockTypeKeyValueSet &&
@@ -272,18 +426,28 @@ func (p *TextractProcessor) processForms(blocks []types.Block) []FormField {
            block.EntityTypes[0] == types.EntityTypeKey {
             
             key := p.getTextFromRelationships(block.Relationships, blocks)
-            value := p.getValueFromKeyBlock(block, blocks)
+            valueBlock, value := p.getValueFromKeyBlock(block, blocks)
             
             if key != "" && value != "" {
+                // the pair is only as reliable as its weakest part
+                weakest := block
+                if valueBlock != nil && blockConfidence(*valueBlock) < blockConfidence(block) {
+                    weakest = *valueBlock
+                }
+                if p.isLowConfidence(weakest) {
+                    review = append(review, p.reviewItem(weakest, fmt.Sprintf("%s: %s", key, value)))
+                    continue
+                }
                 forms = append(forms, FormField{
-                    Key:   key,
-                    Value: value,
+                    Key:        key,
+                    Value:      value,
+                    Confidence: blockConfidence(weakest),
                 })
             }
         }
     }
     
-    return forms
+    return forms, review
 }
 
 // get text from relationships
@@ -307,19 +471,19 @@ func (p *TextractProcessor) getTextFromRelationships(relationships []types.Relat
     return strings.TrimSpace(text.String())
 }
 
-// get value from key block
-func (p *TextractProcessor) getValueFromKeyBlock(keyBlock types.Block, blocks []types.Block) string {
+// get value block and its text from key block
+func (p *TextractProcessor) getValueFromKeyBlock(keyBlock types.Block, blocks []types.Block) (*types.Block, string) {
     for _, rel := range keyBlock.Relationships {
         // use string constant instead of undefined constant
         if rel.Type == "VALUE" {
             for _, id := range rel.Ids {
-                for _, block := range blocks {
-                    if block.Id != nil && *block.Id == id {
-                        return p.getTextFromRelationships(block.Relationships, blocks)
+                for i := range blocks {
+                    if blocks[i].Id != nil && *blocks[i].Id == id {
+                        return &blocks[i], p.getTextFromRelationships(blocks[i].Relationships, blocks)
                     }
                 }
             }
         }
     }
-    return ""
+    return nil, ""
 }
@@ -0,0 +1,163 @@
+package image
+
+import (
+    "context"
+    "testing"
+
+    "github.com/aws/aws-sdk-go-v2/aws"
+    "github.com/aws/aws-sdk-go-v2/service/textract/types"
+    "github.com/feichai0017/document-processor/internal/agent/document"
+    "github.com/feichai0017/document-processor/internal/models"
+)
+
+func TestBuildChunksFlagsLowConfidence(t *testing.T) {
+    processor := &TextractProcessor{config: &TextractConfig{
+        MinConfidence: 80,
+        EnableTable:   true,
+        EnableForm:    true,
+    }}
+
+    block := func(blockType types.BlockType, id, text string, confidence float32, children ...string) types.Block {
+        b := types.Block{
+            BlockType:  blockType,
+            Id:         aws.String(id),
+            Confidence: aws.Float32(confidence),
+        }
+        if text != "" {
+            b.Text = aws.String(text)
+        }
+        if len(children) > 0 {
+            b.Relationships = []types.Relationship{{Type: types.RelationshipTypeChild, Ids: children}}
+        }
+        return b
+    }
+    cell := func(id, text string, confidence float32, row, col int32) types.Block {
+        b := block(types.BlockTypeCell, id, text, confidence)
+        b.RowIndex = aws.Int32(row)
+        b.ColumnIndex = aws.Int32(col)
+        return b
+    }
+    pair := func(keyID, keyWord string, keyConfidence float32, valueID, valueWord string, valueConfidence float32) []types.Block {
+        key := block(types.BlockTypeKeyValueSet, keyID, "", keyConfidence, keyID+"-w")
+        key.EntityTypes = []types.EntityType{types.EntityTypeKey}
+        key.Relationships = append(key.Relationships, types.Relationship{Type: types.RelationshipTypeValue, Ids: []string{valueID}})
+        value := block(types.BlockTypeKeyValueSet, valueID, "", valueConfidence, valueID+"-w")
+        value.EntityTypes = []types.EntityType{types.EntityTypeValue}
+        return []types.Block{
+            key,
+            value,
+            block(types.BlockTypeWord, keyID+"-w", keyWord, 99),
+            block(types.BlockTypeWord, valueID+"-w", valueWord, 99),
+        }
+    }
+
+    blocks := []types.Block{
+        // a reliable line with one unreliable word, and an unreliable line
+        // whose words are covered by the line itself
+        block(types.BlockTypeLine, "l1", "Invoice 42", 95, "w1", "w2"),
+        block(types.BlockTypeWord, "w1", "Invoice", 95),
+        block(types.BlockTypeWord, "w2", "42", 60),
+        block(types.BlockTypeLine, "l2", "smudged", 50, "w3"),
+        block(types.BlockTypeWord, "w3", "smudged", 40),
+        block(types.BlockTypeTable, "t1", "", 99, "c1", "c2"),
+        cell("c1", "Qty", 90, 1, 1),
+        cell("c2", "B", 30, 1, 2),
+    }
+    blocks = append(blocks, pair("k1", "Name", 95, "v1", "Alice", 50)...)
+    blocks = append(blocks, pair("k2", "Date", 40, "v2", "today", 95)...)
+    blocks = append(blocks, pair("k3", "Total", 95, "v3", "10", 90)...)
+
+    chunks, review := processor.buildChunks(blocks)
+
+    chunkReview := func(chunkType string) []models.ReviewItem {
+        for _, chunk := range chunks {
+            if chunk.Metadata["type"] == chunkType {
+                items, _ := chunk.Metadata["review"].([]models.ReviewItem)
+                return items
+            }
+        }
+        t.Fatalf("no %s chunk", chunkType)
+        return nil
+    }
+    assertReview := func(name string, got []models.ReviewItem, want []models.ReviewItem) {
+        t.Helper()
+        if len(got) != len(want) {
+            t.Fatalf("%s: got %d review items %+v, want %d", name, len(got), got, len(want))
+        }
+        for i := range want {
+            if got[i].BlockType != want[i].BlockType || got[i].Text != want[i].Text || got[i].Confidence != want[i].Confidence {
+                t.Errorf("%s[%d] = %s %q %.0f, want %s %q %.0f", name, i,
+                    got[i].BlockType, got[i].Text, got[i].Confidence,
+                    want[i].BlockType, want[i].Text, want[i].Confidence)
+            }
+            if got[i].Threshold != 80 {
+                t.Errorf("%s[%d] threshold = %v, want 80", name, i, got[i].Threshold)
+            }
+        }
+    }
+
+    assertReview("text", chunkReview("text"), []models.ReviewItem{
+        {BlockType: "LINE", Text: "smudged", Confidence: 50},
+        {BlockType: "WORD", Text: "42", Confidence: 60},
+    })
+    assertReview("table", chunkReview("table"), []models.ReviewItem{
+        {BlockType: "CELL", Text: "B", Confidence: 30},
+    })
+    // the weaker side of each pair is flagged, at document level
+    assertReview("form", review, []models.ReviewItem{
+        {BlockType: "KEY_VALUE_SET", Text: "Name: Alice", Confidence: 50},
+        {BlockType: "KEY_VALUE_SET", Text: "Date: today", Confidence: 40},
+    })
+
+    var forms []string
+    for _, chunk := range chunks {
+        if chunk.Metadata["type"] == "form" {
+            forms = append(forms, chunk.Content)
+        }
+    }
+    if len(forms) != 1 || forms[0] != "Total: 10" {
+        t.Errorf("form chunks = %q, want [Total: 10]", forms)
+    }
+}
+
+func TestReportReviewCollectsFormReview(t *testing.T) {
+    processor := &TextractProcessor{config: &TextractConfig{MinConfidence: 80, EnableForm: true}}
+
+    ctx, collector := document.WithReviewCollector(context.Background())
+    key := types.Block{
+        BlockType:   types.BlockTypeKeyValueSet,
+        Id:          aws.String("k"),
+        Confidence:  aws.Float32(20),
+        EntityTypes: []types.EntityType{types.EntityTypeKey},
+        Page:        aws.Int32(2),
+        Relationships: []types.Relationship{
+            {Type: types.RelationshipTypeChild, Ids: []string{"kw"}},
+            {Type: types.RelationshipTypeValue, Ids: []string{"v"}},
+        },
+    }
+    value := types.Block{
+        BlockType:     types.BlockTypeKeyValueSet,
+        Id:            aws.String("v"),
+        Confidence:    aws.Float32(90),
+        EntityTypes:   []types.EntityType{types.EntityTypeValue},
+        Page:          aws.Int32(2),
+        Relationships: []types.Relationship{{Type: types.RelationshipTypeChild, Ids: []string{"vw"}}},
+    }
+    words := []types.Block{
+        {BlockType: types.BlockTypeWord, Id: aws.String("kw"), Text: aws.String("Name"), Confidence: aws.Float32(99), Page: aws.Int32(2)},
+        {BlockType: types.BlockTypeWord, Id: aws.String("vw"), Text: aws.String("Bob"), Confidence: aws.Float32(99), Page: aws.Int32(2)},
+    }
+
+    chunks, review := processor.buildPageChunks(append([]types.Block{key, value}, words...), 2)
+    document.ReportReview(ctx, review...)
+
+    for _, chunk := range chunks {
+        if chunk.Metadata["type"] == "form" {
+            t.Errorf("unexpected form chunk %q", chunk.Content)
+        }
+    }
+    items := collector.Items()
+    if len(items) != 1 || items[0].Text != "Name: Bob" || items[0].Page != 2 {
+        t.Fatalf("collected review = %+v, want Name: Bob on page 2", items)
+    }
+}
@@ -0,0 +1,128 @@
+package image
+
+import (
+    "context"
+    "fmt"
+    "strings"
+
+    "github.com/aws/aws-sdk-go-v2/aws"
+    "github.com/aws/aws-sdk-go-v2/service/textract/types"
+    "github.com/feichai0017/document-processor/internal/agent/document"
+    "github.com/feichai0017/document-processor/internal/models"
+)
+
+// queriesConfig merges the configured queries with the ones passed in the
+// request, returns nil when there is nothing to ask
+func (p *TextractProcessor) queriesConfig(ctx context.Context) *types.QueriesConfig {
+    queries := append([]types.Query{}, p.config.QueriesConfig...)
+    for _, q := range document.OptionsFromContext(ctx).Queries {
+        query := types.Query{
+            Text:  aws.String(q.Text),
+            Pages: q.Pages,
+        }
+        if q.Alias != "" {
+            query.Alias = aws.String(q.Alias)
+        }
+        queries = append(queries, query)
+    }
+
+    if len(queries) == 0 {
+        return nil
+    }
+    return &types.QueriesConfig{Queries: queries}
+}
+
+// featureTypes returns the feature types of the request's document profile or
+// the configured ones, QUERIES is added when the request carries queries
+func (p *TextractProcessor) featureTypes(ctx context.Context, queries *types.QueriesConfig) []types.FeatureType {
+    features := append([]types.FeatureType{}, p.config.FeatureTypes...)
+    if requested := document.OptionsFromContext(ctx).Features; len(requested) > 0 {
+        features = make([]types.FeatureType, 0, len(requested))
+        for _, f := range requested {
+            features = append(features, types.FeatureType(strings.ToUpper(f)))
+        }
+    }
+    if queries == nil {
+        return features
+    }
+    for _, f := range features {
+        if f == types.FeatureTypeQueries {
+            return features
+        }
+    }
+    return append(features, types.FeatureTypeQueries)
+}
+
+// processQueries pairs QUERY blocks with their QUERY_RESULT answers, answers
+// below MinConfidence are returned and also flagged for review
+func (p *TextractProcessor) processQueries(blocks []types.Block) ([]models.QueryResult, []models.ReviewItem) {
+    byID := make(map[string]types.Block, len(blocks))
+    for _, block := range blocks {
+        if block.Id != nil {
+            byID[*block.Id] = block
+        }
+    }
+
+    var results []models.QueryResult
+    var review []models.ReviewItem
+    for _, block := range blocks {
+        if block.BlockType != types.BlockTypeQuery || block.Query == nil {
+            continue
+        }
+
+        result := models.QueryResult{
+            Query: aws.ToString(block.Query.Text),
+            Alias: aws.ToString(block.Query.Alias),
+        }
+        if block.Page != nil {
+            result.Page = int(*block.Page)
+        }
+
+        // keep the most confident answer
+        for _, rel := range block.Relationships {
+            if rel.Type != types.RelationshipTypeAnswer {
+                continue
+            }
+            for _, id := range rel.Ids {
+                answer, ok := byID[id]
+                if !ok || answer.BlockType != types.BlockTypeQueryResult {
+                    continue
+                }
+                if confidence := blockConfidence(answer); result.Answer == "" || confidence > result.Confidence {
+                    result.Answer = strings.TrimSpace(aws.ToString(answer.Text))
+                    result.Confidence = confidence
+                    if answer.Page != nil {
+                        result.Page = int(*answer.Page)
+                    }
+                }
+            }
+        }
+
+        if result.Answer != "" && result.Confidence < float64(p.config.MinConfidence) {
+            review = append(review, models.ReviewItem{
+                Source:     "textract",
+                BlockType:  string(types.BlockTypeQueryResult),
+                Text:       fmt.Sprintf("%s: %s", result.Query, result.Answer),
+                Confidence: result.Confidence,
+                Threshold:  float64(p.config.MinConfidence),
+                Page:       result.Page,
+            })
+        }
+        results = append(results, result)
+    }
+
+    return results, review
+}
+
+// queriesContent renders query answers as chunk text
+func queriesContent(results []models.QueryResult) string {
+    lines := make([]string, 0, len(results))
+    for _, r := range results {
+        label := r.Alias
+        if label == "" {
+            label = r.Query
+        }
+        lines = append(lines, fmt.Sprintf("%s: %s", label, r.Answer))
+    }
+    return strings.Join(lines, "\n")
+}
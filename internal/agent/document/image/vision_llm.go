@@ -0,0 +1,140 @@
+package image
+
+import (
+    "bytes"
+    "context"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "image"
+    "image/jpeg"
+    "io"
+    "net/http"
+    "time"
+
+    "github.com/feichai0017/document-processor/internal/agent/document"
+)
+
+// LLMBackend 视觉 LLM 服务类型
+type LLMBackend string
+
+const (
+    BackendOllama LLMBackend = "ollama"
+    BackendOpenAI LLMBackend = "openai" // OpenAI 兼容的 /chat/completions，如 vLLM、LM Studio
+)
+
+func (b LLMBackend) orDefault() LLMBackend {
+    if b == "" {
+        return BackendOllama
+    }
+    return b
+}
+
+// ChatMessage 对话消息，图像以 base64 放在 Images 中，
+// 各后端负责转换为自己的请求格式
+type ChatMessage struct {
+    Role    string   `json:"role"`
+    Content string   `json:"content"`
+    Images  []string `json:"images,omitempty"`
+}
+
+// VisionLLM 视觉 LLM 客户端接口，所有 LLM 步骤（OCR 校正、结构化抽取等）都通过它调用模型
+type VisionLLM interface {
+    // AnalyzeImage 将图像和提示词发送给模型
+    AnalyzeImage(ctx context.Context, img image.Image, prompt string) (string, error)
+    // AnalyzeImageStream 使用流式响应分析图像，onToken 不为空时逐段回调生成的内容
+    AnalyzeImageStream(ctx context.Context, img image.Image, prompt string, onToken func(string)) (string, error)
+    // Chat 发送多轮对话，format 不为空时要求模型按该 JSON Schema（或 "json"）输出
+    Chat(ctx context.Context, messages []ChatMessage, format json.RawMessage) (string, error)
+    // Ping 检查服务是否可用
+    Ping(ctx context.Context) error
+    Close() error
+}
+
+// StatusError 服务返回的非 200 响应
+type StatusError struct {
+    StatusCode int
+    Body       string
+}
+
+func (e *StatusError) Error() string {
+    return fmt.Sprintf("unexpected status code %d: %s", e.StatusCode, e.Body)
+}
+
+// ErrorClass 429 为限流，408 和 5xx 为临时故障
+func (e *StatusError) ErrorClass() document.ErrorClass {
+    switch {
+    case e.StatusCode == http.StatusTooManyRequests:
+        return document.ErrorClassThrottled
+    case e.StatusCode == http.StatusRequestTimeout, e.StatusCode >= 500:
+        return document.ErrorClassTransient
+    }
+    return document.ErrorClassUnknown
+}
+
+// NewVisionLLM 按 Backend 创建客户端
+func NewVisionLLM(config *OllamaConfig) (VisionLLM, error) {
+    switch config.Backend.orDefault() {
+    case BackendOllama:
+        return NewOllamaClient(config), nil
+    case BackendOpenAI:
+        return NewOpenAIClient(config), nil
+    default:
+        return nil, fmt.Errorf("unsupported llm backend: %s", config.Backend)
+    }
+}
+
+// NewImageMessage 构建带图像的用户消息
+func NewImageMessage(img image.Image, prompt string) (ChatMessage, error) {
+    // 将图像转换为 base64
+    buf := new(bytes.Buffer)
+    if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 85}); err != nil {
+        return ChatMessage{}, fmt.Errorf("failed to encode image: %w", err)
+    }
+
+    return ChatMessage{
+        Role:    "user",
+        Content: prompt,
+        Images:  []string{base64.StdEncoding.EncodeToString(buf.Bytes())},
+    }, nil
+}
+
+func requestTimeout(config *OllamaConfig) time.Duration {
+    if config.RequestTimeout > 0 {
+        return config.RequestTimeout
+    }
+    return 120 * time.Second
+}
+
+// newHTTPClients 返回普通请求和流式请求使用的 HTTP 客户端，两者共享连接池。
+// 普通请求受 RequestTimeout 限制；流式响应可能持续很久，只限制等待响应头的时间，
+// 生成过程由调用方的 ctx 控制
+func newHTTPClients(config *OllamaConfig) (*http.Client, *http.Client) {
+    timeout := requestTimeout(config)
+    transport := http.DefaultTransport.(*http.Transport).Clone()
+    transport.ResponseHeaderTimeout = timeout
+    return &http.Client{Transport: transport, Timeout: timeout}, &http.Client{Transport: transport}
+}
+
+// ping 发送 GET 请求，200 视为可用
+func ping(ctx context.Context, client *http.Client, url, apiKey string) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return err
+    }
+    if apiKey != "" {
+        req.Header.Set("Authorization", "Bearer "+apiKey)
+    }
+
+    resp, err := client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    io.Copy(io.Discard, resp.Body)
+
+    if resp.StatusCode != http.StatusOK {
+        return &StatusError{StatusCode: resp.StatusCode}
+    }
+    return nil
+}
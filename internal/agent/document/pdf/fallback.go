@@ -0,0 +1,82 @@
+package pdf
+
+import (
+    "bytes"
+    "context"
+    "io"
+    "strings"
+
+    "github.com/feichai0017/document-processor/internal/agent/document"
+    "github.com/feichai0017/document-processor/internal/models"
+    "github.com/feichai0017/document-processor/pkg/logger"
+)
+
+// FallbackProcessor 优先使用本地文本层处理 PDF，
+// 只有扫描件（没有文本层）或超过 maxBytes 的文件交给 fallback 处理器（如 Textract 异步分析）
+type FallbackProcessor struct {
+    local    *Processor
+    fallback document.Processor
+    maxBytes int64
+    logger   logger.Logger
+}
+
+// NewFallbackProcessor maxBytes 为 0 时不按大小切换
+func NewFallbackProcessor(local *Processor, fallback document.Processor, maxBytes int64, log logger.Logger) *FallbackProcessor {
+    return &FallbackProcessor{
+        local:    local,
+        fallback: fallback,
+        maxBytes: maxBytes,
+        logger:   log,
+    }
+}
+
+func (p *FallbackProcessor) CanProcess(mimeType string) bool {
+    return p.local.CanProcess(mimeType)
+}
+
+func (p *FallbackProcessor) Process(ctx context.Context, file io.Reader) ([]models.DocumentChunk, error) {
+    content, err := io.ReadAll(file)
+    if err != nil {
+        return nil, err
+    }
+
+    if p.maxBytes > 0 && int64(len(content)) > p.maxBytes {
+        p.logger.Info("PDF exceeds local processing limit, using fallback processor",
+            logger.Int("size", len(content)),
+            logger.Int64("maxBytes", p.maxBytes),
+        )
+        return p.fallback.Process(ctx, bytes.NewReader(content))
+    }
+
+    chunks, err := p.local.Process(ctx, bytes.NewReader(content))
+    if err != nil {
+        return nil, err
+    }
+    if hasText(chunks) {
+        return chunks, nil
+    }
+
+    p.logger.Info("PDF has no text layer, using fallback processor",
+        logger.Int("pages", len(chunks)),
+    )
+    return p.fallback.Process(ctx, bytes.NewReader(content))
+}
+
+func (p *FallbackProcessor) ExtractMetadata(ctx context.Context, file io.Reader) (models.DocumentMetadata, error) {
+    return p.local.ExtractMetadata(ctx, file)
+}
+
+// Close 只关闭本地处理器，fallback 处理器由工厂共享
+func (p *FallbackProcessor) Close() error {
+    return p.local.Close()
+}
+
+// hasText 判断是否有任一页提取到了文本，扫描件的所有页都没有文本层
+func hasText(chunks []models.DocumentChunk) bool {
+    for _, chunk := range chunks {
+        if strings.TrimSpace(chunk.Content) != "" {
+            return true
+        }
+    }
+    return false
+}
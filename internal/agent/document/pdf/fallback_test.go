@@ -0,0 +1,74 @@
+package pdf
+
+import (
+    "context"
+    "io"
+    "strings"
+    "testing"
+
+    "github.com/feichai0017/document-processor/internal/agent/document"
+    "github.com/feichai0017/document-processor/internal/models"
+    "github.com/feichai0017/document-processor/pkg/logger"
+)
+
+type stubProcessor struct {
+    calls int
+}
+
+func (p *stubProcessor) CanProcess(mimeType string) bool { return true }
+
+func (p *stubProcessor) Process(ctx context.Context, reader io.Reader) ([]models.DocumentChunk, error) {
+    p.calls++
+    return []models.DocumentChunk{{Content: "from fallback"}}, nil
+}
+
+func (p *stubProcessor) ExtractMetadata(ctx context.Context, reader io.Reader) (models.DocumentMetadata, error) {
+    return models.DocumentMetadata{}, nil
+}
+
+func (p *stubProcessor) Close() error { return nil }
+
+func TestFallbackProcessorOversized(t *testing.T) {
+    fallback := &stubProcessor{}
+    log := logger.NewTestLogger()
+    processor := NewFallbackProcessor(NewProcessor(log), fallback, 8, log)
+
+    chunks, err := processor.Process(context.Background(), strings.NewReader("%PDF-1.7 larger than the limit"))
+    if err != nil {
+        t.Fatalf("Process() error = %v", err)
+    }
+    if fallback.calls != 1 || len(chunks) != 1 || chunks[0].Content != "from fallback" {
+        t.Fatalf("oversized pdf was not sent to the fallback processor: calls=%d chunks=%v", fallback.calls, chunks)
+    }
+}
+
+func TestFallbackProcessorCorruptInputIsNotRetried(t *testing.T) {
+    fallback := &stubProcessor{}
+    log := logger.NewTestLogger()
+    processor := NewFallbackProcessor(NewProcessor(log), fallback, 0, log)
+
+    _, err := processor.Process(context.Background(), strings.NewReader("not a pdf"))
+    if document.ClassifyError(err) != document.ErrorClassCorruptInput {
+        t.Fatalf("Process() error = %v, want corrupt input", err)
+    }
+    if fallback.calls != 0 {
+        t.Fatalf("corrupt pdf was sent to the fallback processor")
+    }
+}
+
+func TestHasText(t *testing.T) {
+    tests := []struct {
+        name   string
+        chunks []models.DocumentChunk
+        want   bool
+    }{
+        {"no pages", nil, false},
+        {"blank pages", []models.DocumentChunk{{Content: ""}, {Content: " \n\t"}}, false},
+        {"one page with text", []models.DocumentChunk{{Content: ""}, {Content: "Invoice"}}, true},
+    }
+    for _, tt := range tests {
+        if got := hasText(tt.chunks); got != tt.want {
+            t.Errorf("%s: hasText() = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
@@ -7,11 +7,13 @@ import (
     "encoding/hex"
     "fmt"
     "io"
+    "sync/atomic"
     "time"
 
     "github.com/ledongthuc/pdf"
     "golang.org/x/sync/errgroup"
     
+    "github.com/feichai0017/document-processor/internal/agent/document"
     "github.com/feichai0017/document-processor/internal/models"
     "github.com/feichai0017/document-processor/pkg/logger"
 )
@@ -43,11 +45,13 @@ func (p *Processor) Process(ctx context.Context, file io.Reader) ([]models.Docum
     // use correct parameters to call pdf.NewReader
     pdfReader, err := pdf.NewReader(reader, reader.Size())
     if err != nil {
-        return nil, err
+        return nil, document.Errorf(document.ErrorClassCorruptInput, "failed to open pdf: %w", err)
     }
 
     numPages := pdfReader.NumPage()
     chunks := make([]models.DocumentChunk, 0, numPages)
+    document.ReportProgress(ctx, document.StageProcessing, 0, numPages)
+    var pagesDone int64
 
     // calculate file hash
     hash := sha256.Sum256(content)
@@ -72,16 +76,22 @@ func (p *Processor) Process(ctx context.Context, file io.Reader) ([]models.Docum
             case <-ctx.Done():
                 return ctx.Err()
             }
+            // 取消后不再开始新的页面
+            if err := ctx.Err(); err != nil {
+                return err
+            }
             
             page := pdfReader.Page(pageNum)
             if page.V.IsNull() {
+                document.ReportProgress(ctx, document.StageProcessing, int(atomic.AddInt64(&pagesDone, 1)), numPages)
                 return nil
             }
 
             text, err := page.GetPlainText(nil)
             if err != nil {
-                return fmt.Errorf("failed to get text from page %d: %w", pageNum, err)
+                return document.Errorf(document.ErrorClassCorruptInput, "failed to get text from page %d: %w", pageNum, err)
             }
+            document.ReportProgress(ctx, document.StageProcessing, int(atomic.AddInt64(&pagesDone, 1)), numPages)
 
             chunk := models.DocumentChunk{
                 Content: text,
@@ -119,6 +129,25 @@ func (p *Processor) Process(ctx context.Context, file io.Reader) ([]models.Docum
     return p.postProcessChunks(chunks)
 }
 
+// FirstPageText returns the text layer of the first page, used to classify
+// the document before the full pipeline runs
+func FirstPageText(content []byte) (string, error) {
+    reader := bytes.NewReader(content)
+    pdfReader, err := pdf.NewReader(reader, reader.Size())
+    if err != nil {
+        return "", err
+    }
+    if pdfReader.NumPage() == 0 {
+        return "", nil
+    }
+
+    page := pdfReader.Page(1)
+    if page.V.IsNull() {
+        return "", nil
+    }
+    return page.GetPlainText(nil)
+}
+
 func (p *Processor) ExtractMetadata(ctx context.Context, file io.Reader) (models.DocumentMetadata, error) {
     content, err := io.ReadAll(file)
     if err != nil {
@@ -12,7 +12,7 @@ type Processor interface {
     // CanProcess 检查是否可以处理指定MIME类型的文件
     CanProcess(mimeType string) bool
     
-    // Process 处理文档并返回文档块
+    // Process 处理文档并返回文档块，处理过程中通过 ReportProgress 报告进度
     Process(ctx context.Context, reader io.Reader) ([]models.DocumentChunk, error)
     
     // ExtractMetadata 提取文档元数据
@@ -0,0 +1,53 @@
+package document
+
+import (
+    "context"
+)
+
+// 处理阶段
+const (
+    StageClassifying    = "classifying"     // 识别文档类型
+    StageAnalyzing      = "analyzing"       // 等待外部服务（如 Textract 异步分析）完成，页数未知
+    StageProcessing     = "processing"      // 逐页 OCR 或文本提取
+    StageCorrecting     = "correcting"      // 视觉 LLM 校正
+    StageExtracting     = "extracting"      // 结构化抽取
+    StagePostProcessing = "post_processing" // 摘要、翻译
+    StageStoring        = "storing"         // 保存结果
+)
+
+// Progress 处理进度，PagesTotal 为 0 表示总页数未知
+type Progress struct {
+    Stage      string
+    PagesDone  int
+    PagesTotal int
+}
+
+// ProgressReporter 接收处理器报告的进度，实现需要支持并发调用
+type ProgressReporter interface {
+    ReportProgress(ctx context.Context, progress Progress)
+}
+
+// ProgressFunc 函数形式的 ProgressReporter
+type ProgressFunc func(ctx context.Context, progress Progress)
+
+func (f ProgressFunc) ReportProgress(ctx context.Context, progress Progress) {
+    f(ctx, progress)
+}
+
+type progressKey struct{}
+
+// WithProgressReporter 将进度接收方附加到 context 上
+func WithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+    return context.WithValue(ctx, progressKey{}, reporter)
+}
+
+// ReportProgress 报告进度，context 上没有接收方时不做任何事
+func ReportProgress(ctx context.Context, stage string, pagesDone, pagesTotal int) {
+    if reporter, ok := ctx.Value(progressKey{}).(ProgressReporter); ok && reporter != nil {
+        reporter.ReportProgress(ctx, Progress{
+            Stage:      stage,
+            PagesDone:  pagesDone,
+            PagesTotal: pagesTotal,
+        })
+    }
+}
@@ -0,0 +1,42 @@
+package document
+
+import (
+    "context"
+    "sync"
+
+    "github.com/feichai0017/document-processor/internal/models"
+)
+
+// ReviewCollector 收集不属于任何文档块的待复核区域（如低置信度的表单键值对），
+// 由调用方合并到文档级的复核列表，实现支持并发调用
+type ReviewCollector struct {
+    mu    sync.Mutex
+    items []models.ReviewItem
+}
+
+// Items 返回已收集的复核项
+func (c *ReviewCollector) Items() []models.ReviewItem {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return append([]models.ReviewItem(nil), c.items...)
+}
+
+type reviewKey struct{}
+
+// WithReviewCollector 在 context 上附加复核项收集器
+func WithReviewCollector(ctx context.Context) (context.Context, *ReviewCollector) {
+    collector := &ReviewCollector{}
+    return context.WithValue(ctx, reviewKey{}, collector), collector
+}
+
+// ReportReview 报告文档级的复核项，context 上没有收集器时不做任何事
+func ReportReview(ctx context.Context, items ...models.ReviewItem) {
+    if len(items) == 0 {
+        return
+    }
+    if collector, ok := ctx.Value(reviewKey{}).(*ReviewCollector); ok && collector != nil {
+        collector.mu.Lock()
+        collector.items = append(collector.items, items...)
+        collector.mu.Unlock()
+    }
+}
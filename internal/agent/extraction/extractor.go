@@ -0,0 +1,230 @@
+package extraction
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/feichai0017/document-processor/internal/agent/document/image"
+	"github.com/feichai0017/document-processor/internal/agent/prompts"
+	"github.com/feichai0017/document-processor/pkg/jsonschema"
+	"github.com/feichai0017/document-processor/pkg/logger"
+)
+
+// ChatClient 抽取所需的 LLM 对话接口
+type ChatClient interface {
+	Chat(ctx context.Context, messages []image.ChatMessage, format json.RawMessage) (string, error)
+}
+
+// Config 抽取配置
+type Config struct {
+	MaxAttempts   int               // 校验失败后携带错误重试，包含第一次调用
+	MaxInputChars int               // 发送给模型的文档文本上限
+	Prompts       *prompts.Registry // 系统提示词模板库，为空时使用内置模板
+	Cache         *image.LLMCache   // 通过校验的抽取结果缓存，为空时不缓存
+	Model         string            // 模型名称，作为缓存键的一部分
+}
+
+// Request 抽取请求
+type Request struct {
+	Schema        json.RawMessage
+	Text          string   // 文档文本（OCR 或 PDF 提取结果）
+	Images        []string // 可选，base64 编码的页面图像
+	Instructions  string   // 可选，文档类型相关的附加说明
+	DocumentType  string   // 用于选择提示词模板
+	PromptVersion int      // 固定提示词版本，0 为最新版本
+}
+
+// Result 抽取结果，Valid 为 false 时 Data 为最后一次未通过校验的输出
+type Result struct {
+	Data          json.RawMessage         `json:"-"`
+	Attempts      int                     `json:"attempts"`
+	Valid         bool                    `json:"valid"`
+	Errors        []jsonschema.FieldError `json:"errors,omitempty"` // 最后一次校验错误
+	PromptID      string                  `json:"promptId"`
+	PromptVersion int                     `json:"promptVersion"`
+	Cached        bool                    `json:"cached,omitempty"` // 命中缓存，Attempts 为 0
+}
+
+// Extractor 使用 LLM 按调用方提供的 JSON Schema 抽取字段
+type Extractor struct {
+	client ChatClient
+	logger logger.Logger
+	config *Config
+}
+
+func NewExtractor(client ChatClient, log logger.Logger, cfg *Config) (*Extractor, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if cfg.Prompts == nil {
+		registry, err := prompts.NewRegistry("", log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load prompts: %w", err)
+		}
+		cfg.Prompts = registry
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.MaxInputChars <= 0 {
+		cfg.MaxInputChars = 24000
+	}
+
+	return &Extractor{
+		client: client,
+		logger: log,
+		config: cfg,
+	}, nil
+}
+
+// Extract 调用模型抽取结构化数据并按 Schema 校验，
+// 校验失败时将错误反馈给模型重试，直到通过或达到最大尝试次数
+func (e *Extractor) Extract(ctx context.Context, req *Request) (*Result, error) {
+	schema, err := jsonschema.Parse(req.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	text := truncate(req.Text, e.config.MaxInputChars)
+
+	tmpl, err := e.config.Prompts.GetVersion(prompts.Extraction, req.DocumentType, "", req.PromptVersion)
+	if err != nil {
+		return nil, err
+	}
+	system, err := tmpl.Render(prompts.Data{
+		DocumentType: req.DocumentType,
+		Instructions: req.Instructions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := e.cacheKey(req, text, tmpl)
+	var cached json.RawMessage
+	if e.config.Cache.Get(ctx, cacheKey, &cached) {
+		return &Result{
+			Data:          cached,
+			Valid:         true,
+			PromptID:      tmpl.Key(),
+			PromptVersion: tmpl.Version,
+			Cached:        true,
+		}, nil
+	}
+
+	messages := []image.ChatMessage{
+		{Role: "system", Content: system},
+		{
+			Role: "user",
+			Content: fmt.Sprintf("JSON Schema:\n%s\n\nDocument:\n%s\n\nReturn the extracted JSON.",
+				string(req.Schema), text),
+			Images: req.Images,
+		},
+	}
+
+	result := &Result{
+		PromptID:      tmpl.Key(),
+		PromptVersion: tmpl.Version,
+	}
+	for attempt := 1; attempt <= e.config.MaxAttempts; attempt++ {
+		result.Attempts = attempt
+
+		output, err := e.client.Chat(ctx, messages, req.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("extraction request failed: %w", err)
+		}
+		output = trimCodeFence(output)
+
+		err = schema.Validate([]byte(output))
+		if err == nil {
+			result.Data = json.RawMessage(output)
+			result.Valid = true
+			result.Errors = nil
+			e.config.Cache.Set(ctx, cacheKey, result.Data)
+			return result, nil
+		}
+
+		var validationErr *jsonschema.ValidationError
+		if !errors.As(err, &validationErr) {
+			return nil, err
+		}
+		result.Errors = validationErr.Errors
+		if json.Valid([]byte(output)) {
+			// 保留最后一次输出，便于人工复核
+			result.Data = json.RawMessage(output)
+		}
+
+		e.logger.Warn("Extraction output failed schema validation",
+			logger.Int("attempt", attempt),
+			logger.String("error", validationErr.Error()),
+		)
+
+		// 将模型输出和校验错误反馈给模型
+		messages = append(messages,
+			image.ChatMessage{Role: "assistant", Content: output},
+			image.ChatMessage{Role: "user", Content: feedbackPrompt(validationErr)},
+		)
+	}
+
+	return result, nil
+}
+
+// cacheKey 抽取结果的缓存键，由图像、文本摘要、Schema、附加说明和提示词版本决定
+func (e *Extractor) cacheKey(req *Request, text string, tmpl *prompts.Template) image.LLMCacheKey {
+	images := make([]string, 0, len(req.Images))
+	for _, img := range req.Images {
+		images = append(images, image.HashBase64(img))
+	}
+
+	return image.LLMCacheKey{
+		Operation:     "extraction",
+		Images:        images,
+		Model:         e.config.Model,
+		PromptID:      tmpl.Key(),
+		PromptVersion: tmpl.Version,
+		Options: map[string]interface{}{
+			"schema":       image.HashBytes(req.Schema),
+			"text":         image.HashBytes([]byte(text)),
+			"instructions": req.Instructions,
+			"documentType": req.DocumentType,
+		},
+	}
+}
+
+func feedbackPrompt(err *jsonschema.ValidationError) string {
+	var b strings.Builder
+	b.WriteString("The JSON does not match the schema:\n")
+	for _, fe := range err.Errors {
+		b.WriteString("- ")
+		b.WriteString(fe.String())
+		b.WriteString("\n")
+	}
+	b.WriteString("Return the corrected JSON only.")
+	return b.String()
+}
+
+// trimCodeFence 去掉模型偶尔输出的 ```json 代码块包装
+func trimCodeFence(output string) string {
+	output = strings.TrimSpace(output)
+	if !strings.HasPrefix(output, "```") {
+		return output
+	}
+	output = strings.TrimPrefix(output, "```json")
+	output = strings.TrimPrefix(output, "```")
+	output = strings.TrimSuffix(output, "```")
+	return strings.TrimSpace(output)
+}
+
+// truncate 按字符数截断文本，不会切断多字节字符
+func truncate(text string, maxChars int) string {
+	if len(text) <= maxChars {
+		return text
+	}
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return string(runes[:maxChars])
+}
@@ -0,0 +1,30 @@
+package extraction
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text     string
+		maxChars int
+		want     string
+	}{
+		{"invoice", 10, "invoice"},
+		{"invoice", 3, "inv"},
+		{"发票号码：12345", 4, "发票号码"},
+		{"总计 ¥1,375", 4, "总计 ¥"},
+		{"中文", 2, "中文"},
+	}
+
+	for _, tt := range tests {
+		got := truncate(tt.text, tt.maxChars)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.text, tt.maxChars, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) produced invalid UTF-8", tt.text, tt.maxChars)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package postprocess
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopwords 各语言最常见的虚词，用于区分使用拉丁字母的语言
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "for", "with", "this", "that", "on", "are", "by", "shall", "be"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "mit", "von", "den", "zu", "für", "auf", "ein", "eine", "dem", "des", "wird", "sich"},
+	"fr": {"le", "les", "et", "des", "est", "pour", "une", "dans", "du", "que", "par", "sur", "au", "aux"},
+	"es": {"el", "los", "las", "y", "que", "para", "por", "con", "una", "es", "del", "se"},
+	"it": {"il", "lo", "gli", "e", "di", "che", "per", "con", "una", "del", "della", "è", "non"},
+	"pt": {"o", "os", "as", "e", "que", "para", "com", "uma", "não", "do", "da", "em", "ao"},
+}
+
+// latinLanguages 得分相同时按此顺序选择
+var latinLanguages = []string{"en", "de", "fr", "es", "it", "pt"}
+
+// detectLanguage 按文字系统和常见虚词粗略判断文本的语言，返回语言代码，无法判断时返回空
+func detectLanguage(text string) string {
+	var han, kana, hangul, cyrillic, latin int
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	// 一个汉字的信息量约等于几个字母，中文文本中常夹杂编号和英文名称
+	switch {
+	case kana > 0 && kana*4 >= han:
+		return "ja"
+	case han > 0 && han*3 >= latin:
+		return "zh"
+	case hangul > 0 && hangul*3 >= latin:
+		return "ko"
+	case cyrillic > latin:
+		return "ru"
+	case latin == 0:
+		return ""
+	}
+
+	scores := make(map[string]int, len(latinLanguages))
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		for lang, words := range stopwords {
+			for _, w := range words {
+				if word == w {
+					scores[lang]++
+				}
+			}
+		}
+		// 变音字母和 ß 基本只出现在德语中
+		if strings.ContainsAny(word, "äöüß") {
+			scores["de"]++
+		}
+	}
+
+	best, bestScore := "", 0
+	for _, lang := range latinLanguages {
+		if scores[lang] > bestScore {
+			best, bestScore = lang, scores[lang]
+		}
+	}
+	return best
+}
+
+// baseLanguage 去掉地区部分并转为小写，如 pt-BR → pt
+func baseLanguage(code string) string {
+	return strings.ToLower(strings.SplitN(strings.TrimSpace(code), "-", 2)[0])
+}
@@ -0,0 +1,301 @@
+package postprocess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/feichai0017/document-processor/internal/agent/document/image"
+	"github.com/feichai0017/document-processor/internal/agent/prompts"
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/pkg/logger"
+)
+
+// ChatClient 后处理所需的 LLM 对话接口
+type ChatClient interface {
+	Chat(ctx context.Context, messages []image.ChatMessage, format json.RawMessage) (string, error)
+}
+
+// Config 后处理配置
+type Config struct {
+	MaxInputChars int               // 单次摘要请求的文本上限，超过时分段摘要后合并
+	Concurrency   int               // 并发翻译的块数
+	Prompts       *prompts.Registry // 为空时使用内置模板
+	Cache         *image.LLMCache   // 为空时不缓存
+	Model         string            // 模型名称，作为缓存键的一部分
+	// SourceLanguages 只翻译检测为这些语言的内容块，如 zh、de；为空时翻译所有块。
+	// 已经是目标语言的块总是跳过
+	SourceLanguages []string
+}
+
+// SummaryRequest 摘要请求
+type SummaryRequest struct {
+	Text          string
+	Length        models.SummaryLength
+	DocumentType  string // 用于选择提示词模板，如合同使用 summary/contract
+	Instructions  string
+	PromptVersion int
+}
+
+// TranslationRequest 翻译请求，Chunks 与文档内容块一一对应
+type TranslationRequest struct {
+	Chunks         []models.TranslatedChunk // Text 为原文
+	TargetLanguage string
+	DocumentType   string
+	PromptVersion  int
+}
+
+// PostProcessor 在文档处理完成后调用 LLM 生成摘要和译文
+type PostProcessor struct {
+	client ChatClient
+	logger logger.Logger
+	config *Config
+}
+
+func NewPostProcessor(client ChatClient, log logger.Logger, cfg *Config) (*PostProcessor, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if cfg.Prompts == nil {
+		registry, err := prompts.NewRegistry("", log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load prompts: %w", err)
+		}
+		cfg.Prompts = registry
+	}
+	if cfg.MaxInputChars <= 0 {
+		cfg.MaxInputChars = 24000
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+
+	return &PostProcessor{
+		client: client,
+		logger: log,
+		config: cfg,
+	}, nil
+}
+
+// Summarize 生成摘要，长文档先分段摘要，再将各段摘要合并为最终摘要
+func (p *PostProcessor) Summarize(ctx context.Context, req *SummaryRequest) (*models.Summary, error) {
+	if err := req.Length.Validate(); err != nil {
+		return nil, err
+	}
+	length := req.Length
+	if length == "" {
+		length = models.SummaryMedium
+	}
+
+	tmpl, err := p.config.Prompts.GetVersion(prompts.Summary, req.DocumentType, "", req.PromptVersion)
+	if err != nil {
+		return nil, err
+	}
+	system, err := tmpl.Render(prompts.Data{
+		DocumentType: req.DocumentType,
+		Instructions: req.Instructions,
+		MaxWords:     length.Words(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	segments := splitText(req.Text, p.config.MaxInputChars)
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("document has no text to summarize")
+	}
+
+	summary := &models.Summary{
+		Length:        length,
+		Segments:      len(segments),
+		PromptID:      tmpl.Key(),
+		PromptVersion: tmpl.Version,
+	}
+
+	text := segments[0]
+	if len(segments) > 1 {
+		partials := make([]string, len(segments))
+		for i, segment := range segments {
+			partial, err := p.chat(ctx, "summary", tmpl, system, segment)
+			if err != nil {
+				return nil, fmt.Errorf("failed to summarize segment %d: %w", i+1, err)
+			}
+			partials[i] = partial
+		}
+		text = strings.Join(partials, "\n\n")
+	}
+
+	summary.Text, err = p.chat(ctx, "summary", tmpl, system, text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize document: %w", err)
+	}
+	return summary, nil
+}
+
+// Translate 逐块翻译，单个块失败时记录错误并继续翻译其他块；
+// 原文语言不在 SourceLanguages 中或已是目标语言的块标记为跳过
+func (p *PostProcessor) Translate(ctx context.Context, req *TranslationRequest) (*models.Translation, error) {
+	if req.TargetLanguage == "" {
+		return nil, fmt.Errorf("target language is required")
+	}
+	if err := models.ValidateLanguage(req.TargetLanguage); err != nil {
+		return nil, err
+	}
+
+	tmpl, err := p.config.Prompts.GetVersion(prompts.Translation, req.DocumentType, "", req.PromptVersion)
+	if err != nil {
+		return nil, err
+	}
+	system, err := tmpl.Render(prompts.Data{
+		DocumentType:   req.DocumentType,
+		TargetLanguage: languageName(req.TargetLanguage),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	translation := &models.Translation{
+		TargetLanguage: req.TargetLanguage,
+		Chunks:         make([]models.TranslatedChunk, len(req.Chunks)),
+		PromptID:       tmpl.Key(),
+		PromptVersion:  tmpl.Version,
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(p.config.Concurrency)
+	for i, chunk := range req.Chunks {
+		i, chunk := i, chunk
+		translation.Chunks[i].Position = chunk.Position
+		if strings.TrimSpace(chunk.Text) == "" {
+			continue
+		}
+		language := detectLanguage(chunk.Text)
+		translation.Chunks[i].SourceLanguage = language
+		if !p.translatable(language, req.TargetLanguage) {
+			translation.Chunks[i].Skipped = true
+			continue
+		}
+
+		g.Go(func() error {
+			text, err := p.chat(gctx, "translation", tmpl, system, chunk.Text)
+			if err != nil {
+				if gctx.Err() != nil {
+					return gctx.Err()
+				}
+				// 没有可用实例时其余块也会失败，整体放弃由调用方跳过翻译
+				if errors.Is(err, image.ErrLLMUnavailable) {
+					return err
+				}
+				p.logger.Warn("Failed to translate chunk",
+					logger.Int("position", chunk.Position),
+					logger.Error(err),
+				)
+				translation.Chunks[i].Error = err.Error()
+				return nil
+			}
+			translation.Chunks[i].Text = text
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return translation, nil
+}
+
+// translatable 检测到的原文语言是否需要翻译，未能检测语言时只在没有限制源语言时翻译
+func (p *PostProcessor) translatable(language, target string) bool {
+	if language != "" && language == baseLanguage(target) {
+		return false
+	}
+	if len(p.config.SourceLanguages) == 0 {
+		return true
+	}
+	for _, source := range p.config.SourceLanguages {
+		if language != "" && baseLanguage(source) == language {
+			return true
+		}
+	}
+	return false
+}
+
+// chat 发送一次系统提示词加文本的对话，结果按提示词版本和文本缓存
+func (p *PostProcessor) chat(ctx context.Context, operation string, tmpl *prompts.Template, system, text string) (string, error) {
+	key := image.LLMCacheKey{
+		Operation:     operation,
+		Model:         p.config.Model,
+		PromptID:      tmpl.Key(),
+		PromptVersion: tmpl.Version,
+		Options: map[string]interface{}{
+			"system": image.HashBytes([]byte(system)),
+			"text":   image.HashBytes([]byte(text)),
+		},
+	}
+
+	var output string
+	if p.config.Cache.Get(ctx, key, &output) {
+		return output, nil
+	}
+
+	output, err := p.client.Chat(ctx, []image.ChatMessage{
+		{Role: "system", Content: system},
+		{Role: "user", Content: text},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	output = strings.TrimSpace(output)
+	p.config.Cache.Set(ctx, key, output)
+	return output, nil
+}
+
+// splitText 按字符数切分文本，尽量在换行处断开，不会切断多字节字符
+func splitText(text string, maxChars int) []string {
+	runes := []rune(strings.TrimSpace(text))
+	var segments []string
+	for len(runes) > 0 {
+		if len(runes) <= maxChars {
+			segments = append(segments, string(runes))
+			break
+		}
+
+		cut := maxChars
+		for i := maxChars - 1; i > maxChars/2; i-- {
+			if runes[i] == '\n' {
+				cut = i + 1
+				break
+			}
+		}
+		segments = append(segments, strings.TrimSpace(string(runes[:cut])))
+		runes = []rune(strings.TrimSpace(string(runes[cut:])))
+	}
+	return segments
+}
+
+// languageNames 常用语言代码对应的名称，提示词中使用名称比代码更可靠
+var languageNames = map[string]string{
+	"en": "English",
+	"zh": "Chinese",
+	"de": "German",
+	"fr": "French",
+	"es": "Spanish",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"pt": "Portuguese",
+	"ru": "Russian",
+}
+
+func languageName(code string) string {
+	base := baseLanguage(code)
+	if name, ok := languageNames[base]; ok {
+		return name
+	}
+	return code
+}
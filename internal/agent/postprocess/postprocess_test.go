@@ -0,0 +1,245 @@
+package postprocess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/feichai0017/document-processor/internal/agent/document/image"
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/internal/testutil/fakeollama"
+	"github.com/feichai0017/document-processor/pkg/logger"
+)
+
+func newTestPostProcessor(t *testing.T, cfg *Config, llm *image.OllamaConfig) (*PostProcessor, *fakeollama.Server) {
+	t.Helper()
+
+	server := fakeollama.NewServer()
+	t.Cleanup(server.Close)
+	// 回复中带上用户消息，便于按块核对译文
+	server.SetReply(func(req fakeollama.ChatRequest) string {
+		return "reply: " + req.Messages[len(req.Messages)-1].Content
+	})
+
+	if llm == nil {
+		llm = &image.OllamaConfig{}
+	}
+	llm.Endpoint = server.URL()
+	llm.Model = "llama3.2"
+	if llm.BreakerCooldown == 0 {
+		llm.BreakerCooldown = time.Minute
+	}
+	pool, err := image.NewVisionLLMPool(llm)
+	if err != nil {
+		t.Fatalf("NewVisionLLMPool() error = %v", err)
+	}
+	t.Cleanup(func() { pool.Close() })
+
+	p, err := NewPostProcessor(pool, logger.NewTestLogger(), cfg)
+	if err != nil {
+		t.Fatalf("NewPostProcessor() error = %v", err)
+	}
+	return p, server
+}
+
+func systemPrompt(req fakeollama.ChatRequest) string {
+	return req.Messages[0].Content
+}
+
+func TestSummarizeLength(t *testing.T) {
+	tests := []struct {
+		length     models.SummaryLength
+		wantLength models.SummaryLength
+		wantWords  string
+	}{
+		{models.SummaryShort, models.SummaryShort, "about 60 words"},
+		{models.SummaryMedium, models.SummaryMedium, "about 150 words"},
+		{models.SummaryLong, models.SummaryLong, "about 400 words"},
+		{"", models.SummaryMedium, "about 150 words"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.wantLength), func(t *testing.T) {
+			p, server := newTestPostProcessor(t, nil, nil)
+
+			summary, err := p.Summarize(context.Background(), &SummaryRequest{Text: "Contract text", Length: tt.length})
+			if err != nil {
+				t.Fatalf("Summarize() error = %v", err)
+			}
+			if summary.Length != tt.wantLength || summary.Segments != 1 || summary.Text != "reply: Contract text" {
+				t.Errorf("summary = %+v", summary)
+			}
+			if summary.PromptID != "summary/default" || summary.PromptVersion != 1 {
+				t.Errorf("prompt = %s v%d", summary.PromptID, summary.PromptVersion)
+			}
+
+			requests := server.Requests()
+			if len(requests) != 1 || !strings.Contains(systemPrompt(requests[0]), tt.wantWords) {
+				t.Errorf("system prompt = %q, want %q", systemPrompt(requests[0]), tt.wantWords)
+			}
+		})
+	}
+
+	p, _ := newTestPostProcessor(t, nil, nil)
+	if _, err := p.Summarize(context.Background(), &SummaryRequest{Text: "text", Length: "huge"}); err == nil {
+		t.Error("Summarize() accepted an unknown length")
+	}
+}
+
+func TestSummarizeContractPrompt(t *testing.T) {
+	p, _ := newTestPostProcessor(t, nil, nil)
+	summary, err := p.Summarize(context.Background(), &SummaryRequest{Text: "text", DocumentType: "contract"})
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if summary.PromptID != "summary/contract" {
+		t.Errorf("PromptID = %s, want summary/contract", summary.PromptID)
+	}
+}
+
+func TestSummarizeLongDocumentInSegments(t *testing.T) {
+	p, server := newTestPostProcessor(t, &Config{MaxInputChars: 20}, nil)
+
+	text := "第一段的内容较长。\n第二段的内容也较长。\n第三段内容。"
+	summary, err := p.Summarize(context.Background(), &SummaryRequest{Text: text, Length: models.SummaryShort})
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if summary.Segments < 2 {
+		t.Fatalf("Segments = %d, want the text split", summary.Segments)
+	}
+	// 每段一次请求，再合并一次
+	requests := server.Requests()
+	if len(requests) != summary.Segments+1 {
+		t.Errorf("requests = %d, want %d", len(requests), summary.Segments+1)
+	}
+	for _, req := range requests {
+		if !strings.Contains(systemPrompt(req), "about 60 words") {
+			t.Errorf("segment prompt lost the summary length: %q", systemPrompt(req))
+		}
+	}
+}
+
+var translationChunks = []models.TranslatedChunk{
+	{Position: 0, Text: "本合同由甲乙双方签订，付款期限为三十天。"},
+	{Position: 1, Text: "Der Käufer zahlt den Betrag innerhalb von 30 Tagen auf das Konto des Verkäufers."},
+	{Position: 2, Text: "The buyer pays the amount within 30 days."},
+	{Position: 3, Text: "Le paiement est dû dans les trente jours pour une commande."},
+	{Position: 4, Text: "   "},
+}
+
+func TestTranslateConfiguredSourceLanguages(t *testing.T) {
+	p, server := newTestPostProcessor(t, &Config{SourceLanguages: []string{"zh", "DE"}}, nil)
+
+	translation, err := p.Translate(context.Background(), &TranslationRequest{Chunks: translationChunks, TargetLanguage: "en-US"})
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+
+	want := []models.TranslatedChunk{
+		{Position: 0, Text: "reply: " + translationChunks[0].Text, SourceLanguage: "zh"},
+		{Position: 1, Text: "reply: " + translationChunks[1].Text, SourceLanguage: "de"},
+		{Position: 2, SourceLanguage: "en", Skipped: true},
+		{Position: 3, SourceLanguage: "fr", Skipped: true},
+		{Position: 4},
+	}
+	if fmt.Sprint(translation.Chunks) != fmt.Sprint(want) {
+		t.Errorf("chunks = %+v\nwant %+v", translation.Chunks, want)
+	}
+	if translation.TargetLanguage != "en-US" || translation.PromptID != "translation/default" {
+		t.Errorf("translation = %+v", translation)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("requests = %d, want only the zh and de chunks", len(requests))
+	}
+	if !strings.Contains(systemPrompt(requests[0]), "into English") {
+		t.Errorf("system prompt = %q", systemPrompt(requests[0]))
+	}
+}
+
+func TestTranslateAllSourceLanguagesByDefault(t *testing.T) {
+	p, server := newTestPostProcessor(t, nil, nil)
+
+	translation, err := p.Translate(context.Background(), &TranslationRequest{Chunks: translationChunks, TargetLanguage: "en"})
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	// 只跳过已经是目标语言的块
+	for i, chunk := range translation.Chunks[:4] {
+		if skipped := i == 2; chunk.Skipped != skipped || (chunk.Text != "") == skipped {
+			t.Errorf("chunk %d = %+v", i, chunk)
+		}
+	}
+	if got := len(server.Requests()); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestTranslateChunkFailure(t *testing.T) {
+	p, server := newTestPostProcessor(t, &Config{Concurrency: 1}, &image.OllamaConfig{FailureThreshold: 5})
+	server.FailNext(1)
+
+	translation, err := p.Translate(context.Background(), &TranslationRequest{Chunks: translationChunks[:2], TargetLanguage: "en"})
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if translation.Chunks[0].Error == "" || translation.Chunks[0].Text != "" {
+		t.Errorf("failed chunk = %+v", translation.Chunks[0])
+	}
+	if translation.Chunks[1].Error != "" || translation.Chunks[1].Text == "" {
+		t.Errorf("other chunk = %+v", translation.Chunks[1])
+	}
+}
+
+func TestBreakerOpen(t *testing.T) {
+	p, server := newTestPostProcessor(t, nil, &image.OllamaConfig{FailureThreshold: 1})
+	server.FailNext(1)
+
+	ctx := context.Background()
+	if _, err := p.Summarize(ctx, &SummaryRequest{Text: "text"}); err == nil || errors.Is(err, image.ErrLLMUnavailable) {
+		t.Fatalf("first Summarize() error = %v, want the server error", err)
+	}
+
+	// 熔断后不再发送请求，调用方据此跳过摘要和翻译
+	_, err := p.Summarize(ctx, &SummaryRequest{Text: "text"})
+	if !errors.Is(err, image.ErrLLMUnavailable) {
+		t.Errorf("Summarize() error = %v, want ErrLLMUnavailable", err)
+	}
+	_, err = p.Translate(ctx, &TranslationRequest{Chunks: translationChunks, TargetLanguage: "en"})
+	if !errors.Is(err, image.ErrLLMUnavailable) {
+		t.Errorf("Translate() error = %v, want ErrLLMUnavailable", err)
+	}
+	if got := len(server.Requests()); got != 1 {
+		t.Errorf("open breaker still sent requests: %d", got)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"发票号码：INV-1001，合计金额 ¥1,375", "zh"},
+		{"本合同自 2024 年 1 月 1 日起生效", "zh"},
+		{"請求書の合計金額は 1,375 円です", "ja"},
+		{"계약 기간은 1년입니다", "ko"},
+		{"Договор вступает в силу", "ru"},
+		{"Die Rechnung ist innerhalb von 14 Tagen zu zahlen", "de"},
+		{"Größe und Gewicht", "de"},
+		{"This agreement is made by and between the parties", "en"},
+		{"La facture est payable dans les trente jours", "fr"},
+		{"El pago se realiza por transferencia para la empresa", "es"},
+		{"INV-1001 1,375.00", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.text); got != tt.want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
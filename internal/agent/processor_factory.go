@@ -4,11 +4,14 @@ import (
     "context"
     "fmt"
     "strings"
+    "time"
 
     "github.com/aws/aws-sdk-go-v2/service/textract/types"
     "github.com/feichai0017/document-processor/internal/agent/document"
     "github.com/feichai0017/document-processor/internal/agent/document/pdf"
     "github.com/feichai0017/document-processor/internal/agent/document/image"
+    "github.com/feichai0017/document-processor/internal/agent/prompts"
+    "github.com/feichai0017/document-processor/internal/models"
     "github.com/feichai0017/document-processor/pkg/logger"
     cfg "github.com/feichai0017/document-processor/config"
 )
@@ -24,20 +27,49 @@ var extToMIME = map[string]string{
     ".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
 }
 
+// expenseFileTypes AnalyzeExpense 同步接口支持的文件类型，PDF 只支持单页，由处理器检查页数
+var expenseFileTypes = map[string]bool{
+    ".jpg":  true,
+    ".jpeg": true,
+    ".png":  true,
+    ".pdf":  true,
+}
+
+// SupportsExpense 判断文件类型能否进入费用分析模式
+func SupportsExpense(fileType string) bool {
+    return expenseFileTypes[strings.ToLower(fileType)]
+}
+
 type ProcessorFactory struct {
-    processors map[string]document.Processor
-    logger     logger.Logger
+    processors     map[string]document.Processor
+    modeProcessors map[models.ProcessingMode]document.Processor // 按处理模式选择，优先于 MIME 类型
+    engines        map[string]document.Processor                // 按引擎名称选择，由文档类型的处理配置指定
+    logger         logger.Logger
+}
+
+// FactoryOptions 处理器共享的 LLM 资源，均可为空
+type FactoryOptions struct {
+    LLM     *image.VisionLLMPool // 本地 OCR 的 LLM 校正使用，为空时只做 OCR
+    Prompts *prompts.Registry
+    Cache   *image.LLMCache
 }
 
-func NewProcessorFactory(logger logger.Logger) (ProcessorFactory, error) {
+func NewProcessorFactory(logger logger.Logger, opts *FactoryOptions) (ProcessorFactory, error) {
+    if opts == nil {
+        opts = &FactoryOptions{}
+    }
+
     factory := &ProcessorFactory{
-        processors: make(map[string]document.Processor),
-        logger:     logger,
+        processors:     make(map[string]document.Processor),
+        modeProcessors: make(map[models.ProcessingMode]document.Processor),
+        engines:        make(map[string]document.Processor),
+        logger:         logger,
     }
 
     // 初始化 PDF 处理器
     pdfProcessor := pdf.NewProcessor(logger)
     factory.processors["application/pdf"] = pdfProcessor
+    factory.engines["pdf"] = pdfProcessor
 
     textractCfg := cfg.GetTextractConfig()
 
@@ -46,6 +78,7 @@ func NewProcessorFactory(logger logger.Logger) (ProcessorFactory, error) {
         Region:        textractCfg.Region,
         AccessKey:     textractCfg.AccessKey,
         SecretKey:     textractCfg.SecretKey,
+        Endpoint:      textractCfg.Endpoint,
         MinConfidence: 80.0,
         EnableTable:   true,
         EnableForm:    true,
@@ -53,6 +86,10 @@ func NewProcessorFactory(logger logger.Logger) (ProcessorFactory, error) {
             types.FeatureTypeTables,
             types.FeatureTypeForms,
         },
+        EnableAsync:  textractCfg.EnableAsync,
+        S3Bucket:     textractCfg.BucketName,
+        PollInterval: 5 * time.Second,
+        MaxPollTime:  15 * time.Minute,
     }
 
     textractProcessor, err := image.NewTextractProcessor(context.Background(), textractConfig, logger)
@@ -66,16 +103,42 @@ func NewProcessorFactory(logger logger.Logger) (ProcessorFactory, error) {
     factory.processors["image/png"] = textractProcessor
     factory.processors["image/tiff"] = textractProcessor
 
-    /* 
-    imageProcessor, err := image.NewProcessor(logger, nil)
+    // 发票/收据的费用分析由 Textract AnalyzeExpense 完成
+    factory.modeProcessors[models.ModeExpense] = textractProcessor
+    factory.engines["textract"] = textractProcessor
+
+    // 启用异步分析后，PDF 仍优先读取本地文本层，只有扫描件和超大文件交给 Textract
+    if textractProcessor.CanProcess("application/pdf") {
+        factory.processors["application/pdf"] = pdf.NewFallbackProcessor(pdfProcessor, textractProcessor, textractCfg.PDFMaxLocalBytes, logger)
+    }
+
+    // 初始化本地 OCR 处理器（Tesseract，启用 LLM 时做 OCR 校正），可通过引擎名 tesseract 选择
+    ocrCfg := cfg.GetOCRConfig()
+    imageOptions := image.DefaultProcessOptions()
+    imageOptions.Language = ocrCfg.Languages
+    imageOptions.MinConfidence = ocrCfg.MinConfidence
+    imageOptions.OllamaConfig.Enabled = opts.LLM != nil
+    imageOptions.LLMPool = opts.LLM
+    imageOptions.Prompts = opts.Prompts
+    imageOptions.Cache = opts.Cache
+
+    imageProcessor, err := image.NewProcessor(logger, imageOptions)
     if err != nil {
         return ProcessorFactory{}, fmt.Errorf("failed to create image processor: %w", err)
     }
-    factory.processors["image/jpeg"] = imageProcessor
-    factory.processors["image/jpg"] = imageProcessor
-    factory.processors["image/png"] = imageProcessor
-    factory.processors["image/tiff"] = imageProcessor
-    */
+    factory.engines["tesseract"] = imageProcessor
+
+    // IMAGE_ENGINE=tesseract 时图像默认使用本地 OCR
+    switch ocrCfg.ImageEngine {
+    case "textract":
+    case "tesseract":
+        factory.processors["image/jpeg"] = imageProcessor
+        factory.processors["image/jpg"] = imageProcessor
+        factory.processors["image/png"] = imageProcessor
+        factory.processors["image/tiff"] = imageProcessor
+    default:
+        return ProcessorFactory{}, fmt.Errorf("unsupported image engine: %s", ocrCfg.ImageEngine)
+    }
 
     return *factory, nil
 }
@@ -92,7 +155,7 @@ func (f *ProcessorFactory) GetProcessor(fileType string) (document.Processor, er
         f.logger.Error("Unsupported file type",
             logger.String("fileType", fileType),
         )
-        return nil, fmt.Errorf("unsupported file type: %s", fileType)
+        return nil, document.Errorf(document.ErrorClassUnsupportedType, "unsupported file type: %s", fileType)
     }
 
     f.logger.Info("Mapped MIME type",
@@ -106,8 +169,66 @@ func (f *ProcessorFactory) GetProcessor(fileType string) (document.Processor, er
         f.logger.Error("No processor found",
             logger.String("mimeType", mimeType),
         )
-        return nil, fmt.Errorf("no processor found for mime type: %s", mimeType)
+        return nil, document.Errorf(document.ErrorClassUnsupportedType, "no processor found for mime type: %s", mimeType)
     }
 
     return processor, nil
+}
+
+// SelectProcessor 根据请求的处理选项选择处理器，未指定模式时按文件类型选择
+func (f *ProcessorFactory) SelectProcessor(fileType string, opts *models.ProcessingOptions) (document.Processor, error) {
+    mode := opts.ResolveMode()
+    if mode == models.ModeDefault {
+        if processor, ok := f.engineProcessor(fileType, opts); ok {
+            return processor, nil
+        }
+        return f.GetProcessor(fileType)
+    }
+
+    if _, ok := extToMIME[strings.ToLower(fileType)]; !ok {
+        return nil, document.Errorf(document.ErrorClassUnsupportedType, "unsupported file type: %s", fileType)
+    }
+    if mode == models.ModeExpense && !SupportsExpense(fileType) {
+        return nil, document.Errorf(document.ErrorClassUnsupportedType, "expense analysis does not support file type: %s", fileType)
+    }
+
+    processor, ok := f.modeProcessors[mode]
+    if !ok {
+        return nil, document.Errorf(document.ErrorClassUnsupportedType, "no processor found for mode: %s", mode)
+    }
+
+    f.logger.Info("Selected processor by mode",
+        logger.String("fileType", fileType),
+        logger.String("mode", string(mode)),
+    )
+
+    return processor, nil
+}
+
+// engineProcessor 返回处理选项指定的引擎，引擎不存在或不支持该文件类型时返回 false
+func (f *ProcessorFactory) engineProcessor(fileType string, opts *models.ProcessingOptions) (document.Processor, bool) {
+    if opts == nil || opts.Engine == "" {
+        return nil, false
+    }
+
+    mimeType, ok := extToMIME[strings.ToLower(fileType)]
+    if !ok {
+        return nil, false
+    }
+
+    processor, ok := f.engines[opts.Engine]
+    if !ok || !processor.CanProcess(mimeType) {
+        f.logger.Warn("Requested engine is not available for file type, falling back",
+            logger.String("engine", opts.Engine),
+            logger.String("fileType", fileType),
+        )
+        return nil, false
+    }
+
+    f.logger.Info("Selected processor by engine",
+        logger.String("fileType", fileType),
+        logger.String("engine", opts.Engine),
+    )
+
+    return processor, true
 }
\ No newline at end of file
@@ -0,0 +1,36 @@
+package agent
+
+import (
+    "testing"
+
+    "github.com/feichai0017/document-processor/internal/agent/document"
+    "github.com/feichai0017/document-processor/internal/agent/document/pdf"
+    "github.com/feichai0017/document-processor/internal/models"
+    "github.com/feichai0017/document-processor/pkg/logger"
+)
+
+func TestSelectProcessorExpenseFileTypes(t *testing.T) {
+    log := logger.NewTestLogger()
+    expense := pdf.NewProcessor(log)
+    factory := &ProcessorFactory{
+        processors:     map[string]document.Processor{},
+        modeProcessors: map[models.ProcessingMode]document.Processor{models.ModeExpense: expense},
+        engines:        map[string]document.Processor{},
+        logger:         log,
+    }
+    opts := &models.ProcessingOptions{DocumentType: "invoice"}
+
+    for _, fileType := range []string{".jpg", ".JPEG", ".png", ".pdf"} {
+        processor, err := factory.SelectProcessor(fileType, opts)
+        if err != nil || processor != expense {
+            t.Errorf("SelectProcessor(%s) = %v, %v; want expense processor", fileType, processor, err)
+        }
+    }
+
+    for _, fileType := range []string{".doc", ".docx", ".tiff"} {
+        _, err := factory.SelectProcessor(fileType, opts)
+        if document.ClassifyError(err) != document.ErrorClassUnsupportedType {
+            t.Errorf("SelectProcessor(%s) error = %v, want unsupported type", fileType, err)
+        }
+    }
+}
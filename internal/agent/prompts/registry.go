@@ -0,0 +1,309 @@
+package prompts
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/feichai0017/document-processor/pkg/logger"
+)
+
+// 内置的提示词 ID
+const (
+	OCRCorrection = "ocr_correction"
+	Extraction    = "extraction"
+	Summary       = "summary"
+	Translation   = "translation"
+)
+
+// DefaultDocumentType 没有对应文档类型的模板时使用
+const DefaultDocumentType = "default"
+
+//go:embed templates
+var builtin embed.FS
+
+// Data 模板数据，各提示词按需使用其中的字段
+type Data struct {
+	OCRText      string
+	DocumentType string
+	Language     string
+	Instructions string
+	// 摘要和翻译使用
+	MaxWords       int
+	TargetLanguage string
+}
+
+// Template 一个版本的提示词模板
+type Template struct {
+	ID           string
+	DocumentType string
+	Language     string // 为空表示不区分语言
+	Version      int
+	tmpl         *template.Template
+}
+
+// Key 模板键，如 ocr_correction/invoice.eng
+func (t *Template) Key() string {
+	key := t.ID + "/" + t.DocumentType
+	if t.Language != "" {
+		key += "." + t.Language
+	}
+	return key
+}
+
+// Render 渲染模板并去掉首尾空白
+func (t *Template) Render(data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s v%d: %w", t.Key(), t.Version, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Registry 提示词模板库，模板按 ID、文档类型和语言组织并带版本号。
+// 文件布局为 <dir>/<id>/<documentType>[.<language>].v<version>.tmpl，
+// 目录中的模板覆盖同名同版本的内置模板
+type Registry struct {
+	dir    string
+	logger logger.Logger
+
+	mu        sync.RWMutex
+	templates map[string][]*Template // key -> 按版本升序
+	signature string
+}
+
+// NewRegistry 加载内置模板和 dir 中的模板，dir 为空时只使用内置模板
+func NewRegistry(dir string, log logger.Logger) (*Registry, error) {
+	r := &Registry{
+		dir:    dir,
+		logger: log,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Get 返回最新版本的模板，按 文档类型+语言 → 文档类型 → default+语言 → default 的顺序查找
+func (r *Registry) Get(id, documentType, language string) (*Template, error) {
+	return r.GetVersion(id, documentType, language, 0)
+}
+
+// GetVersion 返回指定版本的模板，version 为 0 时返回最新版本
+func (r *Registry) GetVersion(id, documentType, language string, version int) (*Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if documentType == "" {
+		documentType = DefaultDocumentType
+	}
+
+	for _, key := range candidateKeys(id, documentType, language) {
+		versions := r.templates[key]
+		if len(versions) == 0 {
+			continue
+		}
+		if version == 0 {
+			return versions[len(versions)-1], nil
+		}
+		for _, t := range versions {
+			if t.Version == version {
+				return t, nil
+			}
+		}
+	}
+
+	if version != 0 {
+		return nil, fmt.Errorf("prompt %s not found for %s/%s at version %d", id, documentType, language, version)
+	}
+	return nil, fmt.Errorf("prompt %s not found for %s/%s", id, documentType, language)
+}
+
+func candidateKeys(id, documentType, language string) []string {
+	keys := make([]string, 0, 4)
+	add := func(docType string) {
+		if language != "" {
+			keys = append(keys, id+"/"+docType+"."+language)
+		}
+		keys = append(keys, id+"/"+docType)
+	}
+	add(documentType)
+	if documentType != DefaultDocumentType {
+		add(DefaultDocumentType)
+	}
+	return keys
+}
+
+// Reload 重新加载全部模板，任一模板解析失败时保留当前模板不变
+func (r *Registry) Reload() error {
+	templates := make(map[string][]*Template)
+
+	sub, err := fs.Sub(builtin, "templates")
+	if err != nil {
+		return err
+	}
+	if err := loadTemplates(sub, templates); err != nil {
+		return fmt.Errorf("failed to load builtin prompts: %w", err)
+	}
+
+	signature := ""
+	if r.dir != "" {
+		if signature, err = dirSignature(r.dir); err != nil {
+			return err
+		}
+		if err := loadTemplates(os.DirFS(r.dir), templates); err != nil {
+			return fmt.Errorf("failed to load prompts from %s: %w", r.dir, err)
+		}
+	}
+
+	for _, versions := range templates {
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].Version < versions[j].Version
+		})
+	}
+
+	r.mu.Lock()
+	r.templates = templates
+	r.signature = signature
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Watch 定期检查模板目录，文件有变化时热加载，直到 ctx 结束
+func (r *Registry) Watch(ctx context.Context, interval time.Duration) {
+	if r.dir == "" || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		signature, err := dirSignature(r.dir)
+		if err != nil {
+			r.logger.Warn("Failed to scan prompt directory", logger.String("dir", r.dir), logger.Error(err))
+			continue
+		}
+
+		r.mu.RLock()
+		changed := signature != r.signature
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := r.Reload(); err != nil {
+			r.logger.Error("Failed to reload prompts, keeping previous templates",
+				logger.String("dir", r.dir),
+				logger.Error(err),
+			)
+			// 记录签名，避免对同一个错误反复重载
+			r.mu.Lock()
+			r.signature = signature
+			r.mu.Unlock()
+			continue
+		}
+		r.logger.Info("Prompts reloaded", logger.String("dir", r.dir))
+	}
+}
+
+// loadTemplates 加载 <id>/<file>.tmpl 形式的模板，同键同版本的模板后加载的覆盖先加载的
+func loadTemplates(fsys fs.FS, templates map[string][]*Template) error {
+	files, err := fs.Glob(fsys, "*/*.tmpl")
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		t, err := parseName(file)
+		if err != nil {
+			return err
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		t.tmpl, err = template.New(file).Option("missingkey=zero").Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("invalid prompt template %s: %w", file, err)
+		}
+
+		key := t.Key()
+		versions := templates[key]
+		replaced := false
+		for i, existing := range versions {
+			if existing.Version == t.Version {
+				versions[i] = t
+				replaced = true
+			}
+		}
+		if !replaced {
+			versions = append(versions, t)
+		}
+		templates[key] = versions
+	}
+
+	return nil
+}
+
+// parseName 解析 <id>/<documentType>[.<language>].v<version>.tmpl
+func parseName(file string) (*Template, error) {
+	id := path.Dir(file)
+	parts := strings.Split(strings.TrimSuffix(path.Base(file), ".tmpl"), ".")
+	if len(parts) < 2 || len(parts) > 3 || !strings.HasPrefix(parts[len(parts)-1], "v") {
+		return nil, fmt.Errorf("invalid prompt file name %s, expected <documentType>[.<language>].v<version>.tmpl", file)
+	}
+
+	version, err := strconv.Atoi(strings.TrimPrefix(parts[len(parts)-1], "v"))
+	if err != nil || version <= 0 {
+		return nil, fmt.Errorf("invalid prompt version in %s", file)
+	}
+
+	t := &Template{
+		ID:           id,
+		DocumentType: parts[0],
+		Version:      version,
+	}
+	if len(parts) == 3 {
+		t.Language = parts[1]
+	}
+	return t, nil
+}
+
+// dirSignature 由模板文件名、大小和修改时间组成，用于判断目录是否变化
+func dirSignature(dir string) (string, error) {
+	files, err := fs.Glob(os.DirFS(dir), "*/*.tmpl")
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	var b strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
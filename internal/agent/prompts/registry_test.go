@@ -0,0 +1,242 @@
+package prompts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/feichai0017/document-processor/pkg/logger"
+)
+
+func writeTemplate(t *testing.T, dir, file, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestRegistry(t *testing.T, files map[string]string) (*Registry, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for file, content := range files {
+		writeTemplate(t, dir, file, content)
+	}
+	r, err := NewRegistry(dir, logger.NewTestLogger())
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	return r, dir
+}
+
+func render(t *testing.T, tmpl *Template) string {
+	t.Helper()
+	out, err := tmpl.Render(Data{})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	return out
+}
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		file    string
+		want    Template
+		wantErr bool
+	}{
+		{file: "summary/default.v1.tmpl", want: Template{ID: "summary", DocumentType: "default", Version: 1}},
+		{file: "ocr_correction/invoice.chi_sim.v12.tmpl", want: Template{ID: "ocr_correction", DocumentType: "invoice", Language: "chi_sim", Version: 12}},
+		{file: "summary/default.tmpl", wantErr: true},
+		{file: "summary/default.eng.1.tmpl", wantErr: true},
+		{file: "summary/default.vx.tmpl", wantErr: true},
+		{file: "summary/default.v0.tmpl", wantErr: true},
+		{file: "summary/default.v-1.tmpl", wantErr: true},
+		{file: "summary/a.b.c.v1.tmpl", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseName(tt.file)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseName(%q) = %+v, want error", tt.file, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseName(%q) error = %v", tt.file, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("parseName(%q) = %+v, want %+v", tt.file, *got, tt.want)
+		}
+	}
+}
+
+func TestNewRegistryRejectsBadFileName(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "summary/default.latest.tmpl", "x")
+	if _, err := NewRegistry(dir, logger.NewTestLogger()); err == nil {
+		t.Fatal("NewRegistry() accepted an invalid file name")
+	}
+}
+
+func TestGetReturnsHighestVersion(t *testing.T) {
+	r, _ := newTestRegistry(t, map[string]string{
+		"summary/invoice.v2.tmpl":  "v2",
+		"summary/invoice.v10.tmpl": "v10",
+		"summary/invoice.v3.tmpl":  "v3",
+	})
+
+	tmpl, err := r.Get(Summary, "invoice", "")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if tmpl.Version != 10 || render(t, tmpl) != "v10" {
+		t.Errorf("Get() = v%d %q, want v10", tmpl.Version, render(t, tmpl))
+	}
+
+	tmpl, err = r.GetVersion(Summary, "invoice", "", 3)
+	if err != nil || render(t, tmpl) != "v3" {
+		t.Errorf("GetVersion(3) = %v, %v", tmpl, err)
+	}
+	if _, err := r.GetVersion(Summary, "invoice", "", 4); err == nil {
+		t.Error("GetVersion(4) found a missing version")
+	}
+}
+
+func TestDirectoryOverridesBuiltin(t *testing.T) {
+	r, _ := newTestRegistry(t, map[string]string{
+		"summary/default.v1.tmpl": "custom",
+	})
+	tmpl, err := r.Get(Summary, "", "")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if render(t, tmpl) != "custom" {
+		t.Errorf("Get() = %q, want the directory template", render(t, tmpl))
+	}
+}
+
+func TestGetFallbackOrder(t *testing.T) {
+	files := map[string]string{
+		"extraction/receipt.eng.v1.tmpl": "receipt+eng",
+		"extraction/receipt.v1.tmpl":     "receipt",
+		"extraction/default.eng.v1.tmpl": "default+eng",
+		"extraction/default.v5.tmpl":     "default",
+	}
+	r, dir := newTestRegistry(t, files)
+
+	tests := []struct {
+		documentType string
+		language     string
+		want         string
+	}{
+		{"receipt", "eng", "receipt+eng"},
+		{"receipt", "chi_sim", "receipt"},
+		{"receipt", "", "receipt"},
+		{"contract", "eng", "default+eng"},
+		{"contract", "chi_sim", "default"},
+		{"", "eng", "default+eng"},
+		{"", "", "default"},
+	}
+	check := func() {
+		t.Helper()
+		for _, tt := range tests {
+			tmpl, err := r.Get(Extraction, tt.documentType, tt.language)
+			if err != nil {
+				t.Errorf("Get(%q, %q) error = %v", tt.documentType, tt.language, err)
+				continue
+			}
+			if got := render(t, tmpl); got != tt.want {
+				t.Errorf("Get(%q, %q) = %q, want %q", tt.documentType, tt.language, got, tt.want)
+			}
+		}
+	}
+	check()
+
+	// 去掉 文档类型+语言 的模板后回退到文档类型
+	os.Remove(filepath.Join(dir, "extraction", "receipt.eng.v1.tmpl"))
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	tests[0].want = "receipt"
+	check()
+
+	if _, err := r.Get("missing", "receipt", "eng"); err == nil {
+		t.Error("Get() found a missing prompt")
+	}
+}
+
+func TestReloadKeepsTemplatesOnParseError(t *testing.T) {
+	r, dir := newTestRegistry(t, map[string]string{
+		"summary/default.v1.tmpl": "first",
+	})
+
+	writeTemplate(t, dir, "summary/default.v1.tmpl", "second")
+	writeTemplate(t, dir, "summary/contract.v2.tmpl", "{{ .Broken")
+	if err := r.Reload(); err == nil {
+		t.Fatal("Reload() accepted an invalid template")
+	}
+
+	tmpl, err := r.Get(Summary, "", "")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if render(t, tmpl) != "first" {
+		t.Errorf("Get() = %q after a failed reload, want the previous template", render(t, tmpl))
+	}
+	if tmpl, _ := r.Get(Summary, "contract", ""); tmpl.Version == 2 {
+		t.Error("a template from the failed reload was installed")
+	}
+}
+
+func TestWatchReloadsChangedDirectory(t *testing.T) {
+	r, dir := newTestRegistry(t, map[string]string{
+		"translation/default.v1.tmpl": "v1",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Watch(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			tmpl, err := r.Get(Translation, "", "")
+			if err == nil && render(t, tmpl) == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Watch() did not pick up %q", want)
+	}
+
+	writeTemplate(t, dir, "translation/default.v2.tmpl", "v2")
+	waitFor("v2")
+
+	// 内容大小变化也会改变目录签名
+	writeTemplate(t, dir, "translation/default.v2.tmpl", "v2 edited")
+	waitFor("v2 edited")
+
+	// 解析失败时保留上一组模板，修复后再次加载
+	writeTemplate(t, dir, "translation/default.v3.tmpl", "{{ if }}")
+	time.Sleep(50 * time.Millisecond)
+	if tmpl, _ := r.Get(Translation, "", ""); render(t, tmpl) != "v2 edited" {
+		t.Fatalf("Get() = %q after a broken template", render(t, tmpl))
+	}
+	writeTemplate(t, dir, "translation/default.v3.tmpl", "v3")
+	waitFor("v3")
+}
@@ -0,0 +1,342 @@
+// Package e2e 离线运行上传 → 队列 → worker → 下载的完整流程：
+// Redis 使用 miniredis，Textract 与 S3 使用 fakeaws 替身服务，摘要和翻译使用 fakeollama，API 通过 httptest 调用
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+
+	"github.com/feichai0017/document-processor/api/handlers"
+	"github.com/feichai0017/document-processor/api/routes"
+	"github.com/feichai0017/document-processor/config"
+	"github.com/feichai0017/document-processor/internal/agent"
+	"github.com/feichai0017/document-processor/internal/agent/document/image"
+	"github.com/feichai0017/document-processor/internal/agent/postprocess"
+	"github.com/feichai0017/document-processor/internal/repository"
+	"github.com/feichai0017/document-processor/internal/service/document"
+	"github.com/feichai0017/document-processor/internal/testutil/fakeaws"
+	"github.com/feichai0017/document-processor/internal/testutil/fakeollama"
+	"github.com/feichai0017/document-processor/pkg/converters"
+	"github.com/feichai0017/document-processor/pkg/logger"
+	"github.com/feichai0017/document-processor/pkg/queue"
+	"github.com/feichai0017/document-processor/pkg/storage/s3"
+	"github.com/feichai0017/document-processor/pkg/worker"
+)
+
+const bucket = "documents"
+
+// pngHeader Textract 只看到字节流，响应来自 fakeaws 的样例
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+
+type stack struct {
+	api *httptest.Server
+	aws *fakeaws.Server
+	llm *fakeollama.Server
+}
+
+// newStack 启动 API 服务和全部类型的 worker，与 cmd/server、cmd/worker 的组装方式一致
+func newStack(t *testing.T) *stack {
+	t.Helper()
+	log := logger.NewTestLogger()
+
+	aws, err := fakeaws.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start fake aws: %v", err)
+	}
+	t.Cleanup(aws.Close)
+	aws.CreateBucket(bucket)
+
+	// 处理器工厂从环境变量读取 Textract 配置，Endpoint 指向替身服务
+	t.Setenv("AWS_ENDPOINT", aws.URL())
+	t.Setenv("AWS_REGION", fakeaws.Region)
+	t.Setenv("AWS_ACCESS_KEY", "test")
+	t.Setenv("AWS_SECRET_KEY", "test")
+	t.Setenv("AWS_S3_BUCKET_NAME", bucket)
+
+	redis := miniredis.RunT(t)
+	q, err := queue.NewAsynqQueue(&queue.QueueConfig{
+		RedisAddr:  redis.Addr(),
+		MaxRetries: 1,
+		Logger:     log,
+	})
+	if err != nil {
+		t.Fatalf("NewAsynqQueue() error = %v", err)
+	}
+
+	store, err := s3.NewS3StorageWithConfig(log, &config.S3Config{
+		BucketName: bucket,
+		Region:     fakeaws.Region,
+		AccessKey:  "test",
+		SecretKey:  "test",
+		Endpoint:   aws.URL(),
+	})
+	if err != nil {
+		t.Fatalf("NewS3StorageWithConfig() error = %v", err)
+	}
+
+	factory, err := agent.NewProcessorFactory(log, nil)
+	if err != nil {
+		t.Fatalf("NewProcessorFactory() error = %v", err)
+	}
+
+	// 摘要和翻译的 LLM，连续失败一次即熔断
+	llm := fakeollama.NewServer()
+	t.Cleanup(llm.Close)
+	pool, err := image.NewVisionLLMPool(&image.OllamaConfig{
+		Endpoint:         llm.URL(),
+		Model:            "llama3.2",
+		FailureThreshold: 1,
+		BreakerCooldown:  time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewVisionLLMPool() error = %v", err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	postProcessor, err := postprocess.NewPostProcessor(pool, log, nil)
+	if err != nil {
+		t.Fatalf("NewPostProcessor() error = %v", err)
+	}
+
+	service := document.NewService(factory, q, store, log, nil, nil, nil, postProcessor, nil,
+		repository.NewRedis(q.RedisClient(), time.Hour))
+	t.Cleanup(func() { service.Close() })
+
+	pools := make([]worker.PoolConfig, 0, len(queue.TaskTypes))
+	for _, taskType := range queue.TaskTypes {
+		pools = append(pools, worker.PoolConfig{TaskType: taskType, Concurrency: 1})
+	}
+	w, err := worker.NewDocumentWorker(&worker.Config{RedisAddr: redis.Addr(), Pools: pools}, service, log)
+	if err != nil {
+		t.Fatalf("NewDocumentWorker() error = %v", err)
+	}
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatalf("worker Start() error = %v", err)
+	}
+	t.Cleanup(func() { w.Stop() })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	routes.SetupRoutes(r, handlers.NewHandlers(service, log))
+	api := httptest.NewServer(r)
+	t.Cleanup(api.Close)
+
+	return &stack{api: api, aws: aws, llm: llm}
+}
+
+// upload 通过 API 上传文件，返回任务 ID
+func (s *stack) upload(t *testing.T, filename string, data []byte, fields map[string]string) string {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", filename)
+	part.Write(data)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	form.Close()
+
+	resp, err := http.Post(s.api.URL+"/api/v1/documents/process", form.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatalf("upload error = %v", err)
+	}
+	defer resp.Body.Close()
+	var result handlers.ProcessResponse
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&result) != nil || result.TaskID == "" {
+		t.Fatalf("upload status = %d, response = %+v", resp.StatusCode, result)
+	}
+	return result.TaskID
+}
+
+// waitFinished 轮询任务状态直到结束
+func (s *stack) waitFinished(t *testing.T, taskID string) map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		var status map[string]interface{}
+		s.getJSON(t, "/api/v1/documents/status/"+taskID, &status)
+		switch status["status"] {
+		case "completed", "failed", "cancelled":
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task %s did not finish: %v", taskID, status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (s *stack) getJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(s.api.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s status = %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("GET %s invalid response: %v", path, err)
+	}
+}
+
+// textractCalls 统计替身服务收到的 Textract 操作
+func (s *stack) textractCalls(operation string) int {
+	n := 0
+	for _, req := range s.aws.Requests() {
+		if req.Operation == operation {
+			n++
+		}
+	}
+	return n
+}
+
+// TestUploadProcessDownload 处理器工厂的 Textract 配置每个进程只读取一次，所有场景共用一套服务
+func TestUploadProcessDownload(t *testing.T) {
+	s := newStack(t)
+	s.llm.SetReply(func(req fakeollama.ChatRequest) string {
+		if strings.Contains(req.Messages[0].Content, "translat") {
+			return "translated"
+		}
+		return "summary of the invoice"
+	})
+	postProcessing := map[string]string{"summaryLength": "short", "translateTo": "de"}
+
+	// 任务入队后延迟执行，asynq 每 5 秒转发一次到期的任务，先全部提交再等待
+	imageTask := s.upload(t, "invoice.png", pngHeader, nil)
+	expenseTask := s.upload(t, "receipt.png", pngHeader, map[string]string{"mode": "expense", "documentType": "invoice"})
+	postProcessTask := s.upload(t, "contract.png", pngHeader, postProcessing)
+
+	t.Run("image", func(t *testing.T) {
+		taskID := imageTask
+		status := s.waitFinished(t, taskID)
+		if status["status"] != "completed" {
+			t.Fatalf("task status = %v", status)
+		}
+		if s.textractCalls("AnalyzeDocument") == 0 {
+			t.Error("image was not sent to Textract")
+		}
+
+		var result converters.ProcessedDocument
+		s.getJSON(t, "/api/v1/documents/download/"+taskID, &result)
+		if result.TaskID != taskID || len(result.Content) == 0 {
+			t.Fatalf("downloaded result = %+v", result)
+		}
+		var text []string
+		for _, chunk := range result.Content {
+			text = append(text, chunk.Text)
+		}
+		if !strings.Contains(strings.Join(text, "\n"), "INV-1001") {
+			t.Errorf("result content = %q, want the Textract fixture text", text)
+		}
+
+		// 上传的文件保存在 S3 替身服务中
+		found := false
+		for _, req := range s.aws.Requests() {
+			if req.Operation == "PutObject" && strings.HasPrefix(req.Path, "/"+bucket+"/") && bytes.Equal(req.Body, pngHeader) {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("upload was not stored in S3")
+		}
+	})
+
+	t.Run("expense", func(t *testing.T) {
+		taskID := expenseTask
+		if status := s.waitFinished(t, taskID); status["status"] != "completed" {
+			t.Fatalf("task status = %v", status)
+		}
+		if s.textractCalls("AnalyzeExpense") == 0 {
+			t.Error("receipt was not sent to AnalyzeExpense")
+		}
+
+		var result converters.ProcessedDocument
+		s.getJSON(t, "/api/v1/documents/download/"+taskID, &result)
+		if len(result.Expenses) != 1 || result.Expenses[0].InvoiceNumber != "INV-1001" {
+			t.Fatalf("expenses = %+v", result.Expenses)
+		}
+	})
+
+	t.Run("post-processing", func(t *testing.T) {
+		taskID := postProcessTask
+		if status := s.waitFinished(t, taskID); status["status"] != "completed" {
+			t.Fatalf("task status = %v", status)
+		}
+
+		var result converters.ProcessedDocument
+		s.getJSON(t, "/api/v1/documents/download/"+taskID, &result)
+		if result.Summary == nil || result.Summary.Text != "summary of the invoice" || result.Summary.Length != "short" {
+			t.Errorf("summary = %+v", result.Summary)
+		}
+		if result.Translation == nil || result.Translation.TargetLanguage != "de" || len(result.Translation.Chunks) != len(result.Content) {
+			t.Fatalf("translation = %+v", result.Translation)
+		}
+		translated := 0
+		for i, chunk := range result.Translation.Chunks {
+			if chunk.Position != result.Content[i].Position {
+				t.Errorf("translated chunk %d position = %d, want %d", i, chunk.Position, result.Content[i].Position)
+			}
+			if chunk.Text == "translated" {
+				translated++
+			}
+		}
+		if translated == 0 {
+			t.Errorf("no chunk was translated: %+v", result.Translation.Chunks)
+		}
+	})
+
+	t.Run("unsupported file", func(t *testing.T) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "notes.txt")
+		fmt.Fprint(part, "plain text")
+		form.Close()
+
+		resp, err := http.Post(s.api.URL+"/api/v1/documents/process", form.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Error("unsupported file type was accepted")
+		}
+	})
+
+	// 其他任务结束后再让 LLM 失败：第一次请求失败后熔断，摘要和翻译都被跳过，处理结果不受影响
+	t.Run("post-processing without llm", func(t *testing.T) {
+		s.llm.FailNext(1000)
+		requests := len(s.llm.Requests())
+
+		taskID := s.upload(t, "contract.png", pngHeader, postProcessing)
+		if status := s.waitFinished(t, taskID); status["status"] != "completed" {
+			t.Fatalf("task status = %v", status)
+		}
+
+		var result converters.ProcessedDocument
+		s.getJSON(t, "/api/v1/documents/download/"+taskID, &result)
+		if len(result.Content) == 0 {
+			t.Fatal("result has no content")
+		}
+		if result.Summary == nil || result.Summary.Text != "" || result.Summary.Error == "" {
+			t.Errorf("summary = %+v, want the LLM error", result.Summary)
+		}
+		if result.Translation == nil || result.Translation.Skipped == "" || len(result.Translation.Chunks) != 0 {
+			t.Errorf("translation = %+v, want skipped", result.Translation)
+		}
+		if got := len(s.llm.Requests()) - requests; got != 1 {
+			t.Errorf("LLM requests after the failure = %d, want 1", got)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+    "fmt"
+    "time"
+)
+
+// BatchPolicy 批量任务中有文件失败时的处理方式
+type BatchPolicy string
+
+const (
+    BatchPolicyContinue BatchPolicy = "continue" // 其他文件照常处理
+    BatchPolicyAbort    BatchPolicy = "abort"    // 停止提交剩余文件并取消已提交的任务
+)
+
+// Validate 检查策略，为空时按 continue 处理
+func (p BatchPolicy) Validate() error {
+    switch p {
+    case "", BatchPolicyContinue, BatchPolicyAbort:
+        return nil
+    }
+    return fmt.Errorf("unsupported batch policy: %s", p)
+}
+
+// OrDefault 未指定时使用 continue
+func (p BatchPolicy) OrDefault() BatchPolicy {
+    if p == "" {
+        return BatchPolicyContinue
+    }
+    return p
+}
+
+// 只用于批量任务中没有创建任务的文件
+const (
+    StatusRejected ProcessingStatus = "rejected" // 提交时校验失败
+    StatusSkipped  ProcessingStatus = "skipped"  // abort 策略下其他文件失败，未提交
+)
+
+// BatchStatus 批量任务的整体状态
+type BatchStatus string
+
+const (
+    BatchPending   BatchStatus = "pending"   // 还没有任务开始处理
+    BatchRunning   BatchStatus = "running"   // 还有任务未结束
+    BatchCompleted BatchStatus = "completed" // 所有文件处理成功
+    BatchPartial   BatchStatus = "partial"   // 全部结束，部分文件失败、被拒绝或取消
+    BatchFailed    BatchStatus = "failed"    // 全部结束，没有文件处理成功
+)
+
+// Batch 批量任务，Items 与提交的文件一一对应
+type Batch struct {
+    ID        string                   `json:"id"`
+    Status    BatchStatus              `json:"status"`
+    Policy    BatchPolicy              `json:"policy"`
+    Aborted   bool                     `json:"aborted,omitempty"`
+    Progress  float64                  `json:"progress"` // 0-1，未创建任务的文件计为已完成
+    Total     int                      `json:"total"`
+    Counts    map[ProcessingStatus]int `json:"counts"`
+    Items     []*BatchItem             `json:"items"`
+    CreatedAt time.Time                `json:"createdAt"`
+    UpdatedAt time.Time                `json:"updatedAt"`
+}
+
+// BatchItem 批量任务中的一个文件
+type BatchItem struct {
+    Index        int              `json:"index"`
+    Filename     string           `json:"filename"`
+    FileSize     int64            `json:"fileSize"`
+    TaskID       string           `json:"taskId,omitempty"`
+    Status       ProcessingStatus `json:"status"`
+    Progress     float64          `json:"progress"`
+    Error        string           `json:"error,omitempty"`
+    ErrorClass   string           `json:"errorClass,omitempty"`
+    Deduplicated bool             `json:"deduplicated,omitempty"`
+}
+
+// IsTerminal 任务已结束或没有创建任务
+func (s ProcessingStatus) IsTerminal() bool {
+    switch s {
+    case StatusCompleted, StatusFailed, StatusCancelled, StatusRejected, StatusSkipped:
+        return true
+    }
+    return false
+}
+
+// Summarize 根据各文件的状态计算整体状态、进度和计数
+func (b *Batch) Summarize() {
+    b.Total = len(b.Items)
+    b.Counts = make(map[ProcessingStatus]int)
+    if b.Total == 0 {
+        b.Status = BatchCompleted
+        return
+    }
+
+    var progress float64
+    finished, completed, started := 0, 0, 0
+    for _, item := range b.Items {
+        b.Counts[item.Status]++
+        switch item.Status {
+        case StatusPending:
+        case StatusRejected, StatusSkipped:
+            finished++
+            progress += 1
+            continue
+        default:
+            started++
+        }
+        switch {
+        case item.Status == StatusCompleted:
+            completed++
+            finished++
+            progress += 1
+        case item.Status.IsTerminal():
+            finished++
+            progress += 1
+        default:
+            progress += item.Progress
+        }
+    }
+    b.Progress = progress / float64(b.Total)
+
+    switch {
+    case completed == b.Total:
+        b.Status = BatchCompleted
+    case finished == b.Total && completed == 0:
+        b.Status = BatchFailed
+    case finished == b.Total:
+        b.Status = BatchPartial
+    case started == 0:
+        b.Status = BatchPending
+    default:
+        b.Status = BatchRunning
+    }
+}
+
+// Finished 所有文件都已结束
+func (b *Batch) Finished() bool {
+    return b.Status != BatchPending && b.Status != BatchRunning
+}
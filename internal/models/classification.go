@@ -0,0 +1,39 @@
+package models
+
+// 分类阶段识别的文档类型
+const (
+    DocTypeInvoice       = "invoice"
+    DocTypeReceipt       = "receipt"
+    DocTypeIDCard        = "id_card"
+    DocTypeContract      = "contract"
+    DocTypeBankStatement = "bank_statement"
+    DocTypeOther         = "other"
+)
+
+// KnownDocumentTypes 分类器可输出的全部文档类型
+var KnownDocumentTypes = []string{
+    DocTypeInvoice,
+    DocTypeReceipt,
+    DocTypeIDCard,
+    DocTypeContract,
+    DocTypeBankStatement,
+    DocTypeOther,
+}
+
+// ClassificationMethod 文档类型的来源
+type ClassificationMethod string
+
+const (
+    ClassifiedByRequest ClassificationMethod = "request" // 请求中显式指定
+    ClassifiedByLLM     ClassificationMethod = "llm"
+    ClassifiedByRules   ClassificationMethod = "rules" // 关键词规则兜底
+)
+
+// Classification 文档分类结果
+type Classification struct {
+    DocumentType string               `json:"documentType"`
+    Confidence   float64              `json:"confidence"` // 0-1
+    Method       ClassificationMethod `json:"method"`
+    // Applied 是否按该类型切换了处理配置，置信度不足或文件类型不适用时只记录分类结果
+    Applied bool `json:"applied"`
+}
@@ -1,7 +1,12 @@
 package models
 
 import (
+    "encoding/json"
+    "fmt"
+    "strings"
     "time"
+
+    "github.com/feichai0017/document-processor/pkg/jsonschema"
 )
 
 // FileType 文件类型
@@ -34,6 +39,25 @@ type DocumentChunk struct {
     Metadata map[string]interface{} `json:"metadata"`
 }
 
+// BoundingBox 区域位置（相对于页面宽高的比例）
+type BoundingBox struct {
+    Left   float64 `json:"left"`
+    Top    float64 `json:"top"`
+    Width  float64 `json:"width"`
+    Height float64 `json:"height"`
+}
+
+// ReviewItem 低于置信度阈值、需要人工复核的区域
+type ReviewItem struct {
+    Source      string       `json:"source"`
+    BlockType   string       `json:"blockType"`
+    Text        string       `json:"text"`
+    Confidence  float64      `json:"confidence"`
+    Threshold   float64      `json:"threshold"`
+    Page        int          `json:"page,omitempty"`
+    BoundingBox *BoundingBox `json:"boundingBox,omitempty"`
+}
+
 type ProcessingTask struct {
     ID        string            `json:"id"`
     Status    ProcessingStatus  `json:"status"`
@@ -41,9 +65,28 @@ type ProcessingTask struct {
     Priority  int              `json:"priority"`
     Progress  float64          `json:"progress"`
     Error     string            `json:"error,omitempty"`
+    ErrorClass string           `json:"errorClass,omitempty"` // corrupt_input、unsupported_type、throttled、transient 等
     Metadata  map[string]string `json:"metadata"`
     CreatedAt time.Time        `json:"createdAt"`
     UpdatedAt time.Time        `json:"updatedAt,omitempty"`
+
+    // 处理中的进度详情
+    Stage      string     `json:"stage,omitempty"`
+    PagesDone  int        `json:"pagesDone,omitempty"`
+    PagesTotal int        `json:"pagesTotal,omitempty"`
+    ETA        *time.Time `json:"eta,omitempty"` // 按当前速度估算的完成时间
+
+    // Deduplicated 提交时复用了相同文件和处理选项的已有任务
+    Deduplicated bool `json:"deduplicated,omitempty"`
+
+    // 任务生命周期，由任务仓库保存
+    Options        *ProcessingOptions `json:"options,omitempty"`
+    Attempts       int                `json:"attempts"`
+    StartedAt      *time.Time         `json:"startedAt,omitempty"` // 第一次开始处理的时间
+    FinishedAt     *time.Time         `json:"finishedAt,omitempty"`
+    Stages         []StageTiming      `json:"stages,omitempty"`
+    Errors         []TaskError        `json:"errors,omitempty"` // 每次失败的错误，包括已重试的
+    ResultLocation string             `json:"resultLocation,omitempty"` // 结果在存储中的位置
 }
 
 type ProcessingStatus string
@@ -56,3 +99,120 @@ const (
     StatusCancelled ProcessingStatus = "cancelled"
 )
 
+// ProcessingMode 处理模式
+type ProcessingMode string
+
+const (
+    ModeDefault ProcessingMode = ""
+    ModeExpense ProcessingMode = "expense" // 发票/收据费用分析
+)
+
+// ProcessingOptions 单次请求的处理选项，随任务 Payload 传递到 worker
+type ProcessingOptions struct {
+    Mode         ProcessingMode  `json:"mode,omitempty"`
+    DocumentType string          `json:"documentType,omitempty"` // 文档类型提示，如 invoice、receipt
+    Queries      []Query         `json:"queries,omitempty"`      // 自然语言查询（Textract Queries）
+    Schema       json.RawMessage `json:"schema,omitempty"`       // 结构化抽取使用的 JSON Schema
+
+    // 以下选项通常由文档类型对应的处理配置填充
+    Engine   string   `json:"engine,omitempty"`   // 指定处理引擎，如 textract，为空时按文件类型选择
+    Features []string `json:"features,omitempty"` // Textract FeatureTypes，如 TABLES、FORMS
+    Prompt   string   `json:"prompt,omitempty"`   // 结构化抽取的附加说明
+
+    // PromptVersions 按提示词 ID 固定模板版本，如 {"ocr_correction": 2}，未指定时使用最新版本
+    PromptVersions map[string]int `json:"promptVersions,omitempty"`
+
+    // 后处理：摘要和翻译，为空时跳过
+    SummaryLength SummaryLength `json:"summaryLength,omitempty"` // short、medium、long
+    TranslateTo   string        `json:"translateTo,omitempty"`   // 目标语言代码，如 en
+}
+
+// Query 自然语言查询
+type Query struct {
+    Text  string   `json:"text"`
+    Alias string   `json:"alias,omitempty"`
+    Pages []string `json:"pages,omitempty"` // 如 "1"、"2-3"、"*"，为空表示第一页
+}
+
+// QueryResult 查询结果
+type QueryResult struct {
+    Query      string  `json:"query"`
+    Alias      string  `json:"alias,omitempty"`
+    Answer     string  `json:"answer"`
+    Confidence float64 `json:"confidence"`
+    Page       int     `json:"page,omitempty"`
+}
+
+const (
+    maxQueries         = 15
+    maxQueryTextLength = 200
+)
+
+// expenseDocumentTypes 会自动切换到费用分析模式的文档类型
+var expenseDocumentTypes = map[string]bool{
+    "invoice": true,
+    "receipt": true,
+    "bill":    true,
+}
+
+// ResolveMode 返回实际使用的处理模式，显式指定的模式优先于文档类型提示
+func (o *ProcessingOptions) ResolveMode() ProcessingMode {
+    if o == nil {
+        return ModeDefault
+    }
+    if o.Mode != ModeDefault {
+        return o.Mode
+    }
+    if expenseDocumentTypes[strings.ToLower(o.DocumentType)] {
+        return ModeExpense
+    }
+    return ModeDefault
+}
+
+// Validate 检查处理选项
+func (o *ProcessingOptions) Validate() error {
+    if o == nil {
+        return nil
+    }
+    switch o.Mode {
+    case ModeDefault, ModeExpense:
+    default:
+        return fmt.Errorf("unsupported processing mode: %s", o.Mode)
+    }
+
+    if len(o.Queries) > maxQueries {
+        return fmt.Errorf("too many queries: %d (max %d)", len(o.Queries), maxQueries)
+    }
+    aliases := make(map[string]bool)
+    for i, q := range o.Queries {
+        if strings.TrimSpace(q.Text) == "" {
+            return fmt.Errorf("query %d: text is required", i)
+        }
+        if len(q.Text) > maxQueryTextLength {
+            return fmt.Errorf("query %d: text exceeds %d characters", i, maxQueryTextLength)
+        }
+        if q.Alias != "" {
+            if aliases[q.Alias] {
+                return fmt.Errorf("query %d: duplicate alias %s", i, q.Alias)
+            }
+            aliases[q.Alias] = true
+        }
+    }
+
+    for id, version := range o.PromptVersions {
+        if version <= 0 {
+            return fmt.Errorf("invalid version %d for prompt %s", version, id)
+        }
+    }
+
+    if len(o.Schema) > 0 {
+        if _, err := jsonschema.Parse(o.Schema); err != nil {
+            return err
+        }
+    }
+
+    if err := o.SummaryLength.Validate(); err != nil {
+        return err
+    }
+    return ValidateLanguage(o.TranslateTo)
+}
@@ -0,0 +1,38 @@
+package models
+
+// Expense 发票/收据的结构化费用分析结果
+type Expense struct {
+    Index         int               `json:"index"`
+    Vendor        string            `json:"vendor,omitempty"`
+    InvoiceNumber string            `json:"invoiceNumber,omitempty"`
+    InvoiceDate   string            `json:"invoiceDate,omitempty"`
+    DueDate       string            `json:"dueDate,omitempty"`
+    Currency      string            `json:"currency,omitempty"`
+    Subtotal      *float64          `json:"subtotal,omitempty"`
+    Tax           *float64          `json:"tax,omitempty"`
+    Total         *float64          `json:"total,omitempty"`
+    AmountDue     *float64          `json:"amountDue,omitempty"`
+    SummaryFields []ExpenseField    `json:"summaryFields"`
+    LineItems     []ExpenseLineItem `json:"lineItems"`
+}
+
+// ExpenseField 费用字段（原始识别结果）
+type ExpenseField struct {
+    Type       string  `json:"type"`
+    Label      string  `json:"label,omitempty"`
+    Value      string  `json:"value"`
+    Currency   string  `json:"currency,omitempty"`
+    Confidence float64 `json:"confidence"`
+    Page       int     `json:"page,omitempty"`
+}
+
+// ExpenseLineItem 明细行
+type ExpenseLineItem struct {
+    Group       int            `json:"group"`
+    Description string         `json:"description,omitempty"`
+    ProductCode string         `json:"productCode,omitempty"`
+    Quantity    *float64       `json:"quantity,omitempty"`
+    UnitPrice   *float64       `json:"unitPrice,omitempty"`
+    Price       *float64       `json:"price,omitempty"`
+    Fields      []ExpenseField `json:"fields"`
+}
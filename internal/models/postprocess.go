@@ -0,0 +1,75 @@
+package models
+
+import (
+    "fmt"
+    "regexp"
+)
+
+// SummaryLength 摘要长度
+type SummaryLength string
+
+const (
+    SummaryShort  SummaryLength = "short"
+    SummaryMedium SummaryLength = "medium"
+    SummaryLong   SummaryLength = "long"
+)
+
+// summaryWords 各长度对应的目标字数
+var summaryWords = map[SummaryLength]int{
+    SummaryShort:  60,
+    SummaryMedium: 150,
+    SummaryLong:   400,
+}
+
+// Words 返回目标字数，未知长度返回 0
+func (l SummaryLength) Words() int {
+    return summaryWords[l]
+}
+
+// Validate 检查摘要长度，空值表示不生成摘要
+func (l SummaryLength) Validate() error {
+    if l == "" || summaryWords[l] > 0 {
+        return nil
+    }
+    return fmt.Errorf("unsupported summary length: %s (expected short, medium or long)", l)
+}
+
+// languageTag 目标语言代码，如 en、zh、de、pt-BR
+var languageTag = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})?$`)
+
+// ValidateLanguage 检查语言代码，空值表示不翻译
+func ValidateLanguage(lang string) error {
+    if lang == "" || languageTag.MatchString(lang) {
+        return nil
+    }
+    return fmt.Errorf("invalid target language: %s", lang)
+}
+
+// Summary 文档摘要
+type Summary struct {
+    Text          string        `json:"text,omitempty"`
+    Length        SummaryLength `json:"length"`
+    Segments      int           `json:"segments"` // 长文档分段摘要后再合并，1 表示一次完成
+    PromptID      string        `json:"promptId"`
+    PromptVersion int           `json:"promptVersion"`
+    Error         string        `json:"error,omitempty"`
+    Skipped       string        `json:"skipped,omitempty"` // LLM 不可用等原因跳过摘要时的说明
+}
+
+// Translation 按块翻译结果，与 ProcessedDocument.Content 按 Position 对应
+type Translation struct {
+    TargetLanguage string            `json:"targetLanguage"`
+    Chunks         []TranslatedChunk `json:"chunks"`
+    PromptID       string            `json:"promptId"`
+    PromptVersion  int               `json:"promptVersion"`
+    Error          string            `json:"error,omitempty"`
+    Skipped        string            `json:"skipped,omitempty"` // LLM 不可用等原因跳过翻译时的说明
+}
+
+type TranslatedChunk struct {
+    Position       int    `json:"position"`
+    Text           string `json:"text,omitempty"`
+    SourceLanguage string `json:"sourceLanguage,omitempty"` // 检测到的原文语言
+    Skipped        bool   `json:"skipped,omitempty"`        // 原文语言不在翻译范围内或已是目标语言
+    Error          string `json:"error,omitempty"`          // 该块翻译失败时的错误，其他块不受影响
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+    "fmt"
+    "sort"
+)
+
+// CorrectionStatus 修正的复核状态
+type CorrectionStatus string
+
+const (
+    CorrectionPending  CorrectionStatus = "pending"
+    CorrectionAccepted CorrectionStatus = "accepted"
+    CorrectionRejected CorrectionStatus = "rejected"
+)
+
+// Correction LLM 对 OCR 文本提出的一处修正，
+// Start/End 为 OCR 文本中的字符（rune）偏移，未能定位时为 -1
+type Correction struct {
+    ID        string           `json:"id"`
+    Original  string           `json:"original"`
+    Corrected string           `json:"corrected"`
+    Start     int              `json:"start"`
+    End       int              `json:"end"`
+    Status    CorrectionStatus `json:"status"`
+}
+
+// Located 修正是否能在 OCR 文本中定位
+func (c Correction) Located() bool {
+    return c.Start >= 0 && c.End >= c.Start
+}
+
+// Uncertainty LLM 标记为不确定的片段，Start/End 含义同 Correction
+type Uncertainty struct {
+    Text  string `json:"text"`
+    Start int    `json:"start"`
+    End   int    `json:"end"`
+}
+
+// TextRevision OCR 文本与 LLM 修正的结构化差异
+type TextRevision struct {
+    OCRText       string        `json:"ocrText"`
+    Corrections   []Correction  `json:"corrections"`
+    Uncertainties []Uncertainty `json:"uncertainties"`
+    MergedText    string        `json:"mergedText"` // 应用未被拒绝的修正后的文本
+}
+
+// SetStatus 接受或拒绝单条修正并重新生成 MergedText
+func (r *TextRevision) SetStatus(id string, status CorrectionStatus) error {
+    switch status {
+    case CorrectionPending, CorrectionAccepted, CorrectionRejected:
+    default:
+        return fmt.Errorf("invalid correction status: %s", status)
+    }
+
+    for i := range r.Corrections {
+        if r.Corrections[i].ID == id {
+            r.Corrections[i].Status = status
+            r.MergedText = r.Merge()
+            return nil
+        }
+    }
+    return fmt.Errorf("correction not found: %s", id)
+}
+
+// Merge 将已定位且未被拒绝的修正应用到 OCR 文本上
+func (r *TextRevision) Merge() string {
+    applied := make([]Correction, 0, len(r.Corrections))
+    for _, c := range r.Corrections {
+        if c.Located() && c.Status != CorrectionRejected {
+            applied = append(applied, c)
+        }
+    }
+    sort.Slice(applied, func(i, j int) bool {
+        return applied[i].Start < applied[j].Start
+    })
+
+    text := []rune(r.OCRText)
+    merged := make([]rune, 0, len(text))
+    cursor := 0
+    for _, c := range applied {
+        // 重叠的修正只保留位置靠前的一条
+        if c.Start < cursor || c.End > len(text) {
+            continue
+        }
+        merged = append(merged, text[cursor:c.Start]...)
+        merged = append(merged, []rune(c.Corrected)...)
+        cursor = c.End
+    }
+    merged = append(merged, text[cursor:]...)
+
+    return string(merged)
+}
@@ -0,0 +1,88 @@
+package models
+
+import "testing"
+
+func newRevision() *TextRevision {
+    // "收款方 ACME Corp0ration 金额 1375"，偏移按字符计算
+    r := &TextRevision{
+        OCRText: "收款方 ACME Corp0ration 金额 1375",
+        Corrections: []Correction{
+            {ID: "c1", Original: "Corp0ration", Corrected: "Corporation", Start: 9, End: 20, Status: CorrectionPending},
+            {ID: "c2", Original: "金额", Corrected: "总金额", Start: 21, End: 23, Status: CorrectionPending},
+            {ID: "c3", Original: "unknown", Corrected: "x", Start: -1, End: -1, Status: CorrectionPending},
+        },
+    }
+    r.MergedText = r.Merge()
+    return r
+}
+
+func TestMerge(t *testing.T) {
+    r := newRevision()
+    if r.MergedText != "收款方 ACME Corporation 总金额 1375" {
+        t.Errorf("MergedText = %q", r.MergedText)
+    }
+}
+
+func TestSetStatusRejectsCorrection(t *testing.T) {
+    r := newRevision()
+
+    if err := r.SetStatus("c2", CorrectionRejected); err != nil {
+        t.Fatalf("SetStatus() error = %v", err)
+    }
+    if r.MergedText != "收款方 ACME Corporation 金额 1375" {
+        t.Errorf("MergedText after rejecting c2 = %q", r.MergedText)
+    }
+
+    if err := r.SetStatus("c1", CorrectionAccepted); err != nil {
+        t.Fatalf("SetStatus() error = %v", err)
+    }
+    if err := r.SetStatus("c2", CorrectionPending); err != nil {
+        t.Fatalf("SetStatus() error = %v", err)
+    }
+    if r.MergedText != "收款方 ACME Corporation 总金额 1375" {
+        t.Errorf("MergedText after restoring c2 = %q", r.MergedText)
+    }
+
+    r.SetStatus("c1", CorrectionRejected)
+    r.SetStatus("c2", CorrectionRejected)
+    if r.MergedText != r.OCRText {
+        t.Errorf("MergedText with all corrections rejected = %q", r.MergedText)
+    }
+
+    if err := r.SetStatus("c9", CorrectionAccepted); err == nil {
+        t.Error("SetStatus() accepted an unknown correction")
+    }
+    if err := r.SetStatus("c1", "maybe"); err == nil {
+        t.Error("SetStatus() accepted an invalid status")
+    }
+}
+
+func TestMergeOverlappingCorrections(t *testing.T) {
+    r := &TextRevision{
+        OCRText: "Tota1 due",
+        Corrections: []Correction{
+            // 顺序与位置无关，位置靠前的修正生效
+            {ID: "c2", Original: "a1 d", Corrected: "al d", Start: 3, End: 7},
+            {ID: "c1", Original: "Tota1", Corrected: "Total", Start: 0, End: 5},
+        },
+    }
+    if got := r.Merge(); got != "Total due" {
+        t.Errorf("Merge() = %q", got)
+    }
+
+    // 拒绝靠前的修正后，重叠的另一条生效
+    r.Corrections[1].Status = CorrectionRejected
+    if got := r.Merge(); got != "Total due" {
+        t.Errorf("Merge() after rejecting c1 = %q", got)
+    }
+    r.Corrections[0].Corrected = "aX d"
+    if got := r.Merge(); got != "TotaX due" {
+        t.Errorf("Merge() after rejecting c1 = %q", got)
+    }
+
+    // 超出文本的偏移被忽略
+    r.Corrections = []Correction{{ID: "c1", Corrected: "x", Start: 5, End: 50}}
+    if got := r.Merge(); got != "Tota1 due" {
+        t.Errorf("Merge() with out of range offsets = %q", got)
+    }
+}
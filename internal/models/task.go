@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// StageTiming 处理阶段的起止时间，同一阶段在重试时会再次出现
+type StageTiming struct {
+    Stage      string     `json:"stage"`
+    Attempt    int        `json:"attempt"`
+    StartedAt  time.Time  `json:"startedAt"`
+    FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// TaskError 一次处理失败，Final 为 true 时任务不再重试
+type TaskError struct {
+    Attempt    int       `json:"attempt"`
+    Error      string    `json:"error"`
+    ErrorClass string    `json:"errorClass,omitempty"`
+    Final      bool      `json:"final,omitempty"`
+    At         time.Time `json:"at"`
+}
+
+// StartAttempt worker 开始一次处理
+func (t *ProcessingTask) StartAttempt(now time.Time) {
+    t.closeStage(now)
+    t.Attempts++
+    t.Status = StatusRunning
+    t.Error = ""
+    t.ErrorClass = ""
+    if t.StartedAt == nil {
+        t.StartedAt = &now
+    }
+}
+
+// EnterStage 记录进入新的处理阶段，上一个阶段随之结束
+func (t *ProcessingTask) EnterStage(stage string, now time.Time) {
+    if n := len(t.Stages); n > 0 && t.Stages[n-1].FinishedAt == nil && t.Stages[n-1].Stage == stage {
+        return
+    }
+    t.closeStage(now)
+    t.Stages = append(t.Stages, StageTiming{Stage: stage, Attempt: t.Attempts, StartedAt: now})
+}
+
+// Fail 记录一次失败，final 为 false 时任务等待重试
+func (t *ProcessingTask) Fail(err, class string, final bool, now time.Time) {
+    t.closeStage(now)
+    t.Errors = append(t.Errors, TaskError{
+        Attempt:    t.Attempts,
+        Error:      err,
+        ErrorClass: class,
+        Final:      final,
+        At:         now,
+    })
+    t.Error = err
+    t.ErrorClass = class
+    t.Status = StatusPending
+    if final {
+        t.Status = StatusFailed
+        t.FinishedAt = &now
+    }
+}
+
+// Finish 任务完成或被取消
+func (t *ProcessingTask) Finish(status ProcessingStatus, now time.Time) {
+    t.closeStage(now)
+    t.Status = status
+    t.FinishedAt = &now
+    if status == StatusCompleted {
+        t.Progress = 1
+        t.Error = ""
+        t.ErrorClass = ""
+    }
+}
+
+// Requeue 死信队列中的任务重新入队，保留尝试次数和错误历史
+func (t *ProcessingTask) Requeue() {
+    t.Status = StatusPending
+    t.Progress = 0
+    t.Error = ""
+    t.ErrorClass = ""
+    t.FinishedAt = nil
+}
+
+func (t *ProcessingTask) closeStage(now time.Time) {
+    if n := len(t.Stages); n > 0 && t.Stages[n-1].FinishedAt == nil {
+        t.Stages[n-1].FinishedAt = &now
+    }
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/feichai0017/document-processor/internal/models"
+)
+
+// errConflict 多次重试后仍有并发修改
+var errConflict = errors.New("concurrent task update")
+
+// maxUpdateAttempts 并发修改时 Update 的最大尝试次数
+const maxUpdateAttempts = 10
+
+// Redis 将任务保存为 JSON，ttl 为 0 时不过期
+type Redis struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewRedis(client *redis.Client, ttl time.Duration) *Redis {
+	return &Redis{client: client, ttl: ttl}
+}
+
+func taskKey(taskID string) string {
+	return fmt.Sprintf("task:%s", taskID)
+}
+
+func (r *Redis) Create(ctx context.Context, task *models.ProcessingTask) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+	ok, err := r.client.SetNX(ctx, taskKey(task.ID), data, r.ttl).Result()
+	if err != nil {
+		return fmt.Errorf("failed to save task: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("task %s already exists", task.ID)
+	}
+	return nil
+}
+
+func (r *Redis) Get(ctx context.Context, taskID string) (*models.ProcessingTask, error) {
+	return r.get(ctx, r.client, taskID)
+}
+
+func (r *Redis) get(ctx context.Context, c redis.Cmdable, taskID string) (*models.ProcessingTask, error) {
+	data, err := c.Get(ctx, taskKey(taskID)).Bytes()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	var task models.ProcessingTask
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+	}
+	return &task, nil
+}
+
+func (r *Redis) Update(ctx context.Context, taskID string, fn func(*models.ProcessingTask) error) (*models.ProcessingTask, error) {
+	key := taskKey(taskID)
+	var updated *models.ProcessingTask
+
+	// WATCH 期间任务被其他进程修改时事务失败，重新读取后重试
+	txf := func(tx *redis.Tx) error {
+		task, err := r.get(ctx, tx, taskID)
+		if err != nil {
+			return err
+		}
+		if err := fn(task); err != nil {
+			return err
+		}
+		task.UpdatedAt = time.Now()
+		data, err := json.Marshal(task)
+		if err != nil {
+			return fmt.Errorf("failed to marshal task: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// KeepTTL 保留创建时设置的过期时间
+			pipe.Set(ctx, key, data, redis.KeepTTL)
+			return nil
+		})
+		if err == nil {
+			updated = task
+		}
+		return err
+	}
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		err := r.client.Watch(ctx, txf, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+	return nil, fmt.Errorf("failed to update task %s: %w", taskID, errConflict)
+}
+
+// Close 客户端由队列共享，不在这里关闭
+func (r *Redis) Close() error {
+	return nil
+}
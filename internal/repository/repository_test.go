@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/feichai0017/document-processor/internal/models"
+)
+
+// backends 每个实现返回两个共享同一存储的仓库，模拟 API 服务和 worker 两个进程
+var backends = []struct {
+	name string
+	open func(t *testing.T) (TaskRepository, TaskRepository)
+}{
+	{
+		name: "redis",
+		open: func(t *testing.T) (TaskRepository, TaskRepository) {
+			server := miniredis.RunT(t)
+			client := func() *redis.Client {
+				c := redis.NewClient(&redis.Options{Addr: server.Addr()})
+				t.Cleanup(func() { c.Close() })
+				return c
+			}
+			return NewRedis(client(), time.Hour), NewRedis(client(), time.Hour)
+		},
+	},
+	{
+		name: "sqlite",
+		open: func(t *testing.T) (TaskRepository, TaskRepository) {
+			path := filepath.Join(t.TempDir(), "tasks.db")
+			open := func() TaskRepository {
+				repo, err := NewSQLite(path)
+				if err != nil {
+					t.Fatalf("NewSQLite() error = %v", err)
+				}
+				t.Cleanup(func() { repo.Close() })
+				return repo
+			}
+			return open(), open()
+		},
+	},
+}
+
+func newTask(id string) *models.ProcessingTask {
+	return &models.ProcessingTask{
+		ID:        id,
+		Status:    models.StatusPending,
+		Type:      ".pdf",
+		Metadata:  map[string]string{"filename": "invoice.pdf"},
+		Options:   &models.ProcessingOptions{DocumentType: "invoice"},
+		CreatedAt: time.Now().Truncate(time.Millisecond),
+	}
+}
+
+func TestCreateGetUpdate(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			repo, other := backend.open(t)
+			ctx := context.Background()
+
+			if err := repo.Create(ctx, newTask("task-1")); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if err := repo.Create(ctx, newTask("task-1")); err == nil {
+				t.Error("Create() accepted a duplicate task")
+			}
+
+			got, err := other.Get(ctx, "task-1")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.Status != models.StatusPending || got.Metadata["filename"] != "invoice.pdf" ||
+				got.Options == nil || got.Options.DocumentType != "invoice" {
+				t.Errorf("Get() = %+v", got)
+			}
+
+			updated, err := other.Update(ctx, "task-1", func(task *models.ProcessingTask) error {
+				task.StartAttempt(time.Now())
+				task.Progress = 0.5
+				return nil
+			})
+			if err != nil || updated.Status != models.StatusRunning || updated.UpdatedAt.IsZero() {
+				t.Fatalf("Update() = %+v, %v", updated, err)
+			}
+			got, _ = repo.Get(ctx, "task-1")
+			if got.Status != models.StatusRunning || got.Progress != 0.5 || got.Attempts != 1 {
+				t.Errorf("Get() after Update() = %+v", got)
+			}
+
+			// fn 返回错误时不保存
+			errStop := errors.New("stop")
+			if _, err := repo.Update(ctx, "task-1", func(task *models.ProcessingTask) error {
+				task.Progress = 0.9
+				return errStop
+			}); !errors.Is(err, errStop) {
+				t.Errorf("Update() error = %v, want the fn error", err)
+			}
+			if got, _ := repo.Get(ctx, "task-1"); got.Progress != 0.5 {
+				t.Errorf("failed Update() was saved: progress = %v", got.Progress)
+			}
+		})
+	}
+}
+
+func TestTaskNotFound(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			repo, _ := backend.open(t)
+			ctx := context.Background()
+
+			if _, err := repo.Get(ctx, "missing"); !errors.Is(err, ErrTaskNotFound) {
+				t.Errorf("Get() error = %v, want ErrTaskNotFound", err)
+			}
+			called := false
+			_, err := repo.Update(ctx, "missing", func(*models.ProcessingTask) error {
+				called = true
+				return nil
+			})
+			if !errors.Is(err, ErrTaskNotFound) || called {
+				t.Errorf("Update() error = %v, fn called = %v; want ErrTaskNotFound without calling fn", err, called)
+			}
+		})
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			repo, other := backend.open(t)
+			ctx := context.Background()
+			if err := repo.Create(ctx, newTask("task-1")); err != nil {
+				t.Fatal(err)
+			}
+
+			// 两个进程同时修改同一任务，每次修改都不能丢失
+			const writers = maxUpdateAttempts
+			var wg sync.WaitGroup
+			errs := make(chan error, writers)
+			for i := 0; i < writers; i++ {
+				r := repo
+				if i%2 == 1 {
+					r = other
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := r.Update(ctx, "task-1", func(task *models.ProcessingTask) error {
+						attempts := task.Attempts
+						// 读取和写入之间留出时间，让其他写入者插入
+						time.Sleep(time.Millisecond)
+						task.Attempts = attempts + 1
+						return nil
+					})
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatalf("Update() error = %v", err)
+				}
+			}
+
+			got, _ := repo.Get(ctx, "task-1")
+			if got.Attempts != writers {
+				t.Errorf("Attempts = %d, want %d", got.Attempts, writers)
+			}
+		})
+	}
+}
+
+func TestHistoryKeptAcrossUpdates(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			repo, other := backend.open(t)
+			ctx := context.Background()
+			if err := repo.Create(ctx, newTask("task-1")); err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Now()
+			steps := []struct {
+				repo TaskRepository
+				fn   func(*models.ProcessingTask)
+			}{
+				{repo, func(task *models.ProcessingTask) { task.StartAttempt(now) }},
+				{other, func(task *models.ProcessingTask) { task.EnterStage("processing", now) }},
+				{repo, func(task *models.ProcessingTask) { task.Fail("textract throttled", "throttled", false, now) }},
+				{other, func(task *models.ProcessingTask) { task.StartAttempt(now) }},
+				{repo, func(task *models.ProcessingTask) { task.EnterStage("processing", now) }},
+				{other, func(task *models.ProcessingTask) { task.Finish(models.StatusCompleted, now) }},
+			}
+			for _, step := range steps {
+				fn := step.fn
+				if _, err := step.repo.Update(ctx, "task-1", func(task *models.ProcessingTask) error {
+					fn(task)
+					return nil
+				}); err != nil {
+					t.Fatalf("Update() error = %v", err)
+				}
+			}
+
+			got, err := repo.Get(ctx, "task-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != models.StatusCompleted || got.Attempts != 2 || got.Error != "" || got.FinishedAt == nil {
+				t.Errorf("task = %+v", got)
+			}
+			// 失败的尝试保留在错误历史中
+			if len(got.Errors) != 1 || got.Errors[0].Attempt != 1 || got.Errors[0].ErrorClass != "throttled" || got.Errors[0].Final {
+				t.Errorf("errors = %+v", got.Errors)
+			}
+			if len(got.Stages) != 2 || got.Stages[0].Attempt != 1 || got.Stages[1].Attempt != 2 ||
+				got.Stages[0].FinishedAt == nil || got.Stages[1].FinishedAt == nil {
+				t.Errorf("stages = %+v", got.Stages)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/feichai0017/document-processor/internal/models"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS task_records (
+	task_id    TEXT PRIMARY KEY,
+	status     TEXT NOT NULL,
+	data       TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS task_records_status ON task_records (status, updated_at);
+`
+
+// SQLite 基于嵌入式 SQLite 的仓库，任务保存为 JSON，状态单独成列。
+// 多个进程共享同一个文件时（如同一台机器上的 API 服务和 worker）依靠文件锁串行写入
+type SQLite struct {
+	db *sql.DB
+}
+
+// NewSQLite 打开或创建 path 处的数据库
+func NewSQLite(path string) (*SQLite, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create task repository directory: %w", err)
+	}
+
+	// 事务开始时即获取写锁，读取后写入不会因其他进程的写入失败
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open task repository: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create task repository schema: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) Create(ctx context.Context, task *models.ProcessingTask) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO task_records (task_id, status, data, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		task.ID, string(task.Status), string(data), task.CreatedAt.UnixNano(), time.Now().UnixNano(),
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("task %s already exists", task.ID)
+		}
+		return fmt.Errorf("failed to save task: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLite) Get(ctx context.Context, taskID string) (*models.ProcessingTask, error) {
+	return s.get(ctx, s.db, taskID)
+}
+
+// querier *sql.DB 和 *sql.Tx 共有的查询方法
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *SQLite) get(ctx context.Context, q querier, taskID string) (*models.ProcessingTask, error) {
+	var data string
+	err := q.QueryRowContext(ctx, `SELECT data FROM task_records WHERE task_id = ?`, taskID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	var task models.ProcessingTask
+	if err := json.Unmarshal([]byte(data), &task); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+	}
+	return &task, nil
+}
+
+func (s *SQLite) Update(ctx context.Context, taskID string, fn func(*models.ProcessingTask) error) (*models.ProcessingTask, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin task update: %w", err)
+	}
+	defer tx.Rollback()
+
+	task, err := s.get(ctx, tx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(task); err != nil {
+		return nil, err
+	}
+	task.UpdatedAt = time.Now()
+	data, err := json.Marshal(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE task_records SET status = ?, data = ?, updated_at = ? WHERE task_id = ?`,
+		string(task.Status), string(data), task.UpdatedAt.UnixNano(), taskID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to update task: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit task update: %w", err)
+	}
+	return task, nil
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
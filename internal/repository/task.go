@@ -0,0 +1,24 @@
+// Package repository 保存任务的完整生命周期：提交时的任务信息和处理选项、每次尝试、
+// 各阶段耗时、错误历史和结果位置。API 服务和 worker 都通过 TaskRepository 读写任务
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/feichai0017/document-processor/internal/models"
+)
+
+// ErrTaskNotFound 任务不存在或已过期
+var ErrTaskNotFound = errors.New("task not found")
+
+// TaskRepository 任务仓库
+type TaskRepository interface {
+	// Create 保存新提交的任务，任务已存在时返回错误
+	Create(ctx context.Context, task *models.ProcessingTask) error
+	Get(ctx context.Context, taskID string) (*models.ProcessingTask, error)
+	// Update 读取任务后调用 fn 修改并保存，返回保存后的任务。
+	// 并发修改同一任务时重新读取并重试，fn 可能被调用多次
+	Update(ctx context.Context, taskID string, fn func(task *models.ProcessingTask) error) (*models.ProcessingTask, error)
+	Close() error
+}
@@ -0,0 +1,366 @@
+package document
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+
+	docagent "github.com/feichai0017/document-processor/internal/agent/document"
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/pkg/logger"
+	"github.com/feichai0017/document-processor/pkg/queue"
+)
+
+// defaultBatchConcurrency 同时上传和入队的文件数
+const defaultBatchConcurrency = 4
+
+type batchIDCtx struct{}
+
+// withBatchID 批量提交的任务在任务信息中记录 batchId，失败时据此执行批量任务的策略
+func withBatchID(ctx context.Context, batchID string) context.Context {
+	return context.WithValue(ctx, batchIDCtx{}, batchID)
+}
+
+func batchIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(batchIDCtx{}).(string)
+	return id
+}
+
+// ProcessBatch 批量处理文件。单个文件提交失败不影响整个请求，失败原因记录在对应的条目中；
+// policy 为 abort 时停止提交剩余文件，并取消已提交的任务
+func (s *DocumentService) ProcessBatch(
+	ctx context.Context,
+	files []*multipart.FileHeader,
+	opts *models.ProcessingOptions,
+	policy models.BatchPolicy,
+) (*models.Batch, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, docagent.NewError(docagent.ErrorClassInvalidRequest, err)
+	}
+	policy = policy.OrDefault()
+	batchCallbackURL := batchCallbackURLFrom(ctx)
+	if batchCallbackURL != "" {
+		if err := s.validateCallbackURL(batchCallbackURL); err != nil {
+			return nil, err
+		}
+	}
+
+	batchKey := idempotencyKeyFrom(ctx)
+	record := &queue.Batch{
+		ID:        uuid.New().String(),
+		Policy:    string(policy),
+		Items:     make([]*queue.BatchItem, len(files)),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	// 相同 Idempotency-Key 的重试返回第一次创建的批量任务
+	if batchKey != "" {
+		existing, ok, err := s.queue.Reserve(ctx, "idempotency:batch:"+batchKey, record.ID, s.config.IdempotencyTTL)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return s.GetBatch(ctx, existing)
+		}
+	}
+
+	for i, header := range files {
+		record.Items[i] = &queue.BatchItem{
+			Index:    i,
+			Filename: header.Filename,
+			FileSize: header.Size,
+		}
+	}
+	// 提交文件前保存记录，幂等重试和状态查询都能找到批量任务
+	if err := s.queue.SaveBatch(ctx, record); err != nil {
+		if batchKey != "" {
+			s.queue.Release(context.Background(), "idempotency:batch:"+batchKey, record.ID)
+		}
+		return nil, err
+	}
+	if batchCallbackURL != "" {
+		if err := s.webhooks.RegisterBatch(ctx, record.ID, batchCallbackURL); err != nil {
+			if batchKey != "" {
+				s.queue.Release(context.Background(), "idempotency:batch:"+batchKey, record.ID)
+			}
+			return nil, fmt.Errorf("failed to register batch callback: %w", err)
+		}
+	}
+
+	limit := s.config.MaxConcurrent
+	if limit <= 0 {
+		limit = defaultBatchConcurrency
+	}
+	var g errgroup.Group
+	g.SetLimit(limit)
+
+	var aborted atomic.Bool
+	var mu sync.Mutex
+	tasks := make(map[string]*models.ProcessingTask, len(files))
+	batchCtx := withBatchID(ctx, record.ID)
+
+	for i, header := range files {
+		item := record.Items[i]
+		g.Go(func() error {
+			if aborted.Load() {
+				item.Skipped = true
+				return nil
+			}
+
+			// 批量请求的 Idempotency-Key 按文件序号区分
+			fileCtx := batchCtx
+			if batchKey != "" {
+				fileCtx = WithIdempotencyKey(batchCtx, fmt.Sprintf("%s/%d", batchKey, item.Index))
+			}
+			task, err := s.submitBatchFile(fileCtx, header, opts)
+			if err != nil {
+				item.Error = err.Error()
+				item.ErrorClass = string(docagent.ClassifyError(err))
+				if policy == models.BatchPolicyAbort {
+					aborted.Store(true)
+				}
+				s.logger.Warn("Batch file rejected",
+					logger.String("batchId", record.ID),
+					logger.String("filename", header.Filename),
+					logger.Error(err),
+				)
+				return nil
+			}
+
+			item.TaskID = task.ID
+			item.Deduplicated = task.Deduplicated
+			mu.Lock()
+			tasks[task.ID] = task
+			mu.Unlock()
+			return nil
+		})
+	}
+	g.Wait()
+
+	if aborted.Load() {
+		record.Aborted = true
+		s.cancelBatchTasks(ctx, record, "")
+	}
+	record.Submitted = true
+	record.UpdatedAt = time.Now()
+	if err := s.queue.SaveBatch(ctx, record); err != nil {
+		return nil, err
+	}
+	// 提交期间已经结束的任务没有触发批量回调，全部结束时在这里发送
+	s.notifyBatchFinished(ctx, record.ID)
+
+	s.logger.Info("Batch created",
+		logger.String("batchId", record.ID),
+		logger.Int("files", len(files)),
+		logger.Int("tasks", len(tasks)),
+		logger.Bool("aborted", record.Aborted),
+	)
+
+	batch := toBatch(record)
+	for _, item := range batch.Items {
+		if task, ok := tasks[item.TaskID]; ok && !record.Aborted {
+			item.Status = task.Status
+			item.Progress = task.Progress
+		}
+	}
+	if record.Aborted {
+		// 已提交的任务刚被取消，读取取消后的状态
+		s.loadBatchItems(ctx, batch)
+	}
+	batch.Summarize()
+	return batch, nil
+}
+
+// submitBatchFile 打开并提交单个文件
+func (s *DocumentService) submitBatchFile(ctx context.Context, header *multipart.FileHeader, opts *models.ProcessingOptions) (*models.ProcessingTask, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", header.Filename, err)
+	}
+	defer file.Close()
+
+	return s.ProcessFile(ctx, file, header, opts)
+}
+
+// GetBatch 返回批量任务及每个文件当前的状态
+func (s *DocumentService) GetBatch(ctx context.Context, batchID string) (*models.Batch, error) {
+	record, err := s.queue.GetBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := toBatch(record)
+	s.loadBatchItems(ctx, batch)
+	batch.Summarize()
+	return batch, nil
+}
+
+// loadBatchItems 读取已创建任务的文件的当前状态
+func (s *DocumentService) loadBatchItems(ctx context.Context, batch *models.Batch) {
+	for _, item := range batch.Items {
+		if item.TaskID == "" {
+			continue
+		}
+		task, err := s.GetProcessingStatus(ctx, item.TaskID)
+		if err != nil {
+			// 任务状态已过期，无法确定结果
+			item.Status = models.StatusFailed
+			item.Error = err.Error()
+			continue
+		}
+		item.Status = task.Status
+		item.Progress = task.Progress
+		item.Error = task.Error
+		item.ErrorClass = task.ErrorClass
+	}
+}
+
+// toBatch 转换保存的记录，未创建任务的文件标记为 rejected 或 skipped，其余为 pending
+func toBatch(record *queue.Batch) *models.Batch {
+	batch := &models.Batch{
+		ID:        record.ID,
+		Policy:    models.BatchPolicy(record.Policy),
+		Aborted:   record.Aborted,
+		Items:     make([]*models.BatchItem, len(record.Items)),
+		CreatedAt: record.CreatedAt,
+		UpdatedAt: record.UpdatedAt,
+	}
+	for i, r := range record.Items {
+		item := &models.BatchItem{
+			Index:        r.Index,
+			Filename:     r.Filename,
+			FileSize:     r.FileSize,
+			TaskID:       r.TaskID,
+			Status:       models.StatusPending,
+			Error:        r.Error,
+			ErrorClass:   r.ErrorClass,
+			Deduplicated: r.Deduplicated,
+		}
+		switch {
+		case r.Skipped:
+			item.Status = models.StatusSkipped
+		case r.TaskID == "":
+			item.Status = models.StatusRejected
+		}
+		batch.Items[i] = item
+	}
+	return batch
+}
+
+// abortBatch 任务最终失败后，abort 策略的批量任务取消其余未结束的任务
+func (s *DocumentService) abortBatch(ctx context.Context, batchID, failedTaskID string) {
+	record, err := s.queue.GetBatch(ctx, batchID)
+	if err != nil {
+		s.logger.Warn("Failed to load batch of failed task",
+			logger.String("batchId", batchID),
+			logger.String("taskId", failedTaskID),
+			logger.Error(err),
+		)
+		return
+	}
+	if record.Policy != string(models.BatchPolicyAbort) || record.Aborted {
+		return
+	}
+
+	record.Aborted = true
+	record.UpdatedAt = time.Now()
+	if err := s.queue.SaveBatch(ctx, record); err != nil {
+		s.logger.Error("Failed to save aborted batch",
+			logger.String("batchId", batchID),
+			logger.Error(err),
+		)
+		return
+	}
+
+	s.logger.Info("Aborting batch after task failure",
+		logger.String("batchId", batchID),
+		logger.String("taskId", failedTaskID),
+	)
+	s.cancelBatchTasks(ctx, record, failedTaskID)
+}
+
+// cancelBatchTasks 取消批量任务中未结束的任务。复用的已有任务属于其他提交，不取消
+func (s *DocumentService) cancelBatchTasks(ctx context.Context, record *queue.Batch, exceptTaskID string) {
+	for _, item := range record.Items {
+		if item.TaskID == "" || item.TaskID == exceptTaskID || item.Deduplicated {
+			continue
+		}
+		if task, err := s.GetProcessingStatus(ctx, item.TaskID); err == nil && task.Status.IsTerminal() {
+			continue
+		}
+		if err := s.CancelTask(ctx, item.TaskID); err != nil {
+			// 任务可能刚好结束
+			s.logger.Debug("Failed to cancel batch task",
+				logger.String("batchId", record.ID),
+				logger.String("taskId", item.TaskID),
+				logger.Error(err),
+			)
+		}
+	}
+}
+
+// WriteBatchResults 将批量任务中处理成功的结果打包为 zip，
+// 文件名为 "<序号>_<原文件名>.json"，batch.json 为批量任务的状态和每个文件的错误
+func (s *DocumentService) WriteBatchResults(ctx context.Context, batch *models.Batch, w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	for _, item := range batch.Items {
+		if item.Status != models.StatusCompleted {
+			continue
+		}
+		doc, err := s.GetProcessedDocument(ctx, item.TaskID)
+		if err == nil {
+			var data []byte
+			if data, err = json.Marshal(doc); err == nil {
+				err = writeZipEntry(zw, batchResultName(item), data)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			// 单个结果读取失败时在 batch.json 中说明，不影响其他结果
+			item.Error = fmt.Sprintf("result unavailable: %v", err)
+		}
+	}
+
+	manifest, err := json.MarshalIndent(batch, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+	if err := writeZipEntry(zw, "batch.json", manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeZipEntry(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry %s: %w", name, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to write zip entry %s: %w", name, err)
+	}
+	return nil
+}
+
+// batchResultName 加上序号，同名文件不会相互覆盖
+func batchResultName(item *models.BatchItem) string {
+	name := filepath.Base(item.Filename)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return fmt.Sprintf("%03d_%s.json", item.Index, name)
+}
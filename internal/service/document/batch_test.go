@@ -0,0 +1,488 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/feichai0017/document-processor/internal/agent"
+	docagent "github.com/feichai0017/document-processor/internal/agent/document"
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/internal/repository"
+	"github.com/feichai0017/document-processor/pkg/converters"
+	"github.com/feichai0017/document-processor/pkg/logger"
+	"github.com/feichai0017/document-processor/pkg/queue"
+	"github.com/feichai0017/document-processor/pkg/webhook"
+)
+
+// memStorage 内存存储，delay 按文件名延迟写入以打乱提交的完成顺序
+type memStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	delay func(name string) time.Duration
+}
+
+func (m *memStorage) Store(ctx context.Context, reader io.Reader, filename string) (string, error) {
+	if m.delay != nil {
+		time.Sleep(m.delay(filename))
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[filename] = data
+	return filename, nil
+}
+
+func (m *memStorage) Get(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[fileID]
+	if !ok {
+		return nil, fmt.Errorf("file not found: %s", fileID)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memStorage) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, id)
+	return nil
+}
+
+func (m *memStorage) CleanupBefore(ctx context.Context, threshold time.Time) error {
+	return nil
+}
+
+func (m *memStorage) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.files)
+}
+
+func newBatchService(t *testing.T, concurrency int) (*DocumentService, *memStorage) {
+	t.Helper()
+	log := logger.NewTestLogger()
+
+	q, err := queue.NewAsynqQueue(&queue.QueueConfig{RedisAddr: miniredis.RunT(t).Addr(), Logger: log})
+	if err != nil {
+		t.Fatalf("NewAsynqQueue() error = %v", err)
+	}
+	store := &memStorage{files: make(map[string][]byte)}
+	s := NewService(agent.ProcessorFactory{}, q, store, log, &ServiceConfig{
+		MaxFileSize:    1024,
+		AllowedTypes:   []string{".pdf", ".png"},
+		MaxConcurrent:  concurrency,
+		IdempotencyTTL: time.Hour,
+	}, nil, nil, nil, nil, repository.NewRedis(q.RedisClient(), time.Hour)).(*DocumentService)
+	t.Cleanup(func() { s.Close() })
+	return s, store
+}
+
+// batchFiles 按顺序生成上传文件，内容各不相同
+func batchFiles(t *testing.T, names ...string) []*multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for i, name := range names {
+		part, err := w.CreateFormFile("files", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := fmt.Sprintf("file %d: %s", i, name)
+		if strings.HasPrefix(name, "large") {
+			content = strings.Repeat("x", 2048)
+		}
+		part.Write([]byte(content))
+	}
+	w.Close()
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["files"]
+}
+
+func itemStatuses(batch *models.Batch) []models.ProcessingStatus {
+	statuses := make([]models.ProcessingStatus, len(batch.Items))
+	for i, item := range batch.Items {
+		statuses[i] = item.Status
+	}
+	return statuses
+}
+
+func assertStatuses(t *testing.T, batch *models.Batch, want ...models.ProcessingStatus) {
+	t.Helper()
+	got := itemStatuses(batch)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("item statuses = %v, want %v", got, want)
+	}
+}
+
+func TestProcessBatchContinue(t *testing.T) {
+	s, _ := newBatchService(t, 4)
+	ctx := context.Background()
+
+	files := batchFiles(t, "a.pdf", "notes.exe", "b.png", "large.pdf", "c.pdf")
+	batch, err := s.ProcessBatch(ctx, files, nil, "")
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+
+	if batch.Policy != models.BatchPolicyContinue || batch.Aborted {
+		t.Errorf("batch policy = %s, aborted = %v", batch.Policy, batch.Aborted)
+	}
+	assertStatuses(t, batch,
+		models.StatusPending, models.StatusRejected, models.StatusPending, models.StatusRejected, models.StatusPending)
+
+	// 校验失败记录在对应的条目中
+	if item := batch.Items[1]; item.TaskID != "" || item.ErrorClass != string(docagent.ErrorClassUnsupportedType) || !strings.Contains(item.Error, ".exe") {
+		t.Errorf("unsupported file item = %+v", item)
+	}
+	if item := batch.Items[3]; item.TaskID != "" || item.ErrorClass != string(docagent.ErrorClassInvalidRequest) {
+		t.Errorf("oversized file item = %+v", item)
+	}
+
+	for _, i := range []int{0, 2, 4} {
+		task, err := s.tasks.Get(ctx, batch.Items[i].TaskID)
+		if err != nil {
+			t.Fatalf("item %d task: %v", i, err)
+		}
+		if task.Metadata["batchId"] != batch.ID || task.Metadata["filename"] != files[i].Filename {
+			t.Errorf("item %d task metadata = %v", i, task.Metadata)
+		}
+	}
+
+	// 查询结果与提交时一致
+	got, err := s.GetBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatalf("GetBatch() error = %v", err)
+	}
+	assertStatuses(t, got, itemStatuses(batch)...)
+	if got.Counts[models.StatusRejected] != 2 || got.Counts[models.StatusPending] != 3 {
+		t.Errorf("counts = %v", got.Counts)
+	}
+}
+
+func TestProcessBatchKeepsFileOrder(t *testing.T) {
+	s, store := newBatchService(t, 4)
+	// 靠前的文件写入最慢，提交按相反的顺序完成
+	store.delay = func(name string) time.Duration {
+		var i int
+		fmt.Sscanf(name[strings.Index(name, "/")+1:], "f%02d", &i)
+		return time.Duration(12-i) * 5 * time.Millisecond
+	}
+
+	names := make([]string, 12)
+	for i := range names {
+		names[i] = fmt.Sprintf("f%02d.pdf", i)
+	}
+	files := batchFiles(t, names...)
+	batch, err := s.ProcessBatch(context.Background(), files, nil, models.BatchPolicyContinue)
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+
+	for i, item := range batch.Items {
+		if item.Index != i || item.Filename != names[i] {
+			t.Errorf("item %d = %d %s, want %s", i, item.Index, item.Filename, names[i])
+		}
+		task, err := s.tasks.Get(context.Background(), item.TaskID)
+		if err != nil || task.Metadata["filename"] != names[i] {
+			t.Errorf("item %d points to task of %v (%v)", i, task, err)
+		}
+	}
+}
+
+func TestProcessBatchAbortOnRejectedFile(t *testing.T) {
+	// 逐个提交，被拒绝的文件之后的文件都不提交
+	s, store := newBatchService(t, 1)
+	ctx := context.Background()
+
+	files := batchFiles(t, "a.pdf", "b.png", "notes.exe", "c.pdf")
+	batch, err := s.ProcessBatch(ctx, files, nil, models.BatchPolicyAbort)
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+
+	if !batch.Aborted {
+		t.Error("batch was not aborted")
+	}
+	assertStatuses(t, batch,
+		models.StatusCancelled, models.StatusCancelled, models.StatusRejected, models.StatusSkipped)
+
+	// 已提交的任务被取消：不留在队列中，上传的文件被删除
+	for _, item := range batch.Items[:2] {
+		task, err := s.tasks.Get(ctx, item.TaskID)
+		if err != nil || task.Status != models.StatusCancelled {
+			t.Errorf("task %s = %v (%v)", item.TaskID, task, err)
+		}
+		if _, err := s.queue.CancelTask(ctx, item.TaskID); !errors.Is(err, queue.ErrTaskNotFound) {
+			t.Errorf("task %s is still queued: %v", item.TaskID, err)
+		}
+	}
+	if store.len() != 0 {
+		t.Errorf("%d uploaded files left after abort", store.len())
+	}
+	if batch.Status != models.BatchFailed {
+		t.Errorf("batch status = %s", batch.Status)
+	}
+}
+
+func TestProcessBatchAbortOnTaskFailure(t *testing.T) {
+	s, _ := newBatchService(t, 4)
+	ctx := context.Background()
+
+	abort, err := s.ProcessBatch(ctx, batchFiles(t, "a.pdf", "b.pdf", "c.pdf"), nil, models.BatchPolicyAbort)
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+	cont, err := s.ProcessBatch(ctx, batchFiles(t, "d.pdf", "e.pdf"), nil, models.BatchPolicyContinue)
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+
+	s.RecordFailure(ctx, abort.Items[1].TaskID, docagent.Errorf(docagent.ErrorClassCorruptInput, "broken"), true)
+	s.RecordFailure(ctx, cont.Items[0].TaskID, docagent.Errorf(docagent.ErrorClassCorruptInput, "broken"), true)
+
+	got, err := s.GetBatch(ctx, abort.ID)
+	if err != nil {
+		t.Fatalf("GetBatch() error = %v", err)
+	}
+	if !got.Aborted {
+		t.Error("abort batch was not aborted")
+	}
+	assertStatuses(t, got, models.StatusCancelled, models.StatusFailed, models.StatusCancelled)
+
+	// continue 策略下其他文件照常处理
+	got, err = s.GetBatch(ctx, cont.ID)
+	if err != nil {
+		t.Fatalf("GetBatch() error = %v", err)
+	}
+	if got.Aborted {
+		t.Error("continue batch was aborted")
+	}
+	assertStatuses(t, got, models.StatusFailed, models.StatusPending)
+}
+
+func TestProcessBatchIdempotentReplay(t *testing.T) {
+	s, store := newBatchService(t, 4)
+	ctx := WithIdempotencyKey(context.Background(), "batch-key")
+
+	files := batchFiles(t, "a.pdf", "notes.exe", "b.pdf")
+	first, err := s.ProcessBatch(ctx, files, nil, models.BatchPolicyContinue)
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+	uploaded := store.len()
+
+	replay, err := s.ProcessBatch(ctx, batchFiles(t, "a.pdf", "notes.exe", "b.pdf"), nil, models.BatchPolicyContinue)
+	if err != nil {
+		t.Fatalf("replayed ProcessBatch() error = %v", err)
+	}
+	if replay.ID != first.ID {
+		t.Fatalf("replay created batch %s, want %s", replay.ID, first.ID)
+	}
+	for i := range first.Items {
+		if replay.Items[i].TaskID != first.Items[i].TaskID || replay.Items[i].Status != first.Items[i].Status {
+			t.Errorf("replayed item %d = %+v, want %+v", i, replay.Items[i], first.Items[i])
+		}
+	}
+	if store.len() != uploaded {
+		t.Errorf("replay uploaded %d more files", store.len()-uploaded)
+	}
+
+	// 其他 Idempotency-Key 创建新的批量任务
+	other, err := s.ProcessBatch(WithIdempotencyKey(context.Background(), "other-key"), files, nil, "")
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+	if other.ID == first.ID || other.Items[0].TaskID == first.Items[0].TaskID {
+		t.Error("a different Idempotency-Key reused the batch")
+	}
+}
+
+func TestWriteBatchResults(t *testing.T) {
+	s, _ := newBatchService(t, 4)
+	ctx := context.Background()
+
+	batch, err := s.ProcessBatch(ctx, batchFiles(t, "a.pdf", "notes.exe", "b.pdf", "a.pdf", "c.pdf"), nil, "")
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+
+	// a.pdf 两次提交都完成，b.pdf 失败，c.pdf 完成但结果已被删除
+	for _, i := range []int{0, 3, 4} {
+		taskID := batch.Items[i].TaskID
+		if i != 4 {
+			if _, err := s.storeResult(ctx, &converters.ProcessedDocument{TaskID: taskID, Status: fmt.Sprintf("result %d", i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.tasks.Update(ctx, taskID, func(t *models.ProcessingTask) error {
+			t.Finish(models.StatusCompleted, time.Now())
+			return nil
+		})
+	}
+	s.RecordFailure(ctx, batch.Items[2].TaskID, errors.New("broken"), true)
+
+	batch, err = s.GetBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatalf("GetBatch() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := s.WriteBatchResults(ctx, batch, &buf); err != nil {
+		t.Fatalf("WriteBatchResults() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	entries := make(map[string][]byte)
+	var names []string
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		entries[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+		names = append(names, f.Name)
+	}
+	if want := "[000_a.json 003_a.json batch.json]"; fmt.Sprint(names) != want {
+		t.Errorf("zip entries = %v, want %s", names, want)
+	}
+
+	for name, want := range map[string]string{"000_a.json": "result 0", "003_a.json": "result 3"} {
+		var doc converters.ProcessedDocument
+		if err := json.Unmarshal(entries[name], &doc); err != nil || doc.Status != want {
+			t.Errorf("%s = %+v (%v)", name, doc, err)
+		}
+	}
+
+	var manifest models.Batch
+	if err := json.Unmarshal(entries["batch.json"], &manifest); err != nil {
+		t.Fatalf("invalid batch.json: %v", err)
+	}
+	if manifest.ID != batch.ID || len(manifest.Items) != 5 {
+		t.Fatalf("batch.json = %+v", manifest)
+	}
+	assertStatuses(t, &manifest,
+		models.StatusCompleted, models.StatusRejected, models.StatusFailed, models.StatusCompleted, models.StatusCompleted)
+	if manifest.Items[1].Error == "" || manifest.Items[2].Error != "broken" {
+		t.Errorf("batch.json errors = %q, %q", manifest.Items[1].Error, manifest.Items[2].Error)
+	}
+	if !strings.HasPrefix(manifest.Items[4].Error, "result unavailable") {
+		t.Errorf("missing result error = %q", manifest.Items[4].Error)
+	}
+}
+
+func TestBatchCallbackAfterLastItem(t *testing.T) {
+	s, _ := newBatchService(t, 4)
+	ctx := context.Background()
+
+	submit := WithCallbackURL(WithBatchCallbackURL(ctx, "https://example.com/batch"), "https://example.com/task")
+	if _, err := s.ProcessBatch(submit, batchFiles(t, "a.pdf"), nil, ""); !errors.Is(err, ErrWebhooksDisabled) {
+		t.Fatalf("ProcessBatch() without webhooks error = %v", err)
+	}
+
+	dispatcher, err := webhook.NewDispatcher(webhook.NewMemory(), s.logger, &webhook.Config{Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.webhooks = dispatcher
+	if _, err := s.ProcessBatch(WithBatchCallbackURL(ctx, "http://10.0.0.1/hook"), batchFiles(t, "a.pdf"), nil, ""); docagent.ClassifyError(err) != docagent.ErrorClassInvalidRequest {
+		t.Fatalf("ProcessBatch() with a private batch callback error = %v", err)
+	}
+
+	batch, err := s.ProcessBatch(submit, batchFiles(t, "a.pdf", "notes.exe", "b.pdf"), nil, "")
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+	batchDeliveries := func() []*webhook.Delivery {
+		t.Helper()
+		deliveries, err := s.ListWebhookDeliveries(ctx, webhook.BatchSubject(batch.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return deliveries
+	}
+	if got := batchDeliveries(); len(got) != 0 {
+		t.Fatalf("batch event sent before any task finished: %+v", got)
+	}
+
+	first := batch.Items[0].TaskID
+	s.tasks.Update(ctx, first, func(t *models.ProcessingTask) error {
+		t.Finish(models.StatusCompleted, time.Now())
+		return nil
+	})
+	s.NotifyTaskFinished(ctx, first, models.StatusCompleted, nil)
+	if got := batchDeliveries(); len(got) != 0 {
+		t.Fatalf("batch event sent while a task is pending: %+v", got)
+	}
+	// 每个任务仍然收到自己的事件
+	if deliveries, _ := s.ListWebhookDeliveries(ctx, first); len(deliveries) != 1 || deliveries[0].URL != "https://example.com/task" {
+		t.Errorf("task deliveries = %+v", deliveries)
+	}
+
+	last := batch.Items[2].TaskID
+	s.RecordFailure(ctx, last, errors.New("broken"), true)
+	// 重复的结束通知不会再次发送
+	s.NotifyTaskFinished(ctx, last, models.StatusFailed, errors.New("broken"))
+
+	deliveries := batchDeliveries()
+	if len(deliveries) != 1 {
+		t.Fatalf("batch deliveries = %+v", deliveries)
+	}
+	delivery := deliveries[0]
+	event := delivery.Event
+	if delivery.URL != "https://example.com/batch" || delivery.Status != webhook.DeliveryPending {
+		t.Errorf("delivery = %+v", delivery)
+	}
+	if event.Type != webhook.EventBatchFinished || event.BatchID != batch.ID || event.TaskID != "" || event.Status != string(models.BatchPartial) {
+		t.Errorf("event = %+v", event)
+	}
+	if event.Counts["completed"] != 1 || event.Counts["failed"] != 1 || event.Counts["rejected"] != 1 {
+		t.Errorf("event counts = %v", event.Counts)
+	}
+	if !strings.HasSuffix(event.ResultURL, "/api/v1/documents/batch/"+batch.ID+"/download") {
+		t.Errorf("event result url = %q", event.ResultURL)
+	}
+}
+
+func TestBatchCallbackWhenNoFileIsSubmitted(t *testing.T) {
+	s, _ := newBatchService(t, 4)
+	ctx := WithBatchCallbackURL(context.Background(), "https://example.com/batch")
+	dispatcher, err := webhook.NewDispatcher(webhook.NewMemory(), s.logger, &webhook.Config{Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.webhooks = dispatcher
+
+	batch, err := s.ProcessBatch(ctx, batchFiles(t, "notes.exe", "large.pdf"), nil, "")
+	if err != nil {
+		t.Fatalf("ProcessBatch() error = %v", err)
+	}
+
+	deliveries, err := s.ListWebhookDeliveries(ctx, webhook.BatchSubject(batch.ID))
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("batch deliveries = %+v, %v", deliveries, err)
+	}
+	if event := deliveries[0].Event; event.Status != string(models.BatchFailed) || event.ResultURL != "" || event.Counts["rejected"] != 2 {
+		t.Errorf("event = %+v", event)
+	}
+}
@@ -0,0 +1,137 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/internal/repository"
+	"github.com/feichai0017/document-processor/pkg/logger"
+	"github.com/feichai0017/document-processor/pkg/queue"
+)
+
+// RequeueResult 批量重新入队的结果，单个任务失败不影响其他任务
+type RequeueResult struct {
+	Requeued []string          `json:"requeued"`
+	Failed   map[string]string `json:"failed,omitempty"` // 任务 ID 到失败原因
+}
+
+// ListFailedTasks 分页列出死信队列中的任务
+func (s *DocumentService) ListFailedTasks(ctx context.Context, page, size int) ([]*queue.DeadTask, int, error) {
+	tasks, total, err := s.queue.ListDeadTasks(ctx, page, size)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list failed tasks: %w", err)
+	}
+	return tasks, total, nil
+}
+
+// RequeueFailedTasks 将死信队列中的任务重新入队，taskIDs 为空时重新入队所有未取消的任务。
+// opts 不为空时替换任务原有的处理选项
+func (s *DocumentService) RequeueFailedTasks(ctx context.Context, taskIDs []string, opts *models.ProcessingOptions) (*RequeueResult, error) {
+	var update func(*queue.Task) error
+	if opts != nil {
+		if err := opts.Validate(); err != nil {
+			return nil, err
+		}
+		update = func(task *queue.Task) error {
+			task.Payload["options"] = opts
+			if task.Metadata == nil {
+				task.Metadata = make(map[string]string)
+			}
+			task.Metadata["mode"] = string(opts.ResolveMode())
+			return nil
+		}
+	}
+
+	if len(taskIDs) == 0 {
+		tasks, _, err := s.queue.ListDeadTasks(ctx, 0, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list failed tasks: %w", err)
+		}
+		for _, task := range tasks {
+			if !task.Cancelled {
+				taskIDs = append(taskIDs, task.TaskID)
+			}
+		}
+	}
+
+	result := &RequeueResult{
+		Requeued: []string{},
+		Failed:   make(map[string]string),
+	}
+	for _, taskID := range taskIDs {
+		if err := s.requeueFailedTask(ctx, taskID, opts, update); err != nil {
+			s.logger.Warn("Failed to requeue task",
+				logger.String("taskId", taskID),
+				logger.Error(err),
+			)
+			result.Failed[taskID] = err.Error()
+			continue
+		}
+		result.Requeued = append(result.Requeued, taskID)
+	}
+
+	s.logger.Info("Requeued failed tasks",
+		logger.Int("requeued", len(result.Requeued)),
+		logger.Int("failed", len(result.Failed)),
+	)
+
+	return result, nil
+}
+
+func (s *DocumentService) requeueFailedTask(ctx context.Context, taskID string, opts *models.ProcessingOptions, update func(*queue.Task) error) error {
+	dead, err := s.queue.GetDeadTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	// 取消时上传的文件已被删除
+	if dead.Cancelled {
+		return fmt.Errorf("task %s was cancelled and cannot be requeued", taskID)
+	}
+	if update != nil && (dead.Task == nil || dead.Task.Payload == nil) {
+		return fmt.Errorf("task %s has no payload to update", taskID)
+	}
+	if err := s.queue.RequeueDeadTask(ctx, taskID, update); err != nil {
+		return err
+	}
+
+	// 保留尝试次数和错误历史，任务回到排队中
+	_, err = s.updateTask(ctx, taskID, func(t *models.ProcessingTask) error {
+		t.Requeue()
+		if opts != nil {
+			t.Options = opts
+			t.Metadata["mode"] = string(opts.ResolveMode())
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, repository.ErrTaskNotFound) {
+		return fmt.Errorf("task requeued but failed to reset status: %w", err)
+	}
+	return nil
+}
+
+// PurgeFailedTasks 从死信队列删除任务及其上传的文件，taskIDs 为空时清空死信队列
+func (s *DocumentService) PurgeFailedTasks(ctx context.Context, taskIDs []string) (int, error) {
+	purged, err := s.queue.PurgeDeadTasks(ctx, taskIDs)
+	for _, dead := range purged {
+		if dead.Cancelled || dead.Task == nil {
+			continue
+		}
+		if fileID, ok := dead.Task.Payload["fileId"].(string); ok && fileID != "" {
+			if err := s.storage.Delete(ctx, fileID); err != nil {
+				s.logger.Warn("Failed to delete file of purged task",
+					logger.String("taskId", dead.TaskID),
+					logger.String("fileId", fileID),
+					logger.Error(err),
+				)
+			}
+		}
+	}
+	if err != nil {
+		return len(purged), fmt.Errorf("failed to purge failed tasks: %w", err)
+	}
+
+	s.logger.Info("Purged failed tasks", logger.Int("count", len(purged)))
+	return len(purged), nil
+}
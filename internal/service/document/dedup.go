@@ -0,0 +1,145 @@
+package document
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/pkg/logger"
+)
+
+// ErrIdempotencyConflict 同一个 Idempotency-Key 用于了不同的文件或处理选项
+var ErrIdempotencyConflict = errors.New("idempotency key was already used for a different request")
+
+type idempotencyKeyCtx struct{}
+
+// WithIdempotencyKey 将客户端提供的 Idempotency-Key 附加到 context 上，
+// 使用相同 key 重试的请求返回第一次创建的任务
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtx{}, key)
+}
+
+func idempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyCtx{}).(string)
+	return key
+}
+
+// submissionFingerprint 文件内容和处理选项共同决定处理结果，
+// 包含租户使不同租户上传相同文件时不会复用彼此的任务和结果
+func submissionFingerprint(tenant, fileHash string, opts *models.ProcessingOptions) (string, error) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal options: %w", err)
+	}
+	sum := sha256.Sum256(append([]byte(tenant+"\n"+fileHash+"\n"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// reservation Reserve 占用的键，创建任务失败时释放
+type reservation struct {
+	key   string
+	value string
+}
+
+// claimSubmission 检查幂等键和内容去重：命中时返回已有的任务；
+// 否则为 taskID 占用对应的键，并返回创建任务失败时释放这些键的函数
+func (s *DocumentService) claimSubmission(ctx context.Context, taskID, fingerprint string) (*models.ProcessingTask, func(), error) {
+	var claimed []reservation
+	release := func() {
+		for _, r := range claimed {
+			if err := s.queue.Release(context.Background(), r.key, r.value); err != nil {
+				s.logger.Warn("Failed to release submission key",
+					logger.String("key", r.key),
+					logger.Error(err),
+				)
+			}
+		}
+	}
+
+	// 相同 Idempotency-Key 的重试返回第一次创建的任务
+	idemKey, idemValue := "", ""
+	if key := idempotencyKeyFrom(ctx); key != "" {
+		idemKey = "idempotency:" + key
+		idemValue = taskID + ":" + fingerprint
+		existing, ok, err := s.queue.Reserve(ctx, idemKey, idemValue, s.config.IdempotencyTTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			existingID, existingFingerprint, _ := strings.Cut(existing, ":")
+			if existingFingerprint != fingerprint {
+				return nil, nil, ErrIdempotencyConflict
+			}
+			return s.existingTask(ctx, existingID), nil, nil
+		}
+		claimed = append(claimed, reservation{key: idemKey, value: idemValue})
+	}
+
+	if !s.config.Deduplicate {
+		return nil, release, nil
+	}
+
+	// 相同文件和选项复用已完成的结果或正在处理的任务
+	dedupKey := "dedup:" + fingerprint
+	for attempt := 0; attempt < 2; attempt++ {
+		existing, ok, err := s.queue.Reserve(ctx, dedupKey, taskID, s.config.DedupTTL)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		if ok {
+			claimed = append(claimed, reservation{key: dedupKey, value: taskID})
+			break
+		}
+
+		task, err := s.GetProcessingStatus(ctx, existing)
+		if err == nil && task.Status != models.StatusFailed && task.Status != models.StatusCancelled {
+			// 幂等键原子地改为指向复用的任务，期间使用相同键的重试不会创建新任务
+			if idemKey != "" {
+				swapped, err := s.queue.Swap(ctx, idemKey, idemValue, existing+":"+fingerprint, s.config.IdempotencyTTL)
+				if err != nil {
+					release()
+					return nil, nil, err
+				}
+				if !swapped {
+					s.logger.Warn("Idempotency key changed while reusing a task",
+						logger.String("key", idemKey),
+						logger.String("taskId", existing),
+					)
+				}
+			}
+			task.Deduplicated = true
+			s.logger.Info("Reusing task for duplicate upload",
+				logger.String("taskId", existing),
+				logger.String("status", string(task.Status)),
+			)
+			return task, nil, nil
+		}
+
+		// 已有任务失败、被取消或已过期，由本次提交接管
+		if err := s.queue.Release(ctx, dedupKey, existing); err != nil {
+			release()
+			return nil, nil, err
+		}
+	}
+
+	return nil, release, nil
+}
+
+// existingTask 返回幂等键指向的任务，首次请求尚未完成入队时按排队中返回
+func (s *DocumentService) existingTask(ctx context.Context, taskID string) *models.ProcessingTask {
+	task, err := s.GetProcessingStatus(ctx, taskID)
+	if err != nil {
+		return &models.ProcessingTask{
+			ID:       taskID,
+			Status:   models.StatusPending,
+			Metadata: map[string]string{},
+		}
+	}
+	return task
+}
@@ -0,0 +1,35 @@
+package document
+
+import (
+	"testing"
+
+	"github.com/feichai0017/document-processor/internal/models"
+)
+
+func TestSubmissionFingerprint(t *testing.T) {
+	opts := &models.ProcessingOptions{DocumentType: "invoice"}
+	base, err := submissionFingerprint("tenant-a", "hash", opts)
+	if err != nil {
+		t.Fatalf("submissionFingerprint() error = %v", err)
+	}
+
+	same, _ := submissionFingerprint("tenant-a", "hash", &models.ProcessingOptions{DocumentType: "invoice"})
+	if same != base {
+		t.Error("identical submissions have different fingerprints")
+	}
+
+	for name, fingerprint := range map[string]func() (string, error){
+		"other tenant":  func() (string, error) { return submissionFingerprint("tenant-b", "hash", opts) },
+		"no tenant":     func() (string, error) { return submissionFingerprint("", "hash", opts) },
+		"other file":    func() (string, error) { return submissionFingerprint("tenant-a", "other", opts) },
+		"other options": func() (string, error) { return submissionFingerprint("tenant-a", "hash", &models.ProcessingOptions{}) },
+	} {
+		got, err := fingerprint()
+		if err != nil {
+			t.Fatalf("%s: submissionFingerprint() error = %v", name, err)
+		}
+		if got == base {
+			t.Errorf("%s: fingerprint matches the original submission", name)
+		}
+	}
+}
@@ -3,8 +3,11 @@ package document
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"path/filepath"
 	"strconv"
@@ -13,22 +16,49 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/sync/errgroup"
 
+	"github.com/feichai0017/document-processor/config"
 	"github.com/feichai0017/document-processor/internal/agent"
+	"github.com/feichai0017/document-processor/internal/agent/classification"
+	docagent "github.com/feichai0017/document-processor/internal/agent/document"
+	"github.com/feichai0017/document-processor/internal/agent/document/image"
+	"github.com/feichai0017/document-processor/internal/agent/document/pdf"
+	"github.com/feichai0017/document-processor/internal/agent/extraction"
+	"github.com/feichai0017/document-processor/internal/agent/postprocess"
+	"github.com/feichai0017/document-processor/internal/agent/prompts"
 	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/internal/repository"
+	"github.com/feichai0017/document-processor/internal/utils/validator"
+	"github.com/feichai0017/document-processor/pkg/cache"
 	"github.com/feichai0017/document-processor/pkg/logger"
 	"github.com/feichai0017/document-processor/pkg/queue"
 	"github.com/feichai0017/document-processor/pkg/storage"
+	"github.com/feichai0017/document-processor/pkg/taskindex"
 	"github.com/feichai0017/document-processor/pkg/converters"
+	"github.com/feichai0017/document-processor/pkg/webhook"
 )
 
+// ErrTaskCancelled 任务被用户取消，worker 不应重试
+var ErrTaskCancelled = errors.New("task cancelled")
+
 type DocumentService struct {
 	processorFactory agent.ProcessorFactory
 	queue            queue.Queue
 	storage          storage.Storage
 	logger           logger.Logger
 	config           *ServiceConfig
+	extractor        *extraction.Extractor     // 为 nil 时忽略请求中的 schema
+	classifier       *classification.Classifier // 为 nil 时不做文档分类
+	postProcessor    *postprocess.PostProcessor // 为 nil 时忽略摘要和翻译选项
+	webhooks         *webhook.Dispatcher        // 为 nil 时不支持回调
+	index            taskindex.Index            // 由 StartTaskIndex 打开，为 nil 时不支持任务列表
+	tasks            repository.TaskRepository
+	llmPool          *image.VisionLLMPool // 由 GetService 创建，Close 时关闭健康检查和指标
+
+	// 后台任务（如提示词热加载）使用的上下文，Close 时取消
+	lifecycle  context.Context
+	stop       context.CancelFunc
+	background sync.WaitGroup
 }
 
 type ServiceConfig struct {
@@ -39,6 +69,21 @@ type ServiceConfig struct {
 	MaxConcurrent     int
 	ProcessTimeout    time.Duration
 	RetentionPeriod   time.Duration
+
+	// 去重和幂等提交
+	Deduplicate    bool
+	DedupTTL       time.Duration
+	IdempotencyTTL time.Duration
+
+	// PublicBaseURL 外部访问 API 的地址，用于回调事件中的结果链接
+	PublicBaseURL string
+
+	// ClassificationApplyConfidence 自动分类结果达到该置信度才应用对应的处理配置
+	ClassificationApplyConfidence float64
+
+	// 任务索引，TaskIndexPath 为空时不启用
+	TaskIndexPath      string
+	TaskIndexReconcile time.Duration
 }
 
 func NewService(
@@ -47,6 +92,11 @@ func NewService(
 	storage storage.Storage,
 	logger logger.Logger,
 	cfg *ServiceConfig,
+	extractor *extraction.Extractor,
+	classifier *classification.Classifier,
+	postProcessor *postprocess.PostProcessor,
+	webhooks *webhook.Dispatcher,
+	tasks repository.TaskRepository,
 ) DocumentProcessor {
 	if cfg == nil {
 		cfg = &ServiceConfig{
@@ -58,12 +108,20 @@ func NewService(
 		}
 	}
 
+	lifecycle, stop := context.WithCancel(context.Background())
 	return &DocumentService{
+		lifecycle:        lifecycle,
+		stop:             stop,
 		processorFactory: factory,
 		queue:           queue,
 		storage:         storage,
 		logger:          logger,
 		config:          cfg,
+		extractor:       extractor,
+		classifier:      classifier,
+		postProcessor:   postProcessor,
+		webhooks:        webhooks,
+		tasks:           tasks,
 	}
 }
 
@@ -75,27 +133,184 @@ func GetService(log logger.Logger) (DocumentProcessor, error) {
 	}
 
 	// 初始化队列
-	q, err := queue.GetQueue()
+	q, err := queue.GetQueue(log)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize queue: %w", err)
 	}
 
-	// 初始化处理器工厂
-	factory, err := agent.NewProcessorFactory(log)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize processor factory: %w", err)
-	}
-
 	// 默认配置
+	dedupCfg := config.GetDedupConfig()
+	webhookCfg := config.GetWebhookConfig()
+	indexCfg := config.GetTaskIndexConfig()
 	cfg := &ServiceConfig{
 		MaxFileSize:      50 * 1024 * 1024, // 50MB
 		AllowedTypes:     []string{".pdf", ".doc", ".docx", ".jpg", ".jpeg", ".png", ".tiff"},
 		MaxConcurrent:    5,
 		ProcessTimeout:   30 * time.Minute,
 		RetentionPeriod:  24 * time.Hour,
+		Deduplicate:      dedupCfg.Enabled,
+		DedupTTL:         dedupCfg.TTL,
+		IdempotencyTTL:   dedupCfg.IdempotencyTTL,
+		PublicBaseURL:    webhookCfg.PublicBaseURL,
+	}
+	if indexCfg.Enabled {
+		cfg.TaskIndexPath = indexCfg.Path
+		cfg.TaskIndexReconcile = indexCfg.ReconcileInterval
+	}
+
+	// 初始化提示词模板库，配置了目录时定期热加载
+	promptsCfg := config.GetPromptsConfig()
+	promptRegistry, err := prompts.NewRegistry(promptsCfg.Dir, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize prompts: %w", err)
+	}
+
+	// 初始化 LLM 输出缓存
+	llmCache, err := newLLMCache(config.GetCacheConfig(), q, store, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize llm cache: %w", err)
+	}
+
+	// 初始化结构化抽取和分类使用的 LLM（Ollama 或 OpenAI 兼容服务）
+	var extractor *extraction.Extractor
+	var postProcessor *postprocess.PostProcessor
+	var llmPool *image.VisionLLMPool
+	if ollamaCfg := config.GetOllamaConfig(); ollamaCfg.Enabled {
+		// 多实例负载均衡，失败重试并熔断不健康的实例
+		client, err := image.NewVisionLLMPool(&image.OllamaConfig{
+			Enabled:             true,
+			Backend:             image.LLMBackend(ollamaCfg.Backend),
+			Endpoint:            ollamaCfg.Endpoint,
+			Endpoints:           ollamaCfg.Endpoints,
+			APIKey:              ollamaCfg.APIKey,
+			Model:               ollamaCfg.Model,
+			MaxTokens:           ollamaCfg.MaxTokens,
+			Temperature:         ollamaCfg.Temperature,
+			MaxPoolSize:         ollamaCfg.MaxConcurrent,
+			PoolTimeout:         ollamaCfg.PoolTimeout,
+			RequestTimeout:      ollamaCfg.RequestTimeout,
+			MaxRetries:          ollamaCfg.MaxRetries,
+			RetryBackoff:        ollamaCfg.RetryBackoff,
+			FailureThreshold:    ollamaCfg.BreakerThreshold,
+			BreakerCooldown:     ollamaCfg.BreakerCooldown,
+			HealthCheckInterval: ollamaCfg.HealthCheckInterval,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize llm client: %w", err)
+		}
+		llmPool = client
+		extractor, err = extraction.NewExtractor(client, log, &extraction.Config{
+			MaxAttempts: ollamaCfg.ExtractionMaxAttempts,
+			Prompts:     promptRegistry,
+			Cache:       llmCache,
+			Model:       ollamaCfg.Model,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize extractor: %w", err)
+		}
+		postProcessor, err = postprocess.NewPostProcessor(client, log, &postprocess.Config{
+			Prompts:         promptRegistry,
+			Cache:           llmCache,
+			Model:           ollamaCfg.Model,
+			SourceLanguages: ollamaCfg.TranslateSourceLanguages,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize post-processor: %w", err)
+		}
+	}
+
+	// 初始化处理器工厂，本地 OCR 的 LLM 校正与抽取共用连接池、提示词和缓存
+	factory, err := agent.NewProcessorFactory(log, &agent.FactoryOptions{
+		LLM:     llmPool,
+		Prompts: promptRegistry,
+		Cache:   llmCache,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize processor factory: %w", err)
+	}
+
+	// 初始化文档分类，LLM 不可用时只使用关键词规则
+	var classifier *classification.Classifier
+	if classifyCfg := config.GetClassificationConfig(); classifyCfg.Enabled {
+		var client classification.ChatClient
+		if classifyCfg.UseLLM && llmPool != nil {
+			client = llmPool
+		}
+		classifier = classification.NewClassifier(client, log, &classification.Config{
+			MinConfidence: classifyCfg.MinConfidence,
+		})
+		cfg.ClassificationApplyConfidence = classifyCfg.ApplyConfidence
+	}
+
+	// 初始化任务完成回调，未配置签名密钥时不支持回调
+	var webhooks *webhook.Dispatcher
+	if webhookCfg.Secret != "" {
+		webhooks, err = webhook.NewDispatcher(webhook.NewRedis(q.RedisClient(), webhookCfg.LogTTL), log, &webhook.Config{
+			Secret:               webhookCfg.Secret,
+			MaxAttempts:          webhookCfg.MaxAttempts,
+			Timeout:              webhookCfg.Timeout,
+			AllowPrivateNetworks: webhookCfg.AllowPrivateNetworks,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize webhooks: %w", err)
+		}
+	}
+
+	// 初始化任务仓库，API 服务和 worker 都通过它读写任务
+	tasks, err := newTaskRepository(config.GetTaskRepositoryConfig(), q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize task repository: %w", err)
+	}
+
+	service := NewService(factory, q, store, log, cfg, extractor, classifier, postProcessor, webhooks, tasks).(*DocumentService)
+	service.llmPool = llmPool
+	service.goBackground(func(ctx context.Context) {
+		promptRegistry.Watch(ctx, promptsCfg.ReloadInterval)
+	})
+	return service, nil
+}
+
+// goBackground 启动随服务生命周期运行的后台任务，Close 时取消并等待其退出
+func (s *DocumentService) goBackground(fn func(ctx context.Context)) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		fn(s.lifecycle)
+	}()
+}
+
+// Close 停止服务的后台任务，API 服务和 worker 关闭时调用
+func (s *DocumentService) Close() error {
+	s.stop()
+	s.background.Wait()
+	if s.llmPool != nil {
+		s.llmPool.Close()
+	}
+	if err := s.tasks.Close(); err != nil {
+		return fmt.Errorf("failed to close task repository: %w", err)
+	}
+	return nil
+}
+
+// newLLMCache 按配置创建 LLM 输出缓存，未启用时返回 nil
+func newLLMCache(cfg *config.CacheConfig, q *queue.AsynqQueue, store storage.Storage, log logger.Logger) (*image.LLMCache, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var backend cache.Cache
+	switch cache.Type(cfg.Backend) {
+	case cache.TypeMemory:
+		backend = cache.NewMemory(cfg.Size)
+	case cache.TypeRedis:
+		backend = cache.NewRedis(q.RedisClient(), cfg.Prefix)
+	case cache.TypeStorage:
+		backend = cache.NewStorage(store, cfg.Prefix)
+	default:
+		return nil, fmt.Errorf("unsupported cache backend: %s", cfg.Backend)
 	}
 
-	return NewService(factory, q, store, log, cfg), nil
+	return image.NewLLMCache(backend, cfg.TTL, log), nil
 }
 
 // ProcessFile 处理单个文件
@@ -103,6 +318,7 @@ func (s *DocumentService) ProcessFile(
 	ctx context.Context,
 	file multipart.File,
 	header *multipart.FileHeader,
+	opts *models.ProcessingOptions,
 ) (*models.ProcessingTask, error) {
 	s.logger.Info("Starting file processing",
 		logger.String("filename", header.Filename),
@@ -117,15 +333,50 @@ func (s *DocumentService) ProcessFile(
 		)
 		return nil, err
 	}
+	if opts == nil {
+		opts = &models.ProcessingOptions{}
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.validateCallback(ctx); err != nil {
+		return nil, err
+	}
 
 	// 成任务ID
 	taskID := uuid.New().String()
 
+	// 按文件内容和处理选项去重，相同的 Idempotency-Key 返回同一个任务
+	fileHash, err := validator.HashFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash file: %w", err)
+	}
+	fingerprint, err := submissionFingerprint(tenantFrom(ctx), fileHash, opts)
+	if err != nil {
+		return nil, err
+	}
+	existing, release, err := s.claimSubmission(ctx, taskID, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if err := s.registerCallback(ctx, existing); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+	submitted := false
+	defer func() {
+		if !submitted {
+			release()
+		}
+	}()
+
 	// 创建处理任务
 	task := &models.ProcessingTask{
 		ID:        taskID,
 		Status:    models.StatusPending,
-		Type:      "document:process",
+		Type:      queue.TaskTypeFor(filepath.Ext(header.Filename)), // 按文件类型进入独立的队列
 		Priority:  s.config.QueuePriority,
 		Progress:  0,
 		CreatedAt: time.Now(),
@@ -134,11 +385,22 @@ func (s *DocumentService) ProcessFile(
 			"filename": header.Filename,
 			"size":    fmt.Sprintf("%d", header.Size),
 			"type":    filepath.Ext(header.Filename),
+			"mode":    string(opts.ResolveMode()),
+			"sha256":  fileHash,
 		},
+		Options: opts,
+	}
+	// 记录所属的批量任务和租户：批量任务最终失败时按批量任务的策略处理，租户用于任务列表
+	if batchID := batchIDFrom(ctx); batchID != "" {
+		task.Metadata["batchId"] = batchID
+	}
+	if tenant := tenantFrom(ctx); tenant != "" {
+		task.Metadata["tenant"] = tenant
 	}
 
 	// 存储文件
-	fileID, err := s.storage.Store(ctx, file, header.Filename)
+	// 按任务区分存储位置，同名文件互不覆盖，取消时可以安全删除
+	fileID, err := s.storage.Store(ctx, file, fmt.Sprintf("%s/%s", taskID, header.Filename))
 	if err != nil {
 		s.logger.Error("Failed to store file",
 			logger.String("filename", header.Filename),
@@ -146,6 +408,19 @@ func (s *DocumentService) ProcessFile(
 		)
 		return nil, fmt.Errorf("failed to store file: %w", err)
 	}
+	// 任务未能入队时删除已上传的文件
+	defer func() {
+		if submitted {
+			return
+		}
+		if err := s.storage.Delete(context.Background(), fileID); err != nil {
+			s.logger.Warn("Failed to delete file of unsubmitted task",
+				logger.String("taskId", taskID),
+				logger.String("fileId", fileID),
+				logger.Error(err),
+			)
+		}
+	}()
 
 	// 准备任务数据
 	queueTask := &queue.Task{
@@ -157,34 +432,36 @@ func (s *DocumentService) ProcessFile(
 			"filename": header.Filename,
 			"size":     header.Size,
 			"type":     filepath.Ext(header.Filename),
+			"options":  opts,
 		},
 		Metadata:  task.Metadata,
 		CreatedAt: task.CreatedAt,
 	}
 
+	// 入队前注册回调地址并保存任务，worker 开始处理时一定能找到
+	if err := s.registerCallback(ctx, task); err != nil {
+		return nil, err
+	}
+	if err := s.tasks.Create(ctx, task); err != nil {
+		return nil, fmt.Errorf("failed to save task: %w", err)
+	}
+
 	// 加入处理队列
 	if err := s.queue.Enqueue(ctx, queueTask); err != nil {
 		s.logger.Error("Failed to enqueue task",
 			logger.String("taskId", taskID),
 			logger.Error(err),
 		)
+		s.tasks.Update(ctx, taskID, func(t *models.ProcessingTask) error {
+			t.Fail(err.Error(), string(docagent.ClassifyError(err)), true, time.Now())
+			return nil
+		})
 		return nil, fmt.Errorf("failed to enqueue task: %w", err)
 	}
+	submitted = true
+	s.publishTask(ctx, task)
 
-	// 保存初始状态
-	initialStatus := &queue.TaskStatus{
-		TaskID:    taskID,
-		Status:    "pending",
-		Progress:  0,
-		StartedAt: time.Now(),
-	}
-
-	if err := s.queue.SaveFinalStatus(ctx, initialStatus); err != nil {
-		s.logger.Error("Failed to save initial status",
-			logger.String("taskId", taskID),
-			logger.Error(err),
-		)
-	}
+	s.indexTask(ctx, task, header.Size)
 
 	s.logger.Info("File processing task created",
 		logger.String("taskId", taskID),
@@ -194,48 +471,36 @@ func (s *DocumentService) ProcessFile(
 	return task, nil
 }
 
-// ProcessBatch 批量处理文件
-func (s *DocumentService) ProcessBatch(ctx context.Context, files []*multipart.FileHeader) ([]*models.ProcessingTask, error) {
-	tasks := make([]*models.ProcessingTask, 0, len(files))
-	var mu sync.Mutex
-	
-	// 使用 errgroup 来管理并发和错误
-	g, ctx := errgroup.WithContext(ctx)
-	
-	for _, header := range files {
-		header := header // 创建副本用于闭包
-		g.Go(func() error {
-			file, err := header.Open()
-			if err != nil {
-				return fmt.Errorf("failed to open file %s: %w", header.Filename, err)
-			}
-			defer file.Close()
-			
-			task, err := s.ProcessFile(ctx, file, header)
-			if err != nil {
-				return fmt.Errorf("failed to process file %s: %w", header.Filename, err)
-			}
-			
-			mu.Lock()
-			tasks = append(tasks, task)
-			mu.Unlock()
-			
-			return nil
-		})
-	}
-	
-	if err := g.Wait(); err != nil {
-		return tasks, err // 返回已处理的任务和错误
-	}
-	
-	return tasks, nil
-}
-
 // HandleDocument 实现文档处理逻辑
 func (s *DocumentService) HandleDocument(ctx context.Context, task *queue.Task) error {
 	if task == nil || task.Payload == nil || task.Metadata == nil {
-        return fmt.Errorf("invalid task: missing required data")
+        return docagent.Errorf(docagent.ErrorClassInvalidRequest, "invalid task: missing required data")
     }
+
+	// 开始处理前已被取消
+	if s.queue.IsCancelled(ctx, task.ID) {
+		s.cleanupCancelledTask(task)
+		return fmt.Errorf("%w: %s", ErrTaskCancelled, task.ID)
+	}
+
+	if err := s.startAttempt(ctx, task); err != nil {
+		return fmt.Errorf("failed to record task start: %w", err)
+	}
+
+	err := s.handleDocument(ctx, task)
+	if err != nil && ctx.Err() != nil && s.queue.IsCancelled(context.Background(), task.ID) {
+		s.logger.Info("Task processing cancelled",
+			logger.String("taskId", task.ID),
+			logger.Error(err),
+		)
+		s.cleanupCancelledTask(task)
+		return fmt.Errorf("%w: %s", ErrTaskCancelled, task.ID)
+	}
+	return err
+}
+
+// handleDocument 处理文档，各阶段之间检查 ctx，取消后尽快返回
+func (s *DocumentService) handleDocument(ctx context.Context, task *queue.Task) error {
 	
 	s.logger.Info("Processing document",
 		logger.String("taskId", task.ID),
@@ -248,15 +513,42 @@ func (s *DocumentService) HandleDocument(ctx context.Context, task *queue.Task)
 	if err != nil {
 		return fmt.Errorf("failed to get file: %w", err)
 	}
+	defer reader.Close()
+
+	// 读入内存，结构化抽取时需要复用原始图像
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// 解析请求的处理选项
+	opts, err := optionsFromPayload(task.Payload)
+	if err != nil {
+		return docagent.Errorf(docagent.ErrorClassInvalidRequest, "invalid task options: %w", err)
+	}
+	requestedSchema := len(opts.Schema) > 0
+
+	// 处理器通过 context 报告进度，写入任务仓库
+	ctx = docagent.WithProgressReporter(ctx, newTaskProgressReporter(task.ID, s.saveProgress))
+
+	// 识别文档类型并应用对应的处理配置（引擎、Textract 特性、抽取 Schema 和提示词）
+	docagent.ReportProgress(ctx, docagent.StageClassifying, 0, 0)
+	docClass := s.classifyDocument(ctx, task, opts, data)
+	if docClass != nil {
+		classification.ProfileFor(opts.DocumentType).Apply(opts)
+	}
 
 	// 获取处理器
-	processor, err := s.processorFactory.GetProcessor(task.Metadata["type"])
+	processor, err := s.processorFactory.SelectProcessor(task.Metadata["type"], opts)
 	if err != nil {
 		return fmt.Errorf("failed to get processor: %w", err)
 	}
 
-	// 处理文档
-	chunks, err := processor.Process(ctx, reader)
+	// 处理文档（附带存储位置，供 Textract 异步分析直接读取 S3 对象）
+	processCtx := docagent.WithOptions(ctx, opts)
+	processCtx = docagent.WithSource(processCtx, docagent.Source{Key: fileID})
+	processCtx, reviews := docagent.WithReviewCollector(processCtx)
+	chunks, err := processor.Process(processCtx, bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("failed to process document: %w", err)
 	}
@@ -268,6 +560,12 @@ func (s *DocumentService) HandleDocument(ctx context.Context, task *queue.Task)
 		return fmt.Errorf("failed to convert document: %w", err)
 	}
 
+	// 合并不属于任何块的复核项（如低置信度的表单键值对）
+	if items := reviews.Items(); len(items) > 0 {
+		processedDoc.Review = append(processedDoc.Review, items...)
+		processedDoc.Metadata.NeedsReview = true
+	}
+
 	// 更新处理结果
 	processedDoc.TaskID = task.ID
 	processedDoc.ProcessedAt = time.Now()
@@ -277,15 +575,38 @@ func (s *DocumentService) HandleDocument(ctx context.Context, task *queue.Task)
 		processedDoc.Metadata.FileSize = size
 	}
 
-	// 序列化并存储结果
-	resultData, err := json.Marshal(processedDoc)
-	if err != nil {
-		return fmt.Errorf("failed to marshal result: %w", err)
+	processedDoc.Classification = docClass
+
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	resultReader := bytes.NewReader(resultData)
-	if _, err := s.storage.Store(ctx, resultReader, fmt.Sprintf("result:%s", task.ID)); err != nil {
-		return fmt.Errorf("failed to store result: %w", err)
+	// 按请求或文档类型的 JSON Schema 抽取结构化数据，抽取失败不影响任务结果
+	if len(opts.Schema) > 0 && (s.extractor != nil || requestedSchema) {
+		docagent.ReportProgress(ctx, docagent.StageExtracting, 0, 0)
+		s.extractStructured(ctx, task, opts, chunks, data, processedDoc)
+	}
+
+	// 可选的后处理：摘要和翻译，失败不影响任务结果
+	if opts.SummaryLength != "" || opts.TranslateTo != "" {
+		docagent.ReportProgress(ctx, docagent.StagePostProcessing, 0, 0)
+	}
+	if opts.SummaryLength != "" {
+		s.summarize(ctx, task, opts, processedDoc)
+	}
+	if opts.TranslateTo != "" {
+		s.translate(ctx, task, opts, processedDoc)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 序列化并存储结果
+	docagent.ReportProgress(ctx, docagent.StageStoring, 0, 0)
+	location, err := s.storeResult(ctx, processedDoc)
+	if err != nil {
+		return err
 	}
 
 	s.logger.Info("Document processing completed",
@@ -293,17 +614,12 @@ func (s *DocumentService) HandleDocument(ctx context.Context, task *queue.Task)
 		logger.Int("chunkCount", len(chunks)),
 	)
 
-	// 在处理完成后，将最终状态保存到 Redis
-	finalStatus := &queue.TaskStatus{
-		TaskID:     task.ID,
-		Status:     "completed",
-		Progress:   1.0,
-		Error:      "",
-		StartedAt:  task.CreatedAt,
-		FinishedAt: time.Now(),
-	}
-	
-	if err := s.queue.SaveFinalStatus(ctx, finalStatus); err != nil {
+	// 记录结果位置，任务完成
+	if _, err := s.updateTask(ctx, task.ID, func(t *models.ProcessingTask) error {
+		t.ResultLocation = location
+		t.Finish(models.StatusCompleted, time.Now())
+		return nil
+	}); err != nil {
 		s.logger.Error("Failed to save final status",
 			logger.String("taskId", task.ID),
 			logger.Error(err),
@@ -313,40 +629,80 @@ func (s *DocumentService) HandleDocument(ctx context.Context, task *queue.Task)
 	return nil
 }
 
-// GetProcessingStatus 获取处理状态
+// GetProcessingStatus 获取处理状态，未结束的任务补充队列中的实时进度
 func (s *DocumentService) GetProcessingStatus(ctx context.Context, taskID string) (*models.ProcessingTask, error) {
-    status, err := s.queue.GetTaskStatus(ctx, taskID)
+    task, err := s.tasks.Get(ctx, taskID)
+    if errors.Is(err, repository.ErrTaskNotFound) {
+        // 升级前提交的任务只有队列中的状态
+        status, err := s.queue.GetTaskStatus(ctx, taskID)
+        if err != nil {
+            return nil, fmt.Errorf("failed to get task status: %w", err)
+        }
+        return toProcessingTask(status), nil
+    }
     if err != nil {
-        return nil, fmt.Errorf("failed to get task status: %w", err)
+        return nil, fmt.Errorf("failed to get task: %w", err)
     }
 
+    if task.Status.IsTerminal() {
+        return task, nil
+    }
+    return s.syncWithQueue(ctx, task), nil
+}
+
+// toProcessingTask 将队列中的任务状态转换为 API 返回的任务，用于仓库中没有记录的任务和状态推送
+func toProcessingTask(status *queue.TaskStatus) *models.ProcessingTask {
     // 确保状态正确映射
     var taskStatus models.ProcessingStatus
     switch status.Status {
     case "pending":
         taskStatus = models.StatusPending
-    case "active":
+    case "active", "running":
         taskStatus = models.StatusRunning
     case "completed":
         taskStatus = models.StatusCompleted
     case "failed":
         taskStatus = models.StatusFailed
+    case "cancelled":
+        taskStatus = models.StatusCancelled
     default:
         taskStatus = models.StatusPending
     }
 
+    // 处理过程中写入的任务信息（如文档类型）
+    metadata := status.Metadata
+    if metadata == nil {
+        metadata = make(map[string]string)
+    }
+
     // 确保返回完整的任务信息
-    return &models.ProcessingTask{
+    task := &models.ProcessingTask{
         ID:        status.TaskID,
         Status:    taskStatus,
         Type:      "document:process",
         Priority:  0, // 可以从队列配置中获取
         Progress:  status.Progress,
         Error:     status.Error,
-        Metadata:  make(map[string]string), // 初始化一个空的 metadata map
+        ErrorClass: status.ErrorClass,
+        Metadata:  metadata,
         CreatedAt: status.StartedAt,
         UpdatedAt: status.FinishedAt,
-    }, nil
+    }
+
+    // 处理器报告的阶段、页数和预计完成时间
+    if detail := status.Detail; detail != nil {
+        task.PagesDone = detail.PagesDone
+        task.PagesTotal = detail.PagesTotal
+        if taskStatus == models.StatusRunning {
+            task.Stage = detail.Stage
+            task.UpdatedAt = detail.UpdatedAt
+            if eta, ok := detail.EstimatedCompletion(); ok {
+                task.ETA = &eta
+            }
+        }
+    }
+
+    return task
 }
 
 // GetProcessingResult 获取处理结果
@@ -362,7 +718,11 @@ func (s *DocumentService) GetProcessedDocument(ctx context.Context, taskID strin
 	}
 
 	// 获取结果
-	reader, err := s.storage.Get(ctx, fmt.Sprintf("result:%s", taskID))
+	location := status.ResultLocation
+	if location == "" {
+		location = resultKey(taskID)
+	}
+	reader, err := s.storage.Get(ctx, location)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get result: %w", err)
 	}
@@ -375,19 +735,121 @@ func (s *DocumentService) GetProcessedDocument(ctx context.Context, taskID strin
 	return &result, nil
 }
 
+// ReviewCorrection 接受或拒绝单条 LLM 修正，更新合并文本后回写处理结果
+func (s *DocumentService) ReviewCorrection(
+	ctx context.Context,
+	taskID string,
+	correctionID string,
+	status models.CorrectionStatus,
+) (*converters.ProcessedDocument, error) {
+	doc, err := s.GetProcessedDocument(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc.Revision == nil {
+		return nil, fmt.Errorf("task %s has no corrections to review", taskID)
+	}
+	if err := doc.Revision.SetStatus(correctionID, status); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.storeResult(ctx, doc); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Correction reviewed",
+		logger.String("taskId", taskID),
+		logger.String("correctionId", correctionID),
+		logger.String("status", string(status)),
+	)
+
+	return doc, nil
+}
+
+// storeResult 序列化并存储处理结果，返回结果在存储中的位置
+func (s *DocumentService) storeResult(ctx context.Context, doc *converters.ProcessedDocument) (string, error) {
+	resultData, err := json.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	location, err := s.storage.Store(ctx, bytes.NewReader(resultData), resultKey(doc.TaskID))
+	if err != nil {
+		return "", fmt.Errorf("failed to store result: %w", err)
+	}
+	return location, nil
+}
+
 // CancelTask 取消任务
 func (s *DocumentService) CancelTask(ctx context.Context, taskID string) error {
-	if err := s.queue.CancelTask(ctx, taskID); err != nil {
+	result, err := s.queue.CancelTask(ctx, taskID)
+	if err != nil {
 		return fmt.Errorf("failed to cancel task: %w", err)
 	}
 
+	// 处理中的任务由 worker 中止后清理，排队中的任务在这里清理
+	if !result.Active {
+		task := result.Task
+		if task == nil {
+			task = &queue.Task{ID: taskID}
+		}
+		s.cleanupCancelledTask(task)
+	}
+
 	s.logger.Info("Task cancelled",
 		logger.String("taskId", taskID),
+		logger.Bool("active", result.Active),
 	)
 
 	return nil
 }
 
+// cleanupCancelledTask 删除已上传的文件和可能已保存的结果，并记录取消状态。
+// 调用时 ctx 通常已经被取消，这里使用独立的 context
+func (s *DocumentService) cleanupCancelledTask(task *queue.Task) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if fileID, ok := task.Payload["fileId"].(string); ok && fileID != "" {
+		if err := s.storage.Delete(ctx, fileID); err != nil {
+			s.logger.Warn("Failed to delete file of cancelled task",
+				logger.String("taskId", task.ID),
+				logger.String("fileId", fileID),
+				logger.Error(err),
+			)
+		}
+	}
+	if err := s.storage.Delete(ctx, resultKey(task.ID)); err != nil {
+		s.logger.Debug("No result to delete for cancelled task",
+			logger.String("taskId", task.ID),
+		)
+	}
+
+	_, err := s.updateTask(ctx, task.ID, func(t *models.ProcessingTask) error {
+		t.Finish(models.StatusCancelled, time.Now())
+		return nil
+	})
+	if errors.Is(err, repository.ErrTaskNotFound) {
+		// 升级前提交的任务只有队列中的状态。
+		// TODO: 升级前提交的任务都已结束并过期后删除这个回退
+		err = s.queue.SaveFinalStatus(ctx, &queue.TaskStatus{
+			TaskID:     task.ID,
+			Status:     "cancelled",
+			StartedAt:  task.CreatedAt,
+			FinishedAt: time.Now(),
+		})
+	}
+	if err != nil {
+		s.logger.Error("Failed to save cancelled status",
+			logger.String("taskId", task.ID),
+			logger.Error(err),
+		)
+	}
+
+	s.NotifyTaskFinished(ctx, task.ID, models.StatusCancelled, nil)
+}
+
 // CleanupTasks 清理过期任务
 func (s *DocumentService) CleanupTasks(ctx context.Context) error {
 	threshold := time.Now().Add(-s.config.RetentionPeriod)
@@ -407,7 +869,7 @@ func (s *DocumentService) CleanupTasks(ctx context.Context) error {
 func (s *DocumentService) validateFile(header *multipart.FileHeader) error {
 	// 检查文件大小
 	if header.Size > s.config.MaxFileSize {
-		return fmt.Errorf("file size exceeds maximum limit of %d bytes", s.config.MaxFileSize)
+		return docagent.Errorf(docagent.ErrorClassInvalidRequest, "file size exceeds maximum limit of %d bytes", s.config.MaxFileSize)
 	}
 
 	// 检查文件类型
@@ -420,10 +882,265 @@ func (s *DocumentService) validateFile(header *multipart.FileHeader) error {
 		}
 	}
 	if !validType {
-		return fmt.Errorf("unsupported file type: %s", ext)
+		return docagent.Errorf(docagent.ErrorClassUnsupportedType, "unsupported file type: %s", ext)
 	}
 
 	return nil
 }
 
+// classifyDocument 识别文档类型并写入任务信息，请求已指定类型时直接使用，
+// 未启用分类且请求未指定类型时返回 nil
+func (s *DocumentService) classifyDocument(
+	ctx context.Context,
+	task *queue.Task,
+	opts *models.ProcessingOptions,
+	data []byte,
+) *models.Classification {
+	var result *models.Classification
+	switch {
+	case opts.DocumentType != "":
+		opts.DocumentType = strings.ToLower(opts.DocumentType)
+		result = &models.Classification{
+			DocumentType: opts.DocumentType,
+			Confidence:   1.0,
+			Method:       models.ClassifiedByRequest,
+			Applied:      true,
+		}
+	case s.classifier != nil:
+		// 只使用首页：图像直接发送，PDF 使用首页文本层
+		filename := task.Metadata["filename"]
+		input := &classification.Input{Filename: filename}
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".jpg", ".jpeg", ".png":
+			input.Images = []string{base64.StdEncoding.EncodeToString(data)}
+		case ".pdf":
+			text, err := pdf.FirstPageText(data)
+			if err != nil {
+				s.logger.Warn("Failed to read first page text for classification",
+					logger.String("taskId", task.ID),
+					logger.Error(err),
+				)
+			}
+			input.Text = text
+		}
+
+		result = s.classifier.Classify(ctx, input)
+		if reason := s.skipClassification(task, result); reason != "" {
+			s.logger.Info("Classification not applied",
+				logger.String("taskId", task.ID),
+				logger.String("documentType", result.DocumentType),
+				logger.String("reason", reason),
+			)
+		} else {
+			opts.DocumentType = result.DocumentType
+			result.Applied = true
+		}
+	default:
+		return nil
+	}
+
+	s.logger.Info("Document classified",
+		logger.String("taskId", task.ID),
+		logger.String("documentType", result.DocumentType),
+		logger.Float64("confidence", result.Confidence),
+		logger.String("method", string(result.Method)),
+	)
+
+	metadata := map[string]string{
+		"documentType":             result.DocumentType,
+		"classificationConfidence": strconv.FormatFloat(result.Confidence, 'f', 2, 64),
+		"classificationMethod":     string(result.Method),
+		"classificationApplied":    strconv.FormatBool(result.Applied),
+	}
+	s.setTaskMetadata(ctx, task.ID, metadata)
+
+	return result
+}
+
+// skipClassification 返回不应用自动分类结果的原因：类型未识别、置信度不足，
+// 或类型会切换到费用分析而文件类型不被 AnalyzeExpense 支持
+func (s *DocumentService) skipClassification(task *queue.Task, result *models.Classification) string {
+	if result.DocumentType == models.DocTypeOther {
+		return "unknown document type"
+	}
+	if result.Confidence < s.config.ClassificationApplyConfidence {
+		return fmt.Sprintf("confidence %.2f is below %.2f", result.Confidence, s.config.ClassificationApplyConfidence)
+	}
+	mode := (&models.ProcessingOptions{DocumentType: result.DocumentType}).ResolveMode()
+	if mode == models.ModeExpense && !agent.SupportsExpense(task.Metadata["type"]) {
+		return fmt.Sprintf("expense analysis does not support %s files", task.Metadata["type"])
+	}
+	return ""
+}
+
+// extractStructured 使用文档文本（图像文件同时附带原图）调用 LLM 抽取结构化数据
+func (s *DocumentService) extractStructured(
+	ctx context.Context,
+	task *queue.Task,
+	opts *models.ProcessingOptions,
+	chunks []models.DocumentChunk,
+	data []byte,
+	doc *converters.ProcessedDocument,
+) {
+	if s.extractor == nil {
+		doc.Extraction = &converters.ExtractionInfo{Error: "structured extraction is not enabled"}
+		return
+	}
+
+	texts := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		if chunk.Content != "" {
+			texts = append(texts, chunk.Content)
+		}
+	}
+
+	req := &extraction.Request{
+		Schema:        opts.Schema,
+		Text:          strings.Join(texts, "\n\n"),
+		Instructions:  opts.Prompt,
+		DocumentType:  opts.DocumentType,
+		PromptVersion: opts.PromptVersions[prompts.Extraction],
+	}
+	switch strings.ToLower(filepath.Ext(task.Metadata["filename"])) {
+	case ".jpg", ".jpeg", ".png":
+		req.Images = []string{base64.StdEncoding.EncodeToString(data)}
+	}
+
+	result, err := s.extractor.Extract(ctx, req)
+	if errors.Is(err, image.ErrLLMUnavailable) {
+		s.logger.Warn("Vision LLM unavailable, skipping structured extraction",
+			logger.String("taskId", task.ID),
+			logger.Error(err),
+		)
+		doc.Extraction = &converters.ExtractionInfo{Skipped: err.Error()}
+		return
+	}
+	if err != nil {
+		s.logger.Error("Structured extraction failed",
+			logger.String("taskId", task.ID),
+			logger.Error(err),
+		)
+		doc.Extraction = &converters.ExtractionInfo{Error: err.Error()}
+		return
+	}
+
+	// 未通过 Schema 校验的输出不作为抽取结果，只保留在 Extraction.Raw 中供排查
+	doc.Extraction = &converters.ExtractionInfo{
+		Valid:         result.Valid,
+		Attempts:      result.Attempts,
+		Errors:        result.Errors,
+		PromptID:      result.PromptID,
+		PromptVersion: result.PromptVersion,
+		Cached:        result.Cached,
+	}
+	if result.Valid {
+		doc.Extracted = result.Data
+	} else {
+		doc.Extraction.Raw = result.Data
+	}
+}
+
+// summarize 基于文档全部文本生成摘要
+func (s *DocumentService) summarize(
+	ctx context.Context,
+	task *queue.Task,
+	opts *models.ProcessingOptions,
+	doc *converters.ProcessedDocument,
+) {
+	if s.postProcessor == nil {
+		doc.Summary = &models.Summary{Length: opts.SummaryLength, Error: "summarization is not enabled"}
+		return
+	}
+
+	texts := make([]string, 0, len(doc.Content))
+	for _, chunk := range doc.Content {
+		if chunk.Text != "" {
+			texts = append(texts, chunk.Text)
+		}
+	}
 
+	summary, err := s.postProcessor.Summarize(ctx, &postprocess.SummaryRequest{
+		Text:          strings.Join(texts, "\n\n"),
+		Length:        opts.SummaryLength,
+		DocumentType:  opts.DocumentType,
+		PromptVersion: opts.PromptVersions[prompts.Summary],
+	})
+	if errors.Is(err, image.ErrLLMUnavailable) {
+		s.logger.Warn("Vision LLM unavailable, skipping summarization",
+			logger.String("taskId", task.ID),
+			logger.Error(err),
+		)
+		doc.Summary = &models.Summary{Length: opts.SummaryLength, Skipped: err.Error()}
+		return
+	}
+	if err != nil {
+		s.logger.Error("Summarization failed",
+			logger.String("taskId", task.ID),
+			logger.Error(err),
+		)
+		doc.Summary = &models.Summary{Length: opts.SummaryLength, Error: err.Error()}
+		return
+	}
+	doc.Summary = summary
+}
+
+// translate 将每个内容块翻译为目标语言，译文按 Position 与原文对应
+func (s *DocumentService) translate(
+	ctx context.Context,
+	task *queue.Task,
+	opts *models.ProcessingOptions,
+	doc *converters.ProcessedDocument,
+) {
+	if s.postProcessor == nil {
+		doc.Translation = &models.Translation{TargetLanguage: opts.TranslateTo, Error: "translation is not enabled"}
+		return
+	}
+
+	chunks := make([]models.TranslatedChunk, 0, len(doc.Content))
+	for _, chunk := range doc.Content {
+		chunks = append(chunks, models.TranslatedChunk{Position: chunk.Position, Text: chunk.Text})
+	}
+
+	translation, err := s.postProcessor.Translate(ctx, &postprocess.TranslationRequest{
+		Chunks:         chunks,
+		TargetLanguage: opts.TranslateTo,
+		DocumentType:   opts.DocumentType,
+		PromptVersion:  opts.PromptVersions[prompts.Translation],
+	})
+	if errors.Is(err, image.ErrLLMUnavailable) {
+		s.logger.Warn("Vision LLM unavailable, skipping translation",
+			logger.String("taskId", task.ID),
+			logger.Error(err),
+		)
+		doc.Translation = &models.Translation{TargetLanguage: opts.TranslateTo, Skipped: err.Error()}
+		return
+	}
+	if err != nil {
+		s.logger.Error("Translation failed",
+			logger.String("taskId", task.ID),
+			logger.Error(err),
+		)
+		doc.Translation = &models.Translation{TargetLanguage: opts.TranslateTo, Error: err.Error()}
+		return
+	}
+	doc.Translation = translation
+}
+
+// optionsFromPayload 从任务 Payload 中还原处理选项（经过 JSON 序列化后为 map）
+func optionsFromPayload(payload map[string]interface{}) (*models.ProcessingOptions, error) {
+	opts := &models.ProcessingOptions{}
+	raw, ok := payload["options"]
+	if !ok || raw == nil {
+		return opts, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal options: %w", err)
+	}
+	if err := json.Unmarshal(data, opts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal options: %w", err)
+	}
+
+	return opts, opts.Validate()
+}
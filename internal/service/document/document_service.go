@@ -2,17 +2,35 @@ package document
 
 import (
     "context"
+    "io"
     "mime/multipart"
     "github.com/feichai0017/document-processor/internal/models"
     "github.com/feichai0017/document-processor/pkg/converters"
     "github.com/feichai0017/document-processor/pkg/queue"
+    "github.com/feichai0017/document-processor/pkg/taskindex"
+    "github.com/feichai0017/document-processor/pkg/webhook"
 )
 
 type DocumentProcessor interface {
-    ProcessFile(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*models.ProcessingTask, error)
-    ProcessBatch(ctx context.Context, files []*multipart.FileHeader) ([]*models.ProcessingTask, error)
+    ProcessFile(ctx context.Context, file multipart.File, header *multipart.FileHeader, opts *models.ProcessingOptions) (*models.ProcessingTask, error)
+    ProcessBatch(ctx context.Context, files []*multipart.FileHeader, opts *models.ProcessingOptions, policy models.BatchPolicy) (*models.Batch, error)
+    GetBatch(ctx context.Context, batchID string) (*models.Batch, error)
+    WriteBatchResults(ctx context.Context, batch *models.Batch, w io.Writer) error
     GetProcessingStatus(ctx context.Context, taskID string) (*models.ProcessingTask, error)
+    ListTasks(ctx context.Context, filter *taskindex.Filter) (*taskindex.Page, error)
+    StartTaskIndex(ctx context.Context) error
     HandleDocument(ctx context.Context, task *queue.Task) error
     GetProcessedDocument(ctx context.Context, taskID string) (*converters.ProcessedDocument, error)
+    ReviewCorrection(ctx context.Context, taskID string, correctionID string, status models.CorrectionStatus) (*converters.ProcessedDocument, error)
     CancelTask(ctx context.Context, taskID string) error
+    ListFailedTasks(ctx context.Context, page, size int) ([]*queue.DeadTask, int, error)
+    RequeueFailedTasks(ctx context.Context, taskIDs []string, opts *models.ProcessingOptions) (*RequeueResult, error)
+    PurgeFailedTasks(ctx context.Context, taskIDs []string) (int, error)
+    NotifyTaskFinished(ctx context.Context, taskID string, status models.ProcessingStatus, taskErr error)
+    RecordFailure(ctx context.Context, taskID string, taskErr error, final bool)
+    StreamTaskStatus(ctx context.Context, taskIDs []string) (<-chan *models.ProcessingTask, error)
+    ListWebhookDeliveries(ctx context.Context, taskID string) ([]*webhook.Delivery, error)
+    ReplayWebhook(ctx context.Context, deliveryID string) (*webhook.Delivery, error)
+    DeliverWebhook(ctx context.Context, deliveryID string, final bool) error
+    Close() error
 }
\ No newline at end of file
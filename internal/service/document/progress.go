@@ -0,0 +1,82 @@
+package document
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	docagent "github.com/feichai0017/document-processor/internal/agent/document"
+	"github.com/feichai0017/document-processor/pkg/queue"
+)
+
+// progressFlushInterval 同一阶段内两次写入任务仓库的最小间隔，逐页报告时避免每页都写一次
+const progressFlushInterval = time.Second
+
+// stageRange 各阶段在整体进度中所占的区间，processing 阶段按页数在区间内线性增长
+var stageRange = map[string][2]float64{
+	docagent.StageClassifying:    {0.00, 0.05},
+	docagent.StageAnalyzing:      {0.05, 0.05},
+	docagent.StageProcessing:     {0.05, 0.80},
+	docagent.StageCorrecting:     {0.80, 0.85},
+	docagent.StageExtracting:     {0.85, 0.90},
+	docagent.StagePostProcessing: {0.90, 0.97},
+	docagent.StageStoring:        {0.97, 0.99},
+}
+
+// taskProgressReporter 将处理器报告的进度换算为整体进度，通过 save 写入任务仓库，
+// stageChanged 表示进入了新阶段，需要记录阶段耗时
+type taskProgressReporter struct {
+	taskID    string
+	startedAt time.Time
+	save      func(ctx context.Context, taskID string, progress *queue.TaskProgress, stageChanged bool)
+
+	mu        sync.Mutex
+	last      queue.TaskProgress
+	lastFlush time.Time
+}
+
+func newTaskProgressReporter(taskID string, save func(ctx context.Context, taskID string, progress *queue.TaskProgress, stageChanged bool)) *taskProgressReporter {
+	return &taskProgressReporter{
+		taskID:    taskID,
+		startedAt: time.Now(),
+		save:      save,
+	}
+}
+
+func (r *taskProgressReporter) ReportProgress(ctx context.Context, p docagent.Progress) {
+	bounds, ok := stageRange[p.Stage]
+	if !ok {
+		return
+	}
+	overall := bounds[0]
+	if p.PagesTotal > 0 {
+		overall += (bounds[1] - bounds[0]) * float64(p.PagesDone) / float64(p.PagesTotal)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 并发处理的页面可能乱序报告，进度只增不减
+	if overall < r.last.Progress {
+		return
+	}
+
+	now := time.Now()
+	stageChanged := p.Stage != r.last.Stage
+	finished := p.PagesTotal > 0 && p.PagesDone >= p.PagesTotal
+	r.last = queue.TaskProgress{
+		Stage:      p.Stage,
+		PagesDone:  p.PagesDone,
+		PagesTotal: p.PagesTotal,
+		Progress:   overall,
+		StartedAt:  r.startedAt,
+		UpdatedAt:  now,
+	}
+	if !stageChanged && !finished && now.Sub(r.lastFlush) < progressFlushInterval {
+		return
+	}
+
+	r.lastFlush = now
+	progress := r.last
+	r.save(ctx, r.taskID, &progress, stageChanged)
+}
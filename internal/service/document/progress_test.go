@@ -0,0 +1,79 @@
+package document
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	docagent "github.com/feichai0017/document-processor/internal/agent/document"
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/internal/repository"
+	"github.com/feichai0017/document-processor/pkg/logger"
+	"github.com/feichai0017/document-processor/pkg/queue"
+)
+
+func TestProgressIsSavedOnTask(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewTestLogger()
+
+	q, err := queue.NewAsynqQueue(&queue.QueueConfig{RedisAddr: miniredis.RunT(t).Addr(), Logger: log})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := repository.NewSQLite(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tasks.Close()
+	s := &DocumentService{queue: q, tasks: tasks, logger: log}
+
+	task := &models.ProcessingTask{ID: "task-1", Status: models.StatusPending, CreatedAt: time.Now()}
+	task.StartAttempt(time.Now())
+	if err := tasks.Create(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+
+	events, closeSub, err := q.SubscribeTaskStatus(ctx, "task-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeSub()
+
+	reporter := newTaskProgressReporter("task-1", s.saveProgress)
+	reporter.ReportProgress(ctx, docagent.Progress{Stage: docagent.StageClassifying})
+	reporter.ReportProgress(ctx, docagent.Progress{Stage: docagent.StageProcessing, PagesDone: 2, PagesTotal: 4})
+
+	got, err := tasks.Get(ctx, "task-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Stage != docagent.StageProcessing || got.PagesDone != 2 || got.PagesTotal != 4 || got.Progress <= 0.05 {
+		t.Errorf("saved progress = %s %d/%d %v", got.Stage, got.PagesDone, got.PagesTotal, got.Progress)
+	}
+	if len(got.Stages) != 2 || got.Stages[0].FinishedAt == nil || got.Stages[1].Stage != docagent.StageProcessing {
+		t.Errorf("stages = %+v", got.Stages)
+	}
+
+	// 进度仍然推送给状态订阅方
+	select {
+	case status := <-events:
+		if status.Status != "running" || status.Detail == nil {
+			t.Errorf("event = %+v", status)
+		}
+	case <-time.After(time.Second):
+		t.Error("progress was not published")
+	}
+
+	// 任务结束后迟到的进度不覆盖结果
+	tasks.Update(ctx, "task-1", func(t *models.ProcessingTask) error {
+		t.Finish(models.StatusCompleted, time.Now())
+		return nil
+	})
+	reporter.ReportProgress(ctx, docagent.Progress{Stage: docagent.StageStoring})
+	if got, _ := tasks.Get(ctx, "task-1"); got.Progress != 1 || got.Stage != docagent.StageProcessing {
+		t.Errorf("progress after finish = %s %v", got.Stage, got.Progress)
+	}
+}
@@ -0,0 +1,119 @@
+package document
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	docagent "github.com/feichai0017/document-processor/internal/agent/document"
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/pkg/logger"
+)
+
+// RecordFailure 在任务的错误历史中记录一次处理失败：final 为 true 时任务失败并发送回调，
+// 否则任务等待重试
+func (s *DocumentService) RecordFailure(ctx context.Context, taskID string, taskErr error, final bool) {
+	class := string(docagent.ClassifyError(taskErr))
+	task, err := s.updateTask(ctx, taskID, func(t *models.ProcessingTask) error {
+		t.Fail(taskErr.Error(), class, final, time.Now())
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to record task failure",
+			logger.String("taskId", taskID),
+			logger.Bool("final", final),
+			logger.Error(err),
+		)
+	}
+	if !final {
+		return
+	}
+
+	s.NotifyTaskFinished(ctx, taskID, models.StatusFailed, taskErr)
+
+	if task != nil && task.Metadata["batchId"] != "" {
+		s.abortBatch(ctx, task.Metadata["batchId"], taskID)
+	}
+}
+
+// StreamTaskStatus 推送任务状态：先发送当前状态，之后每次状态或进度变化发送一次，
+// 所有任务进入最终状态或 ctx 结束时关闭返回的 channel
+func (s *DocumentService) StreamTaskStatus(ctx context.Context, taskIDs []string) (<-chan *models.ProcessingTask, error) {
+	if len(taskIDs) == 0 {
+		return nil, fmt.Errorf("at least one task id is required")
+	}
+
+	// 先订阅再读取当前状态，两者之间的变化不会丢失
+	ctx, cancel := context.WithCancel(ctx)
+	events, closeSub, err := s.queue.SubscribeTaskStatus(ctx, taskIDs...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	current := make([]*models.ProcessingTask, 0, len(taskIDs))
+	for _, taskID := range taskIDs {
+		task, err := s.GetProcessingStatus(ctx, taskID)
+		if err != nil {
+			closeSub()
+			cancel()
+			return nil, fmt.Errorf("task %s: %w", taskID, err)
+		}
+		current = append(current, task)
+	}
+
+	out := make(chan *models.ProcessingTask, len(taskIDs))
+	go func() {
+		defer close(out)
+		defer cancel()
+		defer closeSub()
+
+		pending := make(map[string]bool, len(taskIDs))
+		send := func(task *models.ProcessingTask) bool {
+			if !pending[task.ID] {
+				return true
+			}
+			if task.Status.IsTerminal() {
+				delete(pending, task.ID)
+			}
+			select {
+			case out <- task:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, task := range current {
+			pending[task.ID] = true
+		}
+		for _, task := range current {
+			if !send(task) {
+				return
+			}
+		}
+
+		for len(pending) > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case status, ok := <-events:
+				if !ok {
+					return
+				}
+				task := toProcessingTask(status)
+				// 最终状态读取完整的任务信息（如文档类型），推送的进度事件只包含进度
+				if task.Status.IsTerminal() {
+					if full, err := s.GetProcessingStatus(ctx, status.TaskID); err == nil && full.Status.IsTerminal() {
+						task = full
+					}
+				}
+				if !send(task) {
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,200 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/pkg/logger"
+	"github.com/feichai0017/document-processor/pkg/queue"
+	"github.com/feichai0017/document-processor/pkg/taskindex"
+)
+
+// ErrTaskIndexDisabled 任务索引未启用，无法列出任务
+var ErrTaskIndexDisabled = errors.New("task index is not enabled")
+
+// reconcileBatchSize 每次核对的未结束任务数
+const reconcileBatchSize = 200
+
+// taskExpiredError 任务的状态在记录到索引前已从 Redis 中过期
+const taskExpiredError = "task expired before its final status was recorded"
+
+type tenantCtx struct{}
+
+// WithTenant 将租户附加到 context 上，提交的任务记录所属租户，列表按租户过滤
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantCtx{}, tenant)
+}
+
+func tenantFrom(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantCtx{}).(string)
+	return tenant
+}
+
+// StartTaskIndex 打开任务索引并根据状态变化更新索引，只由 API 服务调用，worker 不需要索引。
+// 未配置索引位置时不启用；ctx 结束后关闭索引
+func (s *DocumentService) StartTaskIndex(ctx context.Context) error {
+	if s.config.TaskIndexPath == "" {
+		s.logger.Info("Task index disabled")
+		return nil
+	}
+
+	index, err := taskindex.NewSQLite(s.config.TaskIndexPath)
+	if err != nil {
+		return err
+	}
+	s.index = index
+
+	go func() {
+		s.runTaskIndexer(ctx)
+		if err := index.Close(); err != nil {
+			s.logger.Warn("Failed to close task index", logger.Error(err))
+		}
+	}()
+
+	s.logger.Info("Task index started", logger.String("path", s.config.TaskIndexPath))
+	return nil
+}
+
+// runTaskIndexer 订阅所有任务的状态变化并写入索引，同时定期核对未结束的任务，
+// 订阅断开期间错过的变化由核对补上
+func (s *DocumentService) runTaskIndexer(ctx context.Context) {
+	interval := s.config.TaskIndexReconcile
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.reconcileTaskIndex(ctx)
+	for ctx.Err() == nil {
+		events, closeSub, err := s.queue.SubscribeAllTaskStatus(ctx)
+		if err != nil {
+			s.logger.Warn("Failed to subscribe task events for index", logger.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+	subscribed:
+		for {
+			select {
+			case <-ctx.Done():
+				break subscribed
+			case <-ticker.C:
+				s.reconcileTaskIndex(ctx)
+			case status, ok := <-events:
+				if !ok {
+					break subscribed
+				}
+				s.updateTaskIndex(ctx, status)
+			}
+		}
+		closeSub()
+	}
+}
+
+// reconcileTaskIndex 按队列中的实际状态更新索引中未结束的任务。
+// 队列和任务仓库中都已没有记录的任务标记为失败；状态没有变化或暂时查不到的任务
+// 更新时间后排到后面，避免每轮都核对同一批任务
+func (s *DocumentService) reconcileTaskIndex(ctx context.Context) {
+	entries, err := s.index.Unfinished(ctx, reconcileBatchSize)
+	if err != nil {
+		s.logger.Warn("Failed to load unfinished tasks from index", logger.Error(err))
+		return
+	}
+	for _, entry := range entries {
+		now := time.Now()
+		task, err := s.GetProcessingStatus(ctx, entry.TaskID)
+		switch {
+		case errors.Is(err, queue.ErrTaskNotFound):
+			err = s.index.UpdateStatus(ctx, &taskindex.StatusUpdate{
+				TaskID:     entry.TaskID,
+				Status:     string(models.StatusFailed),
+				Error:      taskExpiredError,
+				UpdatedAt:  now,
+				FinishedAt: now,
+			})
+		case err != nil:
+			s.logger.Warn("Failed to reconcile indexed task",
+				logger.String("taskId", entry.TaskID),
+				logger.Error(err),
+			)
+			err = s.index.Touch(ctx, entry.TaskID, entry.Status, now)
+		case string(task.Status) == entry.Status:
+			err = s.index.Touch(ctx, entry.TaskID, entry.Status, now)
+		default:
+			update := &taskindex.StatusUpdate{
+				TaskID:     task.ID,
+				Status:     string(task.Status),
+				Error:      task.Error,
+				ErrorClass: task.ErrorClass,
+				UpdatedAt:  now,
+			}
+			if task.FinishedAt != nil {
+				update.FinishedAt = *task.FinishedAt
+			}
+			err = s.index.UpdateStatus(ctx, update)
+		}
+		if err != nil {
+			s.logger.Warn("Failed to update task index",
+				logger.String("taskId", entry.TaskID),
+				logger.Error(err),
+			)
+		}
+	}
+}
+
+func (s *DocumentService) updateTaskIndex(ctx context.Context, status *queue.TaskStatus) {
+	update := &taskindex.StatusUpdate{
+		TaskID:     status.TaskID,
+		Status:     status.Status,
+		Error:      status.Error,
+		ErrorClass: status.ErrorClass,
+		UpdatedAt:  time.Now(),
+		FinishedAt: status.FinishedAt,
+	}
+	if err := s.index.UpdateStatus(ctx, update); err != nil {
+		s.logger.Warn("Failed to update task index",
+			logger.String("taskId", status.TaskID),
+			logger.Error(err),
+		)
+	}
+}
+
+// indexTask 将新提交的任务写入索引，失败不影响提交
+func (s *DocumentService) indexTask(ctx context.Context, task *models.ProcessingTask, size int64) {
+	if s.index == nil {
+		return
+	}
+	entry := &taskindex.Entry{
+		TaskID:    task.ID,
+		BatchID:   task.Metadata["batchId"],
+		Tenant:    task.Metadata["tenant"],
+		Filename:  task.Metadata["filename"],
+		FileType:  task.Metadata["type"],
+		Kind:      queue.TaskKind(task.Type),
+		Size:      size,
+		Status:    string(task.Status),
+		CreatedAt: task.CreatedAt,
+		UpdatedAt: task.UpdatedAt,
+	}
+	if err := s.index.Put(ctx, entry); err != nil {
+		s.logger.Warn("Failed to index task",
+			logger.String("taskId", task.ID),
+			logger.Error(err),
+		)
+	}
+}
+
+// ListTasks 按条件列出任务，结果来自任务索引
+func (s *DocumentService) ListTasks(ctx context.Context, filter *taskindex.Filter) (*taskindex.Page, error) {
+	if s.index == nil {
+		return nil, ErrTaskIndexDisabled
+	}
+	return s.index.List(ctx, filter)
+}
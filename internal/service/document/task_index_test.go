@@ -0,0 +1,64 @@
+package document
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/internal/repository"
+	"github.com/feichai0017/document-processor/pkg/logger"
+	"github.com/feichai0017/document-processor/pkg/queue"
+	"github.com/feichai0017/document-processor/pkg/taskindex"
+)
+
+func TestReconcileTaskIndex(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	log := logger.NewTestLogger()
+
+	q, err := queue.NewAsynqQueue(&queue.QueueConfig{RedisAddr: miniredis.RunT(t).Addr(), Logger: log})
+	if err != nil {
+		t.Fatalf("NewAsynqQueue() error = %v", err)
+	}
+	tasks, err := repository.NewSQLite(filepath.Join(dir, "repo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := taskindex.NewSQLite(filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	s := &DocumentService{queue: q, tasks: tasks, index: index, logger: log}
+
+	// 超过一批的过期任务排在已完成任务之前
+	old := time.Now().Add(-time.Hour)
+	for i := 0; i < reconcileBatchSize+1; i++ {
+		index.Put(ctx, &taskindex.Entry{TaskID: fmt.Sprintf("expired-%03d", i), Status: "running", CreatedAt: old, UpdatedAt: old})
+	}
+	finished := time.Now()
+	tasks.Create(ctx, &models.ProcessingTask{ID: "done", Status: models.StatusCompleted, FinishedAt: &finished})
+	index.Put(ctx, &taskindex.Entry{TaskID: "done", Status: "running", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+
+	s.reconcileTaskIndex(ctx)
+	s.reconcileTaskIndex(ctx)
+
+	remaining, err := index.Unfinished(ctx, reconcileBatchSize)
+	if err != nil || len(remaining) != 0 {
+		t.Fatalf("Unfinished() after reconcile = %d entries, %v", len(remaining), err)
+	}
+
+	page, _ := index.List(ctx, &taskindex.Filter{Statuses: []string{"failed"}, Limit: 1})
+	if len(page.Entries) != 1 || page.Entries[0].Error != taskExpiredError || page.Entries[0].FinishedAt.IsZero() {
+		t.Errorf("expired task = %+v", page.Entries)
+	}
+	page, _ = index.List(ctx, &taskindex.Filter{Statuses: []string{"completed"}})
+	if len(page.Entries) != 1 || page.Entries[0].TaskID != "done" {
+		t.Errorf("completed tasks = %+v", page.Entries)
+	}
+}
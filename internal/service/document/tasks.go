@@ -0,0 +1,189 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/feichai0017/document-processor/config"
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/internal/repository"
+	"github.com/feichai0017/document-processor/pkg/logger"
+	"github.com/feichai0017/document-processor/pkg/queue"
+)
+
+// newTaskRepository 按配置创建任务仓库，redis 与队列共用连接
+func newTaskRepository(cfg *config.TaskRepositoryConfig, q *queue.AsynqQueue) (repository.TaskRepository, error) {
+	switch cfg.Backend {
+	case "", "redis":
+		return repository.NewRedis(q.RedisClient(), cfg.Retention), nil
+	case "sqlite":
+		return repository.NewSQLite(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unsupported task repository: %s", cfg.Backend)
+	}
+}
+
+// resultKey 处理结果在存储中的位置
+func resultKey(taskID string) string {
+	return fmt.Sprintf("result:%s", taskID)
+}
+
+// updateTask 修改仓库中的任务并推送状态变化
+func (s *DocumentService) updateTask(ctx context.Context, taskID string, fn func(*models.ProcessingTask) error) (*models.ProcessingTask, error) {
+	task, err := s.tasks.Update(ctx, taskID, fn)
+	if err != nil {
+		return nil, err
+	}
+	s.publishTask(ctx, task)
+	return task, nil
+}
+
+// publishTask 推送任务状态，订阅方（状态推送、任务索引）据此更新
+func (s *DocumentService) publishTask(ctx context.Context, task *models.ProcessingTask) {
+	status := &queue.TaskStatus{
+		TaskID:     task.ID,
+		Status:     string(task.Status),
+		Progress:   task.Progress,
+		Error:      task.Error,
+		ErrorClass: task.ErrorClass,
+		StartedAt:  task.CreatedAt,
+	}
+	if task.FinishedAt != nil {
+		status.FinishedAt = *task.FinishedAt
+	}
+	s.queue.PublishTaskStatus(ctx, status)
+}
+
+// startAttempt 记录 worker 开始一次处理。升级前提交的任务在仓库中没有记录，按队列中的任务补建
+func (s *DocumentService) startAttempt(ctx context.Context, task *queue.Task) error {
+	start := func(t *models.ProcessingTask) error {
+		t.StartAttempt(time.Now())
+		return nil
+	}
+
+	_, err := s.updateTask(ctx, task.ID, start)
+	if errors.Is(err, repository.ErrTaskNotFound) {
+		if err := s.tasks.Create(ctx, taskFromQueue(task)); err != nil {
+			return err
+		}
+		_, err = s.updateTask(ctx, task.ID, start)
+	}
+	return err
+}
+
+// taskFromQueue 由队列中的任务还原提交时的任务信息
+func taskFromQueue(task *queue.Task) *models.ProcessingTask {
+	metadata := make(map[string]string, len(task.Metadata))
+	for k, v := range task.Metadata {
+		metadata[k] = v
+	}
+	opts, _ := optionsFromPayload(task.Payload)
+	return &models.ProcessingTask{
+		ID:        task.ID,
+		Status:    models.StatusPending,
+		Type:      task.Type,
+		Priority:  task.Priority,
+		Metadata:  metadata,
+		Options:   opts,
+		CreatedAt: task.CreatedAt,
+		UpdatedAt: time.Now(),
+	}
+}
+
+// saveProgress 将处理进度写入任务仓库并推送，进入新阶段时同时记录阶段耗时
+func (s *DocumentService) saveProgress(ctx context.Context, taskID string, progress *queue.TaskProgress, stageChanged bool) {
+	_, err := s.tasks.Update(ctx, taskID, func(t *models.ProcessingTask) error {
+		if t.Status.IsTerminal() {
+			return nil
+		}
+		if stageChanged {
+			t.EnterStage(progress.Stage, progress.UpdatedAt)
+		}
+		t.Progress = progress.Progress
+		t.Stage = progress.Stage
+		t.PagesDone = progress.PagesDone
+		t.PagesTotal = progress.PagesTotal
+		t.ETA = nil
+		if eta, ok := progress.EstimatedCompletion(); ok {
+			t.ETA = &eta
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Warn("Failed to save task progress",
+			logger.String("taskId", taskID),
+			logger.String("stage", progress.Stage),
+			logger.Error(err),
+		)
+		return
+	}
+
+	s.queue.PublishTaskStatus(ctx, &queue.TaskStatus{
+		TaskID:    taskID,
+		Status:    "running",
+		Progress:  progress.Progress,
+		StartedAt: progress.StartedAt,
+		Detail:    progress,
+	})
+}
+
+// setTaskMetadata 合并写入处理过程中得到的任务信息，如文档类型
+func (s *DocumentService) setTaskMetadata(ctx context.Context, taskID string, metadata map[string]string) {
+	_, err := s.tasks.Update(ctx, taskID, func(t *models.ProcessingTask) error {
+		if t.Metadata == nil {
+			t.Metadata = make(map[string]string, len(metadata))
+		}
+		for k, v := range metadata {
+			t.Metadata[k] = v
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to save task metadata",
+			logger.String("taskId", taskID),
+			logger.Error(err),
+		)
+	}
+}
+
+// syncWithQueue 核对未结束任务在队列中的状态，进度由 worker 写入仓库。队列中任务已经结束而仓库中没有记录时
+// （如 worker 处理中退出后任务超时进入死信队列），以队列为准更新仓库
+func (s *DocumentService) syncWithQueue(ctx context.Context, task *models.ProcessingTask) *models.ProcessingTask {
+	status, err := s.queue.GetTaskStatus(ctx, task.ID)
+	if err != nil {
+		return task
+	}
+
+	switch status.Status {
+	case "failed", "completed", "cancelled":
+		updated, err := s.updateTask(ctx, task.ID, func(t *models.ProcessingTask) error {
+			if t.Status.IsTerminal() {
+				return nil
+			}
+			now := time.Now()
+			switch status.Status {
+			case "failed":
+				t.Fail(status.Error, status.ErrorClass, true, now)
+			case "completed":
+				if t.ResultLocation == "" {
+					t.ResultLocation = resultKey(t.ID)
+				}
+				t.Finish(models.StatusCompleted, now)
+			default:
+				t.Finish(models.StatusCancelled, now)
+			}
+			return nil
+		})
+		if err != nil {
+			s.logger.Warn("Failed to sync task with queue",
+				logger.String("taskId", task.ID),
+				logger.Error(err),
+			)
+			return task
+		}
+		return updated
+	}
+	return task
+}
@@ -0,0 +1,260 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	docagent "github.com/feichai0017/document-processor/internal/agent/document"
+	"github.com/feichai0017/document-processor/internal/models"
+	"github.com/feichai0017/document-processor/pkg/logger"
+	"github.com/feichai0017/document-processor/pkg/webhook"
+)
+
+// ErrWebhooksDisabled 请求了回调但服务未配置签名密钥
+var ErrWebhooksDisabled = errors.New("webhooks are not configured")
+
+type callbackURLCtx struct{}
+
+// WithCallbackURL 将任务完成回调地址附加到 context 上，批量提交时每个任务使用同一个地址
+func WithCallbackURL(ctx context.Context, callbackURL string) context.Context {
+	return context.WithValue(ctx, callbackURLCtx{}, callbackURL)
+}
+
+func callbackURLFrom(ctx context.Context) string {
+	callbackURL, _ := ctx.Value(callbackURLCtx{}).(string)
+	return callbackURL
+}
+
+type batchCallbackURLCtx struct{}
+
+// WithBatchCallbackURL 将批量任务的回调地址附加到 context 上，批量任务全部结束时发送一次事件
+func WithBatchCallbackURL(ctx context.Context, callbackURL string) context.Context {
+	return context.WithValue(ctx, batchCallbackURLCtx{}, callbackURL)
+}
+
+func batchCallbackURLFrom(ctx context.Context) string {
+	callbackURL, _ := ctx.Value(batchCallbackURLCtx{}).(string)
+	return callbackURL
+}
+
+// validateCallback 在创建任务前检查回调地址
+func (s *DocumentService) validateCallback(ctx context.Context) error {
+	callbackURL := callbackURLFrom(ctx)
+	if callbackURL == "" {
+		return nil
+	}
+	return s.validateCallbackURL(callbackURL)
+}
+
+func (s *DocumentService) validateCallbackURL(callbackURL string) error {
+	if s.webhooks == nil {
+		return docagent.NewError(docagent.ErrorClassInvalidRequest, ErrWebhooksDisabled)
+	}
+	if err := s.webhooks.ValidateURL(callbackURL); err != nil {
+		return docagent.NewError(docagent.ErrorClassInvalidRequest, err)
+	}
+	return nil
+}
+
+// registerCallback 为任务注册回调地址；去重复用的任务已经结束时立即发送事件
+func (s *DocumentService) registerCallback(ctx context.Context, task *models.ProcessingTask) error {
+	callbackURL := callbackURLFrom(ctx)
+	if callbackURL == "" || s.webhooks == nil {
+		return nil
+	}
+	if err := s.webhooks.Register(ctx, task.ID, callbackURL); err != nil {
+		return fmt.Errorf("failed to register callback: %w", err)
+	}
+
+	if !task.Deduplicated {
+		return nil
+	}
+	if task.Status.IsTerminal() {
+		var taskErr error
+		if task.Error != "" {
+			taskErr = errors.New(task.Error)
+		}
+		s.NotifyTaskFinished(ctx, task.ID, task.Status, taskErr)
+	}
+	return nil
+}
+
+// NotifyTaskFinished 任务进入最终状态时向注册的回调地址发送签名事件。
+// 每个回调地址保存一条投递记录并入队发送任务，由 worker 投递和重试，
+// 最终失败的投递保留在投递记录中，可以通过重放接口重新发送
+func (s *DocumentService) NotifyTaskFinished(ctx context.Context, taskID string, status models.ProcessingStatus, taskErr error) {
+	if s.webhooks == nil {
+		return
+	}
+
+	event := webhook.Event{
+		TaskID:    taskID,
+		Status:    string(status),
+		CreatedAt: time.Now(),
+	}
+	switch status {
+	case models.StatusCompleted:
+		event.Type = webhook.EventTaskCompleted
+		event.ResultURL = fmt.Sprintf("%s/api/v1/documents/download/%s", s.config.PublicBaseURL, taskID)
+	case models.StatusCancelled:
+		event.Type = webhook.EventTaskCancelled
+	default:
+		event.Type = webhook.EventTaskFailed
+	}
+	if taskErr != nil {
+		event.Error = taskErr.Error()
+		event.ErrorClass = string(docagent.ClassifyError(taskErr))
+	}
+	task, err := s.GetProcessingStatus(ctx, taskID)
+	if err == nil {
+		event.Metadata = task.Metadata
+	}
+
+	deliveries, err := s.webhooks.Prepare(ctx, event)
+	if err != nil {
+		s.logger.Error("Failed to save webhook deliveries",
+			logger.String("taskId", taskID),
+			logger.String("event", event.Type),
+			logger.Error(err),
+		)
+	}
+	for _, delivery := range deliveries {
+		s.scheduleWebhook(ctx, delivery)
+	}
+
+	if task != nil && task.Metadata["batchId"] != "" {
+		s.notifyBatchFinished(ctx, task.Metadata["batchId"])
+	}
+}
+
+// notifyBatchFinished 批量任务的文件全部结束后发送一次 batch.finished 事件。
+// 多个任务同时结束时可能都看到批量任务已结束，用 Reserve 保证只发送一次
+func (s *DocumentService) notifyBatchFinished(ctx context.Context, batchID string) {
+	if s.webhooks == nil {
+		return
+	}
+	// 大多数批量任务没有回调，避免每个任务结束时都读取全部任务的状态
+	if ok, err := s.webhooks.HasCallbacks(ctx, webhook.BatchSubject(batchID)); err != nil || !ok {
+		return
+	}
+
+	record, err := s.queue.GetBatch(ctx, batchID)
+	if err != nil {
+		s.logger.Warn("Failed to load batch of finished task",
+			logger.String("batchId", batchID),
+			logger.Error(err),
+		)
+		return
+	}
+	// 提交完成前 Items 中的任务 ID 还不完整，由 ProcessBatch 在提交后检查
+	if !record.Submitted {
+		return
+	}
+
+	batch := toBatch(record)
+	s.loadBatchItems(ctx, batch)
+	batch.Summarize()
+	switch batch.Status {
+	case models.BatchCompleted, models.BatchPartial, models.BatchFailed:
+	default:
+		return
+	}
+
+	_, first, err := s.queue.Reserve(ctx, "webhook:batch_finished:"+batchID, batchID, 24*time.Hour)
+	if err != nil {
+		s.logger.Error("Failed to reserve batch webhook",
+			logger.String("batchId", batchID),
+			logger.Error(err),
+		)
+		return
+	}
+	if !first {
+		return
+	}
+
+	event := webhook.Event{
+		Type:      webhook.EventBatchFinished,
+		BatchID:   batchID,
+		Status:    string(batch.Status),
+		Counts:    make(map[string]int, len(batch.Counts)),
+		CreatedAt: time.Now(),
+	}
+	for status, count := range batch.Counts {
+		event.Counts[string(status)] = count
+	}
+	if batch.Counts[models.StatusCompleted] > 0 {
+		event.ResultURL = fmt.Sprintf("%s/api/v1/documents/batch/%s/download", s.config.PublicBaseURL, batchID)
+	}
+
+	deliveries, err := s.webhooks.Prepare(ctx, event)
+	if err != nil {
+		s.logger.Error("Failed to save webhook deliveries",
+			logger.String("batchId", batchID),
+			logger.String("event", event.Type),
+			logger.Error(err),
+		)
+	}
+	for _, delivery := range deliveries {
+		s.scheduleWebhook(ctx, delivery)
+	}
+}
+
+// scheduleWebhook 入队投递任务，入队失败的记录保持 pending，可以重放
+func (s *DocumentService) scheduleWebhook(ctx context.Context, delivery *webhook.Delivery) error {
+	if err := s.queue.EnqueueWebhook(ctx, delivery.ID, s.webhooks.MaxAttempts()); err != nil {
+		s.logger.Error("Failed to schedule webhook delivery",
+			logger.String("deliveryId", delivery.ID),
+			logger.String("taskId", delivery.Event.Subject()),
+			logger.Error(err),
+		)
+		return err
+	}
+	return nil
+}
+
+// DeliverWebhook 由 worker 调用，发送一次投递记录中的事件；接收方暂时不可用时返回错误，
+// 由队列稍后重试。final 表示这是最后一次尝试
+func (s *DocumentService) DeliverWebhook(ctx context.Context, deliveryID string, final bool) error {
+	if s.webhooks == nil {
+		return ErrWebhooksDisabled
+	}
+	delivery, retry, err := s.webhooks.Attempt(ctx, deliveryID, final)
+	if err != nil {
+		return err
+	}
+	if retry {
+		last := delivery.Attempts[len(delivery.Attempts)-1]
+		return fmt.Errorf("webhook delivery %s attempt %d failed: %s", deliveryID, len(delivery.Attempts), last.Error)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries 返回任务的回调投递记录
+func (s *DocumentService) ListWebhookDeliveries(ctx context.Context, taskID string) ([]*webhook.Delivery, error) {
+	if s.webhooks == nil {
+		return nil, ErrWebhooksDisabled
+	}
+	return s.webhooks.Deliveries(ctx, taskID)
+}
+
+// ReplayWebhook 将投递记录重新置为待投递并入队，返回重放前的投递记录
+func (s *DocumentService) ReplayWebhook(ctx context.Context, deliveryID string) (*webhook.Delivery, error) {
+	if s.webhooks == nil {
+		return nil, ErrWebhooksDisabled
+	}
+	delivery, err := s.webhooks.Reopen(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.scheduleWebhook(ctx, delivery); err != nil {
+		return nil, fmt.Errorf("failed to schedule webhook replay: %w", err)
+	}
+
+	s.logger.Info("Webhook replay scheduled",
+		logger.String("deliveryId", deliveryID),
+		logger.String("taskId", delivery.Event.Subject()),
+	)
+	return delivery, nil
+}
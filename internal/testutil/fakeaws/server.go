@@ -0,0 +1,312 @@
+// Package fakeaws 提供本地的 Textract 与 S3 替身服务，
+// 基于 httptest 实现，回放 testdata 下的 Textract 响应样例，
+// 使 TextractProcessor、S3Storage 和 DocumentService 可以离线运行
+package fakeaws
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed testdata
+var fixturesFS embed.FS
+
+// Region 替身服务使用的区域
+const Region = "us-east-1"
+
+// Request 记录收到的请求，便于测试断言
+type Request struct {
+	Service   string // "textract" 或 "s3"
+	Operation string
+	Path      string
+	Body      []byte
+}
+
+// Server Textract 与 S3 替身服务
+type Server struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	fixtures map[string][][]byte // 按 Textract 操作名回放的响应序列
+	buckets  map[string]map[string]*object
+	requests []Request
+}
+
+type object struct {
+	data         []byte
+	lastModified time.Time
+}
+
+// NewServer 启动替身服务并加载默认的 Textract 响应样例
+func NewServer() (*Server, error) {
+	s := &Server{
+		fixtures: make(map[string][][]byte),
+		buckets:  make(map[string]map[string]*object),
+	}
+
+	sub, err := fs.Sub(fixturesFS, "testdata/textract")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fixtures: %w", err)
+	}
+	if err := s.LoadFixtures(sub); err != nil {
+		return nil, err
+	}
+
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s, nil
+}
+
+// URL 返回替身服务地址，用作 Textract 与 S3 的 Endpoint
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close 关闭替身服务
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// LoadFixtures 从目录加载响应样例，文件名为操作名（如 AnalyzeDocument.json），
+// 内容为单个响应或按顺序回放的响应数组
+func (s *Server) LoadFixtures(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return fmt.Errorf("failed to list fixtures: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return fmt.Errorf("failed to read fixture %s: %w", entry.Name(), err)
+		}
+		responses, err := splitFixture(data)
+		if err != nil {
+			return fmt.Errorf("invalid fixture %s: %w", entry.Name(), err)
+		}
+		s.SetFixture(strings.TrimSuffix(entry.Name(), ".json"), responses...)
+	}
+
+	return nil
+}
+
+// SetFixture 设置某个 Textract 操作的响应序列，序列用完后重复最后一个响应
+func (s *Server) SetFixture(operation string, responses ...[]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixtures[operation] = responses
+}
+
+// CreateBucket 创建存储桶
+func (s *Server) CreateBucket(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.buckets[name]; !ok {
+		s.buckets[name] = make(map[string]*object)
+	}
+}
+
+// PutObject 直接写入对象
+func (s *Server) PutObject(bucket, key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.buckets[bucket]; !ok {
+		s.buckets[bucket] = make(map[string]*object)
+	}
+	s.buckets[bucket][key] = &object{data: data, lastModified: time.Now()}
+}
+
+// Object 读取对象
+func (s *Server) Object(bucket, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.buckets[bucket][key]
+	if !ok {
+		return nil, false
+	}
+	return obj.data, true
+}
+
+// Requests 返回收到的所有请求
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := make([]Request, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if target := r.Header.Get("X-Amz-Target"); strings.HasPrefix(target, "Textract.") {
+		s.handleTextract(w, strings.TrimPrefix(target, "Textract."), body)
+		return
+	}
+
+	s.handleS3(w, r, body)
+}
+
+func (s *Server) record(req Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+}
+
+// handleTextract 回放 Textract JSON 协议的响应
+func (s *Server) handleTextract(w http.ResponseWriter, operation string, body []byte) {
+	s.record(Request{Service: "textract", Operation: operation, Body: body})
+
+	s.mu.Lock()
+	responses := s.fixtures[operation]
+	var response []byte
+	if len(responses) > 0 {
+		response = responses[0]
+		if len(responses) > 1 {
+			s.fixtures[operation] = responses[1:]
+		}
+	}
+	s.mu.Unlock()
+
+	if response == nil {
+		writeJSONError(w, http.StatusBadRequest, "InvalidParameterException",
+			fmt.Sprintf("no fixture for operation %s", operation))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// handleS3 实现 S3 REST 接口的子集（path-style）：
+// HeadBucket、PutObject、GetObject、DeleteObject、ListObjectsV2
+func (s *Server) handleS3(w http.ResponseWriter, r *http.Request, body []byte) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	operation := r.Method
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		operation = "HeadBucket"
+	case key == "" && r.Method == http.MethodGet:
+		operation = "ListObjectsV2"
+	case r.Method == http.MethodPut:
+		operation = "PutObject"
+	case r.Method == http.MethodGet:
+		operation = "GetObject"
+	case r.Method == http.MethodDelete:
+		operation = "DeleteObject"
+	}
+	s.record(Request{Service: "s3", Operation: operation, Path: r.URL.Path, Body: body})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects, ok := s.buckets[bucket]
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket", bucket)
+		return
+	}
+
+	switch operation {
+	case "HeadBucket":
+		w.WriteHeader(http.StatusOK)
+	case "ListObjectsV2":
+		writeListObjects(w, bucket, r.URL.Query().Get("prefix"), objects)
+	case "PutObject":
+		objects[key] = &object{data: body, lastModified: time.Now()}
+		w.Header().Set("ETag", fmt.Sprintf("%q", fmt.Sprintf("%x", len(body))))
+		w.WriteHeader(http.StatusOK)
+	case "GetObject":
+		obj, ok := objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey", key)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(obj.data)))
+		w.Header().Set("Last-Modified", obj.lastModified.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		w.Write(obj.data)
+	case "DeleteObject":
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+// readBody 读取请求体，兼容 SDK 的 aws-chunked 编码
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") {
+		return body, nil
+	}
+
+	var decoded bytes.Buffer
+	for len(body) > 0 {
+		line, rest, ok := bytes.Cut(body, []byte("\r\n"))
+		if !ok {
+			break
+		}
+		sizeHex, _, _ := bytes.Cut(line, []byte(";"))
+		var size int
+		if _, err := fmt.Sscanf(string(sizeHex), "%x", &size); err != nil || size == 0 {
+			break
+		}
+		if size > len(rest) {
+			return nil, fmt.Errorf("invalid aws-chunked body")
+		}
+		decoded.Write(rest[:size])
+		body = bytes.TrimPrefix(rest[size:], []byte("\r\n"))
+	}
+	return decoded.Bytes(), nil
+}
+
+// splitFixture 将单个响应或响应数组拆分为响应序列
+func splitFixture(data []byte) ([][]byte, error) {
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("[")) {
+		if !json.Valid(trimmed) {
+			return nil, fmt.Errorf("invalid json")
+		}
+		return [][]byte{trimmed}, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(trimmed, &items); err != nil {
+		return nil, err
+	}
+	responses := make([][]byte, len(items))
+	for i, item := range items {
+		responses[i] = item
+	}
+	return responses, nil
+}
+
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"__type":  code,
+		"message": message,
+	})
+}
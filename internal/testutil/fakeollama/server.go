@@ -0,0 +1,197 @@
+// Package fakeollama 提供基于 httptest 的 Ollama /api/chat 替身服务，
+// 记录收到的请求并按 Ollama 的响应格式（含 NDJSON 流式响应）返回预设内容
+package fakeollama
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message 对应 /api/chat 的消息
+type Message struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+// ChatRequest 对应 /api/chat 请求，Options 与 Format 保留原始内容便于断言
+type ChatRequest struct {
+	Model    string                 `json:"model"`
+	Messages []Message              `json:"messages"`
+	Stream   *bool                  `json:"stream"`
+	Format   json.RawMessage        `json:"format,omitempty"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+// ReplyFunc 根据请求生成回复内容
+type ReplyFunc func(req ChatRequest) string
+
+// Server Ollama 替身服务
+type Server struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	reply    ReplyFunc
+	requests []ChatRequest
+	failures int
+	delay    time.Duration
+}
+
+// NewServer 启动替身服务，默认回复固定文本
+func NewServer() *Server {
+	s := &Server{
+		reply: func(ChatRequest) string { return "ok" },
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/chat", s.handleChat)
+	mux.HandleFunc("/api/tags", s.handleTags)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Ollama is running"))
+	})
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// URL 返回替身服务地址，用作 OllamaConfig.Endpoint
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close 关闭替身服务
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// SetReply 设置回复内容
+func (s *Server) SetReply(reply ReplyFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reply = reply
+}
+
+// FailNext 让接下来的 n 个请求返回 503
+func (s *Server) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+}
+
+// SetDelay 设置流式响应每段之间的间隔，模拟耗时较长的生成
+func (s *Server) SetDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = delay
+}
+
+// Requests 返回收到的所有 /api/chat 请求
+func (s *Server) Requests() []ChatRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := make([]ChatRequest, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"models": []map[string]string{{"name": "llama3.2-vision:latest"}},
+	})
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if req.Model == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "model is required"})
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	fail := s.failures > 0
+	if fail {
+		s.failures--
+	}
+	reply := s.reply
+	delay := s.delay
+	s.mu.Unlock()
+
+	if fail {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "server busy"})
+		return
+	}
+
+	content := reply(req)
+	createdAt := time.Now().UTC().Format(time.RFC3339Nano)
+
+	// Ollama 默认使用流式响应
+	if req.Stream == nil || *req.Stream {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		for _, part := range splitTokens(content) {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+			encoder.Encode(map[string]interface{}{
+				"model":      req.Model,
+				"created_at": createdAt,
+				"message":    Message{Role: "assistant", Content: part},
+				"done":       false,
+			})
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		encoder.Encode(map[string]interface{}{
+			"model":       req.Model,
+			"created_at":  createdAt,
+			"message":     Message{Role: "assistant", Content: ""},
+			"done":        true,
+			"done_reason": "stop",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"model":       req.Model,
+		"created_at":  createdAt,
+		"message":     Message{Role: "assistant", Content: content},
+		"done":        true,
+		"done_reason": "stop",
+	})
+}
+
+// splitTokens 按空白拆分内容，模拟逐段生成
+func splitTokens(content string) []string {
+	var parts []string
+	for len(content) > 0 {
+		i := strings.IndexAny(content[1:], " \n")
+		if i < 0 {
+			parts = append(parts, content)
+			break
+		}
+		parts = append(parts, content[:i+1])
+		content = content[i+1:]
+	}
+	return parts
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
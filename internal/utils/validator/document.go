@@ -280,10 +280,18 @@ func (v *DocumentValidator) detectMimeType(file multipart.File) (string, error)
 
 // 计算文件哈希
 func (v *DocumentValidator) calculateHash(file multipart.File) (string, error) {
+    return HashFile(file)
+}
+
+// HashFile 计算文件内容的 SHA-256，完成后将文件指针重置到开头
+func HashFile(file io.ReadSeeker) (string, error) {
     hash := sha256.New()
     if _, err := io.Copy(hash, file); err != nil {
         return "", err
     }
+    if _, err := file.Seek(0, io.SeekStart); err != nil {
+        return "", err
+    }
 
     return hex.EncodeToString(hash.Sum(nil)), nil
 }
@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache 键值缓存，后端可以是进程内 LRU、Redis 或对象存储。
+// Get 未命中时返回 false 和 nil 错误；ttl <= 0 表示不过期
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Type 缓存后端类型
+type Type string
+
+const (
+	TypeMemory  Type = "memory"
+	TypeRedis   Type = "redis"
+	TypeStorage Type = "storage"
+)
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(3)
+
+	for _, key := range []string{"a", "b", "c"} {
+		m.Set(ctx, key, []byte(key), 0)
+	}
+	// 读取 a 后 b 成为最久未使用的条目
+	if _, ok, _ := m.Get(ctx, "a"); !ok {
+		t.Fatal("a is missing")
+	}
+	m.Set(ctx, "d", []byte("d"), 0)
+
+	if _, ok, _ := m.Get(ctx, "b"); ok {
+		t.Error("b was not evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if value, ok, _ := m.Get(ctx, key); !ok || string(value) != key {
+			t.Errorf("Get(%s) = %q, %v", key, value, ok)
+		}
+	}
+
+	// 覆盖已有条目也算使用，淘汰顺序为 a、c、d 中最久未使用的 a
+	m.Set(ctx, "c", []byte("c2"), 0)
+	m.Get(ctx, "d")
+	m.Set(ctx, "e", []byte("e"), 0)
+	if _, ok, _ := m.Get(ctx, "a"); ok {
+		t.Error("a was not evicted")
+	}
+	if value, _, _ := m.Get(ctx, "c"); string(value) != "c2" {
+		t.Errorf("Get(c) = %q, want c2", value)
+	}
+	if m.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", m.Len())
+	}
+}
+
+func TestMemoryExpires(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(10)
+
+	m.Set(ctx, "short", []byte("v"), 10*time.Millisecond)
+	m.Set(ctx, "forever", []byte("v"), 0)
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok, err := m.Get(ctx, "short"); ok || err != nil {
+		t.Errorf("Get(short) = %v, %v; want expired miss", ok, err)
+	}
+	if _, ok, _ := m.Get(ctx, "forever"); !ok {
+		t.Error("entry without ttl expired")
+	}
+	if m.Len() != 1 {
+		t.Errorf("expired entry was not removed, Len() = %d", m.Len())
+	}
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	c := NewRedis(client, "llm:")
+
+	if _, ok, err := c.Get(ctx, "missing"); ok || err != nil {
+		t.Fatalf("Get(missing) = %v, %v; want miss without error", ok, err)
+	}
+
+	if err := c.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if value, ok, err := c.Get(ctx, "key"); !ok || err != nil || string(value) != "value" {
+		t.Fatalf("Get(key) = %q, %v, %v", value, ok, err)
+	}
+	if !server.Exists("llm:key") {
+		t.Error("entry is not stored under the prefix")
+	}
+	if ttl := server.TTL("llm:key"); ttl != time.Minute {
+		t.Errorf("TTL = %v, want 1m", ttl)
+	}
+
+	server.FastForward(2 * time.Minute)
+	if _, ok, _ := c.Get(ctx, "key"); ok {
+		t.Error("entry did not expire")
+	}
+
+	// 连接失败等错误需要返回给调用方，不能当作未命中
+	server.Close()
+	if _, _, err := c.Get(ctx, "key"); err == nil {
+		t.Error("Get() error = nil after the server stopped")
+	}
+}
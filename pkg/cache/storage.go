@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/feichai0017/document-processor/pkg/storage"
+)
+
+// storageEntry 对象存储没有按对象的过期时间，过期时间和值一起保存
+type storageEntry struct {
+	Value     []byte    `json:"value"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+// Storage 将条目保存为对象存储（S3、MinIO）中的对象，适合多个进程共享且条目较大的场景。
+// 过期条目在读取时视为未命中，由存储的生命周期规则或 CleanupBefore 清理
+type Storage struct {
+	store  storage.Storage
+	prefix string
+}
+
+func NewStorage(store storage.Storage, prefix string) *Storage {
+	return &Storage{
+		store:  store,
+		prefix: prefix,
+	}
+}
+
+func (s *Storage) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reader, err := s.store.Get(ctx, s.prefix+key)
+	if storage.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get cache entry: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+
+	var entry storageEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false, fmt.Errorf("failed to decode cache entry: %w", err)
+	}
+	if !entry.ExpiresAt.IsZero() && time.Now().After(entry.ExpiresAt) {
+		return nil, false, nil
+	}
+	return entry.Value, true, nil
+}
+
+func (s *Storage) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	entry := storageEntry{Value: value}
+	if ttl > 0 {
+		entry.ExpiresAt = time.Now().Add(ttl)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = s.store.Store(ctx, bytes.NewReader(data), s.prefix+key)
+	return err
+}
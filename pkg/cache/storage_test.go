@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/feichai0017/document-processor/config"
+	"github.com/feichai0017/document-processor/internal/testutil/fakeaws"
+	"github.com/feichai0017/document-processor/pkg/logger"
+	"github.com/feichai0017/document-processor/pkg/storage/s3"
+)
+
+func newFakeS3(t *testing.T) (*s3.S3Storage, *fakeaws.Server) {
+	t.Helper()
+
+	server, err := fakeaws.NewServer()
+	if err != nil {
+		t.Fatalf("fakeaws.NewServer() error = %v", err)
+	}
+	t.Cleanup(server.Close)
+	server.CreateBucket("cache")
+
+	store, err := s3.NewS3StorageWithConfig(logger.NewTestLogger(), &config.S3Config{
+		BucketName: "cache",
+		Region:     "us-east-1",
+		Endpoint:   server.URL(),
+		AccessKey:  "test",
+		SecretKey:  "test",
+	})
+	if err != nil {
+		t.Fatalf("NewS3StorageWithConfig() error = %v", err)
+	}
+	return store, server
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	store, server := newFakeS3(t)
+	c := NewStorage(store, "llm-cache/")
+
+	if _, ok, err := c.Get(ctx, "missing"); ok || err != nil {
+		t.Fatalf("Get(missing) = %v, %v; want miss without error", ok, err)
+	}
+
+	if err := c.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if _, ok := server.Object("cache", "llm-cache/key"); !ok {
+		t.Error("entry is not stored under the prefix")
+	}
+	if value, ok, err := c.Get(ctx, "key"); !ok || err != nil || string(value) != "value" {
+		t.Fatalf("Get(key) = %q, %v, %v", value, ok, err)
+	}
+
+	if err := c.Set(ctx, "expired", []byte("value"), time.Nanosecond); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, ok, err := c.Get(ctx, "expired"); ok || err != nil {
+		t.Errorf("Get(expired) = %v, %v; want miss", ok, err)
+	}
+
+	server.PutObject("cache", "llm-cache/corrupt", []byte("not json"))
+	if _, ok, err := c.Get(ctx, "corrupt"); ok || err == nil {
+		t.Errorf("Get(corrupt) = %v, %v; want decode error", ok, err)
+	}
+}
+
+// failingStore 模拟存储不可用
+type failingStore struct{}
+
+func (failingStore) Store(ctx context.Context, reader io.Reader, filename string) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+func (failingStore) Get(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (failingStore) Delete(ctx context.Context, id string) error { return nil }
+
+func (failingStore) CleanupBefore(ctx context.Context, threshold time.Time) error { return nil }
+
+func TestStorageReturnsFailures(t *testing.T) {
+	c := NewStorage(failingStore{}, "")
+
+	if _, ok, err := c.Get(context.Background(), "key"); ok || err == nil {
+		t.Fatalf("Get() = %v, %v; want error instead of a miss", ok, err)
+	}
+}
@@ -1,10 +1,12 @@
 package converters
 
 import (
+    "encoding/json"
     "fmt"
     "time"
     
     "github.com/feichai0017/document-processor/internal/models"
+    "github.com/feichai0017/document-processor/pkg/jsonschema"
 )
 
 // DocumentConverter 定义文档转换器接口
@@ -14,11 +16,33 @@ type DocumentConverter interface {
 
 // ProcessedDocument 定义处理后的文档结构
 type ProcessedDocument struct {
-    TaskID      string                 `json:"taskId"`
-    Status      string                 `json:"status"`
-    Content     []ChunkContent         `json:"content"`
-    Metadata    DocumentMetadata       `json:"metadata"`
-    ProcessedAt time.Time             `json:"processedAt"`
+    TaskID         string                 `json:"taskId"`
+    Status         string                 `json:"status"`
+    Content        []ChunkContent         `json:"content"`
+    Metadata       DocumentMetadata       `json:"metadata"`
+    Review         []models.ReviewItem    `json:"review,omitempty"`         // 低置信度区域，需人工复核
+    Expenses       []models.Expense       `json:"expenses,omitempty"`       // 费用分析结果（发票/收据）
+    Queries        []models.QueryResult   `json:"queries,omitempty"`        // 自然语言查询结果
+    Classification *models.Classification `json:"classification,omitempty"` // 文档分类结果
+    Revision       *models.TextRevision   `json:"revision,omitempty"`       // LLM 对 OCR 文本的修正，可逐条接受或拒绝
+    Extracted      json.RawMessage        `json:"extracted,omitempty"`      // 按请求 Schema 抽取并通过校验的结构化数据
+    Extraction     *ExtractionInfo        `json:"extraction,omitempty"`
+    Summary        *models.Summary        `json:"summary,omitempty"`     // 文档摘要
+    Translation    *models.Translation    `json:"translation,omitempty"` // 按块翻译的译文
+    ProcessedAt    time.Time              `json:"processedAt"`
+}
+
+// ExtractionInfo 结构化抽取的执行情况，Valid 为 false 时不返回 Extracted，模型的原始输出在 Raw 中
+type ExtractionInfo struct {
+    Valid         bool                    `json:"valid"`
+    Attempts      int                     `json:"attempts"`
+    Errors        []jsonschema.FieldError `json:"errors,omitempty"`
+    Error         string                  `json:"error,omitempty"`
+    PromptID      string                  `json:"promptId,omitempty"`
+    PromptVersion int                     `json:"promptVersion,omitempty"`
+    Cached        bool                    `json:"cached,omitempty"`  // 命中 LLM 缓存
+    Raw           json.RawMessage         `json:"raw,omitempty"`     // 未通过校验时模型最后一次的原始输出
+    Skipped       string                  `json:"skipped,omitempty"` // LLM 不可用等原因跳过抽取时的说明
 }
 
 // ChunkContent 定义文档块内容
@@ -38,6 +62,7 @@ type DocumentMetadata struct {
     Sections     []string `json:"sections"`
     Language     string   `json:"language,omitempty"`
     Confidence   float64  `json:"confidence"`
+    NeedsReview  bool     `json:"needsReview"`
     ProcessingMs int64    `json:"processingMs"`
 }
 
@@ -67,6 +92,7 @@ func (c *JSONConverter) Convert(chunks []models.DocumentChunk) (*ProcessedDocume
     // 处理每个文档块
     sections := make(map[string]bool)
     var totalConfidence float64
+    var confidenceCount int
 
     for i, chunk := range chunks {
         // 创建内容块
@@ -93,6 +119,23 @@ func (c *JSONConverter) Convert(chunks []models.DocumentChunk) (*ProcessedDocume
         }
         if conf, ok := chunk.Metadata["confidence"].(float64); ok {
             totalConfidence += conf
+            confidenceCount++
+        }
+        if review, ok := chunk.Metadata["review"].([]models.ReviewItem); ok {
+            doc.Review = append(doc.Review, review...)
+        }
+        if expense, ok := chunk.Metadata["expense"].(*models.Expense); ok {
+            doc.Expenses = append(doc.Expenses, *expense)
+        }
+        if queries, ok := chunk.Metadata["queries"].([]models.QueryResult); ok {
+            doc.Queries = append(doc.Queries, queries...)
+        }
+        if revision, ok := chunk.Metadata["revision"].(*models.TextRevision); ok {
+            if doc.Revision == nil {
+                doc.Revision = revision
+            }
+            // 复核状态只在 doc.Revision 上维护，避免块元数据中出现过期副本
+            delete(content.Metadata, "revision")
         }
     }
 
@@ -101,10 +144,11 @@ func (c *JSONConverter) Convert(chunks []models.DocumentChunk) (*ProcessedDocume
         doc.Metadata.Sections = append(doc.Metadata.Sections, section)
     }
     
-    // 计算平均置信度
-    if len(chunks) > 0 {
-        doc.Metadata.Confidence = totalConfidence / float64(len(chunks))
+    // 计算平均置信度（只统计带置信度的块）
+    if confidenceCount > 0 {
+        doc.Metadata.Confidence = totalConfidence / float64(confidenceCount)
     }
+    doc.Metadata.NeedsReview = len(doc.Review) > 0
 
     // 设置文档基本信息
     if len(chunks) > 0 && len(chunks[0].Metadata) > 0 {
@@ -0,0 +1,326 @@
+// Package jsonschema 实现 JSON Schema 的常用子集校验，
+// 用于校验 LLM 按调用方 Schema 抽取的结构化结果
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Schema 支持的关键字：type、properties、required、additionalProperties（布尔值或子 Schema）、
+// items、enum、minLength、maxLength、pattern、minimum、maximum、minItems、maxItems，
+// 以及不参与校验的 title、description 等注释关键字，其他关键字解析时报错，避免被静默忽略
+type Schema struct {
+	Type                 Types              `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"-"`
+	AdditionalSchema     *Schema            `json:"-"` // additionalProperties 为子 Schema 时，额外字段按它校验
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Description          string             `json:"description,omitempty"`
+
+	pattern     *regexp.Regexp
+	unsupported []string // 解析时遇到的不支持的关键字，由 compile 报错
+}
+
+// keywords 支持的关键字，annotation 为 true 的只作说明，不参与校验
+var keywords = map[string]bool{
+	"type": false, "properties": false, "required": false, "additionalProperties": false,
+	"items": false, "enum": false, "minLength": false, "maxLength": false, "pattern": false,
+	"minimum": false, "maximum": false, "minItems": false, "maxItems": false,
+	"title": true, "description": true, "default": true, "examples": true,
+	"$schema": true, "$id": true, "$comment": true,
+}
+
+// Types 单个类型或类型数组
+type Types []string
+
+// UnmarshalJSON 支持 "string" 与 ["string", "null"] 两种写法
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = Types{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = multiple
+	return nil
+}
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationError 校验失败时返回的错误，包含所有字段错误
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.String()
+	}
+	return "schema validation failed: " + strings.Join(msgs, "; ")
+}
+
+var knownTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// Parse 解析并检查 Schema
+func Parse(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := s.compile("$"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UnmarshalJSON 处理布尔值或子 Schema 形式的 additionalProperties，并记录不支持的关键字
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type plain Schema
+	var aux struct {
+		plain
+		AdditionalProperties json.RawMessage `json:"additionalProperties,omitempty"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*s = Schema(aux.plain)
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for name := range fields {
+		if _, ok := keywords[name]; !ok {
+			s.unsupported = append(s.unsupported, name)
+		}
+	}
+	sort.Strings(s.unsupported)
+
+	if len(aux.AdditionalProperties) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(aux.AdditionalProperties, &allowed); err == nil {
+			s.AdditionalProperties = &allowed
+			return nil
+		}
+		var additional Schema
+		if err := json.Unmarshal(aux.AdditionalProperties, &additional); err != nil {
+			return fmt.Errorf("additionalProperties must be a boolean or a schema: %w", err)
+		}
+		s.AdditionalSchema = &additional
+	}
+	return nil
+}
+
+func (s *Schema) compile(path string) error {
+	if len(s.unsupported) > 0 {
+		return fmt.Errorf("invalid schema at %s: unsupported keyword %q", path, s.unsupported[0])
+	}
+	for _, t := range s.Type {
+		if !knownTypes[t] {
+			return fmt.Errorf("invalid schema at %s: unknown type %q", path, t)
+		}
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid schema at %s: bad pattern: %w", path, err)
+		}
+		s.pattern = re
+	}
+	for name, prop := range s.Properties {
+		if prop == nil {
+			return fmt.Errorf("invalid schema at %s.%s: empty schema", path, name)
+		}
+		if err := prop.compile(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		if err := s.Items.compile(path + "[]"); err != nil {
+			return err
+		}
+	}
+	if s.AdditionalSchema != nil {
+		if err := s.AdditionalSchema.compile(path + ".*"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate 校验 JSON 文档，失败时返回 *ValidationError
+func (s *Schema) Validate(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{Errors: []FieldError{{Path: "$", Message: "invalid JSON: " + err.Error()}}}
+	}
+	if decoder.More() {
+		return &ValidationError{Errors: []FieldError{{Path: "$", Message: "unexpected data after JSON value"}}}
+	}
+
+	var errs []FieldError
+	s.validate("$", value, &errs)
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func (s *Schema) validate(path string, value interface{}, errs *[]FieldError) {
+	add := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Type) > 0 && !s.matchesType(value) {
+		add("expected %s, got %s", strings.Join(s.Type, " or "), typeOf(value))
+		return
+	}
+
+	if len(s.Enum) > 0 && !s.inEnum(value) {
+		add("value is not one of the allowed values")
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, FieldError{Path: path + "." + name, Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			field := v[name]
+			prop, ok := s.Properties[name]
+			if !ok {
+				switch {
+				case s.AdditionalSchema != nil:
+					s.AdditionalSchema.validate(path+"."+name, field, errs)
+				case s.AdditionalProperties != nil && !*s.AdditionalProperties:
+					*errs = append(*errs, FieldError{Path: path + "." + name, Message: "is not allowed"})
+				}
+				continue
+			}
+			prop.validate(path+"."+name, field, errs)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			add("expected at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			add("expected at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if s.MinLength != nil && length < *s.MinLength {
+			add("expected at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			add("expected at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			add("does not match pattern %s", s.Pattern)
+		}
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			add("invalid number")
+			return
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			add("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			add("must be <= %v", *s.Maximum)
+		}
+	}
+}
+
+func (s *Schema) matchesType(value interface{}) bool {
+	actual := typeOf(value)
+	for _, t := range s.Type {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) inEnum(value interface{}) bool {
+	encoded, _ := json.Marshal(value)
+	for _, allowed := range s.Enum {
+		expected, _ := json.Marshal(allowed)
+		if bytes.Equal(encoded, expected) {
+			return true
+		}
+		// json.Number 与 float64 的比较
+		if n, ok := value.(json.Number); ok {
+			if f, ok := allowed.(float64); ok && n.String() == fmt.Sprint(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
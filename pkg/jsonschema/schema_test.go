@@ -0,0 +1,187 @@
+package jsonschema
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{name: "object", schema: `{"type":"object","properties":{"total":{"type":"number"}},"required":["total"]}`},
+		{name: "nullable type", schema: `{"type":["string","null"]}`},
+		{name: "annotations", schema: `{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"Invoice","description":"d","type":"object","default":{},"examples":[{}]}`},
+		{name: "additional properties schema", schema: `{"type":"object","additionalProperties":{"type":"string"}}`},
+		{name: "invalid json", schema: `{"type":`, wantErr: "invalid schema"},
+		{name: "unknown type", schema: `{"type":"decimal"}`, wantErr: `unknown type "decimal"`},
+		{name: "type not a string", schema: `{"type":1}`, wantErr: "type must be a string"},
+		{name: "bad pattern", schema: `{"type":"string","pattern":"("}`, wantErr: "bad pattern"},
+		{name: "unsupported keyword", schema: `{"type":"object","oneOf":[{"type":"string"}]}`, wantErr: `$: unsupported keyword "oneOf"`},
+		{name: "unsupported nested keyword", schema: `{"type":"object","properties":{"date":{"type":"string","format":"date"}}}`, wantErr: `$.date: unsupported keyword "format"`},
+		{name: "unsupported keyword in items", schema: `{"type":"array","items":{"$ref":"#/defs/item"}}`, wantErr: `$[]: unsupported keyword "$ref"`},
+		{name: "unsupported keyword in additional properties", schema: `{"additionalProperties":{"const":1}}`, wantErr: `$.*: unsupported keyword "const"`},
+		{name: "additional properties not a schema", schema: `{"additionalProperties":"yes"}`, wantErr: "additionalProperties must be a boolean or a schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.schema))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invoice := `{
+		"type": "object",
+		"properties": {
+			"number": {"type": "string", "pattern": "^INV-[0-9]+$"},
+			"currency": {"type": "string", "enum": ["USD", "EUR"]},
+			"total": {"type": "number", "minimum": 0, "maximum": 1000000},
+			"lines": {"type": "integer", "minimum": 1},
+			"vendor": {"type": ["string", "null"], "minLength": 2, "maxLength": 5},
+			"tags": {"type": "array", "items": {"type": "string"}, "minItems": 1, "maxItems": 2},
+			"paid": {"type": "boolean"}
+		},
+		"required": ["number", "total"],
+		"additionalProperties": false
+	}`
+
+	tests := []struct {
+		name   string
+		schema string
+		data   string
+		want   []FieldError
+	}{
+		{
+			name:   "valid",
+			schema: invoice,
+			data:   `{"number":"INV-1","currency":"USD","total":12.5,"lines":3,"vendor":"ACME","tags":["a"],"paid":true}`,
+		},
+		{
+			name:   "nullable field",
+			schema: invoice,
+			data:   `{"number":"INV-1","total":0,"vendor":null}`,
+		},
+		{
+			name:   "missing required",
+			schema: invoice,
+			data:   `{"number":"INV-1"}`,
+			want:   []FieldError{{Path: "$.total", Message: "is required"}},
+		},
+		{
+			name:   "wrong types",
+			schema: invoice,
+			data:   `{"number":1,"total":"12","paid":"yes"}`,
+			want: []FieldError{
+				{Path: "$.number", Message: "expected string, got integer"},
+				{Path: "$.paid", Message: "expected boolean, got string"},
+				{Path: "$.total", Message: "expected number, got string"},
+			},
+		},
+		{
+			name:   "integer rejects fractions",
+			schema: invoice,
+			data:   `{"number":"INV-1","total":1,"lines":1.5}`,
+			want:   []FieldError{{Path: "$.lines", Message: "expected integer, got number"}},
+		},
+		{
+			name:   "constraints",
+			schema: invoice,
+			data:   `{"number":"1001","currency":"GBP","total":-1,"vendor":"A","tags":[]}`,
+			want: []FieldError{
+				{Path: "$.currency", Message: "value is not one of the allowed values"},
+				{Path: "$.number", Message: "does not match pattern ^INV-[0-9]+$"},
+				{Path: "$.tags", Message: "expected at least 1 items"},
+				{Path: "$.total", Message: "must be >= 0"},
+				{Path: "$.vendor", Message: "expected at least 2 characters"},
+			},
+		},
+		{
+			name:   "length counts characters",
+			schema: invoice,
+			data:   `{"number":"INV-1","total":1,"vendor":"中文供应商"}`,
+		},
+		{
+			name:   "array items",
+			schema: invoice,
+			data:   `{"number":"INV-1","total":1,"tags":["a",2,"c"]}`,
+			want: []FieldError{
+				{Path: "$.tags", Message: "expected at most 2 items"},
+				{Path: "$.tags[1]", Message: "expected string, got integer"},
+			},
+		},
+		{
+			name:   "additional properties not allowed",
+			schema: invoice,
+			data:   `{"number":"INV-1","total":1,"note":"x"}`,
+			want:   []FieldError{{Path: "$.note", Message: "is not allowed"}},
+		},
+		{
+			name:   "additional properties allowed by default",
+			schema: `{"type":"object","properties":{"a":{"type":"string"}}}`,
+			data:   `{"a":"x","b":1}`,
+		},
+		{
+			name:   "additional properties schema",
+			schema: `{"type":"object","properties":{"a":{"type":"string"}},"additionalProperties":{"type":"number"}}`,
+			data:   `{"a":"x","b":1,"c":"two"}`,
+			want:   []FieldError{{Path: "$.c", Message: "expected number, got string"}},
+		},
+		{
+			name:   "numeric enum",
+			schema: `{"enum":[1,2.5]}`,
+			data:   `2.5`,
+		},
+		{
+			name:   "invalid json",
+			schema: invoice,
+			data:   `{"number":`,
+			want:   []FieldError{{Path: "$", Message: "invalid JSON: unexpected EOF"}},
+		},
+		{
+			name:   "trailing data",
+			schema: invoice,
+			data:   `{"number":"INV-1","total":1} {}`,
+			want:   []FieldError{{Path: "$", Message: "unexpected data after JSON value"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := Parse([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			err = schema.Validate([]byte(tt.data))
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() error = %v, want *ValidationError", err)
+			}
+			if !reflect.DeepEqual(validationErr.Errors, tt.want) {
+				t.Fatalf("Validate() errors = %v, want %v", validationErr.Errors, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package queue
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// ErrBatchNotFound 批量任务不存在或已过期
+var ErrBatchNotFound = errors.New("batch not found")
+
+// Batch 批量提交的记录。只保存文件和任务的对应关系，任务状态仍按任务单独保存
+type Batch struct {
+    ID        string       `json:"id"`
+    Policy    string       `json:"policy"`
+    Aborted   bool         `json:"aborted,omitempty"` // abort 策略下有文件失败，其余任务已取消
+    Submitted bool         `json:"submitted,omitempty"` // 所有文件已提交，Items 中的任务 ID 完整
+    Items     []*BatchItem `json:"items"`
+    CreatedAt time.Time    `json:"createdAt"`
+    UpdatedAt time.Time    `json:"updatedAt"`
+}
+
+// BatchItem 批量提交中的一个文件，按提交顺序排列。
+// 提交失败（如校验不通过）时 TaskID 为空，Error 为失败原因
+type BatchItem struct {
+    Index        int    `json:"index"`
+    Filename     string `json:"filename"`
+    FileSize     int64  `json:"fileSize"`
+    TaskID       string `json:"taskId,omitempty"`
+    Skipped      bool   `json:"skipped,omitempty"` // 其他文件失败后未提交
+    Deduplicated bool   `json:"deduplicated,omitempty"`
+    Error        string `json:"error,omitempty"`
+    ErrorClass   string `json:"errorClass,omitempty"`
+}
+
+// SaveBatch 保存批量任务记录，过期时间与任务状态相同
+func (q *AsynqQueue) SaveBatch(ctx context.Context, batch *Batch) error {
+    data, err := json.Marshal(batch)
+    if err != nil {
+        return fmt.Errorf("failed to marshal batch: %w", err)
+    }
+    if err := q.redis.Set(ctx, fmt.Sprintf("batch:%s", batch.ID), data, 24*time.Hour).Err(); err != nil {
+        return fmt.Errorf("failed to save batch: %w", err)
+    }
+    return nil
+}
+
+// GetBatch 读取批量任务记录
+func (q *AsynqQueue) GetBatch(ctx context.Context, batchID string) (*Batch, error) {
+    data, err := q.redis.Get(ctx, fmt.Sprintf("batch:%s", batchID)).Bytes()
+    if err == redis.Nil {
+        return nil, fmt.Errorf("%w: %s", ErrBatchNotFound, batchID)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get batch: %w", err)
+    }
+
+    var batch Batch
+    if err := json.Unmarshal(data, &batch); err != nil {
+        return nil, fmt.Errorf("failed to unmarshal batch: %w", err)
+    }
+    return &batch, nil
+}
@@ -0,0 +1,233 @@
+package queue
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/hibiken/asynq"
+
+    "github.com/feichai0017/document-processor/pkg/logger"
+)
+
+// DeadTask 重试耗尽或被跳过重试后归档的任务
+type DeadTask struct {
+    TaskID       string    `json:"taskId"`
+    Queue        string    `json:"queue"`
+    Type         string    `json:"type"`
+    LastError    string    `json:"lastError"`
+    ErrorClass   string    `json:"errorClass,omitempty"`
+    Retried      int       `json:"retried"`
+    MaxRetry     int       `json:"maxRetry"`
+    LastFailedAt time.Time `json:"lastFailedAt"`
+    Cancelled    bool      `json:"cancelled"` // 用户取消的任务同样会被归档，文件已删除，不能重新入队
+    Task         *Task     `json:"-"`         // 原始任务，payload 解析失败时为 nil
+}
+
+// deadLetterPageSize 逐页读取归档任务时每页的任务数
+const deadLetterPageSize = 500
+
+// restoreDelay 重新入队失败时放回死信队列的任务先以该延迟排期再归档，归档失败也不会立即执行
+const restoreDelay = 24 * time.Hour
+
+// ListDeadTasks 列出所有队列中的归档任务，按最近失败时间倒序分页，page 从 1 开始
+func (q *AsynqQueue) ListDeadTasks(ctx context.Context, page, size int) ([]*DeadTask, int, error) {
+    var tasks []*DeadTask
+    for _, queueName := range allQueues() {
+        for n := 1; ; n++ {
+            infos, err := q.inspector.ListArchivedTasks(queueName, asynq.Page(n), asynq.PageSize(deadLetterPageSize))
+            if err != nil {
+                // 队列还没有任务时 asynq 返回队列不存在
+                if errors.Is(err, asynq.ErrQueueNotFound) {
+                    break
+                }
+                return nil, 0, fmt.Errorf("failed to list archived tasks in %s: %w", queueName, err)
+            }
+            for _, info := range infos {
+                tasks = append(tasks, q.toDeadTask(ctx, queueName, info))
+            }
+            if len(infos) < deadLetterPageSize {
+                break
+            }
+        }
+    }
+
+    sort.Slice(tasks, func(i, j int) bool {
+        return tasks[i].LastFailedAt.After(tasks[j].LastFailedAt)
+    })
+
+    total := len(tasks)
+    if size <= 0 {
+        return tasks, total, nil
+    }
+    if page < 1 {
+        page = 1
+    }
+    start := (page - 1) * size
+    if start >= total {
+        return []*DeadTask{}, total, nil
+    }
+    end := start + size
+    if end > total {
+        end = total
+    }
+    return tasks[start:end], total, nil
+}
+
+// GetDeadTask 查找单个归档任务，任务不存在或未归档时返回错误
+func (q *AsynqQueue) GetDeadTask(ctx context.Context, taskID string) (*DeadTask, error) {
+    queueName, info, err := q.findTask(taskID)
+    if err != nil {
+        return nil, err
+    }
+    if info.State != asynq.TaskStateArchived {
+        return nil, fmt.Errorf("task %s is not in the dead-letter queue (state: %s)", taskID, info.State)
+    }
+    return q.toDeadTask(ctx, queueName, info), nil
+}
+
+// RequeueDeadTask 将归档任务重新入队并清除上次运行留下的状态。
+// update 为 nil 时原样重新运行；否则先修改任务，再以相同 ID 重新入队，
+// 入队失败时将原任务放回死信队列
+func (q *AsynqQueue) RequeueDeadTask(ctx context.Context, taskID string, update func(*Task) error) error {
+    dead, err := q.GetDeadTask(ctx, taskID)
+    if err != nil {
+        return err
+    }
+
+    if update == nil {
+        if err := q.inspector.RunTask(dead.Queue, taskID); err != nil {
+            return fmt.Errorf("failed to requeue task: %w", err)
+        }
+    } else {
+        if dead.Task == nil {
+            return fmt.Errorf("task %s has an unreadable payload", taskID)
+        }
+        // 复制 Payload 和 Metadata，入队失败时用未修改的原任务恢复
+        task := *dead.Task
+        task.Payload = make(map[string]interface{}, len(dead.Task.Payload))
+        for k, v := range dead.Task.Payload {
+            task.Payload[k] = v
+        }
+        task.Metadata = make(map[string]string, len(dead.Task.Metadata))
+        for k, v := range dead.Task.Metadata {
+            task.Metadata[k] = v
+        }
+        if err := update(&task); err != nil {
+            return err
+        }
+        // 任务 ID 在队列中唯一，需要先删除归档的任务
+        if err := q.inspector.DeleteTask(dead.Queue, taskID); err != nil {
+            return fmt.Errorf("failed to remove archived task: %w", err)
+        }
+        if err := q.Enqueue(ctx, &task); err != nil {
+            if restoreErr := q.restoreDeadTask(ctx, dead); restoreErr != nil {
+                q.logger.Error("Failed to restore task to the dead-letter queue",
+                    logger.String("taskId", taskID),
+                    logger.Error(restoreErr),
+                )
+                return fmt.Errorf("failed to requeue task: %w (restoring it also failed: %v)", err, restoreErr)
+            }
+            return fmt.Errorf("failed to requeue task: %w", err)
+        }
+    }
+
+    q.redis.Del(ctx,
+        fmt.Sprintf("task_status:%s", taskID),
+        fmt.Sprintf("task_progress:%s", taskID),
+        fmt.Sprintf("task_cancel:%s", taskID),
+    )
+    pending := &TaskStatus{
+        TaskID:    taskID,
+        Status:    "pending",
+        StartedAt: time.Now(),
+    }
+    if err := q.SaveFinalStatus(ctx, pending); err != nil {
+        return fmt.Errorf("task requeued but failed to reset status: %w", err)
+    }
+    return nil
+}
+
+// restoreDeadTask 将已从死信队列删除的原任务重新归档。
+// asynq 只能归档排队中的任务，因此先延迟入队再归档；上次的错误信息不会保留
+func (q *AsynqQueue) restoreDeadTask(ctx context.Context, dead *DeadTask) error {
+    if err := q.enqueue(ctx, dead.Task, asynq.ProcessIn(restoreDelay)); err != nil {
+        return err
+    }
+    if err := q.inspector.ArchiveTask(dead.Queue, dead.TaskID); err != nil {
+        return fmt.Errorf("failed to archive task: %w", err)
+    }
+    return nil
+}
+
+// PurgeDeadTasks 删除归档任务并返回被删除的任务，taskIDs 为空时清空所有队列的归档任务
+func (q *AsynqQueue) PurgeDeadTasks(ctx context.Context, taskIDs []string) ([]*DeadTask, error) {
+    var targets []*DeadTask
+    if len(taskIDs) == 0 {
+        all, _, err := q.ListDeadTasks(ctx, 0, 0)
+        if err != nil {
+            return nil, err
+        }
+        targets = all
+    } else {
+        for _, id := range taskIDs {
+            dead, err := q.GetDeadTask(ctx, id)
+            if err != nil {
+                return nil, err
+            }
+            targets = append(targets, dead)
+        }
+    }
+
+    purged := make([]*DeadTask, 0, len(targets))
+    for _, dead := range targets {
+        if err := q.inspector.DeleteTask(dead.Queue, dead.TaskID); err != nil {
+            return purged, fmt.Errorf("failed to purge task %s: %w", dead.TaskID, err)
+        }
+        // 保留最终状态，删除后仍可查询任务失败的原因
+        if !dead.Cancelled {
+            status := &TaskStatus{
+                TaskID:     dead.TaskID,
+                Status:     "failed",
+                Error:      dead.LastError,
+                ErrorClass: dead.ErrorClass,
+                FinishedAt: dead.LastFailedAt,
+            }
+            if dead.Task != nil {
+                status.StartedAt = dead.Task.CreatedAt
+            }
+            if err := q.SaveFinalStatus(ctx, status); err != nil {
+                return purged, err
+            }
+        }
+        q.redis.Del(ctx, fmt.Sprintf("task_progress:%s", dead.TaskID))
+        purged = append(purged, dead)
+    }
+    return purged, nil
+}
+
+func (q *AsynqQueue) toDeadTask(ctx context.Context, queueName string, info *asynq.TaskInfo) *DeadTask {
+    dead := &DeadTask{
+        TaskID:       info.ID,
+        Queue:        queueName,
+        Type:         info.Type,
+        LastError:    info.LastErr,
+        Retried:      info.Retried,
+        MaxRetry:     info.MaxRetry,
+        LastFailedAt: info.LastFailedAt,
+    }
+
+    // worker 在结果中记录错误类别和取消状态，取消标记过期后仍可识别
+    result := resultOf(info)
+    dead.ErrorClass = result.ErrorClass
+    dead.Cancelled = result.Status == "cancelled" || q.IsCancelled(ctx, info.ID)
+
+    var task Task
+    if err := json.Unmarshal(info.Payload, &task); err == nil {
+        dead.Task = &task
+    }
+    return dead
+}
@@ -0,0 +1,104 @@
+package queue
+
+import (
+    "context"
+    "fmt"
+    "testing"
+    "time"
+)
+
+// archiveTask 入队并直接归档任务，模拟重试耗尽
+func archiveTask(t *testing.T, q *AsynqQueue, task *Task) {
+    t.Helper()
+
+    if err := q.Enqueue(context.Background(), task); err != nil {
+        t.Fatalf("Enqueue() error = %v", err)
+    }
+    if err := q.inspector.ArchiveTask(QueueName(task.Type, task.Priority), task.ID); err != nil {
+        t.Fatalf("ArchiveTask() error = %v", err)
+    }
+}
+
+func newDeadTask(id string) *Task {
+    return &Task{
+        ID:        id,
+        Type:      TaskTypePDFProcess,
+        Priority:  2,
+        Payload:   map[string]interface{}{"fileId": "uploads/" + id + ".pdf"},
+        Metadata:  map[string]string{"type": ".pdf"},
+        CreatedAt: time.Now(),
+    }
+}
+
+func TestListDeadTasksPagesThroughInspector(t *testing.T) {
+    q, _ := newTestQueue(t)
+
+    count := deadLetterPageSize + 3
+    for i := 0; i < count; i++ {
+        archiveTask(t, q, newDeadTask(fmt.Sprintf("task-%d", i)))
+    }
+
+    tasks, total, err := q.ListDeadTasks(context.Background(), 0, 0)
+    if err != nil {
+        t.Fatalf("ListDeadTasks() error = %v", err)
+    }
+    if total != count || len(tasks) != count {
+        t.Fatalf("ListDeadTasks() = %d tasks, total %d; want %d", len(tasks), total, count)
+    }
+
+    page, total, err := q.ListDeadTasks(context.Background(), 2, deadLetterPageSize)
+    if err != nil {
+        t.Fatalf("ListDeadTasks() error = %v", err)
+    }
+    if total != count || len(page) != 3 {
+        t.Fatalf("second page has %d tasks, total %d; want 3 of %d", len(page), total, count)
+    }
+}
+
+func TestRequeueDeadTaskWithUpdate(t *testing.T) {
+    q, _ := newTestQueue(t)
+    ctx := context.Background()
+    archiveTask(t, q, newDeadTask("task-1"))
+
+    err := q.RequeueDeadTask(ctx, "task-1", func(task *Task) error {
+        task.Payload["options"] = map[string]interface{}{"ocr": true}
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("RequeueDeadTask() error = %v", err)
+    }
+
+    if _, err := q.GetDeadTask(ctx, "task-1"); err == nil {
+        t.Fatal("task is still in the dead-letter queue")
+    }
+    status, err := q.GetTaskStatus(ctx, "task-1")
+    if err != nil {
+        t.Fatalf("GetTaskStatus() error = %v", err)
+    }
+    if status.Status != "pending" {
+        t.Errorf("status = %s, want pending", status.Status)
+    }
+}
+
+func TestRequeueDeadTaskRestoresOnEnqueueFailure(t *testing.T) {
+    q, _ := newTestQueue(t)
+    ctx := context.Background()
+    archiveTask(t, q, newDeadTask("task-1"))
+
+    // 无法序列化的 payload 使入队失败
+    err := q.RequeueDeadTask(ctx, "task-1", func(task *Task) error {
+        task.Payload["options"] = make(chan int)
+        return nil
+    })
+    if err == nil {
+        t.Fatal("RequeueDeadTask() error = nil, want enqueue failure")
+    }
+
+    dead, err := q.GetDeadTask(ctx, "task-1")
+    if err != nil {
+        t.Fatalf("task was lost from the dead-letter queue: %v", err)
+    }
+    if dead.Task == nil || dead.Task.Payload["fileId"] != "uploads/task-1.pdf" || dead.Task.Payload["options"] != nil {
+        t.Errorf("restored task = %+v, want the original payload", dead.Task)
+    }
+}
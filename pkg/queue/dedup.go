@@ -0,0 +1,65 @@
+package queue
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// releaseScript 仅当键仍指向给定值时删除，避免删除已被其他任务接管的键
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// swapScript 仅当键仍指向旧值时改为新值并重置过期时间
+var swapScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+    return 1
+end
+return 0
+`)
+
+// Reserve 原子地将 key 指向 value，用于去重和幂等提交。
+// key 已存在时不覆盖，返回已有的值和 false
+func (q *AsynqQueue) Reserve(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error) {
+    ok, err := q.redis.SetNX(ctx, key, value, ttl).Result()
+    if err != nil {
+        return "", false, fmt.Errorf("failed to reserve %s: %w", key, err)
+    }
+    if ok {
+        return value, true, nil
+    }
+
+    existing, err := q.redis.Get(ctx, key).Result()
+    if err == redis.Nil {
+        // 读取前刚好过期，重新尝试一次
+        return q.Reserve(ctx, key, value, ttl)
+    }
+    if err != nil {
+        return "", false, fmt.Errorf("failed to read %s: %w", key, err)
+    }
+    return existing, false, nil
+}
+
+// Release 删除 Reserve 写入的键，键已指向其他值时不做任何操作
+func (q *AsynqQueue) Release(ctx context.Context, key, value string) error {
+    if err := releaseScript.Run(ctx, q.redis, []string{key}, value).Err(); err != nil && err != redis.Nil {
+        return fmt.Errorf("failed to release %s: %w", key, err)
+    }
+    return nil
+}
+
+// Swap 原子地将仍指向 old 的 key 改为 value，key 已被修改或过期时返回 false
+func (q *AsynqQueue) Swap(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+    swapped, err := swapScript.Run(ctx, q.redis, []string{key}, old, value, ttl.Milliseconds()).Int()
+    if err != nil {
+        return false, fmt.Errorf("failed to swap %s: %w", key, err)
+    }
+    return swapped == 1, nil
+}
@@ -0,0 +1,50 @@
+package queue
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func TestReserveReleaseSwap(t *testing.T) {
+    q, server := newTestQueue(t)
+    ctx := context.Background()
+
+    if value, ok, err := q.Reserve(ctx, "idempotency:k", "task-1:fp", time.Hour); !ok || err != nil || value != "task-1:fp" {
+        t.Fatalf("Reserve() = %q, %v, %v", value, ok, err)
+    }
+    if value, ok, err := q.Reserve(ctx, "idempotency:k", "task-2:fp", time.Hour); ok || err != nil || value != "task-1:fp" {
+        t.Fatalf("second Reserve() = %q, %v, %v; want existing value", value, ok, err)
+    }
+
+    // 旧值不匹配时不修改
+    if swapped, err := q.Swap(ctx, "idempotency:k", "task-2:fp", "task-3:fp", time.Hour); swapped || err != nil {
+        t.Fatalf("Swap() with stale value = %v, %v", swapped, err)
+    }
+    if swapped, err := q.Swap(ctx, "idempotency:k", "task-1:fp", "task-0:fp", 2*time.Hour); !swapped || err != nil {
+        t.Fatalf("Swap() = %v, %v", swapped, err)
+    }
+    if got, _ := server.Get("idempotency:k"); got != "task-0:fp" {
+        t.Errorf("value = %q, want task-0:fp", got)
+    }
+    if ttl := server.TTL("idempotency:k"); ttl != 2*time.Hour {
+        t.Errorf("TTL = %v, want 2h", ttl)
+    }
+
+    // 键已指向其他任务时 Release 不删除
+    if err := q.Release(ctx, "idempotency:k", "task-1:fp"); err != nil {
+        t.Fatalf("Release() error = %v", err)
+    }
+    if !server.Exists("idempotency:k") {
+        t.Fatal("Release() removed a key owned by another task")
+    }
+    if err := q.Release(ctx, "idempotency:k", "task-0:fp"); err != nil {
+        t.Fatalf("Release() error = %v", err)
+    }
+    if server.Exists("idempotency:k") {
+        t.Error("Release() kept the key")
+    }
+    if swapped, err := q.Swap(ctx, "idempotency:k", "task-0:fp", "task-1:fp", time.Hour); swapped || err != nil {
+        t.Fatalf("Swap() on a missing key = %v, %v", swapped, err)
+    }
+}
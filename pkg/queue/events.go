@@ -0,0 +1,97 @@
+package queue
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// taskEventChannel 任务状态变化的 Redis 发布订阅频道
+func taskEventChannel(taskID string) string {
+    return fmt.Sprintf("task_events:%s", taskID)
+}
+
+// PublishTaskStatus 发布任务状态变化，保存状态和进度时自动发布，等待重试等不保存的状态由调用方发布。
+// 没有订阅者时 Redis 直接丢弃，发布失败不影响任务处理，订阅方可以回退到轮询
+func (q *AsynqQueue) PublishTaskStatus(ctx context.Context, status *TaskStatus) {
+    data, err := json.Marshal(status)
+    if err != nil {
+        return
+    }
+    q.redis.Publish(ctx, taskEventChannel(status.TaskID), data)
+}
+
+// SubscribeTaskStatus 订阅任务的状态变化，ctx 结束或调用返回的 close 后停止
+func (q *AsynqQueue) SubscribeTaskStatus(ctx context.Context, taskIDs ...string) (<-chan *TaskStatus, func(), error) {
+    channels := make([]string, len(taskIDs))
+    for i, taskID := range taskIDs {
+        channels[i] = taskEventChannel(taskID)
+    }
+
+    return q.subscribe(ctx, q.redis.Subscribe(ctx, channels...), len(channels))
+}
+
+// SubscribeAllTaskStatus 订阅所有任务的状态变化，用于维护任务索引
+func (q *AsynqQueue) SubscribeAllTaskStatus(ctx context.Context) (<-chan *TaskStatus, func(), error) {
+    return q.subscribe(ctx, q.redis.PSubscribe(ctx, taskEventChannel("*")), 1)
+}
+
+// subscribe 等待每个频道的订阅确认后返回，之后发布的事件不会丢失。
+// 确认之间已经收到的事件先于后续事件发出
+func (q *AsynqQueue) subscribe(ctx context.Context, sub *redis.PubSub, channels int) (<-chan *TaskStatus, func(), error) {
+    var early []*redis.Message
+    for confirmed := 0; confirmed < channels; {
+        msg, err := sub.Receive(ctx)
+        if err != nil {
+            sub.Close()
+            return nil, nil, fmt.Errorf("failed to subscribe task events: %w", err)
+        }
+        switch m := msg.(type) {
+        case *redis.Subscription:
+            if m.Kind == "subscribe" || m.Kind == "psubscribe" {
+                confirmed++
+            }
+        case *redis.Message:
+            early = append(early, m)
+        }
+    }
+
+    events := make(chan *TaskStatus, 16)
+    go func() {
+        defer close(events)
+        forward := func(msg *redis.Message) bool {
+            var status TaskStatus
+            if err := json.Unmarshal([]byte(msg.Payload), &status); err != nil {
+                return true
+            }
+            select {
+            case events <- &status:
+                return true
+            case <-ctx.Done():
+                return false
+            }
+        }
+
+        for _, msg := range early {
+            if !forward(msg) {
+                return
+            }
+        }
+        messages := sub.Channel()
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case msg, ok := <-messages:
+                if !ok || !forward(msg) {
+                    return
+                }
+            }
+        }
+    }()
+
+    return events, func() { sub.Close() }, nil
+}
+
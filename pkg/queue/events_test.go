@@ -0,0 +1,39 @@
+package queue
+
+import (
+    "context"
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestSubscribeTaskStatusWaitsForEveryChannel(t *testing.T) {
+    q, _ := newTestQueue(t)
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    taskIDs := make([]string, 20)
+    for i := range taskIDs {
+        taskIDs[i] = fmt.Sprintf("task-%d", i)
+    }
+    events, closeSub, err := q.SubscribeTaskStatus(ctx, taskIDs...)
+    if err != nil {
+        t.Fatalf("SubscribeTaskStatus() error = %v", err)
+    }
+    defer closeSub()
+
+    // 返回后立即发布到最后一个频道，事件不能丢失
+    for i := len(taskIDs) - 1; i >= 0; i-- {
+        q.PublishTaskStatus(ctx, &TaskStatus{TaskID: taskIDs[i], Status: "completed"})
+    }
+
+    seen := map[string]bool{}
+    for len(seen) < len(taskIDs) {
+        select {
+        case status := <-events:
+            seen[status.TaskID] = true
+        case <-ctx.Done():
+            t.Fatalf("received %d of %d events", len(seen), len(taskIDs))
+        }
+    }
+}
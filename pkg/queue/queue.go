@@ -4,11 +4,15 @@ package queue
 import (
     "context"
     "encoding/json"
+    "errors"
     "fmt"
     "time"
     
     "github.com/hibiken/asynq"
     "github.com/redis/go-redis/v9"
+
+    "github.com/feichai0017/document-processor/config"
+    "github.com/feichai0017/document-processor/pkg/logger"
 )
 
 // TaskType 定义任务类型
@@ -19,12 +23,40 @@ const (
     TaskTypeWordProcess   = "word:process"
 )
 
+// ErrTaskNotFound 任务不在任何队列中，且没有保存的最终状态（已被清理或过期）
+var ErrTaskNotFound = errors.New("task not found")
+
 // Queue 接口定义
 type Queue interface {
     Enqueue(ctx context.Context, task *Task) error
     GetTaskStatus(ctx context.Context, taskID string) (*TaskStatus, error)
-    CancelTask(ctx context.Context, taskID string) error
+    CancelTask(ctx context.Context, taskID string) (*CancelResult, error)
+    IsCancelled(ctx context.Context, taskID string) bool
     SaveFinalStatus(ctx context.Context, status *TaskStatus) error
+    SetTaskMetadata(ctx context.Context, taskID string, metadata map[string]string) error
+
+    // 死信队列：重试耗尽后归档的任务
+    ListDeadTasks(ctx context.Context, page, size int) ([]*DeadTask, int, error)
+    GetDeadTask(ctx context.Context, taskID string) (*DeadTask, error)
+    RequeueDeadTask(ctx context.Context, taskID string, update func(*Task) error) error
+    PurgeDeadTasks(ctx context.Context, taskIDs []string) ([]*DeadTask, error)
+
+    // 去重和幂等提交使用的键
+    Reserve(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error)
+    Release(ctx context.Context, key, value string) error
+    Swap(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error)
+
+    // 回调投递
+    EnqueueWebhook(ctx context.Context, deliveryID string, maxAttempts int) error
+
+    // 状态推送：保存状态和进度时发布，订阅方据此推送给客户端
+    PublishTaskStatus(ctx context.Context, status *TaskStatus)
+    SubscribeTaskStatus(ctx context.Context, taskIDs ...string) (<-chan *TaskStatus, func(), error)
+    SubscribeAllTaskStatus(ctx context.Context) (<-chan *TaskStatus, func(), error)
+
+    // 批量提交的记录
+    SaveBatch(ctx context.Context, batch *Batch) error
+    GetBatch(ctx context.Context, batchID string) (*Batch, error)
 }
 
 // Task 定义任务结构
@@ -39,12 +71,35 @@ type Task struct {
 
 // TaskStatus 定义任务状态
 type TaskStatus struct {
-    TaskID     string    `json:"taskId"`
-    Status     string    `json:"status"`
-    Progress   float64   `json:"progress"`
-    Error      string    `json:"error,omitempty"`
-    StartedAt  time.Time `json:"startedAt"`
-    FinishedAt time.Time `json:"finishedAt,omitempty"`
+    TaskID     string            `json:"taskId"`
+    Status     string            `json:"status"`
+    Progress   float64           `json:"progress"`
+    Error      string            `json:"error,omitempty"`
+    ErrorClass string            `json:"errorClass,omitempty"` // 错误类别，如 corrupt_input、transient
+    StartedAt  time.Time         `json:"startedAt"`
+    FinishedAt time.Time         `json:"finishedAt,omitempty"`
+    Metadata   map[string]string `json:"metadata,omitempty"` // 处理过程中写入的任务信息，如文档类型
+    Detail     *TaskProgress     `json:"detail,omitempty"`   // 处理器报告的进度
+}
+
+// TaskProgress 处理过程中报告的进度
+type TaskProgress struct {
+    Stage      string    `json:"stage"`
+    PagesDone  int       `json:"pagesDone"`
+    PagesTotal int       `json:"pagesTotal"` // 0 表示未知
+    Progress   float64   `json:"progress"`   // 0-1，整体进度
+    StartedAt  time.Time `json:"startedAt"`  // 开始处理的时间
+    UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// EstimatedCompletion 按开始以来的平均速度估算完成时间，进度太少时无法估算
+func (p *TaskProgress) EstimatedCompletion() (time.Time, bool) {
+    if p == nil || p.Progress < 0.1 || p.Progress >= 1 || p.StartedAt.IsZero() {
+        return time.Time{}, false
+    }
+    elapsed := p.UpdatedAt.Sub(p.StartedAt)
+    remaining := time.Duration(float64(elapsed) * (1 - p.Progress) / p.Progress)
+    return p.UpdatedAt.Add(remaining), true
 }
 
 // AsynqQueue 实现
@@ -53,6 +108,9 @@ type AsynqQueue struct {
     inspector *asynq.Inspector
     server    *asynq.Server
     redis     *redis.Client
+    timeouts  map[string]time.Duration // 按任务类型简称索引
+    timeout   time.Duration
+    logger    logger.Logger
 }
 
 // QueueConfig 定义队列配置
@@ -63,10 +121,18 @@ type QueueConfig struct {
     RetryDelay     time.Duration
     ProcessTimeout time.Duration
     Concurrency    int
+    // TaskTimeouts 按任务类型简称（image、pdf 等）覆盖 ProcessTimeout
+    TaskTimeouts map[string]time.Duration
+    Logger       logger.Logger
 }
 
 // GetQueue 获取队列实例
-func GetQueue() (*AsynqQueue, error) {
+func GetQueue(log logger.Logger) (*AsynqQueue, error) {
+    timeouts := make(map[string]time.Duration)
+    for kind, cfg := range config.GetQueueConfig().TaskTypes {
+        timeouts[kind] = cfg.Timeout
+    }
+
     asynqQueue, err := NewAsynqQueue(&QueueConfig{
         RedisAddr:      "localhost:6379",
         RedisDB:        0,
@@ -74,6 +140,8 @@ func GetQueue() (*AsynqQueue, error) {
         RetryDelay:     1 * time.Minute,
         ProcessTimeout: 30 * time.Minute,
         Concurrency:    5,
+        TaskTimeouts:   timeouts,
+        Logger:         log,
     })
     if err != nil {
         return nil, err
@@ -119,11 +187,24 @@ func NewAsynqQueue(cfg *QueueConfig) (*AsynqQueue, error) {
         inspector: inspector,
         server:    server,
         redis:     redisClient,
+        timeouts:  cfg.TaskTimeouts,
+        timeout:   cfg.ProcessTimeout,
+        logger:    cfg.Logger,
     }, nil
 }
 
+// RedisClient 返回队列使用的 Redis 客户端，供缓存等组件复用连接
+func (q *AsynqQueue) RedisClient() *redis.Client {
+    return q.redis
+}
+
 // Enqueue 将任务加入队列
 func (q *AsynqQueue) Enqueue(ctx context.Context, task *Task) error {
+    return q.enqueue(ctx, task)
+}
+
+// enqueue 入队任务，extra 中的选项覆盖默认选项
+func (q *AsynqQueue) enqueue(ctx context.Context, task *Task, extra ...asynq.Option) error {
     // 序列化整个任务
     payload, err := json.Marshal(task)
     if err != nil {
@@ -134,19 +215,12 @@ func (q *AsynqQueue) Enqueue(ctx context.Context, task *Task) error {
     opts := []asynq.Option{
         asynq.ProcessIn(time.Second),
         asynq.MaxRetry(3),
-        asynq.Timeout(30 * time.Minute),
+        asynq.Timeout(q.taskTimeout(task.Type)),
         asynq.TaskID(task.ID),
+        // 每种任务类型使用独立的队列，再按优先级划分
+        asynq.Queue(QueueName(task.Type, task.Priority)),
     }
-
-    // 根据优先选择队列
-    switch task.Priority {
-    case 1:
-        opts = append(opts, asynq.Queue("critical"))
-    case 2:
-        opts = append(opts, asynq.Queue("default"))
-    default:
-        opts = append(opts, asynq.Queue("low"))
-    }
+    opts = append(opts, extra...)
 
     // 创建并入队任务
     t := asynq.NewTask(task.Type, payload, opts...)
@@ -161,6 +235,17 @@ func (q *AsynqQueue) Enqueue(ctx context.Context, task *Task) error {
     return nil
 }
 
+// taskTimeout 返回任务类型的处理超时，未配置时使用 ProcessTimeout
+func (q *AsynqQueue) taskTimeout(taskType string) time.Duration {
+    if timeout, ok := q.timeouts[TaskKind(taskType)]; ok && timeout > 0 {
+        return timeout
+    }
+    if q.timeout > 0 {
+        return q.timeout
+    }
+    return 30 * time.Minute
+}
+
 // GetTaskStatus 获取任务状态
 func (q *AsynqQueue) GetTaskStatus(ctx context.Context, taskID string) (*TaskStatus, error) {
     // 首先尝试从 Redis 获取状态
@@ -170,57 +255,130 @@ func (q *AsynqQueue) GetTaskStatus(ctx context.Context, taskID string) (*TaskSta
         return nil, fmt.Errorf("failed to get status from redis: %w", err)
     }
 
+    var saved *TaskStatus
     if err == nil {
-        // 如果找到了保存的状态，直接返回
-        var status TaskStatus
-        if err := json.Unmarshal(data, &status); err != nil {
+        saved = &TaskStatus{}
+        if err := json.Unmarshal(data, saved); err != nil {
             return nil, fmt.Errorf("failed to unmarshal status: %w", err)
         }
-        return &status, nil
+        // 已结束的状态直接返回，未结束的以队列中的实际状态为准
+        if isFinalStatus(saved.Status) {
+            q.loadTaskMetadata(ctx, saved)
+            q.loadTaskProgress(ctx, saved)
+            return saved, nil
+        }
     }
 
-    // 如果 Redis 中没有，从所有队列中查找
-    queues := []string{"critical", "default", "low"}
-    var info *asynq.TaskInfo
-    var lastErr error
+    // 从所有队列中查找
+    _, info, err := q.findTask(taskID)
+    if err != nil {
+        if saved != nil {
+            // 任务已被 asynq 清理，返回保存的状态
+            q.loadTaskMetadata(ctx, saved)
+            q.loadTaskProgress(ctx, saved)
+            return saved, nil
+        }
+        return nil, err
+    }
 
-    for _, queueName := range queues {
-        info, err = q.inspector.GetTaskInfo(queueName, taskID)
-        if err == nil {
-            break
+    status := convertAsynqStatus(info)
+    if saved != nil && !saved.StartedAt.IsZero() {
+        status.StartedAt = saved.StartedAt
+    }
+    q.loadTaskMetadata(ctx, status)
+    q.loadTaskProgress(ctx, status)
+
+    // 只保存最终状态，处理中的任务每次都从队列读取
+    if isFinalStatus(status.Status) {
+        if err := q.SaveFinalStatus(ctx, status); err != nil {
+            q.logger.Warn("Failed to save final task status",
+                logger.String("taskId", taskID),
+                logger.Error(err),
+            )
         }
-        lastErr = err
     }
 
-    if lastErr != nil {
-        return nil, fmt.Errorf("task not found in any queue: %w", lastErr)
+    return status, nil
+}
+
+func isFinalStatus(status string) bool {
+    switch status {
+    case "completed", "failed", "cancelled":
+        return true
     }
+    return false
+}
 
-    status := convertAsynqStatus(info)
-    
-    // 保存状态到 Redis
-    if err := q.SaveFinalStatus(ctx, status); err != nil {
-        fmt.Printf("Failed to save status for task %s: %v\n", taskID, err)
+// CancelResult 取消结果
+type CancelResult struct {
+    Task   *Task // 被取消的任务，用于清理已上传的文件
+    Active bool  // 任务正在处理，已通知 worker 中止，清理和状态由 worker 完成
+}
+
+// CancelTask 取消任务：排队中的任务直接删除，处理中的任务通知 worker 中止。
+// 两种情况都会记录取消标记，worker 据此区分取消和超时、关闭等其他中断
+func (q *AsynqQueue) CancelTask(ctx context.Context, taskID string) (*CancelResult, error) {
+    queueName, info, err := q.findTask(taskID)
+    if err != nil {
+        return nil, err
     }
 
-    return status, nil
+    result := &CancelResult{}
+    var task Task
+    if err := json.Unmarshal(info.Payload, &task); err == nil {
+        result.Task = &task
+    }
+
+    if err := q.redis.Set(ctx, fmt.Sprintf("task_cancel:%s", taskID), "1", 24*time.Hour).Err(); err != nil {
+        return nil, fmt.Errorf("failed to mark task as cancelled: %w", err)
+    }
+
+    switch info.State {
+    case asynq.TaskStateActive:
+        if err := q.inspector.CancelProcessing(taskID); err != nil {
+            return nil, fmt.Errorf("failed to cancel processing: %w", err)
+        }
+        result.Active = true
+        return result, nil
+    case asynq.TaskStateCompleted, asynq.TaskStateArchived:
+        q.redis.Del(ctx, fmt.Sprintf("task_cancel:%s", taskID))
+        return nil, fmt.Errorf("task %s has already finished", taskID)
+    }
+
+    if err := q.inspector.DeleteTask(queueName, taskID); err != nil {
+        // 删除前 worker 可能刚好开始处理
+        if info, infoErr := q.inspector.GetTaskInfo(queueName, taskID); infoErr == nil && info.State == asynq.TaskStateActive {
+            if err := q.inspector.CancelProcessing(taskID); err != nil {
+                return nil, fmt.Errorf("failed to cancel processing: %w", err)
+            }
+            result.Active = true
+            return result, nil
+        }
+        return nil, fmt.Errorf("failed to cancel task: %w", err)
+    }
+
+    return result, nil
 }
 
-// CancelTask 取消任务
-func (q *AsynqQueue) CancelTask(ctx context.Context, taskID string) error {
-    // 尝试在所有队列中取消任务
-    queues := []string{"critical", "default", "low"}
-    var lastErr error
-    
-    for _, queue := range queues {
-        err := q.inspector.DeleteTask(queue, taskID)
+// IsCancelled 任务是否已被请求取消
+func (q *AsynqQueue) IsCancelled(ctx context.Context, taskID string) bool {
+    n, err := q.redis.Exists(ctx, fmt.Sprintf("task_cancel:%s", taskID)).Result()
+    return err == nil && n > 0
+}
+
+// findTask 在所有队列中查找任务
+func (q *AsynqQueue) findTask(taskID string) (string, *asynq.TaskInfo, error) {
+    for _, queueName := range allQueues() {
+        info, err := q.inspector.GetTaskInfo(queueName, taskID)
         if err == nil {
-            return nil
+            return queueName, info, nil
+        }
+        // Redis 故障等错误与任务不存在区分开，调用方不能据此认为任务已过期
+        if !errors.Is(err, asynq.ErrTaskNotFound) && !errors.Is(err, asynq.ErrQueueNotFound) {
+            return "", nil, fmt.Errorf("failed to look up task %s: %w", taskID, err)
         }
-        lastErr = err
     }
-
-    return fmt.Errorf("failed to cancel task: %w", lastErr)
+    return "", nil, fmt.Errorf("%w in any queue: %s", ErrTaskNotFound, taskID)
 }
 
 // SaveFinalStatus 保存最终任务状态
@@ -237,10 +395,72 @@ func (q *AsynqQueue) SaveFinalStatus(ctx context.Context, status *TaskStatus) er
     if err != nil {
         return fmt.Errorf("failed to save status: %w", err)
     }
-    
+
+    q.PublishTaskStatus(ctx, status)
     return nil
 }
 
+// SetTaskMetadata 合并写入任务信息，与任务状态一起返回
+func (q *AsynqQueue) SetTaskMetadata(ctx context.Context, taskID string, metadata map[string]string) error {
+    if len(metadata) == 0 {
+        return nil
+    }
+
+    key := fmt.Sprintf("task_meta:%s", taskID)
+    values := make(map[string]interface{}, len(metadata))
+    for k, v := range metadata {
+        values[k] = v
+    }
+
+    pipe := q.redis.TxPipeline()
+    pipe.HSet(ctx, key, values)
+    pipe.Expire(ctx, key, 24*time.Hour)
+    if _, err := pipe.Exec(ctx); err != nil {
+        return fmt.Errorf("failed to save task metadata: %w", err)
+    }
+
+    return nil
+}
+
+// loadTaskProgress 读取升级前的 worker 写入的处理进度，未结束的任务使用其中的整体进度。
+// 现在的进度保存在任务仓库中。
+// TODO: 升级前提交的任务都已结束并过期后删除，同时删除 task_progress 键的清理
+func (q *AsynqQueue) loadTaskProgress(ctx context.Context, status *TaskStatus) {
+    data, err := q.redis.Get(ctx, fmt.Sprintf("task_progress:%s", status.TaskID)).Bytes()
+    if err != nil {
+        return
+    }
+
+    var progress TaskProgress
+    if err := json.Unmarshal(data, &progress); err != nil {
+        return
+    }
+    status.Detail = &progress
+
+    switch status.Status {
+    case "completed", "failed", "cancelled":
+    default:
+        // 已开始报告进度说明任务正在处理
+        status.Status = "running"
+        status.Progress = progress.Progress
+    }
+}
+
+// loadTaskMetadata 读取任务信息，读取失败时保持状态不变
+func (q *AsynqQueue) loadTaskMetadata(ctx context.Context, status *TaskStatus) {
+    metadata, err := q.redis.HGetAll(ctx, fmt.Sprintf("task_meta:%s", status.TaskID)).Result()
+    if err != nil || len(metadata) == 0 {
+        return
+    }
+
+    if status.Metadata == nil {
+        status.Metadata = make(map[string]string, len(metadata))
+    }
+    for k, v := range metadata {
+        status.Metadata[k] = v
+    }
+}
+
 // convertAsynqStatus 将 asynq 状态转换为 TaskStatus
 func convertAsynqStatus(info *asynq.TaskInfo) *TaskStatus {
     status := &TaskStatus{
@@ -250,19 +470,40 @@ func convertAsynqStatus(info *asynq.TaskInfo) *TaskStatus {
     }
 
     switch info.State {
-    case asynq.TaskStatePending:
+    case asynq.TaskStatePending, asynq.TaskStateScheduled:
         status.Status = "pending"
     case asynq.TaskStateActive:
         status.Status = "running"
-        status.Progress = 0.5
     case asynq.TaskStateCompleted:
         status.Status = "completed"
         status.Progress = 1.0
         status.FinishedAt = info.CompletedAt
     case asynq.TaskStateRetry:
+        // 等待重试，尚未最终失败
+        status.Status = "pending"
+        status.Error = info.LastErr
+        status.ErrorClass = resultOf(info).ErrorClass
+    case asynq.TaskStateArchived:
+        // 重试耗尽或永久错误，进入死信队列
         status.Status = "failed"
         status.Error = info.LastErr
+        status.ErrorClass = resultOf(info).ErrorClass
+        status.FinishedAt = info.LastFailedAt
     }
 
     return status
-}
\ No newline at end of file
+}
+// taskResult worker 通过 ResultWriter 写入的任务结果
+type taskResult struct {
+    Status     string `json:"status"`
+    Error      string `json:"error,omitempty"`
+    ErrorClass string `json:"errorClass,omitempty"`
+}
+
+func resultOf(info *asynq.TaskInfo) taskResult {
+    var result taskResult
+    if len(info.Result) > 0 {
+        json.Unmarshal(info.Result, &result)
+    }
+    return result
+}
@@ -0,0 +1,28 @@
+package queue
+
+import (
+    "testing"
+
+    "github.com/alicebob/miniredis/v2"
+
+    "github.com/feichai0017/document-processor/pkg/logger"
+)
+
+func newTestQueue(t *testing.T) (*AsynqQueue, *miniredis.Miniredis) {
+    t.Helper()
+
+    server := miniredis.RunT(t)
+    q, err := NewAsynqQueue(&QueueConfig{
+        RedisAddr: server.Addr(),
+        Logger:    logger.NewTestLogger(),
+    })
+    if err != nil {
+        t.Fatalf("NewAsynqQueue() error = %v", err)
+    }
+    t.Cleanup(func() {
+        q.client.Close()
+        q.inspector.Close()
+        q.redis.Close()
+    })
+    return q, server
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+    "strings"
+)
+
+// TaskTypes 所有文档任务类型，每种类型使用独立的队列
+var TaskTypes = []string{
+    TaskTypeImageProcess,
+    TaskTypePDFProcess,
+    TaskTypeWordProcess,
+    TaskTypeDocumentProcess,
+}
+
+// priorityQueues 同一任务类型内按优先级划分的队列及其权重
+var priorityQueues = []struct {
+    name   string
+    weight int
+}{
+    {"critical", 6},
+    {"default", 3},
+    {"low", 1},
+}
+
+// extTaskTypes 文件扩展名对应的任务类型，未列出的使用 document:process
+var extTaskTypes = map[string]string{
+    ".jpg":  TaskTypeImageProcess,
+    ".jpeg": TaskTypeImageProcess,
+    ".png":  TaskTypeImageProcess,
+    ".tiff": TaskTypeImageProcess,
+    ".tif":  TaskTypeImageProcess,
+    ".pdf":  TaskTypePDFProcess,
+    ".doc":  TaskTypeWordProcess,
+    ".docx": TaskTypeWordProcess,
+}
+
+// TaskTypeFor 按文件扩展名选择任务类型，图像 OCR 与 PDF 文本提取进入不同的队列
+func TaskTypeFor(ext string) string {
+    if taskType, ok := extTaskTypes[strings.ToLower(ext)]; ok {
+        return taskType
+    }
+    return TaskTypeDocumentProcess
+}
+
+// TaskKind 返回任务类型的简称，如 image:process 返回 image，未知类型返回 document
+func TaskKind(taskType string) string {
+    for _, t := range TaskTypes {
+        if t == taskType {
+            return strings.SplitN(taskType, ":", 2)[0]
+        }
+    }
+    return "document"
+}
+
+// QueueName 返回任务类型和优先级对应的队列，如 image-critical
+func QueueName(taskType string, priority int) string {
+    name := "low"
+    switch priority {
+    case 1:
+        name = "critical"
+    case 2:
+        name = "default"
+    }
+    return TaskKind(taskType) + "-" + name
+}
+
+// QueuesFor 返回任务类型的所有队列及权重，供 worker 配置 asynq 服务器。
+// document 类型同时处理按优先级划分的旧队列，升级前入队的任务仍会被处理
+func QueuesFor(taskType string) map[string]int {
+    if taskType == TaskTypeWebhookDeliver {
+        return map[string]int{WebhookQueue: 1}
+    }
+    kind := TaskKind(taskType)
+    queues := make(map[string]int, len(priorityQueues)*2)
+    for _, q := range priorityQueues {
+        queues[kind+"-"+q.name] = q.weight
+        if kind == "document" {
+            queues[q.name] = q.weight
+        }
+    }
+    return queues
+}
+
+// allQueues 返回所有任务类型的队列以及旧队列，用于按任务 ID 查找任务
+func allQueues() []string {
+    var queues []string
+    for _, taskType := range TaskTypes {
+        kind := TaskKind(taskType)
+        for _, q := range priorityQueues {
+            queues = append(queues, kind+"-"+q.name)
+        }
+    }
+    for _, q := range priorityQueues {
+        queues = append(queues, q.name)
+    }
+    return queues
+}
@@ -0,0 +1,52 @@
+package queue
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+
+    "github.com/hibiken/asynq"
+)
+
+// TaskTypeWebhookDeliver 回调投递任务，每次执行发送一次，失败时由 asynq 按退避重试，
+// worker 重启后未完成的重试仍在队列中
+const TaskTypeWebhookDeliver = "webhook:deliver"
+
+// WebhookQueue 回调投递使用独立的队列，不与文档任务争抢并发，也不出现在死信队列列表中
+const WebhookQueue = "webhook"
+
+type webhookPayload struct {
+    DeliveryID string `json:"deliveryId"`
+}
+
+// EnqueueWebhook 入队一条投递记录的发送任务，最多发送 maxAttempts 次
+func (q *AsynqQueue) EnqueueWebhook(ctx context.Context, deliveryID string, maxAttempts int) error {
+    payload, err := json.Marshal(webhookPayload{DeliveryID: deliveryID})
+    if err != nil {
+        return fmt.Errorf("failed to marshal webhook task: %w", err)
+    }
+    if maxAttempts < 1 {
+        maxAttempts = 1
+    }
+
+    t := asynq.NewTask(TaskTypeWebhookDeliver, payload,
+        asynq.Queue(WebhookQueue),
+        asynq.MaxRetry(maxAttempts-1),
+    )
+    if _, err := q.client.EnqueueContext(ctx, t); err != nil {
+        return fmt.Errorf("failed to enqueue webhook delivery: %w", err)
+    }
+    return nil
+}
+
+// WebhookDeliveryID 读取回调投递任务中的投递记录 ID
+func WebhookDeliveryID(t *asynq.Task) (string, error) {
+    var payload webhookPayload
+    if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+        return "", fmt.Errorf("failed to unmarshal webhook task: %w", err)
+    }
+    if payload.DeliveryID == "" {
+        return "", fmt.Errorf("webhook task has no delivery id")
+    }
+    return payload.DeliveryID, nil
+}
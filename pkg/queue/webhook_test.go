@@ -0,0 +1,32 @@
+package queue
+
+import (
+    "context"
+    "testing"
+
+    "github.com/hibiken/asynq"
+)
+
+func TestEnqueueWebhook(t *testing.T) {
+    q, _ := newTestQueue(t)
+
+    if err := q.EnqueueWebhook(context.Background(), "delivery-1", 3); err != nil {
+        t.Fatalf("EnqueueWebhook() error = %v", err)
+    }
+
+    tasks, err := q.inspector.ListPendingTasks(WebhookQueue)
+    if err != nil || len(tasks) != 1 {
+        t.Fatalf("pending webhook tasks = %v, %v", tasks, err)
+    }
+    if tasks[0].Type != TaskTypeWebhookDeliver || tasks[0].MaxRetry != 2 {
+        t.Errorf("task = %s, max retry %d; want %s, 2", tasks[0].Type, tasks[0].MaxRetry, TaskTypeWebhookDeliver)
+    }
+
+    id, err := WebhookDeliveryID(asynq.NewTask(tasks[0].Type, tasks[0].Payload))
+    if err != nil || id != "delivery-1" {
+        t.Errorf("WebhookDeliveryID() = %q, %v", id, err)
+    }
+    if _, err := WebhookDeliveryID(asynq.NewTask(TaskTypeWebhookDeliver, []byte(`{}`))); err == nil {
+        t.Error("WebhookDeliveryID() accepted a task without a delivery id")
+    }
+}
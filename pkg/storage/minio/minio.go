@@ -2,6 +2,7 @@ package minio
 
 import (
     "context"
+    "errors"
     "fmt"
     "io"
     "time"
@@ -13,6 +14,9 @@ import (
     "github.com/feichai0017/document-processor/pkg/logger"
 )
 
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("file not found")
+
 type MinioStorage struct {
     client     *minio.Client
     bucketName string
@@ -46,6 +50,24 @@ func (m *MinioStorage) Get(ctx context.Context, key string) (io.ReadCloser, erro
         return nil, fmt.Errorf("failed to get file: %w", err)
     }
 
+    // GetObject 不会请求服务端，通过 Stat 区分对象不存在和其他错误
+    if _, err := obj.Stat(); err != nil {
+        obj.Close()
+        if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+            m.logger.Debug("File not found in MinIO",
+                logger.String("bucket", m.bucketName),
+                logger.String("key", key),
+            )
+            return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+        }
+        m.logger.Error("Failed to get file from MinIO",
+            logger.String("bucket", m.bucketName),
+            logger.String("key", key),
+            logger.Error(err),
+        )
+        return nil, fmt.Errorf("failed to get file: %w", err)
+    }
+
     return obj, nil
 }
 
@@ -2,12 +2,14 @@ package s3
 
 import (
     "context"
+    "errors"
     "fmt"
     "io"
     "time"
     
     "github.com/aws/aws-sdk-go-v2/aws"
     "github.com/aws/aws-sdk-go-v2/service/s3"
+    "github.com/aws/aws-sdk-go-v2/service/s3/types"
     "github.com/aws/aws-sdk-go-v2/config"
     "github.com/aws/aws-sdk-go-v2/credentials"
     
@@ -15,6 +17,9 @@ import (
     "github.com/feichai0017/document-processor/pkg/logger"
 )
 
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("file not found")
+
 type S3Storage struct {
     client     *s3.Client
     bucketName string
@@ -51,6 +56,15 @@ func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error)
     }
 
     result, err := s.client.GetObject(ctx, input)
+    var noSuchKey *types.NoSuchKey
+    if errors.As(err, &noSuchKey) {
+        // 缓存等调用方会频繁查询不存在的对象，不作为错误记录
+        s.logger.Debug("File not found in S3",
+            logger.String("bucket", s.bucketName),
+            logger.String("key", key),
+        )
+        return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+    }
     if err != nil {
         s.logger.Error("Failed to get file from S3",
             logger.String("bucket", s.bucketName),
@@ -121,8 +135,12 @@ func (s *S3Storage) CleanupBefore(ctx context.Context, threshold time.Time) erro
 }
 
 func NewS3Storage(log logger.Logger) (*S3Storage, error) {
-    s3Config := cfg.GetS3Config()
-    
+    return NewS3StorageWithConfig(log, cfg.GetS3Config())
+}
+
+// NewS3StorageWithConfig 使用指定配置创建 S3 存储，
+// 设置 Endpoint 时使用 path-style 访问（如 MinIO 或本地 S3 替身）
+func NewS3StorageWithConfig(log logger.Logger, s3Config *cfg.S3Config) (*S3Storage, error) {
     log.Info("S3 Configuration",
         logger.String("bucket", s3Config.BucketName),
         logger.String("region", s3Config.Region),
@@ -143,7 +161,12 @@ func NewS3Storage(log logger.Logger) (*S3Storage, error) {
         return nil, fmt.Errorf("failed to load AWS config: %w", err)
     }
 
-    client := s3.NewFromConfig(awsCfg)
+    client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+        if s3Config.Endpoint != "" {
+            o.BaseEndpoint = aws.String(s3Config.Endpoint)
+            o.UsePathStyle = true
+        }
+    })
     
     // 验证 bucket 是否存在
     _, err = client.HeadBucket(context.Background(), &s3.HeadBucketInput{
@@ -2,6 +2,7 @@ package storage
 
 import (
     "context"
+    "errors"
     "fmt"
     "io"
     "time"
@@ -32,6 +33,11 @@ type Storage interface {
 }
 
 
+// IsNotFound 判断 Get 返回的错误是否表示文件不存在
+func IsNotFound(err error) bool {
+    return errors.Is(err, s3.ErrNotFound) || errors.Is(err, minio.ErrNotFound)
+}
+
 // NewStorage 创建存储实例的工厂方法
 func NewStorage(storageType StorageType, logger logger.Logger) (Storage, error) {
     switch storageType {
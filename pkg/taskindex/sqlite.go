@@ -0,0 +1,281 @@
+package taskindex
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS tasks (
+	task_id     TEXT PRIMARY KEY,
+	batch_id    TEXT NOT NULL DEFAULT '',
+	tenant      TEXT NOT NULL DEFAULT '',
+	filename    TEXT NOT NULL DEFAULT '',
+	file_type   TEXT NOT NULL DEFAULT '',
+	kind        TEXT NOT NULL DEFAULT '',
+	size        INTEGER NOT NULL DEFAULT 0,
+	status      TEXT NOT NULL,
+	error       TEXT NOT NULL DEFAULT '',
+	error_class TEXT NOT NULL DEFAULT '',
+	created_at  INTEGER NOT NULL,
+	updated_at  INTEGER NOT NULL,
+	finished_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS tasks_created ON tasks (created_at, task_id);
+CREATE INDEX IF NOT EXISTS tasks_updated ON tasks (updated_at, task_id);
+CREATE INDEX IF NOT EXISTS tasks_status ON tasks (status, updated_at);
+CREATE INDEX IF NOT EXISTS tasks_tenant ON tasks (tenant, created_at);
+CREATE INDEX IF NOT EXISTS tasks_batch ON tasks (batch_id);
+`
+
+// sortColumns 排序字段对应的列
+var sortColumns = map[SortField]string{
+	SortCreatedAt: "created_at",
+	SortUpdatedAt: "updated_at",
+}
+
+// SQLite 基于嵌入式 SQLite 的索引，不需要额外的服务
+type SQLite struct {
+	db *sql.DB
+}
+
+// NewSQLite 打开或创建 path 处的数据库
+func NewSQLite(path string) (*SQLite, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create task index directory: %w", err)
+		}
+	}
+
+	// WAL 模式下列表查询不会阻塞状态更新
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open task index: %w", err)
+	}
+	// SQLite 同时只允许一个写入者
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create task index schema: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) Put(ctx context.Context, e *Entry) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO tasks (task_id, batch_id, tenant, filename, file_type, kind, size,
+			status, error, error_class, created_at, updated_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (task_id) DO UPDATE SET
+			batch_id = excluded.batch_id, tenant = excluded.tenant, filename = excluded.filename,
+			file_type = excluded.file_type, kind = excluded.kind, size = excluded.size,
+			status = excluded.status, error = excluded.error, error_class = excluded.error_class,
+			created_at = excluded.created_at, updated_at = excluded.updated_at,
+			finished_at = excluded.finished_at`,
+		e.TaskID, e.BatchID, e.Tenant, e.Filename, e.FileType, e.Kind, e.Size,
+		e.Status, e.Error, e.ErrorClass,
+		unixNano(e.CreatedAt), unixNano(e.UpdatedAt), unixNano(e.FinishedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to index task %s: %w", e.TaskID, err)
+	}
+	return nil
+}
+
+func (s *SQLite) UpdateStatus(ctx context.Context, u *StatusUpdate) error {
+	updatedAt := u.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = time.Now()
+	}
+
+	query := `UPDATE tasks SET status = ?, error = ?, error_class = ?, updated_at = ?, finished_at = ?
+		WHERE task_id = ?`
+	if !isFinal(u.Status) && u.Status != "pending" {
+		query += ` AND status NOT IN ('completed', 'failed', 'cancelled')`
+	}
+	_, err := s.db.ExecContext(ctx, query,
+		u.Status, u.Error, u.ErrorClass, unixNano(updatedAt), unixNano(u.FinishedAt), u.TaskID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update indexed task %s: %w", u.TaskID, err)
+	}
+	return nil
+}
+
+func (s *SQLite) List(ctx context.Context, f *Filter) (*Page, error) {
+	sort := f.Sort
+	if sort == "" {
+		sort = SortCreatedAt
+	}
+	column, ok := sortColumns[sort]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field: %s", f.Sort)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	var where []string
+	var args []interface{}
+	if len(f.Statuses) > 0 {
+		where = append(where, "status IN ("+placeholders(len(f.Statuses))+")")
+		for _, v := range f.Statuses {
+			args = append(args, v)
+		}
+	}
+	if len(f.Kinds) > 0 {
+		where = append(where, "kind IN ("+placeholders(len(f.Kinds))+")")
+		for _, v := range f.Kinds {
+			args = append(args, v)
+		}
+	}
+	if f.Tenant != "" {
+		where = append(where, "tenant = ?")
+		args = append(args, f.Tenant)
+	}
+	if f.BatchID != "" {
+		where = append(where, "batch_id = ?")
+		args = append(args, f.BatchID)
+	}
+	if f.Filename != "" {
+		where = append(where, `filename LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(f.Filename)+"%")
+	}
+	if !f.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.From.UnixNano())
+	}
+	if !f.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.To.UnixNano())
+	}
+
+	order, cmp := "DESC", "<"
+	if f.Asc {
+		order, cmp = "ASC", ">"
+	}
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor, sort, f.Asc)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, fmt.Sprintf("(%s, task_id) %s (?, ?)", column, cmp))
+		args = append(args, c.Value, c.ID)
+	}
+
+	query := "SELECT " + entryColumns + " FROM tasks"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// 多取一条判断是否还有下一页
+	query += fmt.Sprintf(" ORDER BY %s %s, task_id %s LIMIT %d", column, order, order, limit+1)
+
+	entries, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		last := page.Entries[limit-1]
+		value := last.CreatedAt
+		if sort == SortUpdatedAt {
+			value = last.UpdatedAt
+		}
+		page.NextCursor = encodeCursor(cursor{Sort: sort, Asc: f.Asc, Value: unixNano(value), ID: last.TaskID})
+	}
+	return page, nil
+}
+
+func (s *SQLite) Unfinished(ctx context.Context, limit int) ([]*Entry, error) {
+	return s.query(ctx, "SELECT "+entryColumns+` FROM tasks
+		WHERE status NOT IN ('completed', 'failed', 'cancelled')
+		ORDER BY updated_at ASC LIMIT ?`, limit)
+}
+
+func (s *SQLite) Touch(ctx context.Context, taskID, status string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE tasks SET updated_at = ? WHERE task_id = ? AND status = ?`,
+		unixNano(at), taskID, status)
+	if err != nil {
+		return fmt.Errorf("failed to touch indexed task %s: %w", taskID, err)
+	}
+	return nil
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+const entryColumns = `task_id, batch_id, tenant, filename, file_type, kind, size,
+	status, error, error_class, created_at, updated_at, finished_at`
+
+func (s *SQLite) query(ctx context.Context, query string, args ...interface{}) ([]*Entry, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task index: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*Entry{}
+	for rows.Next() {
+		var e Entry
+		var createdAt, updatedAt, finishedAt int64
+		if err := rows.Scan(
+			&e.TaskID, &e.BatchID, &e.Tenant, &e.Filename, &e.FileType, &e.Kind, &e.Size,
+			&e.Status, &e.Error, &e.ErrorClass, &createdAt, &updatedAt, &finishedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to read task index: %w", err)
+		}
+		e.CreatedAt = fromUnixNano(createdAt)
+		e.UpdatedAt = fromUnixNano(updatedAt)
+		e.FinishedAt = fromUnixNano(finishedAt)
+		entries = append(entries, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read task index: %w", err)
+	}
+	return entries, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// escapeLike 转义 LIKE 中的通配符，文件名按字面匹配
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// 时间保存为 UnixNano，零值保存为 0
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
@@ -0,0 +1,99 @@
+package taskindex
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestIndex(t *testing.T) *SQLite {
+	t.Helper()
+	index, err := NewSQLite(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite() error = %v", err)
+	}
+	t.Cleanup(func() { index.Close() })
+	return index
+}
+
+func put(t *testing.T, index *SQLite, id, status string, at time.Time) {
+	t.Helper()
+	err := index.Put(context.Background(), &Entry{TaskID: id, Filename: id + ".pdf", Status: status, CreatedAt: at, UpdatedAt: at})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+}
+
+func ids(entries []*Entry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.TaskID
+	}
+	return out
+}
+
+func TestUnfinishedTouch(t *testing.T) {
+	index := newTestIndex(t)
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+	put(t, index, "a", "pending", base)
+	put(t, index, "b", "running", base.Add(time.Second))
+	put(t, index, "c", "completed", base.Add(2*time.Second))
+
+	entries, err := index.Unfinished(ctx, 1)
+	if err != nil || len(entries) != 1 || entries[0].TaskID != "a" {
+		t.Fatalf("Unfinished() = %v, %v; want [a]", ids(entries), err)
+	}
+
+	// 核对过的任务排到后面，下一轮取到其他任务
+	if err := index.Touch(ctx, "a", "pending", time.Now()); err != nil {
+		t.Fatalf("Touch() error = %v", err)
+	}
+	entries, _ = index.Unfinished(ctx, 1)
+	if len(entries) != 1 || entries[0].TaskID != "b" {
+		t.Fatalf("Unfinished() after Touch = %v, want [b]", ids(entries))
+	}
+
+	// 状态已经变化时不更新
+	index.UpdateStatus(ctx, &StatusUpdate{TaskID: "b", Status: "completed", UpdatedAt: base})
+	if err := index.Touch(ctx, "b", "running", time.Now()); err != nil {
+		t.Fatalf("Touch() error = %v", err)
+	}
+	page, _ := index.List(ctx, &Filter{Sort: SortUpdatedAt, Asc: true})
+	if got := ids(page.Entries); fmt.Sprint(got) != "[b c a]" {
+		t.Errorf("List() by updatedAt = %v, want [b c a]", got)
+	}
+}
+
+func TestListCursor(t *testing.T) {
+	index := newTestIndex(t)
+	ctx := context.Background()
+	base := time.Now()
+	for i := 0; i < 5; i++ {
+		put(t, index, fmt.Sprintf("task-%d", i), "pending", base.Add(time.Duration(i)*time.Second))
+	}
+
+	var got []string
+	filter := &Filter{Limit: 2}
+	for {
+		page, err := index.List(ctx, filter)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		got = append(got, ids(page.Entries)...)
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	if fmt.Sprint(got) != "[task-4 task-3 task-2 task-1 task-0]" {
+		t.Errorf("paged tasks = %v", got)
+	}
+
+	// 游标只对相同的排序方式有效
+	if _, err := index.List(ctx, &Filter{Sort: SortUpdatedAt, Cursor: filter.Cursor}); err != ErrInvalidCursor {
+		t.Errorf("List() with a createdAt cursor sorted by updatedAt error = %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+// Package taskindex 持久化的任务索引，用于按状态、类型、时间等条件列出任务。
+// 任务状态仍以队列为准，索引只保存列表和搜索需要的字段，不随 Redis 中的状态过期
+package taskindex
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor 游标无法解析，或与当前的排序方式不一致
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Entry 索引中的任务
+type Entry struct {
+	TaskID     string    `json:"taskId"`
+	BatchID    string    `json:"batchId,omitempty"`
+	Tenant     string    `json:"tenant,omitempty"`
+	Filename   string    `json:"filename"`
+	FileType   string    `json:"fileType"` // 扩展名，如 .pdf
+	Kind       string    `json:"type"`     // 任务类型简称：image、pdf、word、document
+	Size       int64     `json:"size"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	ErrorClass string    `json:"errorClass,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+}
+
+// StatusUpdate 任务状态变化
+type StatusUpdate struct {
+	TaskID     string
+	Status     string
+	Error      string
+	ErrorClass string
+	UpdatedAt  time.Time
+	FinishedAt time.Time
+}
+
+// SortField 排序字段
+type SortField string
+
+// 按 updatedAt 翻页时，翻页期间状态发生变化的任务会移动位置，可能被跳过或重复返回；
+// 需要完整遍历时按 createdAt 排序
+const (
+	SortCreatedAt SortField = "createdAt"
+	SortUpdatedAt SortField = "updatedAt"
+)
+
+// Filter 列表条件，为空的条件不过滤
+type Filter struct {
+	Statuses []string
+	Kinds    []string
+	Tenant   string
+	BatchID  string
+	Filename string // 文件名包含的文本，不区分大小写
+	From     time.Time
+	To       time.Time // 按创建时间过滤，[From, To)
+
+	Sort   SortField // 默认 createdAt
+	Asc    bool      // 默认按时间倒序
+	Limit  int
+	Cursor string // 上一页返回的 NextCursor
+}
+
+// Page 一页任务，NextCursor 为空表示没有更多
+type Page struct {
+	Entries    []*Entry `json:"tasks"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+// Index 任务索引
+type Index interface {
+	// Put 写入或覆盖任务
+	Put(ctx context.Context, entry *Entry) error
+	// UpdateStatus 更新已有任务的状态，任务不在索引中时忽略。
+	// 处理中的进度不会覆盖已结束的状态，重新入队的任务可以从失败回到排队中
+	UpdateStatus(ctx context.Context, update *StatusUpdate) error
+	List(ctx context.Context, filter *Filter) (*Page, error)
+	// Unfinished 返回最早更新的未结束任务，用于与队列中的实际状态核对
+	Unfinished(ctx context.Context, limit int) ([]*Entry, error)
+	// Touch 在状态仍为 status 时更新任务的更新时间，核对过但没有变化的任务排到后面，
+	// 下一轮核对其他任务
+	Touch(ctx context.Context, taskID, status string, at time.Time) error
+	Close() error
+}
+
+// cursor 上一页最后一条记录的排序值，游标只对相同的排序方式有效
+type cursor struct {
+	Sort  SortField `json:"s"`
+	Asc   bool      `json:"a,omitempty"`
+	Value int64     `json:"v"`
+	ID    string    `json:"i"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, sort SortField, asc bool) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sort || c.Asc != asc {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func isFinal(status string) bool {
+	switch status {
+	case "completed", "failed", "cancelled":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,323 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/feichai0017/document-processor/pkg/logger"
+)
+
+// 投递状态
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Delivery 一个事件向一个回调地址的投递记录，重放时追加尝试记录
+type Delivery struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Event     Event     `json:"event"`
+	Status    string    `json:"status"`
+	Attempts  []Attempt `json:"attempts"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Attempt 单次 HTTP 请求
+type Attempt struct {
+	At         time.Time     `json:"at"`
+	StatusCode int           `json:"statusCode,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// Config 投递配置
+type Config struct {
+	Secret      string        // HMAC-SHA256 签名密钥
+	MaxAttempts int           // 每次投递的最大尝试次数，重试由任务队列调度
+	Timeout     time.Duration // 单次请求超时
+	// AllowPrivateNetworks 允许回调到回环、内网和链路本地地址，仅用于本地开发和测试
+	AllowPrivateNetworks bool
+	Client               *http.Client // 为空时使用 Timeout 创建，并在连接时拒绝内网地址
+}
+
+// ErrBlockedAddress 回调地址指向回环、内网、链路本地（如云厂商元数据服务）等地址
+var ErrBlockedAddress = errors.New("callback address is not allowed")
+
+// Dispatcher 签名并投递回调事件，每次尝试都写入投递记录。
+// 投递记录在发送前保存，由任务队列逐次调用 Attempt，进程重启后重试不会丢失
+type Dispatcher struct {
+	store  Store
+	logger logger.Logger
+	config *Config
+	client *http.Client
+}
+
+func NewDispatcher(store Store, log logger.Logger, cfg *Config) (*Dispatcher, error) {
+	if cfg == nil || cfg.Secret == "" {
+		return nil, fmt.Errorf("webhook secret is required")
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	client := cfg.Client
+	if client == nil {
+		client = newClient(cfg.Timeout, cfg.AllowPrivateNetworks)
+	}
+
+	return &Dispatcher{
+		store:  store,
+		logger: log,
+		config: cfg,
+		client: client,
+	}, nil
+}
+
+// newClient 创建投递使用的 HTTP 客户端，连接时检查解析后的地址，防止 DNS 指向内网
+func newClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// 代理会替我们连接目标地址，绕过上面的检查
+	transport.Proxy = nil
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// 重定向可能指向内网地址，回调不跟随重定向
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// blockedIP 回环、私有网络（RFC 1918、ULA）、链路本地、CGNAT、未指定和组播地址
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// sharedAddressSpace 运营商级 NAT 地址段（RFC 6598）
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// ValidateURL 回调地址必须是绝对的 http 或 https 地址，且不能是 localhost 或内网 IP。
+// 域名解析后的地址在连接时检查
+func ValidateURL(raw string) error {
+	return validateURL(raw, false)
+}
+
+func validateURL(raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid callback url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid callback url %q: must be an absolute http or https url", raw)
+	}
+	if allowPrivate {
+		return nil
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && blockedIP(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+// ValidateURL 按投递配置检查回调地址
+func (d *Dispatcher) ValidateURL(raw string) error {
+	return validateURL(raw, d.config.AllowPrivateNetworks)
+}
+
+// MaxAttempts 每次投递的最大尝试次数
+func (d *Dispatcher) MaxAttempts() int {
+	return d.config.MaxAttempts
+}
+
+// Register 为任务注册回调地址
+func (d *Dispatcher) Register(ctx context.Context, taskID, callbackURL string) error {
+	if err := d.ValidateURL(callbackURL); err != nil {
+		return err
+	}
+	return d.store.AddCallback(ctx, taskID, callbackURL)
+}
+
+// RegisterBatch 为批量任务注册回调地址，批量任务全部结束时发送一次 batch.finished 事件
+func (d *Dispatcher) RegisterBatch(ctx context.Context, batchID, callbackURL string) error {
+	return d.Register(ctx, BatchSubject(batchID), callbackURL)
+}
+
+// HasCallbacks 任务或批量任务是否注册了回调地址
+func (d *Dispatcher) HasCallbacks(ctx context.Context, subject string) (bool, error) {
+	urls, err := d.store.Callbacks(ctx, subject)
+	if err != nil {
+		return false, err
+	}
+	return len(urls) > 0, nil
+}
+
+// Prepare 为事件所属任务或批量任务注册的每个回调地址保存一条待投递的记录，没有回调地址时返回空
+func (d *Dispatcher) Prepare(ctx context.Context, event Event) ([]*Delivery, error) {
+	urls, err := d.store.Callbacks(ctx, event.Subject())
+	if err != nil {
+		return nil, err
+	}
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	deliveries := make([]*Delivery, 0, len(urls))
+	for _, u := range urls {
+		now := time.Now()
+		delivery := &Delivery{
+			ID:        uuid.New().String(),
+			URL:       u,
+			Event:     event,
+			Status:    DeliveryPending,
+			Attempts:  []Attempt{},
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := d.store.SaveDelivery(ctx, delivery); err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// Reopen 将已记录的投递重新置为待投递，用于重放；事件 ID 不变，之前的尝试记录保留
+func (d *Dispatcher) Reopen(ctx context.Context, deliveryID string) (*Delivery, error) {
+	delivery, err := d.store.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	previous := *delivery
+	delivery.Status = DeliveryPending
+	delivery.UpdatedAt = time.Now()
+	if err := d.store.SaveDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+// Attempt 发送一次待投递的记录并保存结果，签名使用新的时间戳。
+// 返回 retry 为 true 时调用方应稍后再次调用；final 表示这是最后一次尝试，失败后记录为 failed
+func (d *Dispatcher) Attempt(ctx context.Context, deliveryID string, final bool) (delivery *Delivery, retry bool, err error) {
+	delivery, err = d.store.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, false, err
+	}
+	if delivery.Status != DeliveryPending {
+		// 已经投递成功或失败，重复的队列任务不再发送
+		return delivery, false, nil
+	}
+
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return delivery, false, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	result, retryable := d.post(ctx, delivery, body)
+	delivery.Attempts = append(delivery.Attempts, result)
+	delivery.UpdatedAt = time.Now()
+	switch {
+	case result.Error == "":
+		delivery.Status = DeliveryDelivered
+	case !retryable || final:
+		delivery.Status = DeliveryFailed
+	}
+	if err := d.store.SaveDelivery(ctx, delivery); err != nil {
+		return delivery, false, err
+	}
+
+	if delivery.Status == DeliveryFailed {
+		d.logger.Warn("Webhook delivery failed",
+			logger.String("deliveryId", delivery.ID),
+			logger.String("taskId", delivery.Event.Subject()),
+			logger.String("url", delivery.URL),
+			logger.Int("attempts", len(delivery.Attempts)),
+			logger.String("error", result.Error),
+		)
+	}
+	return delivery, delivery.Status == DeliveryPending, nil
+}
+
+// Delivery 返回单条投递记录
+func (d *Dispatcher) Delivery(ctx context.Context, deliveryID string) (*Delivery, error) {
+	return d.store.GetDelivery(ctx, deliveryID)
+}
+
+// Deliveries 返回任务的投递记录
+func (d *Dispatcher) Deliveries(ctx context.Context, taskID string) ([]*Delivery, error) {
+	return d.store.ListDeliveries(ctx, taskID)
+}
+
+// post 发送一次请求，2xx 视为成功；除 408 和 429 外的 4xx 以及被拒绝的地址不再重试
+func (d *Dispatcher) post(ctx context.Context, delivery *Delivery, body []byte) (Attempt, bool) {
+	start := time.Now()
+	result := Attempt{At: start}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, delivery.Event.ID)
+	req.Header.Set(SignatureHeader, Sign(d.config.Secret, start, body))
+
+	resp, err := d.client.Do(req)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result, !errors.Is(err, ErrBlockedAddress)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return result, false
+	}
+	result.Error = fmt.Sprintf("unexpected status code %d", resp.StatusCode)
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return result, retry
+}
@@ -0,0 +1,292 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/feichai0017/document-processor/pkg/logger"
+)
+
+const testSecret = "test-secret"
+
+// receiver 按顺序返回给定的状态码，记录收到的请求，最后一个状态码重复使用
+type receiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int
+	events   []Event
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	if err := Verify(testSecret, req.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+		r.t.Errorf("Verify() error = %v", err)
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		r.t.Errorf("invalid event body: %v", err)
+	}
+	if req.Header.Get(EventIDHeader) != event.ID {
+		r.t.Errorf("%s = %q, want %q", EventIDHeader, req.Header.Get(EventIDHeader), event.ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	status := r.statuses[0]
+	if len(r.statuses) > 1 {
+		r.statuses = r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) received() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+func newTestDispatcher(t *testing.T, allowPrivate bool) (*Dispatcher, *Memory) {
+	t.Helper()
+	store := NewMemory()
+	d, err := NewDispatcher(store, logger.NewTestLogger(), &Config{
+		Secret:               testSecret,
+		MaxAttempts:          3,
+		Timeout:              time.Second,
+		AllowPrivateNetworks: allowPrivate,
+	})
+	if err != nil {
+		t.Fatalf("NewDispatcher() error = %v", err)
+	}
+	return d, store
+}
+
+// prepare 为 httptest 接收方注册回调并创建一条待投递记录
+func prepare(t *testing.T, d *Dispatcher, store *Memory, statuses ...int) (*Delivery, *receiver) {
+	t.Helper()
+	recv := &receiver{t: t, statuses: statuses}
+	server := httptest.NewServer(recv)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	if err := store.AddCallback(ctx, "task-1", server.URL+"/hook"); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := d.Prepare(ctx, Event{Type: EventTaskCompleted, TaskID: "task-1", Status: "completed"})
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Prepare() = %v, %v", deliveries, err)
+	}
+	if deliveries[0].Status != DeliveryPending || deliveries[0].Event.ID == "" {
+		t.Fatalf("prepared delivery = %+v", deliveries[0])
+	}
+	return deliveries[0], recv
+}
+
+func TestAttemptDeliversSignedEvent(t *testing.T) {
+	d, store := newTestDispatcher(t, true)
+	delivery, recv := prepare(t, d, store, http.StatusOK)
+
+	got, retry, err := d.Attempt(context.Background(), delivery.ID, false)
+	if err != nil || retry {
+		t.Fatalf("Attempt() retry = %v, error = %v", retry, err)
+	}
+	if got.Status != DeliveryDelivered || len(got.Attempts) != 1 || got.Attempts[0].StatusCode != http.StatusOK {
+		t.Fatalf("delivery = %+v", got)
+	}
+	if recv.received() != 1 || recv.events[0].ID != delivery.Event.ID {
+		t.Fatalf("receiver got %+v", recv.events)
+	}
+
+	// 已投递的记录不会被重复的队列任务再次发送
+	if _, retry, err := d.Attempt(context.Background(), delivery.ID, false); retry || err != nil || recv.received() != 1 {
+		t.Fatalf("second Attempt() sent again: retry = %v, error = %v, received = %d", retry, err, recv.received())
+	}
+}
+
+func TestAttemptRetriesServerErrors(t *testing.T) {
+	d, store := newTestDispatcher(t, true)
+	delivery, recv := prepare(t, d, store, http.StatusServiceUnavailable, http.StatusOK)
+
+	got, retry, err := d.Attempt(context.Background(), delivery.ID, false)
+	if err != nil || !retry || got.Status != DeliveryPending {
+		t.Fatalf("Attempt() = %s, retry = %v, error = %v; want pending retry", got.Status, retry, err)
+	}
+
+	got, retry, err = d.Attempt(context.Background(), delivery.ID, false)
+	if err != nil || retry || got.Status != DeliveryDelivered {
+		t.Fatalf("Attempt() = %s, retry = %v, error = %v; want delivered", got.Status, retry, err)
+	}
+	if len(got.Attempts) != 2 || got.Attempts[0].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("attempts = %+v", got.Attempts)
+	}
+	// 重试时事件 ID 不变
+	if recv.events[0].ID != recv.events[1].ID {
+		t.Errorf("event id changed between attempts")
+	}
+}
+
+func TestAttemptFinalServerErrorFails(t *testing.T) {
+	d, store := newTestDispatcher(t, true)
+	delivery, _ := prepare(t, d, store, http.StatusBadGateway)
+
+	got, retry, err := d.Attempt(context.Background(), delivery.ID, true)
+	if err != nil || retry || got.Status != DeliveryFailed {
+		t.Fatalf("Attempt() = %s, retry = %v, error = %v; want failed", got.Status, retry, err)
+	}
+}
+
+func TestAttemptDoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusFound} {
+		d, store := newTestDispatcher(t, true)
+		delivery, recv := prepare(t, d, store, status)
+
+		got, retry, err := d.Attempt(context.Background(), delivery.ID, false)
+		if err != nil || retry || got.Status != DeliveryFailed {
+			t.Errorf("status %d: Attempt() = %s, retry = %v, error = %v; want failed without retry", status, got.Status, retry, err)
+		}
+		if recv.received() != 1 {
+			t.Errorf("status %d: receiver got %d requests", status, recv.received())
+		}
+	}
+
+	// 408 和 429 可以重试
+	for _, status := range []int{http.StatusRequestTimeout, http.StatusTooManyRequests} {
+		d, store := newTestDispatcher(t, true)
+		delivery, _ := prepare(t, d, store, status)
+		if _, retry, _ := d.Attempt(context.Background(), delivery.ID, false); !retry {
+			t.Errorf("status %d was not retried", status)
+		}
+	}
+}
+
+func TestReopen(t *testing.T) {
+	d, store := newTestDispatcher(t, true)
+	delivery, recv := prepare(t, d, store, http.StatusBadRequest, http.StatusOK)
+	ctx := context.Background()
+
+	d.Attempt(ctx, delivery.ID, false)
+	previous, err := d.Reopen(ctx, delivery.ID)
+	if err != nil || previous.Status != DeliveryFailed {
+		t.Fatalf("Reopen() = %+v, %v; want the failed delivery", previous, err)
+	}
+
+	got, _, err := d.Attempt(ctx, delivery.ID, false)
+	if err != nil || got.Status != DeliveryDelivered || len(got.Attempts) != 2 {
+		t.Fatalf("replayed delivery = %+v, %v", got, err)
+	}
+	if recv.events[0].ID != recv.events[1].ID {
+		t.Errorf("replay changed the event id")
+	}
+
+	if _, err := d.Reopen(ctx, "missing"); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("Reopen(missing) error = %v", err)
+	}
+}
+
+func TestBatchCallbacksAreSeparateFromTasks(t *testing.T) {
+	d, _ := newTestDispatcher(t, false)
+	ctx := context.Background()
+
+	if err := d.Register(ctx, "batch-1", "https://example.com/task"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RegisterBatch(ctx, "batch-1", "https://example.com/batch"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RegisterBatch(ctx, "batch-1", "http://127.0.0.1/hook"); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("RegisterBatch() private address error = %v", err)
+	}
+
+	// 批量任务事件只发送到批量任务的回调地址，即使任务 ID 与批量任务 ID 相同
+	deliveries, err := d.Prepare(ctx, Event{Type: EventBatchFinished, BatchID: "batch-1", Status: "partial"})
+	if err != nil || len(deliveries) != 1 || deliveries[0].URL != "https://example.com/batch" {
+		t.Fatalf("Prepare() batch event = %+v, %v", deliveries, err)
+	}
+	deliveries, err = d.Prepare(ctx, Event{Type: EventTaskCompleted, TaskID: "batch-1", BatchID: "batch-1", Status: "completed"})
+	if err != nil || len(deliveries) != 1 || deliveries[0].URL != "https://example.com/task" {
+		t.Fatalf("Prepare() task event = %+v, %v", deliveries, err)
+	}
+
+	batchDeliveries, _ := d.Deliveries(ctx, BatchSubject("batch-1"))
+	taskDeliveries, _ := d.Deliveries(ctx, "batch-1")
+	if len(batchDeliveries) != 1 || batchDeliveries[0].Event.Type != EventBatchFinished || len(taskDeliveries) != 1 {
+		t.Errorf("deliveries: batch = %+v, task = %+v", batchDeliveries, taskDeliveries)
+	}
+
+	if ok, err := d.HasCallbacks(ctx, BatchSubject("batch-2")); ok || err != nil {
+		t.Errorf("HasCallbacks() for an unregistered batch = %v, %v", ok, err)
+	}
+}
+
+func TestPrivateAddressesAreBlocked(t *testing.T) {
+	d, store := newTestDispatcher(t, false)
+	recv := &receiver{t: t, statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	if err := d.Register(context.Background(), "task-1", server.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Register(%s) error = %v, want blocked", server.URL, err)
+	}
+
+	// 绕过注册校验的地址（例如域名解析到内网）在连接时拒绝
+	store.AddCallback(context.Background(), "task-1", server.URL)
+	deliveries, _ := d.Prepare(context.Background(), Event{Type: EventTaskFailed, TaskID: "task-1"})
+	got, retry, err := d.Attempt(context.Background(), deliveries[0].ID, false)
+	if err != nil || retry || got.Status != DeliveryFailed {
+		t.Fatalf("Attempt() = %+v, retry = %v, error = %v; want failed without retry", got, retry, err)
+	}
+	if recv.received() != 0 {
+		t.Fatal("request reached a private address")
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		blocked bool
+		invalid bool
+	}{
+		{url: "https://example.com/hook"},
+		{url: "http://203.0.113.10:8080/hook"},
+		{url: "ftp://example.com", invalid: true},
+		{url: "/relative", invalid: true},
+		{url: "http://localhost:8080/hook", blocked: true},
+		{url: "http://api.localhost/hook", blocked: true},
+		{url: "http://127.0.0.1/hook", blocked: true},
+		{url: "http://10.0.0.5/hook", blocked: true},
+		{url: "http://172.16.3.4/hook", blocked: true},
+		{url: "http://192.168.1.1/hook", blocked: true},
+		{url: "http://169.254.169.254/latest/meta-data", blocked: true},
+		{url: "http://100.64.0.1/hook", blocked: true},
+		{url: "http://0.0.0.0/hook", blocked: true},
+		{url: "http://[::1]/hook", blocked: true},
+		{url: "http://[fd00::1]/hook", blocked: true},
+		{url: "http://[::ffff:10.0.0.1]/hook", blocked: true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateURL(tt.url)
+		switch {
+		case tt.blocked:
+			if !errors.Is(err, ErrBlockedAddress) {
+				t.Errorf("ValidateURL(%s) error = %v, want blocked", tt.url, err)
+			}
+		case tt.invalid:
+			if err == nil || errors.Is(err, ErrBlockedAddress) {
+				t.Errorf("ValidateURL(%s) error = %v, want invalid", tt.url, err)
+			}
+		default:
+			if err != nil {
+				t.Errorf("ValidateURL(%s) error = %v", tt.url, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrDeliveryNotFound 投递记录不存在或已过期
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+// Store 保存任务的回调地址和投递记录，批量任务使用 BatchSubject 作为 taskID
+type Store interface {
+	AddCallback(ctx context.Context, taskID, url string) error
+	Callbacks(ctx context.Context, taskID string) ([]string, error)
+	SaveDelivery(ctx context.Context, delivery *Delivery) error
+	GetDelivery(ctx context.Context, id string) (*Delivery, error)
+	ListDeliveries(ctx context.Context, taskID string) ([]*Delivery, error)
+}
+
+// Memory 进程内存储，用于测试和单进程部署
+type Memory struct {
+	mu         sync.Mutex
+	callbacks  map[string][]string
+	deliveries map[string]*Delivery
+	byTask     map[string][]string
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		callbacks:  make(map[string][]string),
+		deliveries: make(map[string]*Delivery),
+		byTask:     make(map[string][]string),
+	}
+}
+
+func (m *Memory) AddCallback(ctx context.Context, taskID, url string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.callbacks[taskID] {
+		if existing == url {
+			return nil
+		}
+	}
+	m.callbacks[taskID] = append(m.callbacks[taskID], url)
+	return nil
+}
+
+func (m *Memory) Callbacks(ctx context.Context, taskID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.callbacks[taskID]...), nil
+}
+
+func (m *Memory) SaveDelivery(ctx context.Context, delivery *Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.deliveries[delivery.ID]; !ok {
+		subject := delivery.Event.Subject()
+		m.byTask[subject] = append(m.byTask[subject], delivery.ID)
+	}
+	copied := *delivery
+	copied.Attempts = append([]Attempt(nil), delivery.Attempts...)
+	m.deliveries[delivery.ID] = &copied
+	return nil
+}
+
+func (m *Memory) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery, ok := m.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	copied := *delivery
+	copied.Attempts = append([]Attempt(nil), delivery.Attempts...)
+	return &copied, nil
+}
+
+func (m *Memory) ListDeliveries(ctx context.Context, taskID string) ([]*Delivery, error) {
+	m.mu.Lock()
+	ids := append([]string(nil), m.byTask[taskID]...)
+	m.mu.Unlock()
+
+	deliveries := make([]*Delivery, 0, len(ids))
+	for _, id := range ids {
+		delivery, err := m.GetDelivery(ctx, id)
+		if err != nil {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// Redis 使用 Redis 保存回调地址和投递记录，过期由 Redis 负责
+type Redis struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewRedis(client *redis.Client, ttl time.Duration) *Redis {
+	return &Redis{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func (r *Redis) AddCallback(ctx context.Context, taskID, url string) error {
+	key := fmt.Sprintf("webhook:callbacks:%s", taskID)
+	pipe := r.client.TxPipeline()
+	pipe.SAdd(ctx, key, url)
+	pipe.Expire(ctx, key, r.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save callback: %w", err)
+	}
+	return nil
+}
+
+func (r *Redis) Callbacks(ctx context.Context, taskID string) ([]string, error) {
+	urls, err := r.client.SMembers(ctx, fmt.Sprintf("webhook:callbacks:%s", taskID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read callbacks: %w", err)
+	}
+	return urls, nil
+}
+
+func (r *Redis) SaveDelivery(ctx context.Context, delivery *Delivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to marshal delivery: %w", err)
+	}
+
+	listKey := fmt.Sprintf("webhook:task_deliveries:%s", delivery.Event.Subject())
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf("webhook:delivery:%s", delivery.ID), data, r.ttl)
+	// 按创建时间排序，重复保存同一条记录不会产生重复项
+	pipe.ZAdd(ctx, listKey, redis.Z{Score: float64(delivery.CreatedAt.UnixNano()), Member: delivery.ID})
+	pipe.Expire(ctx, listKey, r.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save delivery: %w", err)
+	}
+	return nil
+}
+
+func (r *Redis) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	data, err := r.client.Get(ctx, fmt.Sprintf("webhook:delivery:%s", id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delivery: %w", err)
+	}
+
+	var delivery Delivery
+	if err := json.Unmarshal(data, &delivery); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal delivery: %w", err)
+	}
+	return &delivery, nil
+}
+
+func (r *Redis) ListDeliveries(ctx context.Context, taskID string) ([]*Delivery, error) {
+	ids, err := r.client.ZRange(ctx, fmt.Sprintf("webhook:task_deliveries:%s", taskID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+
+	deliveries := make([]*Delivery, 0, len(ids))
+	for _, id := range ids {
+		delivery, err := r.GetDelivery(ctx, id)
+		if errors.Is(err, ErrDeliveryNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
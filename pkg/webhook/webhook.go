@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 事件类型，任务进入最终状态时发送
+const (
+	EventTaskCompleted = "task.completed"
+	EventTaskFailed    = "task.failed"
+	EventTaskCancelled = "task.cancelled"
+	// EventBatchFinished 批量任务中最后一个文件结束时发送，Status 为批量任务的整体状态
+	EventBatchFinished = "batch.finished"
+)
+
+const (
+	// SignatureHeader 签名请求头，格式为 t=<unix 秒>,v1=<hex(HMAC-SHA256(secret, "<t>.<body>"))>
+	SignatureHeader = "X-Webhook-Signature"
+	// EventIDHeader 事件 ID，重试和重放时不变，接收方可据此去重
+	EventIDHeader = "X-Webhook-Id"
+)
+
+// Event 回调事件，任务事件带 TaskID，批量任务事件带 BatchID
+type Event struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	TaskID     string            `json:"taskId,omitempty"`
+	BatchID    string            `json:"batchId,omitempty"`
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	ErrorClass string            `json:"errorClass,omitempty"`
+	ResultURL  string            `json:"resultUrl,omitempty"` // 仅 completed 事件和有成功文件的批量任务事件
+	Counts     map[string]int    `json:"counts,omitempty"`    // 批量任务中各状态的文件数
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+// Subject 事件所属的任务或批量任务，回调地址和投递记录按此保存
+func (e Event) Subject() string {
+	if e.TaskID == "" && e.BatchID != "" {
+		return BatchSubject(e.BatchID)
+	}
+	return e.TaskID
+}
+
+// BatchSubject 批量任务的回调地址和投递记录使用的键，与任务 ID 区分
+func BatchSubject(batchID string) string {
+	return "batch:" + batchID
+}
+
+// Sign 计算签名请求头的值，时间戳参与签名，防止请求被重放
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, signature(secret, t, body))
+}
+
+// Verify 校验签名请求头，tolerance 为允许的时间偏差，为 0 时不检查时间戳
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var t, v1 string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			v1 = value
+		}
+	}
+	if t == "" || v1 == "" {
+		return errors.New("malformed signature header")
+	}
+
+	if tolerance > 0 {
+		unix, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid signature timestamp: %w", err)
+		}
+		if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+			return errors.New("signature timestamp outside tolerance")
+		}
+	}
+
+	if !hmac.Equal([]byte(v1), []byte(signature(secret, t, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
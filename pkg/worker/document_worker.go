@@ -3,12 +3,15 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	docagent "github.com/feichai0017/document-processor/internal/agent/document"
+	"github.com/feichai0017/document-processor/internal/models"
 	"github.com/feichai0017/document-processor/internal/service/document"
 	"github.com/feichai0017/document-processor/pkg/logger"
 	"github.com/feichai0017/document-processor/pkg/queue"
+	"github.com/feichai0017/document-processor/pkg/webhook"
 	"github.com/hibiken/asynq"
-	"time"
 )
 
 type DocumentWorker struct {
@@ -16,40 +19,61 @@ type DocumentWorker struct {
 	docService document.DocumentProcessor
 }
 
-func NewDocumentWorker(cfg *Config, docService document.DocumentProcessor, logger logger.Logger) (*DocumentWorker, error) {
-	server := asynq.NewServer(
-		asynq.RedisClientOpt{Addr: cfg.RedisAddr, DB: cfg.RedisDB},
-		asynq.Config{
-			Concurrency: cfg.Concurrency,
-			Queues:      cfg.Queues,
-			RetryDelayFunc: func(n int, err error, task *asynq.Task) time.Duration {
-				return time.Duration(n) * time.Minute
-			},
-		},
-	)
+// NewDocumentWorker 为每种任务类型创建独立的 asynq 服务器，只从该类型的队列取任务，
+// 耗时的图像 OCR 不会占满 PDF 文本提取的并发
+func NewDocumentWorker(cfg *Config, docService document.DocumentProcessor, log logger.Logger) (*DocumentWorker, error) {
+	if len(cfg.Pools) == 0 {
+		return nil, fmt.Errorf("at least one worker pool is required")
+	}
 
 	w := &DocumentWorker{
 		BaseWorker: BaseWorker{
-			server:   server,
 			mux:      asynq.NewServeMux(),
-			logger:   logger,
+			logger:   log,
 			stopChan: make(chan struct{}),
 		},
 		docService: docService,
 	}
 
-	// 注册任务处理器
-	w.registerHandlers()
-	return w, nil
-}
+	retryDelay := retryDelayFunc(cfg.RetryBaseDelay, cfg.RetryMaxDelay)
+	webhookRetryDelay := retryDelayFunc(cfg.WebhookBackoff, cfg.WebhookMaxBackoff)
+	registered := make(map[string]bool)
+	for _, pool := range cfg.Pools {
+		if registered[pool.TaskType] {
+			return nil, fmt.Errorf("duplicate worker pool for task type %s", pool.TaskType)
+		}
+		registered[pool.TaskType] = true
+
+		// 注册任务处理器，文档任务共用同一处理流程，由服务按文件类型选择处理器
+		handler, delay := w.handleDocumentProcess, retryDelay
+		if pool.TaskType == queue.TaskTypeWebhookDeliver {
+			handler, delay = w.handleWebhookDelivery, webhookRetryDelay
+		}
 
-func (w *DocumentWorker) registerHandlers() {
-	w.mux.HandleFunc(queue.TaskTypeDocumentProcess, w.handleDocumentProcess)
+		server := asynq.NewServer(
+			asynq.RedisClientOpt{Addr: cfg.RedisAddr, DB: cfg.RedisDB},
+			asynq.Config{
+				Concurrency:    pool.Concurrency,
+				Queues:         queue.QueuesFor(pool.TaskType),
+				RetryDelayFunc: delay,
+			},
+		)
+		w.servers = append(w.servers, server)
+		w.mux.HandleFunc(pool.TaskType, handler)
+
+		log.Info("Worker pool configured",
+			logger.String("taskType", pool.TaskType),
+			logger.Int("concurrency", pool.Concurrency),
+		)
+	}
+
+	return w, nil
 }
 
 func (w *DocumentWorker) handleDocumentProcess(ctx context.Context, t *asynq.Task) error {
 	// 添加原始任务日志
 	w.logger.Info("Received task",
+		logger.String("type", t.Type()),
 		logger.String("payload", string(t.Payload())),
 	)
 
@@ -60,7 +84,8 @@ func (w *DocumentWorker) handleDocumentProcess(ctx context.Context, t *asynq.Tas
 			logger.Error(err),
 			logger.String("payload", string(t.Payload())),
 		)
-		return fmt.Errorf("failed to unmarshal task: %w", err)
+		// 无法解析的任务重试也不会成功
+		return fmt.Errorf("failed to unmarshal task: %v: %w", err, asynq.SkipRetry)
 	}
 
 	// 添加详细日志
@@ -77,40 +102,81 @@ func (w *DocumentWorker) handleDocumentProcess(ctx context.Context, t *asynq.Tas
 			logger.Any("metadata", task.Metadata),
 			logger.Any("payload", task.Payload),
 		)
-		return fmt.Errorf("invalid task data: missing required fields")
+		err := docagent.Errorf(docagent.ErrorClassInvalidRequest, "invalid task data: missing required fields")
+		if task.ID != "" {
+			w.docService.RecordFailure(context.Background(), task.ID, err, true)
+		}
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
 	}
 
-	// 获取任务写入器
+	// 任务的状态、尝试次数和错误历史由服务写入任务仓库；
+	// 结果写入器只记录取消和失败，死信队列据此识别取消的任务和错误类别
 	info := t.ResultWriter()
 
-	// 写入任务开始状态
-	if _, err := info.Write([]byte(`{"status":"running","progress":0}`)); err != nil {
-		w.logger.Error("Failed to write task status", logger.Error(err))
-	}
-
 	err := w.docService.HandleDocument(ctx, &task)
+	if errors.Is(err, document.ErrTaskCancelled) {
+		// 用户取消的任务不再重试，状态已由服务记录
+		if _, writeErr := info.Write([]byte(`{"status":"cancelled"}`)); writeErr != nil {
+			w.logger.Error("Failed to write task cancellation", logger.Error(writeErr))
+		}
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
 	if err != nil {
-		// 写入失败状态
-		if _, writeErr := info.Write([]byte(fmt.Sprintf(`{"status":"failed","error":%q}`, err.Error()))); writeErr != nil {
+		// 写入失败状态和错误类别，任务状态中返回
+		class := docagent.ClassifyError(err)
+		if _, writeErr := info.Write([]byte(fmt.Sprintf(`{"status":"failed","error":%q,"errorClass":%q}`, err.Error(), class))); writeErr != nil {
 			w.logger.Error("Failed to write task failure", logger.Error(writeErr))
 		}
+		// 文件损坏、类型不支持等永久错误不再重试，直接进入死信队列
+		if class.Permanent() {
+			w.logger.Warn("Permanent task failure, skipping retries",
+				logger.String("taskId", task.ID),
+				logger.String("errorClass", string(class)),
+				logger.Error(err),
+			)
+			w.docService.RecordFailure(context.Background(), task.ID, err, true)
+			return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+		}
+		// 最后一次重试也失败后任务进入死信队列，否则推送等待重试的状态
+		retried, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		w.docService.RecordFailure(context.Background(), task.ID, err, retried >= maxRetry)
 		return err
 	}
 
-	// 写入完成状态
-	if _, err := info.Write([]byte(`{"status":"completed","progress":100}`)); err != nil {
-		w.logger.Error("Failed to write task completion", logger.Error(err))
-	}
+	w.docService.NotifyTaskFinished(context.Background(), task.ID, models.StatusCompleted, nil)
 
 	return nil
 }
 
+// handleWebhookDelivery 发送一次回调，接收方暂时不可用时返回错误由 asynq 退避重试；
+// 最后一次尝试的失败记录在投递记录中，可以通过重放接口重新发送
+func (w *DocumentWorker) handleWebhookDelivery(ctx context.Context, t *asynq.Task) error {
+	deliveryID, err := queue.WebhookDeliveryID(t)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	err = w.docService.DeliverWebhook(ctx, deliveryID, retried >= maxRetry)
+	if errors.Is(err, document.ErrWebhooksDisabled) || errors.Is(err, webhook.ErrDeliveryNotFound) {
+		w.logger.Warn("Dropping webhook delivery",
+			logger.String("deliveryId", deliveryID),
+			logger.Error(err),
+		)
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	return err
+}
+
 func (w *DocumentWorker) Start(ctx context.Context) error {
-	go func() {
-		if err := w.server.Run(w.mux); err != nil {
-			w.logger.Error("Worker server stopped", logger.Error(err))
+	for _, server := range w.servers {
+		if err := server.Start(w.mux); err != nil {
+			w.Stop()
+			return fmt.Errorf("failed to start worker server: %w", err)
 		}
-	}()
+	}
 
 	go func() {
 		<-ctx.Done()
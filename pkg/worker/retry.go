@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	docagent "github.com/feichai0017/document-processor/internal/agent/document"
+)
+
+const (
+	defaultRetryBaseDelay = 30 * time.Second
+	defaultRetryMaxDelay  = 10 * time.Minute
+)
+
+// retryDelayFunc 按错误类别计算重试间隔：指数退避加随机抖动，
+// 限流错误从两倍的基础间隔开始，给配额恢复留出时间
+func retryDelayFunc(base, max time.Duration) asynq.RetryDelayFunc {
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+	if max <= 0 {
+		max = defaultRetryMaxDelay
+	}
+
+	return func(n int, err error, task *asynq.Task) time.Duration {
+		delay := float64(base) * math.Pow(2, float64(n))
+		if docagent.ClassifyError(err) == docagent.ErrorClassThrottled {
+			delay *= 2
+		}
+		if delay > float64(max) {
+			delay = float64(max)
+		}
+		// 抖动 ±20%，避免同时失败的任务同时重试
+		jitter := 0.8 + 0.4*rand.Float64()
+		return time.Duration(delay * jitter)
+	}
+}
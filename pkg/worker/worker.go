@@ -2,6 +2,9 @@ package worker
 
 import (
     "context"
+    "sync"
+    "time"
+
     "github.com/hibiken/asynq"
     "github.com/feichai0017/document-processor/pkg/logger"
 )
@@ -12,21 +15,43 @@ type Worker interface {
 }
 
 type Config struct {
-    RedisAddr   string
-    RedisDB     int
+    RedisAddr string
+    RedisDB   int
+
+    // Pools 每种任务类型一个独立的 asynq 服务器，并发互不影响，
+    // 只配置部分类型即可作为专用 worker 运行
+    Pools []PoolConfig
+
+    // 临时错误的重试间隔，从 RetryBaseDelay 开始指数增长，不超过 RetryMaxDelay
+    RetryBaseDelay time.Duration
+    RetryMaxDelay  time.Duration
+
+    // 回调投递失败的重试间隔，从 WebhookBackoff 开始指数增长，不超过 WebhookMaxBackoff
+    WebhookBackoff    time.Duration
+    WebhookMaxBackoff time.Duration
+}
+
+// PoolConfig 单个任务类型的 worker 配置
+type PoolConfig struct {
+    TaskType    string // 如 queue.TaskTypeImageProcess 或 queue.TaskTypeWebhookDeliver
     Concurrency int
-    Queues      map[string]int
 }
 
 type BaseWorker struct {
-    server   *asynq.Server
+    servers  []*asynq.Server
     mux      *asynq.ServeMux
     logger   logger.Logger
     stopChan chan struct{}
+    stopOnce sync.Once
 }
 
 func (w *BaseWorker) Stop() error {
-    close(w.stopChan)
-    w.server.Stop()
+    w.stopOnce.Do(func() {
+        close(w.stopChan)
+        for _, server := range w.servers {
+            server.Stop()
+            server.Shutdown()
+        }
+    })
     return nil
-}
\ No newline at end of file
+}